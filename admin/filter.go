@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"fmt"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+)
+
+// endpointFilter selects the endpoints returned when listing endpoints.
+// Empty fields match all endpoints.
+type endpointFilter struct {
+	accountID string
+	planType  string
+	authType  string
+}
+
+// validate returns an error if the filter's auth type is not a known auth type.
+func (f endpointFilter) validate() error {
+	switch f.authType {
+	case "", AuthTypeStaticAPIKey, AuthTypeNoAuth:
+		return nil
+	default:
+		return fmt.Errorf("invalid auth_type %q, must be one of: %s, %s", f.authType, AuthTypeStaticAPIKey, AuthTypeNoAuth)
+	}
+}
+
+// matches returns true if the GatewayEndpoint matches all of the filter's fields.
+func (f endpointFilter) matches(gatewayEndpoint *proto.GatewayEndpoint) bool {
+	if f.accountID != "" && gatewayEndpoint.GetMetadata().GetAccountId() != f.accountID {
+		return false
+	}
+	if f.planType != "" && gatewayEndpoint.GetMetadata().GetPlanType() != f.planType {
+		return false
+	}
+	if f.authType != "" && authType(gatewayEndpoint) != f.authType {
+		return false
+	}
+	return true
+}
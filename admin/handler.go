@@ -0,0 +1,229 @@
+/*
+Package admin provides the PADS admin HTTP API, used to inspect the GatewayEndpoints served by PADS
+and, if enabled, to manage them at runtime.
+
+The admin API is served on a separate port from the gRPC server, so that it can be kept private
+to operators, and may optionally require a bearer token. All secret values are redacted.
+
+Routes:
+  - GET /v1/endpoints                  - lists served endpoints, filtered by `account_id`, `plan_type` and `auth_type`
+  - GET /v1/endpoints/{endpoint_id}    - returns a single served endpoint
+  - GET /v1/stats                      - returns the number of served endpoints and the current revision
+
+Write routes, only served if the data source supports writes and writes are enabled:
+  - POST /v1/endpoints                 - creates or replaces all endpoints in the body, in the YAML file format
+  - POST /v1/endpoints/{endpoint_id}   - creates an endpoint
+  - PUT /v1/endpoints/{endpoint_id}    - replaces an existing endpoint
+  - DELETE /v1/endpoints/{endpoint_id} - deletes an existing endpoint
+
+Writes are applied to the data source, and are served once the data source streams the resulting updates.
+
+If PADS serves multiple tenants, each request is served the tenant selected by its `pads-tenant` header,
+or the default tenant if it selects none (see NewTenantHandler).
+*/
+package admin
+
+import (
+	"encoding/json"
+	"iter"
+	"net/http"
+	"slices"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/redact"
+)
+
+// Auth type values used to filter and count endpoints by their auth type.
+const (
+	AuthTypeStaticAPIKey = "static_api_key"
+	AuthTypeNoAuth       = "no_auth"
+)
+
+// EndpointStore provides the GatewayEndpoints currently served by PADS.
+// It is implemented by the gRPC server.
+type EndpointStore interface {
+	// GatewayEndpoints returns a copy of the served GatewayEndpoints and the current revision.
+	GatewayEndpoints() (map[string]*proto.GatewayEndpoint, uint64)
+	// Get returns the served GatewayEndpoint with the endpoint ID, if any.
+	Get(endpointID string) (*proto.GatewayEndpoint, bool)
+	// Len returns the number of served GatewayEndpoints.
+	Len() int
+	// Revision returns the current revision, which is incremented for every applied update.
+	Revision() uint64
+	// All returns an iterator over the served GatewayEndpoints, keyed by endpoint ID.
+	All() iter.Seq2[string, *proto.GatewayEndpoint]
+}
+
+// handler serves the admin API.
+type handler struct {
+	store  EndpointStore
+	writer grpc_server.AuthDataWriter
+	logger polylog.Logger
+}
+
+// NewHandler returns the HTTP handler which serves the admin API for the EndpointStore.
+// If writer is nil, the admin API is read-only and the write routes are not served.
+func NewHandler(store EndpointStore, writer grpc_server.AuthDataWriter, logger polylog.Logger) http.Handler {
+	h := &handler{
+		store:  store,
+		writer: writer,
+		logger: logger.With("component", "admin_api"),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/endpoints", h.listEndpoints)
+	mux.HandleFunc("GET /v1/endpoints/{endpoint_id}", h.getEndpoint)
+	mux.HandleFunc("GET /v1/stats", h.getStats)
+
+	if writer != nil {
+		mux.HandleFunc("POST /v1/endpoints", h.bulkUpsertEndpoints)
+		mux.HandleFunc("POST /v1/endpoints/{endpoint_id}", h.createEndpoint)
+		mux.HandleFunc("PUT /v1/endpoints/{endpoint_id}", h.updateEndpoint)
+		mux.HandleFunc("DELETE /v1/endpoints/{endpoint_id}", h.deleteEndpoint)
+	}
+
+	return mux
+}
+
+/* ---------------------------------- Responses ---------------------------------- */
+
+// listEndpointsResponse is the response body of GET /v1/endpoints.
+type listEndpointsResponse struct {
+	Revision  uint64            `json:"revision"`
+	Count     int               `json:"count"`
+	Endpoints []json.RawMessage `json:"endpoints"`
+}
+
+// getEndpointResponse is the response body of GET /v1/endpoints/{endpoint_id}.
+type getEndpointResponse struct {
+	Revision uint64          `json:"revision"`
+	Endpoint json.RawMessage `json:"endpoint"`
+}
+
+// statsResponse is the response body of GET /v1/stats.
+type statsResponse struct {
+	Revision       uint64         `json:"revision"`
+	TotalEndpoints int            `json:"total_endpoints"`
+	ByAuthType     map[string]int `json:"by_auth_type"`
+	ByPlanType     map[string]int `json:"by_plan_type"`
+}
+
+// errorResponse is the response body of all failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+/* ---------------------------------- Handlers ---------------------------------- */
+
+// listEndpoints returns all served endpoints matching the query filters, sorted by endpoint ID.
+func (h *handler) listEndpoints(w http.ResponseWriter, r *http.Request) {
+	filter := endpointFilter{
+		accountID: r.URL.Query().Get("account_id"),
+		planType:  r.URL.Query().Get("plan_type"),
+		authType:  r.URL.Query().Get("auth_type"),
+	}
+	if err := filter.validate(); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	gatewayEndpoints, revision := h.store.GatewayEndpoints()
+
+	endpointIDs := make([]string, 0, len(gatewayEndpoints))
+	for endpointID, gatewayEndpoint := range gatewayEndpoints {
+		if filter.matches(gatewayEndpoint) {
+			endpointIDs = append(endpointIDs, endpointID)
+		}
+	}
+	slices.Sort(endpointIDs)
+
+	response := listEndpointsResponse{
+		Revision:  revision,
+		Count:     len(endpointIDs),
+		Endpoints: make([]json.RawMessage, 0, len(endpointIDs)),
+	}
+	for _, endpointID := range endpointIDs {
+		endpointJSON, err := marshalEndpoint(gatewayEndpoints[endpointID])
+		if err != nil {
+			h.writeInternalError(w, err)
+			return
+		}
+		response.Endpoints = append(response.Endpoints, endpointJSON)
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// getEndpoint returns a single served endpoint by its ID.
+func (h *handler) getEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpointID := r.PathValue("endpoint_id")
+
+	// The revision is read after the endpoint, so it is never older than the returned endpoint.
+	gatewayEndpoint, ok := h.store.Get(endpointID)
+	revision := h.store.Revision()
+	if !ok {
+		h.writeJSON(w, http.StatusNotFound, errorResponse{Error: "endpoint not found: " + endpointID})
+		return
+	}
+
+	endpointJSON, err := marshalEndpoint(gatewayEndpoint)
+	if err != nil {
+		h.writeInternalError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, getEndpointResponse{Revision: revision, Endpoint: endpointJSON})
+}
+
+// getStats returns the number of served endpoints, by auth type and plan type, and the current revision.
+func (h *handler) getStats(w http.ResponseWriter, r *http.Request) {
+	response := statsResponse{
+		Revision:       h.store.Revision(),
+		TotalEndpoints: h.store.Len(),
+		ByAuthType:     make(map[string]int),
+		ByPlanType:     make(map[string]int),
+	}
+	for _, gatewayEndpoint := range h.store.All() {
+		response.ByAuthType[authType(gatewayEndpoint)]++
+		response.ByPlanType[gatewayEndpoint.GetMetadata().GetPlanType()]++
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+/* ---------------------------------- Helpers ---------------------------------- */
+
+// marshalEndpoint returns the JSON encoding of the redacted GatewayEndpoint.
+func marshalEndpoint(gatewayEndpoint *proto.GatewayEndpoint) (json.RawMessage, error) {
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(redact.GatewayEndpoint(gatewayEndpoint))
+}
+
+// authType returns the auth type of the GatewayEndpoint, as used in filters and stats.
+func authType(gatewayEndpoint *proto.GatewayEndpoint) string {
+	if gatewayEndpoint.GetAuth().GetStaticApiKey() != nil {
+		return AuthTypeStaticAPIKey
+	}
+	return AuthTypeNoAuth
+}
+
+func (h *handler) writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	writeJSON(w, statusCode, body, h.logger)
+}
+
+// writeJSON writes the JSON encoding of the body as the response, logging any failure to write it.
+func writeJSON(w http.ResponseWriter, statusCode int, body any, logger polylog.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Error().Err(err).Msg("failed to write admin API response")
+	}
+}
+
+func (h *handler) writeInternalError(w http.ResponseWriter, err error) {
+	h.logger.Error().Err(err).Msg("failed to encode gateway endpoint")
+	h.writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to encode gateway endpoint"})
+}
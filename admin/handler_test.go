@@ -0,0 +1,229 @@
+package admin
+
+import (
+	"encoding/json"
+	"iter"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+)
+
+var testGatewayEndpoints = map[string]*proto.GatewayEndpoint{
+	"endpoint_1_static_key": {
+		EndpointId: "endpoint_1_static_key",
+		Auth: &proto.Auth{
+			AuthType: &proto.Auth_StaticApiKey{
+				StaticApiKey: &proto.StaticAPIKey{
+					ApiKey: "api_key_1",
+				},
+			},
+		},
+		Metadata: &proto.Metadata{
+			AccountId: "account_1",
+			PlanType:  "PLAN_UNLIMITED",
+			Email:     "amos.burton@opa.belt",
+		},
+	},
+	"endpoint_2_no_auth": {
+		EndpointId: "endpoint_2_no_auth",
+		Auth: &proto.Auth{
+			AuthType: &proto.Auth_NoAuth{},
+		},
+		Metadata: &proto.Metadata{
+			AccountId: "account_2",
+			PlanType:  "PLAN_FREE",
+		},
+	},
+	"endpoint_3_static_key": {
+		EndpointId: "endpoint_3_static_key",
+		Auth: &proto.Auth{
+			AuthType: &proto.Auth_StaticApiKey{
+				StaticApiKey: &proto.StaticAPIKey{
+					ApiKey: "api_key_3",
+				},
+			},
+		},
+		Metadata: &proto.Metadata{
+			AccountId: "account_1",
+			PlanType:  "PLAN_FREE",
+		},
+	},
+}
+
+type testEndpointStore struct {
+	gatewayEndpoints map[string]*proto.GatewayEndpoint
+	revision         uint64
+}
+
+func (s *testEndpointStore) GatewayEndpoints() (map[string]*proto.GatewayEndpoint, uint64) {
+	return s.gatewayEndpoints, s.revision
+}
+
+func (s *testEndpointStore) Get(endpointID string) (*proto.GatewayEndpoint, bool) {
+	gatewayEndpoint, ok := s.gatewayEndpoints[endpointID]
+	return gatewayEndpoint, ok
+}
+
+func (s *testEndpointStore) Len() int {
+	return len(s.gatewayEndpoints)
+}
+
+func (s *testEndpointStore) Revision() uint64 {
+	return s.revision
+}
+
+func (s *testEndpointStore) All() iter.Seq2[string, *proto.GatewayEndpoint] {
+	return maps.All(s.gatewayEndpoints)
+}
+
+func Test_listEndpoints(t *testing.T) {
+	tests := []struct {
+		name               string
+		query              string
+		expectedStatusCode int
+		expectedIDs        []string
+	}{
+		{
+			name:               "should list all endpoints sorted by ID",
+			query:              "",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"endpoint_1_static_key", "endpoint_2_no_auth", "endpoint_3_static_key"},
+		},
+		{
+			name:               "should filter endpoints by account_id",
+			query:              "?account_id=account_1",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"endpoint_1_static_key", "endpoint_3_static_key"},
+		},
+		{
+			name:               "should filter endpoints by plan_type",
+			query:              "?plan_type=PLAN_FREE",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"endpoint_2_no_auth", "endpoint_3_static_key"},
+		},
+		{
+			name:               "should filter endpoints by auth_type",
+			query:              "?auth_type=no_auth",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"endpoint_2_no_auth"},
+		},
+		{
+			name:               "should combine filters",
+			query:              "?account_id=account_1&plan_type=PLAN_FREE&auth_type=static_api_key",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"endpoint_3_static_key"},
+		},
+		{
+			name:               "should return no endpoints if none match",
+			query:              "?account_id=account_4",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{},
+		},
+		{
+			name:               "should reject invalid auth_type",
+			query:              "?auth_type=jwt",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			recorder := serve(t, "/v1/endpoints"+test.query)
+			c.Equal(test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Revision  uint64 `json:"revision"`
+				Count     int    `json:"count"`
+				Endpoints []struct {
+					EndpointID string `json:"endpoint_id"`
+				} `json:"endpoints"`
+			}
+			c.NoError(json.Unmarshal(recorder.Body.Bytes(), &response))
+
+			endpointIDs := make([]string, 0, len(response.Endpoints))
+			for _, endpoint := range response.Endpoints {
+				endpointIDs = append(endpointIDs, endpoint.EndpointID)
+			}
+			c.Equal(test.expectedIDs, endpointIDs)
+			c.Equal(len(test.expectedIDs), response.Count)
+			c.Equal(uint64(7), response.Revision)
+
+			// Secret values must never be returned.
+			c.NotContains(recorder.Body.String(), "api_key_1")
+			c.NotContains(recorder.Body.String(), "amos.burton@opa.belt")
+		})
+	}
+}
+
+func Test_getEndpoint(t *testing.T) {
+	tests := []struct {
+		name               string
+		endpointID         string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "should return redacted endpoint",
+			endpointID:         "endpoint_1_static_key",
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `{
+				"revision": 7,
+				"endpoint": {
+					"endpoint_id": "endpoint_1_static_key",
+					"auth": {"static_api_key": {"api_key": "[REDACTED]"}},
+					"metadata": {"account_id": "account_1", "plan_type": "PLAN_UNLIMITED", "email": "a***@opa.belt"}
+				}
+			}`,
+		},
+		{
+			name:               "should return not found for unknown endpoint",
+			endpointID:         "endpoint_4_unknown",
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "endpoint not found: endpoint_4_unknown"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			recorder := serve(t, "/v1/endpoints/"+test.endpointID)
+			c.Equal(test.expectedStatusCode, recorder.Code)
+			c.JSONEq(test.expectedBody, recorder.Body.String())
+		})
+	}
+}
+
+func Test_getStats(t *testing.T) {
+	c := require.New(t)
+
+	recorder := serve(t, "/v1/stats")
+	c.Equal(http.StatusOK, recorder.Code)
+	c.JSONEq(`{
+		"revision": 7,
+		"total_endpoints": 3,
+		"by_auth_type": {"static_api_key": 2, "no_auth": 1},
+		"by_plan_type": {"PLAN_UNLIMITED": 1, "PLAN_FREE": 2}
+	}`, recorder.Body.String())
+}
+
+func serve(t *testing.T, target string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	store := &testEndpointStore{gatewayEndpoints: testGatewayEndpoints, revision: 7}
+	handler := NewHandler(store, nil, polyzero.NewLogger())
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
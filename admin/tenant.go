@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/pokt-network/poktroll/pkg/polylog"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+)
+
+// tenantHandler serves the admin API of multiple named tenants, each with its own handler,
+// ie. its own served GatewayEndpoints and data source writer.
+type tenantHandler struct {
+	handlers map[string]http.Handler
+	logger   polylog.Logger
+}
+
+// NewTenantHandler returns the HTTP handler which serves the admin API of each tenant, by tenant name.
+//
+// Each request is served by the handler of the tenant selected by its `pads-tenant` header, as gRPC clients
+// select their tenant with the `pads-tenant` metadata key, or of the default tenant if it selects none.
+// Requests for a tenant which is not served are rejected with 404 Not Found.
+func NewTenantHandler(handlers map[string]http.Handler, logger polylog.Logger) http.Handler {
+	return &tenantHandler{
+		handlers: handlers,
+		logger:   logger.With("component", "admin_api"),
+	}
+}
+
+func (t *tenantHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant := r.Header.Get(grpc_server.TenantKey)
+	if tenant == "" {
+		tenant = grpc_server.DefaultTenant
+	}
+
+	handler, ok := t.handlers[tenant]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "tenant not served: " + tenant}, t.logger)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
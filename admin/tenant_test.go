@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+)
+
+func Test_TenantHandler(t *testing.T) {
+	logger := polyzero.NewLogger()
+	handler := NewTenantHandler(map[string]http.Handler{
+		grpc_server.DefaultTenant: NewHandler(&testEndpointStore{gatewayEndpoints: testGatewayEndpoints}, nil, logger),
+		"gateway-a": NewHandler(&testEndpointStore{gatewayEndpoints: map[string]*proto.GatewayEndpoint{
+			"endpoint_a": {EndpointId: "endpoint_a", Auth: &proto.Auth{AuthType: &proto.Auth_NoAuth{}}},
+		}}, nil, logger),
+	}, logger)
+
+	tests := []struct {
+		name               string
+		tenant             string
+		endpointID         string
+		expectedStatusCode int
+	}{
+		{
+			name:               "should serve the default tenant if no tenant is selected",
+			endpointID:         "endpoint_1_static_key",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "should serve the selected tenant",
+			tenant:             "gateway-a",
+			endpointID:         "endpoint_a",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "should not serve the endpoints of another tenant",
+			tenant:             "gateway-a",
+			endpointID:         "endpoint_1_static_key",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "should reject a tenant which is not served",
+			tenant:             "gateway-b",
+			endpointID:         "endpoint_a",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/endpoints/"+test.endpointID, nil)
+			if test.tenant != "" {
+				req.Header.Set(grpc_server.TenantKey, test.tenant)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			c.Equal(test.expectedStatusCode, rec.Code)
+		})
+	}
+}
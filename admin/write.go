@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/yaml"
+)
+
+const (
+	// maxEndpointBodyBytes is the maximum size of the request body for a single endpoint.
+	maxEndpointBodyBytes = 1 << 20 // 1 MiB
+	// maxBulkBodyBytes is the maximum size of the request body for a bulk upsert.
+	maxBulkBodyBytes = 64 << 20 // 64 MiB
+)
+
+/* ---------------------------------- Responses ---------------------------------- */
+
+// writeEndpointResponse is the response body of POST and PUT /v1/endpoints/{endpoint_id}.
+type writeEndpointResponse struct {
+	Endpoint json.RawMessage `json:"endpoint"`
+}
+
+// bulkUpsertResponse is the response body of POST /v1/endpoints.
+type bulkUpsertResponse struct {
+	Count int `json:"count"`
+}
+
+/* ---------------------------------- Handlers ---------------------------------- */
+
+// createEndpoint creates a new endpoint, failing if it already exists.
+func (h *handler) createEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.writeEndpoint(w, r, grpc_server.WriteOpCreate, http.StatusCreated)
+}
+
+// updateEndpoint replaces an existing endpoint, failing if it does not exist.
+func (h *handler) updateEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.writeEndpoint(w, r, grpc_server.WriteOpUpdate, http.StatusOK)
+}
+
+// writeEndpoint decodes the endpoint in the request body and writes it to the data source.
+//
+// The request body is a single endpoint in the YAML file format, ie. the value of one of the entries
+// under `endpoints`, and is validated exactly as it is when loaded from a YAML file.
+func (h *handler) writeEndpoint(w http.ResponseWriter, r *http.Request, op grpc_server.WriteOp, statusCode int) {
+	endpointID := r.PathValue("endpoint_id")
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEndpointBodyBytes))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("failed to read request body: %v", err)})
+		return
+	}
+
+	gatewayEndpoint, window, err := yaml.DecodeGatewayEndpoint(endpointID, body)
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writes := []grpc_server.EndpointWrite{
+		{Op: op, EndpointID: endpointID, GatewayEndpoint: gatewayEndpoint, Window: window},
+	}
+	if !h.applyWrites(w, r, writes) {
+		return
+	}
+
+	endpointJSON, err := marshalEndpoint(gatewayEndpoint)
+	if err != nil {
+		h.writeInternalError(w, err)
+		return
+	}
+
+	h.writeJSON(w, statusCode, writeEndpointResponse{Endpoint: endpointJSON})
+}
+
+// deleteEndpoint deletes an existing endpoint, failing if it does not exist.
+func (h *handler) deleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	writes := []grpc_server.EndpointWrite{
+		{Op: grpc_server.WriteOpDelete, EndpointID: r.PathValue("endpoint_id")},
+	}
+	if !h.applyWrites(w, r, writes) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bulkUpsertEndpoints creates or replaces all endpoints in the request body in a single write.
+//
+// The request body is a set of endpoints in the YAML file format, ie. a mapping with an `endpoints` field,
+// and is validated exactly as it is when loaded from a YAML file. Endpoints not in the body are not changed.
+func (h *handler) bulkUpsertEndpoints(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBulkBodyBytes))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("failed to read request body: %v", err)})
+		return
+	}
+
+	authData, windows, err := yaml.DecodeGatewayEndpoints(body)
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writes := grpc_server.UpsertWrites(authData.Endpoints, windows)
+	if !h.applyWrites(w, r, writes) {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, bulkUpsertResponse{Count: len(writes)})
+}
+
+/* ---------------------------------- Helpers ---------------------------------- */
+
+// applyWrites writes to the data source, writing the error response and returning false if the write failed.
+func (h *handler) applyWrites(w http.ResponseWriter, r *http.Request, writes []grpc_server.EndpointWrite) bool {
+	err := h.writer.WriteGatewayEndpoints(r.Context(), writes)
+	if err == nil {
+		h.logger.Info().Int("writes", len(writes)).Msg("wrote gateway endpoints to data source")
+		return true
+	}
+
+	switch {
+	case errors.Is(err, grpc_server.ErrEndpointExists):
+		h.writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	case errors.Is(err, grpc_server.ErrEndpointNotFound):
+		h.writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case errors.Is(err, grpc_server.ErrUnsupportedWrite):
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case errors.Is(err, grpc_server.ErrDataSourceUnavailable):
+		h.writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+	default:
+		h.logger.Error().Err(err).Msg("failed to write gateway endpoints to data source")
+		h.writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to write gateway endpoints to data source"})
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+)
+
+type testAuthDataWriter struct {
+	writes []grpc_server.EndpointWrite
+	err    error
+}
+
+func (w *testAuthDataWriter) WriteGatewayEndpoints(_ context.Context, writes []grpc_server.EndpointWrite) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.writes = append(w.writes, writes...)
+	return nil
+}
+
+func Test_writeRoutes(t *testing.T) {
+	tests := []struct {
+		name               string
+		method             string
+		target             string
+		body               string
+		writerErr          error
+		expectedStatusCode int
+		expectedBody       string
+		expectedWrites     []grpc_server.EndpointWrite
+	}{
+		{
+			name:               "should create endpoint and return it redacted",
+			method:             http.MethodPost,
+			target:             "/v1/endpoints/endpoint_4_static_key",
+			body:               `{"auth": {"api_key": "api_key_4"}, "metadata": {"account_id": "account_4"}, "expires_at": "2025-01-15T00:00:00Z"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedBody: `{"endpoint": {
+				"endpoint_id": "endpoint_4_static_key",
+				"auth": {"static_api_key": {"api_key": "[REDACTED]"}},
+				"metadata": {"account_id": "account_4"}
+			}}`,
+			expectedWrites: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpCreate, EndpointID: "endpoint_4_static_key"},
+			},
+		},
+		{
+			name:               "should reject endpoint that fails validation",
+			method:             http.MethodPost,
+			target:             "/v1/endpoints/endpoint_4_static_key",
+			body:               `{"auth": {"api_key": ""}}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "should return conflict when creating an existing endpoint",
+			method:             http.MethodPost,
+			target:             "/v1/endpoints/endpoint_1_static_key",
+			body:               `{}`,
+			writerErr:          fmt.Errorf("%w: endpoint_1_static_key", grpc_server.ErrEndpointExists),
+			expectedStatusCode: http.StatusConflict,
+			expectedBody:       `{"error": "gateway endpoint already exists: endpoint_1_static_key"}`,
+		},
+		{
+			name:               "should return not found when updating a missing endpoint",
+			method:             http.MethodPut,
+			target:             "/v1/endpoints/endpoint_4_no_auth",
+			body:               `{}`,
+			writerErr:          fmt.Errorf("%w: endpoint_4_no_auth", grpc_server.ErrEndpointNotFound),
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "gateway endpoint not found: endpoint_4_no_auth"}`,
+		},
+		{
+			name:               "should return bad request when the data source cannot store the endpoint",
+			method:             http.MethodPut,
+			target:             "/v1/endpoints/endpoint_1_static_key",
+			body:               `{"metadata": {"email": "amos.burton@opa.belt"}}`,
+			writerErr:          fmt.Errorf("%w: metadata field email", grpc_server.ErrUnsupportedWrite),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "should return service unavailable when the data source is unavailable",
+			method:             http.MethodPut,
+			target:             "/v1/endpoints/endpoint_1_static_key",
+			body:               `{}`,
+			writerErr:          grpc_server.ErrDataSourceUnavailable,
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       `{"error": "data source is unavailable"}`,
+		},
+		{
+			name:               "should not expose data source errors",
+			method:             http.MethodPut,
+			target:             "/v1/endpoints/endpoint_1_static_key",
+			body:               `{}`,
+			writerErr:          fmt.Errorf("connection refused"),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "failed to write gateway endpoints to data source"}`,
+		},
+		{
+			name:               "should delete endpoint",
+			method:             http.MethodDelete,
+			target:             "/v1/endpoints/endpoint_2_no_auth",
+			expectedStatusCode: http.StatusNoContent,
+			expectedWrites: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpDelete, EndpointID: "endpoint_2_no_auth"},
+			},
+		},
+		{
+			name:   "should bulk upsert endpoints in order of endpoint ID",
+			method: http.MethodPost,
+			target: "/v1/endpoints",
+			body: `{"endpoints": {
+				"endpoint_5_no_auth": {},
+				"endpoint_4_static_key": {"auth": {"api_key": "api_key_4"}}
+			}}`,
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"count": 2}`,
+			expectedWrites: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpUpsert, EndpointID: "endpoint_4_static_key"},
+				{Op: grpc_server.WriteOpUpsert, EndpointID: "endpoint_5_no_auth"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			writer := &testAuthDataWriter{err: test.writerErr}
+			store := &testEndpointStore{gatewayEndpoints: testGatewayEndpoints, revision: 7}
+			handler := NewHandler(store, writer, polyzero.NewLogger())
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+
+			c.Equal(test.expectedStatusCode, recorder.Code)
+			if test.expectedBody != "" {
+				c.JSONEq(test.expectedBody, recorder.Body.String())
+			}
+
+			c.Len(writer.writes, len(test.expectedWrites))
+			for i, expectedWrite := range test.expectedWrites {
+				c.Equal(expectedWrite.Op, writer.writes[i].Op)
+				c.Equal(expectedWrite.EndpointID, writer.writes[i].EndpointID)
+			}
+		})
+	}
+}
+
+func Test_writeRoutes_validityWindow(t *testing.T) {
+	c := require.New(t)
+
+	writer := &testAuthDataWriter{}
+	handler := NewHandler(&testEndpointStore{}, writer, polyzero.NewLogger())
+
+	body := `{"starts_at": "2025-01-01T00:00:00Z", "expires_at": "2025-01-15T00:00:00Z"}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/endpoints/endpoint_4_no_auth", strings.NewReader(body)))
+	c.Equal(http.StatusCreated, recorder.Code)
+
+	c.Len(writer.writes, 1)
+	c.True(writer.writes[0].Window.StartsAt.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	c.True(writer.writes[0].Window.ExpiresAt.Equal(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)))
+}
+
+func Test_writeRoutes_readOnly(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewHandler(&testEndpointStore{}, nil, polyzero.NewLogger())
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/v1/endpoints/endpoint_1_static_key", nil))
+	c.Equal(http.StatusMethodNotAllowed, recorder.Code)
+}
@@ -0,0 +1,174 @@
+/*
+Package apikey provides hashing and verification of the API keys served by PADS.
+
+Storing hashed API keys in the YAML file or the Grove Portal DB ensures that a leaked
+config file or DB dump does not expose customer credentials.
+
+Hashed API keys use a "<scheme>:<encoded hash>" prefix scheme:
+  - argon2id:m=<memory>,t=<iterations>,p=<threads>:<base64 salt>:<base64 Argon2id hash>
+
+Any value that does not start with a known scheme prefix is treated as a plaintext API key.
+
+Only the salted, memory-hard Argon2id scheme is supported, so that a leaked hash cannot
+be cheaply brute-forced back into the API key.
+
+DEV_NOTE: Hashed API keys are not yet accepted by the data sources. They would be served to
+PEAS as-is, and PEAS v0.0.8 compares API keys as plaintext, so every request authorized by a
+hashed API key would be rejected. Check rejects them with ErrHashedKeysUnsupported until PEAS
+verifies hashed API keys using Verify.
+*/
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Scheme is the hashing scheme used for a hashed API key.
+type Scheme string
+
+// SchemeArgon2id hashes API keys with a salted Argon2id hash.
+const SchemeArgon2id Scheme = "argon2id"
+
+const schemeSeparator = ":"
+
+// Argon2id parameters used when hashing new API keys.
+// Hashed keys record their parameters, so these may be changed without invalidating existing keys.
+const (
+	argon2idMemory     uint32 = 64 * 1024
+	argon2idIterations uint32 = 1
+	argon2idThreads    uint8  = 4
+	argon2idSaltLength        = 16
+	argon2idKeyLength  uint32 = 32
+)
+
+// encoding is used to encode all salts and hashes in hashed API keys.
+var encoding = base64.RawStdEncoding
+
+// ErrHashedKeysUnsupported is returned by Check for a hashed API key, as PEAS does not yet verify hashed API keys.
+var ErrHashedKeysUnsupported = errors.New("hashed API keys are not supported, as PEAS v0.0.8 compares API keys as plaintext")
+
+// Hash returns the API key hashed using the Argon2id scheme, prefixed with the scheme name.
+func Hash(apiKey string) (string, error) {
+	if apiKey == "" {
+		return "", fmt.Errorf("cannot hash an empty API key")
+	}
+
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	params := argon2idParams{
+		memory:     argon2idMemory,
+		iterations: argon2idIterations,
+		threads:    argon2idThreads,
+	}
+	hash := params.hash(apiKey, salt, argon2idKeyLength)
+	return strings.Join([]string{
+		string(SchemeArgon2id),
+		params.String(),
+		encoding.EncodeToString(salt),
+		encoding.EncodeToString(hash),
+	}, schemeSeparator), nil
+}
+
+// IsHashed returns true if the value is prefixed with a supported hashing scheme.
+func IsHashed(value string) bool {
+	_, ok := schemeOf(value)
+	return ok
+}
+
+// Validate returns an error if the value is a hashed API key that is not well-formed.
+// Plaintext API keys are always considered valid.
+func Validate(value string) error {
+	if !IsHashed(value) {
+		return nil
+	}
+	_, _, _, err := parseArgon2id(value)
+	return err
+}
+
+// Check returns ErrHashedKeysUnsupported if the value is a hashed API key.
+// It is used by the data sources to reject the API keys which PEAS cannot verify.
+func Check(value string) error {
+	if IsHashed(value) {
+		return ErrHashedKeysUnsupported
+	}
+	return nil
+}
+
+// Verify reports whether the API key matches the stored value, in constant time.
+// The stored value may be either a hashed API key or a plaintext API key.
+//
+// It is the verification a PEAS version which supports hashed API keys must perform.
+func Verify(stored, apiKey string) (bool, error) {
+	if !IsHashed(stored) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(apiKey)) == 1, nil
+	}
+
+	params, salt, hash, err := parseArgon2id(stored)
+	if err != nil {
+		return false, err
+	}
+	computed := params.hash(apiKey, salt, uint32(len(hash)))
+	return subtle.ConstantTimeCompare(hash, computed) == 1, nil
+}
+
+// schemeOf returns the hashing scheme of the value, if it is prefixed with a supported scheme.
+func schemeOf(value string) (Scheme, bool) {
+	if strings.HasPrefix(value, string(SchemeArgon2id)+schemeSeparator) {
+		return SchemeArgon2id, true
+	}
+	return "", false
+}
+
+/* -------------------- Argon2id -------------------- */
+
+// argon2idParams are the cost parameters recorded in an Argon2id hashed API key.
+type argon2idParams struct {
+	memory     uint32
+	iterations uint32
+	threads    uint8
+}
+
+func (p argon2idParams) String() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", p.memory, p.iterations, p.threads)
+}
+
+func (p argon2idParams) hash(apiKey string, salt []byte, keyLength uint32) []byte {
+	return argon2.IDKey([]byte(apiKey), salt, p.iterations, p.memory, p.threads, keyLength)
+}
+
+// parseArgon2id parses an Argon2id hashed API key into its parameters, salt and hash.
+func parseArgon2id(value string) (argon2idParams, []byte, []byte, error) {
+	parts := strings.Split(value, schemeSeparator)
+	if len(parts) != 4 || parts[0] != string(SchemeArgon2id) {
+		return argon2idParams{}, nil, nil, fmt.Errorf("invalid %s API key hash: expected format %s:m=<memory>,t=<iterations>,p=<threads>:<salt>:<hash>", SchemeArgon2id, SchemeArgon2id)
+	}
+
+	var params argon2idParams
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.threads); err != nil {
+		return argon2idParams{}, nil, nil, fmt.Errorf("invalid %s API key hash parameters %q: %w", SchemeArgon2id, parts[1], err)
+	}
+	if params.memory == 0 || params.iterations == 0 || params.threads == 0 {
+		return argon2idParams{}, nil, nil, fmt.Errorf("invalid %s API key hash parameters %q: all parameters must be non-zero", SchemeArgon2id, parts[1])
+	}
+
+	salt, err := encoding.DecodeString(parts[2])
+	if err != nil || len(salt) == 0 {
+		return argon2idParams{}, nil, nil, fmt.Errorf("invalid %s API key hash salt", SchemeArgon2id)
+	}
+
+	hash, err := encoding.DecodeString(parts[3])
+	if err != nil || len(hash) == 0 {
+		return argon2idParams{}, nil, nil, fmt.Errorf("invalid %s API key hash", SchemeArgon2id)
+	}
+
+	return params, salt, hash, nil
+}
@@ -0,0 +1,185 @@
+package apikey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Hash(t *testing.T) {
+	tests := []struct {
+		name    string
+		apiKey  string
+		wantErr bool
+	}{
+		{
+			name:   "should hash API key using argon2id",
+			apiKey: "api_key_1",
+		},
+		{
+			name:    "should return error for empty API key",
+			apiKey:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			got, err := Hash(test.apiKey)
+			if test.wantErr {
+				c.Error(err)
+				return
+			}
+			c.NoError(err)
+			c.NotContains(got, test.apiKey)
+			c.True(IsHashed(got))
+			c.NoError(Validate(got))
+
+			// Each hash is salted, so hashing the same API key twice gives different hashes.
+			again, err := Hash(test.apiKey)
+			c.NoError(err)
+			c.NotEqual(got, again)
+		})
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	argon2idHash, err := Hash("api_key_1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		stored  string
+		apiKey  string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "should verify matching argon2id hashed API key",
+			stored: argon2idHash,
+			apiKey: "api_key_1",
+			want:   true,
+		},
+		{
+			name:   "should not verify mismatched argon2id hashed API key",
+			stored: argon2idHash,
+			apiKey: "api_key_2",
+			want:   false,
+		},
+		{
+			name:   "should verify matching plaintext API key",
+			stored: "api_key_1",
+			apiKey: "api_key_1",
+			want:   true,
+		},
+		{
+			name:   "should not verify mismatched plaintext API key",
+			stored: "api_key_1",
+			apiKey: "api_key_2",
+			want:   false,
+		},
+		{
+			name:    "should return error for malformed argon2id hashed API key",
+			stored:  "argon2id:m=0,t=1,p=4:c2FsdA:aGFzaA",
+			apiKey:  "api_key_1",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			got, err := Verify(test.stored, test.apiKey)
+			if test.wantErr {
+				c.Error(err)
+				return
+			}
+			c.NoError(err)
+			c.Equal(test.want, got)
+		})
+	}
+}
+
+func Test_Check(t *testing.T) {
+	argon2idHash, err := Hash("api_key_1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr error
+	}{
+		{
+			name:  "should accept plaintext API key",
+			value: "api_key_1",
+		},
+		{
+			name:  "should accept plaintext API key with an unknown scheme prefix",
+			value: "sha256:QFhfyU9Ndw/kc0kH03jeD/OvWRxJAmcyWp/wspRKKc0",
+		},
+		{
+			name:    "should reject argon2id hashed API key",
+			value:   argon2idHash,
+			wantErr: ErrHashedKeysUnsupported,
+		},
+		{
+			name:    "should reject malformed argon2id hashed API key",
+			value:   "argon2id:not-a-hash",
+			wantErr: ErrHashedKeysUnsupported,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			err := Check(test.value)
+			if test.wantErr != nil {
+				c.ErrorIs(err, test.wantErr)
+				return
+			}
+			c.NoError(err)
+		})
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{
+			name:  "should accept plaintext API key",
+			value: "api_key_1",
+		},
+		{
+			name:  "should accept well-formed argon2id hashed API key",
+			value: "argon2id:m=65536,t=1,p=4:c2FsdHNhbHRzYWx0c2FsdA:aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g",
+		},
+		{
+			name:    "should reject argon2id hashed API key with missing salt",
+			value:   "argon2id:m=65536,t=1,p=4:aGFzaA",
+			wantErr: true,
+		},
+		{
+			name:    "should reject argon2id hashed API key with zero parameters",
+			value:   "argon2id:m=0,t=1,p=4:c2FsdA:aGFzaA",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.value)
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
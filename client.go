@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// This file handles connecting to a running PATH Auth Data Server from the subcommands
+// which act as a gRPC client, eg. `pads diff` and `pads watch`.
+
+// The URL schemes of a running PATH Auth Data Server.
+const (
+	grpcScheme  = "grpc://"
+	grpcsScheme = "grpcs://"
+)
+
+// serverClientOptions configures how a subcommand connects to a running PATH Auth Data Server.
+type serverClientOptions struct {
+	// tokenFile is the file containing the bearer token sent to the server, if it requires one.
+	tokenFile string
+	// caFile is the CA certificate used to verify a grpcs:// server, instead of the system CA certificates.
+	caFile string
+	// certFile and keyFile are the client certificate and key presented to a grpcs:// server which requires mutual TLS.
+	certFile string
+	keyFile  string
+}
+
+// addServerClientFlags adds the flags used to connect to a running PATH Auth Data Server.
+func addServerClientFlags(flags *flag.FlagSet) *serverClientOptions {
+	var options serverClientOptions
+	flags.StringVar(&options.tokenFile, "token-file", "", "File containing the bearer token to send to a running server.")
+	flags.StringVar(&options.caFile, "ca-file", "", "CA certificate used to verify a grpcs:// server. Defaults to the system CA certificates.")
+	flags.StringVar(&options.certFile, "cert-file", "", "Client certificate presented to a grpcs:// server which requires mutual TLS. Requires -key-file.")
+	flags.StringVar(&options.keyFile, "key-file", "", "Key of the client certificate presented to a grpcs:// server. Requires -cert-file.")
+	return &options
+}
+
+// isServerAddress returns true if the source is the address of a running PATH Auth Data Server.
+func isServerAddress(source string) bool {
+	return strings.HasPrefix(source, grpcScheme) || strings.HasPrefix(source, grpcsScheme)
+}
+
+// dialServer returns a client of the running PATH Auth Data Server at the grpc:// or grpcs:// address,
+// which connects exactly as PEAS does. The returned connection must be closed by the caller.
+func dialServer(address string, options serverClientOptions) (proto.GatewayEndpointsClient, *grpc.ClientConn, error) {
+	if !isServerAddress(address) {
+		return nil, nil, fmt.Errorf("server address %q must start with %s or %s", address, grpcScheme, grpcsScheme)
+	}
+
+	if (options.certFile == "") != (options.keyFile == "") {
+		return nil, nil, fmt.Errorf("-cert-file and -key-file must be set together")
+	}
+	if options.certFile != "" && !strings.HasPrefix(address, grpcsScheme) {
+		return nil, nil, fmt.Errorf("a client certificate requires a %s server address", grpcsScheme)
+	}
+
+	transportCredentials := insecure.NewCredentials()
+	if strings.HasPrefix(address, grpcsScheme) {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if options.caFile != "" {
+			caPEM, err := os.ReadFile(options.caFile)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+				return nil, nil, fmt.Errorf("no certificates found in CA file %s", options.caFile)
+			}
+		}
+		if options.certFile != "" {
+			certificate, err := tls.LoadX509KeyPair(options.certFile, options.keyFile)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{certificate}
+		}
+		transportCredentials = credentials.NewTLS(tlsConfig)
+	}
+
+	dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(transportCredentials)}
+	if options.tokenFile != "" {
+		token, err := os.ReadFile(options.tokenFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(bearerTokenCredentials(strings.TrimSpace(string(token)))))
+	}
+
+	target := strings.TrimPrefix(strings.TrimPrefix(address, grpcScheme), grpcsScheme)
+	conn, err := grpc.NewClient(target, dialOptions...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return proto.NewGatewayEndpointsClient(conn), conn, nil
+}
+
+// bearerTokenCredentials sends the bearer token in the authorization metadata of every request,
+// as expected by the clientauth package.
+type bearerTokenCredentials string
+
+func (t bearerTokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity returns false, as PADS may be served over plaintext h2c, eg. behind a service mesh.
+func (t bearerTokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,417 @@
+/*
+Package clientauth authenticates the gRPC clients (eg. PEAS) of PADS using bearer tokens.
+It is also used to authenticate HTTP clients, eg. of the admin API.
+
+Clients must send one of the accepted tokens in the `authorization` request metadata,
+eg. `authorization: Bearer <token>`. Requests without an accepted token are rejected
+with codes.Unauthenticated.
+
+Multiple tokens may be accepted at once to allow rotating tokens without downtime:
+ 1. Add the new token to the accepted tokens.
+ 2. Update the client to send the new token.
+ 3. Remove the old token from the accepted tokens.
+
+Tokens may be provided directly, and/or in a file which is watched and re-read whenever it changes,
+so that tokens can be rotated without restarting PADS.
+
+Tokens may be bound to a tenant (see NewTenantAuthenticator), so that a client is only authorized for the
+tenants of the token it sent. The tenants are recorded in the request context, and checked with TenantAuthorized.
+*/
+package clientauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/buildwithgrove/path-auth-data-server/metrics"
+)
+
+const (
+	// authorizationHeader is the request metadata key containing the client's token.
+	authorizationHeader = "authorization"
+	// bearerPrefix is the scheme prefix of the token in the authorization metadata.
+	bearerPrefix = "Bearer "
+)
+
+// AllTenants is the tenant of the tokens accepted by NewAuthenticator, which are authorized for every tenant.
+const AllTenants = "*"
+
+// Tokens are a set of accepted tokens, provided directly and/or in a file containing one token per line.
+type Tokens struct {
+	Tokens     []string
+	TokensFile string
+}
+
+// Authenticator checks that gRPC requests contain one of the accepted tokens.
+type Authenticator struct {
+	// tokenSets are the accepted tokens of each tenant.
+	tokenSets []*tokenSet
+
+	// watcher watches the directories of the tokens files, if any, to reload them when they change.
+	watcher *fsnotify.Watcher
+
+	logger polylog.Logger
+}
+
+// tokenSet is a set of accepted tokens, which authorize the clients that send them for its tenant.
+type tokenSet struct {
+	tenant string
+
+	// tokens are the accepted tokens provided directly.
+	tokens [][sha256.Size]byte
+
+	// tokensFile is the optional path to a file containing accepted tokens, one per line.
+	tokensFile        string
+	tokensFileModTime time.Time
+	fileTokens        [][sha256.Size]byte
+	fileTokensMu      sync.Mutex
+}
+
+// NewAuthenticator creates an Authenticator which accepts the provided tokens
+// and the tokens in tokensFile, if set, for every tenant. At least one token must be accepted.
+// The tokens file is watched for changes until Close is called.
+func NewAuthenticator(tokens []string, tokensFile string, logger polylog.Logger) (*Authenticator, error) {
+	return NewTenantAuthenticator(map[string]Tokens{AllTenants: {Tokens: tokens, TokensFile: tokensFile}}, logger)
+}
+
+// NewTenantAuthenticator creates an Authenticator which accepts the tokens of each tenant, by tenant name,
+// and authorizes the clients which send them only for that tenant. At least one token must be accepted
+// for each tenant.
+func NewTenantAuthenticator(tenantTokens map[string]Tokens, logger polylog.Logger) (*Authenticator, error) {
+	a := &Authenticator{
+		logger: logger.With("component", "client_auth"),
+	}
+
+	for _, tenant := range slices.Sorted(maps.Keys(tenantTokens)) {
+		set, err := newTokenSet(tenant, tenantTokens[tenant])
+		if err != nil {
+			if tenant == AllTenants {
+				return nil, err
+			}
+			return nil, fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+		a.tokenSets = append(a.tokenSets, set)
+	}
+
+	if len(a.tokenSets) == 0 {
+		return nil, fmt.Errorf("no client auth tokens provided")
+	}
+
+	if err := a.watchTokensFiles(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Close stops watching the tokens files for changes.
+func (a *Authenticator) Close() error {
+	if a.watcher == nil {
+		return nil
+	}
+	return a.watcher.Close()
+}
+
+// newTokenSet returns the set of tokens accepted for the tenant. At least one token must be accepted.
+func newTokenSet(tenant string, tokens Tokens) (*tokenSet, error) {
+	set := &tokenSet{
+		tenant:     tenant,
+		tokensFile: tokens.TokensFile,
+	}
+
+	for _, token := range tokens.Tokens {
+		if token != "" {
+			set.tokens = append(set.tokens, sha256.Sum256([]byte(token)))
+		}
+	}
+
+	if set.tokensFile != "" {
+		if err := set.reloadTokensFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(set.tokens) == 0 && len(set.fileTokens) == 0 {
+		return nil, fmt.Errorf("no client auth tokens provided")
+	}
+
+	return set, nil
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor which authenticates unary requests, eg. FetchAuthDataSync,
+// and records the tenants the client is authorized for in the request context.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		tenants, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(withAuthorizedTenants(ctx, tenants), req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor which authenticates streaming requests, eg. StreamAuthDataUpdates,
+// and records the tenants the client is authorized for in the stream context.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		tenants, err := a.authenticate(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{ServerStream: stream, ctx: withAuthorizedTenants(stream.Context(), tenants)})
+	}
+}
+
+// authorizedStream is a server stream whose context records the tenants the client is authorized for.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context { return s.ctx }
+
+// authorizedTenantsKey is the context key of the tenants an authenticated client is authorized for.
+type authorizedTenantsKey struct{}
+
+func withAuthorizedTenants(ctx context.Context, tenants []string) context.Context {
+	return context.WithValue(ctx, authorizedTenantsKey{}, tenants)
+}
+
+// TenantAuthorized returns true if the client of the request is authorized for the tenant,
+// ie. if its token is accepted for the tenant or for every tenant.
+// Requests which were not authenticated, eg. if client authentication is disabled, are authorized for every tenant.
+func TenantAuthorized(ctx context.Context, tenant string) bool {
+	tenants, ok := ctx.Value(authorizedTenantsKey{}).([]string)
+	if !ok {
+		return true
+	}
+	return slices.Contains(tenants, tenant) || slices.Contains(tenants, AllTenants)
+}
+
+// HTTPHandler wraps an HTTP handler so that requests must contain an accepted token
+// in the `Authorization` header. Unauthenticated requests are rejected with 401 Unauthorized.
+//
+// The name identifies the wrapped handler in metrics, eg. "admin".
+func (a *Authenticator) HTTPHandler(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r.Header.Values(authorizationHeader))
+		if _, reason := a.check(name, token, ok); reason != "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, reason, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns a codes.Unauthenticated error if the request does not contain an accepted token,
+// or the tenants the token is accepted for.
+func (a *Authenticator) authenticate(ctx context.Context, method string) ([]string, error) {
+	token, ok := tokenFromContext(ctx)
+	tenants, reason := a.check(method, token, ok)
+	if reason != "" {
+		return nil, status.Error(codes.Unauthenticated, reason)
+	}
+	return tenants, nil
+}
+
+// check counts the result of authenticating a request in the metrics and returns the tenants the token is
+// accepted for, or the reason the request was rejected if it is not accepted.
+func (a *Authenticator) check(method, token string, ok bool) ([]string, string) {
+	var tenants []string
+	reason := ""
+	if !ok {
+		reason = "missing bearer token"
+	} else if tenants = a.acceptedTenants(token); len(tenants) == 0 {
+		reason = "invalid bearer token"
+	}
+
+	if reason != "" {
+		metrics.ClientAuthRequests.WithLabelValues(method, metrics.ClientAuthResultUnauthenticated).Inc()
+		a.logger.Warn().Str("method", method).Str("reason", reason).Msg("rejected unauthenticated client request")
+		return nil, reason
+	}
+
+	metrics.ClientAuthRequests.WithLabelValues(method, metrics.ClientAuthResultAuthenticated).Inc()
+	return tenants, ""
+}
+
+// acceptedTenants returns the tenants of the token sets which contain the token, if any.
+func (a *Authenticator) acceptedTenants(token string) []string {
+	digest := sha256.Sum256([]byte(token))
+
+	var tenants []string
+	for _, set := range a.tokenSets {
+		if set.isAccepted(digest) {
+			tenants = append(tenants, set.tenant)
+		}
+	}
+	return tenants
+}
+
+// isAccepted returns true if the token digest matches any of the accepted tokens.
+// Token digests are compared in constant time to avoid leaking the accepted tokens.
+func (s *tokenSet) isAccepted(digest [sha256.Size]byte) bool {
+	accepted := false
+	for _, acceptedTokens := range [][][sha256.Size]byte{s.tokens, s.currentFileTokens()} {
+		for _, acceptedDigest := range acceptedTokens {
+			if subtle.ConstantTimeCompare(digest[:], acceptedDigest[:]) == 1 {
+				accepted = true
+			}
+		}
+	}
+	return accepted
+}
+
+// currentFileTokens returns the tokens most recently read from the tokens file.
+func (s *tokenSet) currentFileTokens() [][sha256.Size]byte {
+	s.fileTokensMu.Lock()
+	defer s.fileTokensMu.Unlock()
+	return s.fileTokens
+}
+
+// reloadTokensFile reads the tokens file if its modification time has changed since it was last read.
+func (s *tokenSet) reloadTokensFile() error {
+	s.fileTokensMu.Lock()
+	defer s.fileTokensMu.Unlock()
+
+	info, err := os.Stat(s.tokensFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat client auth tokens file: %w", err)
+	}
+	if info.ModTime().Equal(s.tokensFileModTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.tokensFile)
+	if err != nil {
+		return fmt.Errorf("failed to read client auth tokens file: %w", err)
+	}
+
+	fileTokens := parseTokensFile(data)
+	if len(fileTokens) == 0 && len(s.tokens) == 0 {
+		return fmt.Errorf("no tokens found in client auth tokens file %s", s.tokensFile)
+	}
+
+	s.fileTokens = fileTokens
+	s.tokensFileModTime = info.ModTime()
+	return nil
+}
+
+// watchTokensFiles starts watching the directories of the tokens files, if any, for changes.
+//
+// The directories are watched rather than the files themselves, as tokens files are commonly
+// replaced rather than written in place (eg. Kubernetes Secret volumes swap a symlink).
+func (a *Authenticator) watchTokensFiles() error {
+	var dirs []string
+	for _, set := range a.tokenSets {
+		if set.tokensFile == "" {
+			continue
+		}
+		if dir := filepath.Dir(set.tokensFile); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create client auth tokens file watcher: %w", err)
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch client auth tokens directory %s: %w", dir, err)
+		}
+	}
+	a.watcher = watcher
+
+	go a.reloadOnChange()
+
+	return nil
+}
+
+// reloadOnChange reloads the tokens files in a watched directory whenever it changes.
+// If a changed file cannot be read, its previously read tokens continue to be accepted.
+func (a *Authenticator) reloadOnChange() {
+	for {
+		select {
+		case event, ok := <-a.watcher.Events:
+			if !ok {
+				return
+			}
+			// Any change in a watched directory triggers a reload of its tokens files, as they may be
+			// symlinks whose targets are replaced. Unchanged files are not re-read.
+			for _, set := range a.tokenSets {
+				if set.tokensFile == "" || filepath.Dir(set.tokensFile) != filepath.Dir(event.Name) {
+					continue
+				}
+				if err := set.reloadTokensFile(); err != nil {
+					a.logger.Error().Err(err).Str("tenant", set.tenant).Msg("failed to reload client auth tokens file, keeping previous tokens")
+				}
+			}
+
+		case err, ok := <-a.watcher.Errors:
+			if !ok {
+				return
+			}
+			a.logger.Error().Err(err).Msg("client auth tokens file watcher error")
+		}
+	}
+}
+
+// parseTokensFile returns the digests of the tokens in a tokens file,
+// which contains one token per line. Blank lines and lines starting with `#` are ignored.
+func parseTokensFile(data []byte) [][sha256.Size]byte {
+	var tokens [][sha256.Size]byte
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, sha256.Sum256([]byte(line)))
+	}
+	return tokens
+}
+
+// tokenFromContext returns the bearer token from the request metadata.
+func tokenFromContext(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return bearerToken(md.Get(authorizationHeader))
+}
+
+// bearerToken returns the first bearer token in the authorization header values.
+func bearerToken(authorizationValues []string) (string, bool) {
+	for _, value := range authorizationValues {
+		if token, ok := strings.CutPrefix(value, bearerPrefix); ok && token != "" {
+			return token, true
+		}
+	}
+	return "", false
+}
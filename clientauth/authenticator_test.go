@@ -0,0 +1,294 @@
+package clientauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/buildwithgrove/path-auth-data-server/metrics"
+)
+
+func Test_Authenticator_UnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		tokens   []string
+		metadata metadata.MD
+		wantCode codes.Code
+	}{
+		{
+			name:     "should accept request with accepted token",
+			tokens:   []string{"token_1"},
+			metadata: metadata.Pairs("authorization", "Bearer token_1"),
+			wantCode: codes.OK,
+		},
+		{
+			name:     "should accept request with any of multiple accepted tokens",
+			tokens:   []string{"token_1", "token_2"},
+			metadata: metadata.Pairs("authorization", "Bearer token_2"),
+			wantCode: codes.OK,
+		},
+		{
+			name:     "should reject request without metadata",
+			tokens:   []string{"token_1"},
+			metadata: nil,
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "should reject request without bearer prefix",
+			tokens:   []string{"token_1"},
+			metadata: metadata.Pairs("authorization", "token_1"),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "should reject request with invalid token",
+			tokens:   []string{"token_1"},
+			metadata: metadata.Pairs("authorization", "Bearer token_2"),
+			wantCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			authenticator, err := NewAuthenticator(test.tokens, "", polyzero.NewLogger())
+			c.NoError(err)
+
+			err = callUnary(authenticator, test.metadata)
+			c.Equal(test.wantCode, status.Code(err))
+		})
+	}
+}
+
+func Test_Authenticator_StreamServerInterceptor(t *testing.T) {
+	c := require.New(t)
+
+	authenticator, err := NewAuthenticator([]string{"token_1"}, "", polyzero.NewLogger())
+	c.NoError(err)
+
+	interceptor := authenticator.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/test/Stream"}
+	handler := func(any, grpc.ServerStream) error { return nil }
+
+	before := testutil.ToFloat64(metrics.ClientAuthRequests.WithLabelValues("/test/Stream", metrics.ClientAuthResultUnauthenticated))
+
+	err = interceptor(nil, &testServerStream{ctx: incomingContext(metadata.Pairs("authorization", "Bearer token_1"))}, info, handler)
+	c.NoError(err)
+
+	err = interceptor(nil, &testServerStream{ctx: incomingContext(metadata.Pairs("authorization", "Bearer token_2"))}, info, handler)
+	c.Equal(codes.Unauthenticated, status.Code(err))
+
+	// Unauthenticated requests must be counted in the metrics.
+	after := testutil.ToFloat64(metrics.ClientAuthRequests.WithLabelValues("/test/Stream", metrics.ClientAuthResultUnauthenticated))
+	c.Equal(before+1, after)
+}
+
+func Test_Authenticator_HTTPHandler(t *testing.T) {
+	tests := []struct {
+		name               string
+		authorization      string
+		expectedStatusCode int
+	}{
+		{
+			name:               "should accept request with accepted token",
+			authorization:      "Bearer token_1",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "should reject request without token",
+			authorization:      "",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "should reject request with invalid token",
+			authorization:      "Bearer token_2",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			authenticator, err := NewAuthenticator([]string{"token_1"}, "", polyzero.NewLogger())
+			c.NoError(err)
+
+			handler := authenticator.HTTPHandler("test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			c.Equal(test.expectedStatusCode, recorder.Code)
+		})
+	}
+}
+
+func Test_Authenticator_tokensFile(t *testing.T) {
+	c := require.New(t)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	c.NoError(os.WriteFile(tokensFile, []byte("# current token\ntoken_1\n\n"), 0600))
+
+	authenticator, err := NewAuthenticator(nil, tokensFile, polyzero.NewLogger())
+	c.NoError(err)
+	t.Cleanup(func() { authenticator.Close() })
+
+	c.NoError(callUnary(authenticator, metadata.Pairs("authorization", "Bearer token_1")))
+	c.Error(callUnary(authenticator, metadata.Pairs("authorization", "Bearer token_2")))
+
+	// Rotate the tokens in the file; the change must be picked up without restarting.
+	c.NoError(os.WriteFile(tokensFile, []byte("token_2\n"), 0600))
+	c.NoError(os.Chtimes(tokensFile, time.Now(), time.Now().Add(time.Minute)))
+
+	c.Eventually(func() bool {
+		return callUnary(authenticator, metadata.Pairs("authorization", "Bearer token_2")) == nil
+	}, time.Second, 10*time.Millisecond)
+	c.Error(callUnary(authenticator, metadata.Pairs("authorization", "Bearer token_1")))
+
+	// An unreadable file must not remove the previously accepted tokens.
+	c.NoError(os.Remove(tokensFile))
+	c.NoError(callUnary(authenticator, metadata.Pairs("authorization", "Bearer token_2")))
+}
+
+func Test_NewTenantAuthenticator(t *testing.T) {
+	tests := []struct {
+		name               string
+		token              string
+		authorizedTenants  []string
+		unauthorizedTenant string
+	}{
+		{
+			name:               "should authorize a tenant's token only for the tenant",
+			token:              "token_a",
+			authorizedTenants:  []string{"gateway_a"},
+			unauthorizedTenant: "gateway_b",
+		},
+		{
+			name:               "should authorize a token accepted for multiple tenants for each of them",
+			token:              "token_shared",
+			authorizedTenants:  []string{"gateway_a", "gateway_b"},
+			unauthorizedTenant: "gateway_c",
+		},
+		{
+			name:              "should authorize a token accepted for all tenants for any tenant",
+			token:             "token_all",
+			authorizedTenants: []string{"gateway_a", "gateway_b", "gateway_c"},
+		},
+	}
+
+	authenticator, err := NewTenantAuthenticator(map[string]Tokens{
+		"gateway_a": {Tokens: []string{"token_a", "token_shared"}},
+		"gateway_b": {Tokens: []string{"token_b", "token_shared"}},
+		AllTenants:  {Tokens: []string{"token_all"}},
+	}, polyzero.NewLogger())
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			ctx := authorizedContext(t, authenticator, metadata.Pairs("authorization", "Bearer "+test.token))
+			for _, tenant := range test.authorizedTenants {
+				c.True(TenantAuthorized(ctx, tenant), tenant)
+			}
+			if test.unauthorizedTenant != "" {
+				c.False(TenantAuthorized(ctx, test.unauthorizedTenant))
+			}
+		})
+	}
+}
+
+func Test_TenantAuthorized_stream(t *testing.T) {
+	c := require.New(t)
+
+	authenticator, err := NewTenantAuthenticator(map[string]Tokens{"gateway_a": {Tokens: []string{"token_a"}}}, polyzero.NewLogger())
+	c.NoError(err)
+
+	var ctx context.Context
+	handler := func(_ any, stream grpc.ServerStream) error {
+		ctx = stream.Context()
+		return nil
+	}
+	stream := &testServerStream{ctx: incomingContext(metadata.Pairs("authorization", "Bearer token_a"))}
+	c.NoError(authenticator.StreamServerInterceptor()(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test/Stream"}, handler))
+
+	c.True(TenantAuthorized(ctx, "gateway_a"))
+	c.False(TenantAuthorized(ctx, "gateway_b"))
+}
+
+func Test_TenantAuthorized_unauthenticated(t *testing.T) {
+	// Requests which were not authenticated, ie. if client auth is disabled, are authorized for every tenant.
+	require.True(t, TenantAuthorized(context.Background(), "gateway_a"))
+}
+
+func Test_NewAuthenticator_noTokens(t *testing.T) {
+	c := require.New(t)
+
+	_, err := NewAuthenticator(nil, "", polyzero.NewLogger())
+	c.Error(err)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	c.NoError(os.WriteFile(tokensFile, []byte("# no tokens\n"), 0600))
+
+	_, err = NewAuthenticator(nil, tokensFile, polyzero.NewLogger())
+	c.Error(err)
+
+	// Every tenant must accept at least one token.
+	_, err = NewTenantAuthenticator(map[string]Tokens{"gateway_a": {Tokens: []string{"token_a"}}, "gateway_b": {}}, polyzero.NewLogger())
+	c.ErrorContains(err, "tenant gateway_b")
+}
+
+/* ---------------------------------- Helpers ---------------------------------- */
+
+func callUnary(authenticator *Authenticator, md metadata.MD) error {
+	interceptor := authenticator.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test/Unary"}
+	handler := func(context.Context, any) (any, error) { return nil, nil }
+
+	_, err := interceptor(incomingContext(md), nil, info, handler)
+	return err
+}
+
+// authorizedContext returns the context with which an authenticated unary request is handled.
+func authorizedContext(t *testing.T, authenticator *Authenticator, md metadata.MD) context.Context {
+	var ctx context.Context
+	handler := func(handlerCtx context.Context, _ any) (any, error) {
+		ctx = handlerCtx
+		return nil, nil
+	}
+	_, err := authenticator.UnaryServerInterceptor()(incomingContext(md), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Unary"}, handler)
+	require.NoError(t, err)
+	return ctx
+}
+
+func incomingContext(md metadata.MD) context.Context {
+	if md == nil {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/diff"
+	grove_postgres "github.com/buildwithgrove/path-auth-data-server/postgres/grove"
+	"github.com/buildwithgrove/path-auth-data-server/redact"
+	"github.com/buildwithgrove/path-auth-data-server/yaml"
+)
+
+const (
+	diffCommandName  = "diff"
+	diffCommandUsage = "Show the gateway endpoint changes between two files, servers or databases."
+
+	diffArgsUsage = `<from> <to>
+
+Each source is one of:
+  <file>                    A gateway endpoints YAML or JSON file.
+  grpc://<host>:<port>      A running PATH Auth Data Server. Use grpcs:// for TLS.
+  postgres://<connection>   A Grove Portal DB.`
+)
+
+// The formats supported by the diff command.
+const (
+	diffFormatText = "text"
+	diffFormatJSON = "json"
+)
+
+// The URL schemes of the Postgres sources supported by the diff command.
+// Sources which are not a Postgres database or a running server are files.
+const (
+	postgresScheme   = "postgres://"
+	postgresqlScheme = "postgresql://"
+)
+
+// diffResult is the JSON output of the diff command.
+type diffResult struct {
+	Summary diff.Summary          `json:"summary"`
+	Changes []diff.EndpointChange `json:"changes"`
+}
+
+// diffSourceOptions configures how sources are fetched.
+type diffSourceOptions struct {
+	server  serverClientOptions
+	timeout time.Duration
+}
+
+// runDiffCommand compares the gateway endpoints served from two sources and prints the added, removed and
+// changed endpoints, eg. to preview what PEAS would see change before a new YAML file is applied.
+// Secret values are always redacted.
+//
+// As with diff(1), the command fails if the sources differ, so that it may be used in scripts.
+//
+// eg. `pads diff grpc://localhost:10002 ./gateway-endpoints.yaml`
+func runDiffCommand(args []string) error {
+	flags := newFlagSet(diffCommandName, diffCommandUsage, diffArgsUsage)
+	format := flags.String("format", diffFormatText, fmt.Sprintf("The output format (%s or %s).", diffFormatText, diffFormatJSON))
+	serverOptions := addServerClientFlags(flags)
+	var options diffSourceOptions
+	flags.DurationVar(&options.timeout, "timeout", 30*time.Second, "Timeout for fetching the gateway endpoints of each source.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly two sources")
+	}
+	options.server = *serverOptions
+	if *format != diffFormatText && *format != diffFormatJSON {
+		return fmt.Errorf("unsupported format %q: must be %s or %s", *format, diffFormatText, diffFormatJSON)
+	}
+
+	from, err := fetchDiffSource(flags.Arg(0), options)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", diffSourceName(flags.Arg(0)), err)
+	}
+	to, err := fetchDiffSource(flags.Arg(1), options)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", diffSourceName(flags.Arg(1)), err)
+	}
+
+	changes := diff.GatewayEndpoints(from, to)
+
+	if *format == diffFormatJSON {
+		result := diffResult{Summary: diff.Summarize(changes), Changes: changes}
+		if result.Changes == nil {
+			result.Changes = []diff.EndpointChange{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		printDiff(os.Stdout, changes)
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("%d gateway endpoint(s) differ", len(changes))
+	}
+	return nil
+}
+
+// printDiff prints the changes in a human-readable format, followed by a summary.
+func printDiff(w io.Writer, changes []diff.EndpointChange) {
+	for _, change := range changes {
+		fmt.Fprintln(w, formatEndpointChange(change))
+	}
+
+	summary := diff.Summarize(changes)
+	fmt.Fprintf(w, "%d added, %d removed, %d changed\n", summary.Added, summary.Removed, summary.Changed)
+}
+
+// formatEndpointChange formats a change in a human-readable format, eg.
+//
+//	~ endpoint_1
+//	    metadata.plan_type: "PLAN_FREE" -> "PLAN_UNLIMITED"
+func formatEndpointChange(change diff.EndpointChange) string {
+	prefixes := map[diff.ChangeType]string{
+		diff.ChangeAdded:   "+",
+		diff.ChangeRemoved: "-",
+		diff.ChangeChanged: "~",
+	}
+
+	var formatted strings.Builder
+	fmt.Fprintf(&formatted, "%s %s", prefixes[change.Type], change.EndpointID)
+	for _, field := range change.Fields {
+		switch change.Type {
+		case diff.ChangeAdded:
+			fmt.Fprintf(&formatted, "\n    %s: %q", field.Field, field.To)
+		case diff.ChangeRemoved:
+			fmt.Fprintf(&formatted, "\n    %s: %q", field.Field, field.From)
+		default:
+			fmt.Fprintf(&formatted, "\n    %s: %q -> %q", field.Field, field.From, field.To)
+		}
+	}
+	return formatted.String()
+}
+
+/* ---------------------------------- Sources ---------------------------------- */
+
+// fetchDiffSource returns the gateway endpoints that PEAS would be served from the source.
+func fetchDiffSource(source string, options diffSourceOptions) (map[string]*proto.GatewayEndpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), options.timeout)
+	defer cancel()
+
+	switch {
+	case isServerAddress(source):
+		return fetchServerGatewayEndpoints(ctx, source, options.server)
+	case strings.HasPrefix(source, postgresScheme), strings.HasPrefix(source, postgresqlScheme):
+		return fetchPostgresGatewayEndpoints(ctx, source)
+	default:
+		return fetchFileGatewayEndpoints(source)
+	}
+}
+
+// diffSourceName returns the source with any password redacted, so that it may be printed.
+func diffSourceName(source string) string {
+	if strings.HasPrefix(source, postgresScheme) || strings.HasPrefix(source, postgresqlScheme) {
+		return redact.ConnectionString(source)
+	}
+	return source
+}
+
+// fetchFileGatewayEndpoints returns the gateway endpoints in the file which are currently served,
+// ie. excluding time-bounded endpoints outside of their validity window, as a YAML data source would.
+func fetchFileGatewayEndpoints(filename string) (map[string]*proto.GatewayEndpoint, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	authData, windows, err := yaml.DecodeGatewayEndpoints(data)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for endpointID, window := range windows {
+		if !window.IsActive(now) {
+			delete(authData.Endpoints, endpointID)
+		}
+	}
+
+	return authData.Endpoints, nil
+}
+
+// fetchPostgresGatewayEndpoints returns the gateway endpoints currently served from the Grove Portal DB.
+func fetchPostgresGatewayEndpoints(ctx context.Context, connectionString string) (map[string]*proto.GatewayEndpoint, error) {
+	dataSource, cleanup, err := grove_postgres.NewGrovePostgresDataSource(ctx, connectionString, newLogger("error"))
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	authData, err := dataSource.FetchAuthDataSync()
+	if err != nil {
+		return nil, err
+	}
+
+	return authData.GetEndpoints(), nil
+}
+
+// fetchServerGatewayEndpoints returns the gateway endpoints served by a running PATH Auth Data Server,
+// fetched exactly as PEAS fetches them.
+func fetchServerGatewayEndpoints(ctx context.Context, address string, options serverClientOptions) (map[string]*proto.GatewayEndpoint, error) {
+	client, conn, err := dialServer(address, options)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	authData, err := client.FetchAuthDataSync(ctx, &proto.AuthDataRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return authData.GetEndpoints(), nil
+}
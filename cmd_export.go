@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/buildwithgrove/path-auth-data-server/config"
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/yaml"
+)
+
+const (
+	exportCommandName  = "export"
+	exportCommandUsage = "Export the gateway endpoints of the configured data sources as a YAML or JSON file."
+)
+
+// The formats supported by the export command.
+const (
+	exportFormatYAML = "yaml"
+	exportFormatJSON = "json"
+)
+
+// runExportCommand exports the gateway endpoints stored in the configured data sources and writes
+// them in the YAML file format, eg. to migrate from a YAML file to Postgres or to snapshot Postgres
+// for a development environment.
+//
+// Every stored gateway endpoint is exported with its validity window, including those which are not
+// currently served as they have not yet started or have expired, so that importing the export is lossless.
+//
+// The export contains the API keys exactly as they are stored, so it must be handled as a secret.
+//
+// eg. `pads export -config ./config.yaml -source postgres -o ./gateway-endpoints.yaml`
+func runExportCommand(args []string) error {
+	flags := newFlagSet(exportCommandName, exportCommandUsage, "")
+	configFile := addConfigFlag(flags)
+	tenant := flags.String("tenant", grpc_server.DefaultTenant, "The tenant whose data sources are exported.")
+	source := flags.String("source", "", fmt.Sprintf("The data source to export (%s or %s). Defaults to all configured data sources, merged by precedence.", config.DataSourcePostgres, config.DataSourceYAML))
+	format := flags.String("format", exportFormatYAML, fmt.Sprintf("The output format (%s or %s).", exportFormatYAML, exportFormatJSON))
+	output := flags.String("o", "", "Write the export to the file instead of stdout.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+	if *format != exportFormatYAML && *format != exportFormatJSON {
+		return fmt.Errorf("unsupported format %q: must be %s or %s", *format, exportFormatYAML, exportFormatJSON)
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		return err
+	}
+	logger := newLogger(cfg.Logging.Level)
+
+	tenantDataSources := cfg.TenantDataSources()
+	dataSources, ok := tenantDataSources[*tenant]
+	if !ok {
+		return fmt.Errorf("tenant %q is not configured: the configured tenants are %v", *tenant, slices.Sorted(maps.Keys(tenantDataSources)))
+	}
+
+	var authDataSource grpc_server.AuthDataSource
+	var cleanup func()
+	switch {
+	case *source == "":
+		authDataSource, cleanup, err = getAuthDataSource(dataSources, logger)
+	case slices.Contains(dataSources.Precedence, *source):
+		authDataSource, cleanup, err = getNamedAuthDataSource(*source, dataSources, logger)
+	default:
+		return fmt.Errorf("data source %q is not configured: the configured data sources are %v", *source, dataSources.Precedence)
+	}
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// The served gateway endpoints are never exported, as they omit the validity windows and any
+	// gateway endpoint outside of its window: a data source which cannot export its stored rows fails the export.
+	exporter, ok := authDataSource.(grpc_server.AuthDataExporter)
+	if !ok {
+		return fmt.Errorf("the configured data sources do not support exports")
+	}
+	authData, windows, err := exporter.ExportAuthData()
+	if err != nil {
+		return fmt.Errorf("failed to export gateway endpoints: %w", err)
+	}
+
+	var exported []byte
+	if *format == exportFormatJSON {
+		exported, err = yaml.EncodeGatewayEndpointsJSON(authData, windows)
+	} else {
+		exported, err = yaml.EncodeGatewayEndpoints(authData, windows)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err := os.Stdout.Write(exported)
+		return err
+	}
+
+	// The export contains API keys, so it is only readable by its owner.
+	if err := os.WriteFile(*output, exported, 0600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d gateway endpoint(s) to %s\n", len(authData.GetEndpoints()), *output)
+	return nil
+}
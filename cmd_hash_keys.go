@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
+	"github.com/buildwithgrove/path-auth-data-server/yaml"
+)
+
+const (
+	hashKeysCommandName  = "hash-keys"
+	hashKeysCommandUsage = "Hash the plaintext API keys in a gateway endpoints YAML file."
+)
+
+// runHashKeysCommand hashes the plaintext API keys in a gateway endpoints YAML file using Argon2id,
+// so that the file does not expose customer credentials.
+//
+// DEV_NOTE: The hashed file is not yet accepted by the YAML data source, as PEAS v0.0.8 compares
+// API keys as plaintext. See the apikey package.
+//
+// eg. `pads hash-keys -w ./gateway-endpoints.yaml`
+func runHashKeysCommand(args []string) error {
+	flags := newFlagSet(hashKeysCommandName, hashKeysCommandUsage, "<gateway-endpoints.yaml>")
+	writeInPlace := flags.Bool("w", false, "Write the result to the input file instead of stdout.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one gateway endpoints YAML file")
+	}
+	filename := flags.Arg(0)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	hashed, hashedCount, err := yaml.HashAPIKeys(data)
+	if err != nil {
+		return fmt.Errorf("failed to hash API keys in %s: %w", filename, err)
+	}
+
+	if !*writeInPlace {
+		_, err := os.Stdout.Write(hashed)
+		return err
+	}
+
+	fileInfo, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, hashed, fileInfo.Mode().Perm()); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "hashed %d API key(s) in %s using %s\n", hashedCount, filename, apikey.SchemeArgon2id)
+	return nil
+}
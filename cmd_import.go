@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/buildwithgrove/path-auth-data-server/config"
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/yaml"
+)
+
+const (
+	importCommandName  = "import"
+	importCommandUsage = "Import a gateway endpoints YAML file into the Postgres database of the configured data sources."
+)
+
+// runImportCommand loads a gateway endpoints YAML file into the Grove Portal DB schema of the configured
+// Postgres data source, eg. to migrate from a YAML file to Postgres.
+//
+// Every endpoint in the file is created or replaced in a single transaction; endpoints in the database
+// which are not in the file are not changed. The import fails, without writing anything, if any endpoint
+// has a field that the Grove Portal DB cannot store, so that nothing in the file is silently dropped.
+//
+// eg. `pads import -config ./config.yaml -tenant gateway-eu ./gateway-endpoints.yaml`
+func runImportCommand(args []string) error {
+	flags := newFlagSet(importCommandName, importCommandUsage, "<gateway-endpoints.yaml>")
+	configFile := addConfigFlag(flags)
+	tenant := flags.String("tenant", grpc_server.DefaultTenant, "The tenant whose Postgres data source is imported into.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one gateway endpoints YAML file")
+	}
+	filename := flags.Arg(0)
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		return err
+	}
+	tenantDataSources := cfg.TenantDataSources()
+	dataSources, ok := tenantDataSources[*tenant]
+	if !ok {
+		return fmt.Errorf("tenant %q is not configured: the configured tenants are %v", *tenant, slices.Sorted(maps.Keys(tenantDataSources)))
+	}
+	if dataSources.Postgres.ConnectionString == "" {
+		return fmt.Errorf("no Postgres data source is configured for tenant %q: set its data_sources.postgres.connection_string", *tenant)
+	}
+	logger := newLogger(cfg.Logging.Level)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	// The file is validated exactly as it is when loaded by a YAML data source.
+	authData, windows, err := yaml.DecodeGatewayEndpoints(data)
+	if err != nil {
+		return fmt.Errorf("invalid gateway endpoints file %s: %w", filename, err)
+	}
+
+	authDataSource, cleanup, err := getPostgresAuthDataSource(dataSources.Postgres, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	writer, ok := authDataSource.(grpc_server.AuthDataWriter)
+	if !ok {
+		// This should never happen.
+		return fmt.Errorf("the Postgres data source does not support writes")
+	}
+
+	writes := grpc_server.UpsertWrites(authData.Endpoints, windows)
+	if err := writer.WriteGatewayEndpoints(context.Background(), writes); err != nil {
+		return fmt.Errorf("failed to import %s: %w", filename, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d gateway endpoint(s) from %s\n", len(writes), filename)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/buildwithgrove/path-auth-data-server/yaml"
+)
+
+const (
+	validateCommandName  = "validate"
+	validateCommandUsage = "Validate a gateway endpoints YAML file, printing any errors as JSON."
+)
+
+// validateResult is the JSON output of the validate command.
+type validateResult struct {
+	Valid bool `json:"valid"`
+	// Endpoints is the number of endpoints in a valid file.
+	Endpoints int                    `json:"endpoints,omitempty"`
+	Errors    []yaml.ValidationError `json:"errors,omitempty"`
+}
+
+// runValidateCommand validates a gateway endpoints YAML file without starting the server,
+// so that changes to the file may be checked, eg. in CI, before they are deployed.
+//
+// The result is printed to stdout as JSON and the command fails if the file is invalid.
+//
+// eg. `pads validate ./gateway-endpoints.yaml`
+func runValidateCommand(args []string) error {
+	flags := newFlagSet(validateCommandName, validateCommandUsage, "<gateway-endpoints.yaml>")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one gateway endpoints YAML file")
+	}
+	filename := flags.Arg(0)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	result := validateResult{Errors: yaml.Validate(data)}
+	if len(result.Errors) == 0 {
+		authData, _, err := yaml.DecodeGatewayEndpoints(data)
+		if err != nil {
+			// Validate runs every validation run by DecodeGatewayEndpoints, so this should not be possible.
+			return fmt.Errorf("failed to decode %s: %w", filename, err)
+		}
+		result.Valid = true
+		result.Endpoints = len(authData.Endpoints)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+
+	if !result.Valid {
+		return fmt.Errorf("%s is invalid: %d error(s)", filename, len(result.Errors))
+	}
+	return nil
+}
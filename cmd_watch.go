@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/buildwithgrove/path-auth-data-server/diff"
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+)
+
+const (
+	watchCommandName  = "watch"
+	watchCommandUsage = "Connect to a running server as PEAS would and print every gateway endpoint update."
+
+	watchArgsUsage = `<grpc://host:port>
+
+watch subscribes to updates as an observer, alongside any connected PEAS,
+so it may be run against a server which is serving a PEAS in production.`
+)
+
+// The formats supported by the watch command.
+const (
+	watchFormatText = "text"
+	watchFormatJSON = "json"
+)
+
+// The events printed by the watch command.
+const (
+	watchEventSync   = "sync"
+	watchEventUpdate = "update"
+	watchEventDelete = "delete"
+	watchEventVerify = "verify"
+)
+
+// verifyGracePeriod is how long a mirror that differs from a full fetch is given to catch up
+// before it is reported as inconsistent, as updates may be in flight during the fetch.
+const verifyGracePeriod = 2 * time.Second
+
+// watchEvent is a single event printed by the watch command. In JSON format, each event is printed on its own line.
+type watchEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// EndpointID is set for update and delete events.
+	EndpointID string `json:"endpoint_id,omitempty"`
+	// Endpoints is the number of gateway endpoints in the mirror, after the event is applied.
+	Endpoints int `json:"endpoints"`
+	// Changes are the redacted changes to the mirror made by an update or delete event,
+	// or the differences between the mirror and a full fetch found by a verify event.
+	Changes []diff.EndpointChange `json:"changes,omitempty"`
+	// Consistent is set for verify events.
+	Consistent *bool `json:"consistent,omitempty"`
+}
+
+// watcher mirrors the gateway endpoints of a running server from its full sync and update stream, as PEAS does.
+type watcher struct {
+	client proto.GatewayEndpointsClient
+	mirror map[string]*proto.GatewayEndpoint
+	format string
+	out    io.Writer
+}
+
+// runWatchCommand connects to a running PATH Auth Data Server as PEAS does, fetching the full set
+// of gateway endpoints and subscribing to updates, and prints every update until interrupted.
+//
+// If -verify-interval is set, the mirror of the gateway endpoints built from the updates is periodically
+// compared to a full fetch, and the command fails if they are not consistent.
+//
+// eg. `pads watch -verify-interval 30s grpc://localhost:10002`
+func runWatchCommand(args []string) error {
+	flags := newFlagSet(watchCommandName, watchCommandUsage, watchArgsUsage)
+	format := flags.String("format", watchFormatText, fmt.Sprintf("The output format (%s or %s).", watchFormatText, watchFormatJSON))
+	verifyInterval := flags.Duration("verify-interval", 0, "If set, verify that the mirror of the gateway endpoints is consistent with a full fetch at this interval.")
+	serverOptions := addServerClientFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one server address")
+	}
+	if *format != watchFormatText && *format != watchFormatJSON {
+		return fmt.Errorf("unsupported format %q: must be %s or %s", *format, watchFormatText, watchFormatJSON)
+	}
+
+	client, conn, err := dialServer(flags.Arg(0), *serverOptions)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w := &watcher{client: client, format: *format, out: os.Stdout}
+	return w.watch(ctx, *verifyInterval)
+}
+
+// watch fetches the full set of gateway endpoints, then applies and prints every update until the context is done.
+//
+// watch subscribes as an observer, so that it never takes over the update stream from PEAS. An observer is only
+// sent the updates applied once it is registered, which the server confirms by sending the stream's header,
+// so the full set is fetched after the header is received, and the updates received before the fetch returns
+// are applied on top of it, in order. No update made after the fetch is missed.
+func (w *watcher) watch(ctx context.Context, verifyInterval time.Duration) error {
+	streamCtx := metadata.AppendToOutgoingContext(ctx, grpc_server.ObserverKey, "true")
+	stream, err := w.client.StreamAuthDataUpdates(streamCtx, &proto.AuthDataUpdatesRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to gateway endpoint updates: %w", err)
+	}
+	header, err := stream.Header()
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to gateway endpoint updates: %w", err)
+	}
+	if values := header.Get(grpc_server.ObserverKey); len(values) == 0 || values[0] != "true" {
+		// A stream rejected by the server has no header, and its error is received instead.
+		if _, err := stream.Recv(); err != nil {
+			return fmt.Errorf("failed to subscribe to gateway endpoint updates: %w", err)
+		}
+		return fmt.Errorf("server does not support observers, so watch would take over the update stream from PEAS")
+	}
+
+	authData, err := w.client.FetchAuthDataSync(ctx, &proto.AuthDataRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to fetch gateway endpoints: %w", err)
+	}
+	w.mirror = authData.GetEndpoints()
+	if w.mirror == nil {
+		w.mirror = make(map[string]*proto.GatewayEndpoint)
+	}
+	w.print(watchEvent{Event: watchEventSync})
+
+	updatesCh := make(chan *proto.AuthDataUpdate)
+	streamErrCh := make(chan error, 1)
+	go func() {
+		for {
+			update, err := stream.Recv()
+			if err != nil {
+				streamErrCh <- err
+				return
+			}
+			select {
+			case updatesCh <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var verifyTicker <-chan time.Time
+	if verifyInterval > 0 {
+		ticker := time.NewTicker(verifyInterval)
+		defer ticker.Stop()
+		verifyTicker = ticker.C
+	}
+	// recheck is set once the mirror has differed from a full fetch, to check it again after the grace period.
+	var recheck <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-streamErrCh:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("update stream closed: %w", err)
+
+		case update := <-updatesCh:
+			w.applyUpdate(update)
+
+		case <-verifyTicker:
+			if recheck != nil {
+				continue
+			}
+			consistent, err := w.verify(ctx, false)
+			if err != nil {
+				return err
+			}
+			if !consistent {
+				recheck = time.After(verifyGracePeriod)
+			}
+
+		case <-recheck:
+			recheck = nil
+			consistent, err := w.verify(ctx, true)
+			if err != nil {
+				return err
+			}
+			if !consistent {
+				return fmt.Errorf("mirror of gateway endpoints is not consistent with a full fetch")
+			}
+		}
+	}
+}
+
+// applyUpdate applies an update to the mirror and prints the resulting change.
+func (w *watcher) applyUpdate(update *proto.AuthDataUpdate) {
+	endpointID := update.GetEndpointId()
+
+	from := make(map[string]*proto.GatewayEndpoint)
+	if gatewayEndpoint, ok := w.mirror[endpointID]; ok {
+		from[endpointID] = gatewayEndpoint
+	}
+	to := make(map[string]*proto.GatewayEndpoint)
+
+	event := watchEvent{Event: watchEventDelete, EndpointID: endpointID}
+	if update.GetDelete() {
+		delete(w.mirror, endpointID)
+	} else {
+		event.Event = watchEventUpdate
+		w.mirror[endpointID] = update.GetGatewayEndpoint()
+		to[endpointID] = update.GetGatewayEndpoint()
+	}
+
+	event.Changes = diff.GatewayEndpoints(from, to)
+	w.print(event)
+}
+
+// verify compares the mirror to a full fetch and prints the result, returning true if they are the same.
+// If final is false, differences are only printed as a warning, as updates may still be in flight.
+func (w *watcher) verify(ctx context.Context, final bool) (bool, error) {
+	authData, err := w.client.FetchAuthDataSync(ctx, &proto.AuthDataRequest{})
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch gateway endpoints: %w", err)
+	}
+
+	differences := diff.GatewayEndpoints(w.mirror, authData.GetEndpoints())
+	consistent := len(differences) == 0
+
+	// Differences are only printed once they are final, as most are resolved by in-flight updates.
+	event := watchEvent{Event: watchEventVerify, Consistent: &consistent}
+	if final {
+		event.Changes = differences
+	}
+	if consistent || final {
+		w.print(event)
+	} else if w.format == watchFormatText {
+		fmt.Fprintf(w.out, "%s %s: mirror differs from a full fetch, rechecking in %s\n", time.Now().UTC().Format(time.RFC3339), watchEventVerify, verifyGracePeriod)
+	}
+
+	return consistent, nil
+}
+
+// print prints the event in the configured format.
+func (w *watcher) print(event watchEvent) {
+	event.Time = time.Now().UTC()
+	event.Endpoints = len(w.mirror)
+
+	if w.format == watchFormatJSON {
+		// Encoding the event cannot fail, and a failed write to stdout cannot be reported.
+		_ = json.NewEncoder(w.out).Encode(event)
+		return
+	}
+
+	prefix := fmt.Sprintf("%s %s:", event.Time.Format(time.RFC3339), event.Event)
+	switch event.Event {
+	case watchEventSync:
+		fmt.Fprintf(w.out, "%s %d gateway endpoint(s)\n", prefix, event.Endpoints)
+
+	case watchEventVerify:
+		if *event.Consistent {
+			fmt.Fprintf(w.out, "%s mirror of %d gateway endpoint(s) is consistent with a full fetch\n", prefix, event.Endpoints)
+			return
+		}
+		fmt.Fprintf(w.out, "%s mirror is not consistent with a full fetch, changes from the mirror to the full fetch:\n", prefix)
+		for _, change := range event.Changes {
+			fmt.Fprintln(w.out, formatEndpointChange(change))
+		}
+
+	default:
+		if len(event.Changes) == 0 {
+			fmt.Fprintf(w.out, "%s = %s (unchanged)\n", prefix, event.EndpointID)
+			return
+		}
+		for _, change := range event.Changes {
+			fmt.Fprintf(w.out, "%s %s\n", prefix, formatEndpointChange(change))
+		}
+	}
+}
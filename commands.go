@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/buildwithgrove/path-auth-data-server/config"
+)
+
+// This file handles the PADS subcommands, which are run instead of the server
+// if a subcommand is provided as the first argument, eg. `pads hash-keys <file>`.
+
+type command struct {
+	// usage is a short, single-line description of the command.
+	usage string
+	// run runs the command with the arguments that follow the command name.
+	run func(args []string) error
+}
+
+// commands maps each subcommand name to its implementation.
+var commands = map[string]command{
+	diffCommandName: {
+		usage: diffCommandUsage,
+		run:   runDiffCommand,
+	},
+	exportCommandName: {
+		usage: exportCommandUsage,
+		run:   runExportCommand,
+	},
+	hashKeysCommandName: {
+		usage: hashKeysCommandUsage,
+		run:   runHashKeysCommand,
+	},
+	importCommandName: {
+		usage: importCommandUsage,
+		run:   runImportCommand,
+	},
+	validateCommandName: {
+		usage: validateCommandUsage,
+		run:   runValidateCommand,
+	},
+	watchCommandName: {
+		usage: watchCommandUsage,
+		run:   runWatchCommand,
+	},
+}
+
+// isCommand returns true if the argument is the name of a subcommand or help.
+// The -h and --help flags are not commands: they print the usage of the server flags.
+func isCommand(arg string) bool {
+	_, ok := commands[arg]
+	return ok || isHelp(arg)
+}
+
+func isHelp(arg string) bool {
+	return arg == "help"
+}
+
+// runCommand runs the named subcommand and returns the process exit code.
+func runCommand(name string, args []string) int {
+	if isHelp(name) {
+		printCommandsUsage()
+		return 0
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		printCommandsUsage()
+		return 2
+	}
+
+	if err := cmd.run(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// printCommandsUsage prints the list of available subcommands to stderr.
+func printCommandsUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: pads [command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nIf no command is provided, the PATH Auth Data Server is started with the flags:")
+	fmt.Fprintln(os.Stderr, "  --config <file>  Path to the config file. May also be set with CONFIG_FILE.")
+	fmt.Fprintln(os.Stderr, "  --print-config   Print the effective configuration, with all secret values redacted, and exit.")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].usage)
+	}
+}
+
+// newFlagSet returns a flag set for the named subcommand that prints its usage on error.
+func newFlagSet(name, usage, argsUsage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pads %s [flags] %s\n\n%s\n\nFlags:\n", name, argsUsage, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// addConfigFlag adds the --config flag to the flag set of a subcommand which uses the server configuration.
+func addConfigFlag(flags *flag.FlagSet) *string {
+	return flags.String("config", os.Getenv(config.FileEnv), fmt.Sprintf("Path to the config file. May also be set with %s.", config.FileEnv))
+}
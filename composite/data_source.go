@@ -0,0 +1,299 @@
+/*
+Package composite provides an implementation of the AuthDataSource interface which layers several data sources,
+eg. the Grove Portal DB for customer endpoints and a YAML file for internal endpoints.
+
+Data sources are ordered by precedence: if more than one data source contains a GatewayEndpoint with the same ID,
+the GatewayEndpoint from the data source with the highest precedence is served. Updates from each data source
+are merged into a single update stream, in which updates to overridden GatewayEndpoints are dropped and the
+deletion of an overriding GatewayEndpoint restores the GatewayEndpoint it was overriding.
+*/
+package composite
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
+)
+
+// compositeDataSource implements the AuthDataSource, AuthDataExporter and AuthDataWriter interfaces
+var (
+	_ grpc_server.AuthDataSource   = &compositeDataSource{}
+	_ grpc_server.AuthDataExporter = &compositeDataSource{}
+	_ grpc_server.AuthDataWriter   = &compositeDataSource{}
+)
+
+// Source is a named data source layered by the composite data source.
+// The name is only used to identify the data source in logs.
+type Source struct {
+	Name           string
+	AuthDataSource grpc_server.AuthDataSource
+}
+
+// compositeDataSource implements the AuthDataSource interface by merging several data sources.
+type compositeDataSource struct {
+	// sources are ordered by precedence, highest first.
+	sources []Source
+
+	// gatewayEndpoints holds the GatewayEndpoints of each data source, indexed as sources.
+	// It is used to determine which data source's GatewayEndpoint is served for each ID.
+	gatewayEndpoints   []map[string]*proto.GatewayEndpoint
+	gatewayEndpointsMu sync.Mutex
+
+	authDataUpdatesCh chan *proto.AuthDataUpdate
+	// subscribed is set once SubscribeAuthData is first called, after which updates are read from the data sources.
+	subscribed bool
+
+	logger polylog.Logger
+}
+
+// NewCompositeDataSource creates a data source which merges the sources, ordered by precedence, highest first.
+func NewCompositeDataSource(sources []Source, logger polylog.Logger) (*compositeDataSource, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("composite data source requires at least one data source")
+	}
+
+	gatewayEndpoints := make([]map[string]*proto.GatewayEndpoint, len(sources))
+	for i := range sources {
+		gatewayEndpoints[i] = make(map[string]*proto.GatewayEndpoint)
+	}
+
+	return &compositeDataSource{
+		sources:           sources,
+		gatewayEndpoints:  gatewayEndpoints,
+		authDataUpdatesCh: make(chan *proto.AuthDataUpdate, 100_000),
+		logger:            logger.With("component", "composite_data_source"),
+	}, nil
+}
+
+/* ---------- Data Source Funcs ---------- */
+
+// FetchAuthDataSync fetches the full set of GatewayEndpoints from every data source and merges them,
+// serving the GatewayEndpoint from the data source with the highest precedence for each ID.
+func (c *compositeDataSource) FetchAuthDataSync() (*proto.AuthDataResponse, error) {
+	sourceGatewayEndpoints := make([]map[string]*proto.GatewayEndpoint, len(c.sources))
+	for i, source := range c.sources {
+		authData, err := source.AuthDataSource.FetchAuthDataSync()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch auth data from %s data source: %w", source.Name, err)
+		}
+		sourceGatewayEndpoints[i] = authData.GetEndpoints()
+	}
+
+	return &proto.AuthDataResponse{Endpoints: c.mergeGatewayEndpoints(sourceGatewayEndpoints)}, nil
+}
+
+// ExportAuthData exports every data source and merges their GatewayEndpoints, keeping the GatewayEndpoint and
+// validity window from the data source with the highest precedence for each ID. The export fails unless every
+// data source implements AuthDataExporter, so that it never silently omits a data source's stored GatewayEndpoints.
+func (c *compositeDataSource) ExportAuthData() (*proto.AuthDataResponse, map[string]validity.Window, error) {
+	sourceGatewayEndpoints := make([]map[string]*proto.GatewayEndpoint, len(c.sources))
+	sourceWindows := make([]map[string]validity.Window, len(c.sources))
+	for i, source := range c.sources {
+		exporter, ok := source.AuthDataSource.(grpc_server.AuthDataExporter)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s data source does not support exports", source.Name)
+		}
+		authData, windows, err := exporter.ExportAuthData()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to export auth data from %s data source: %w", source.Name, err)
+		}
+		sourceGatewayEndpoints[i] = authData.GetEndpoints()
+		sourceWindows[i] = windows
+	}
+
+	merged := c.mergeGatewayEndpoints(sourceGatewayEndpoints)
+
+	// The validity window of each GatewayEndpoint is taken from the highest precedence data source containing it.
+	windows := make(map[string]validity.Window)
+	for i := len(sourceGatewayEndpoints) - 1; i >= 0; i-- {
+		for endpointID := range sourceGatewayEndpoints[i] {
+			if window, ok := sourceWindows[i][endpointID]; ok {
+				windows[endpointID] = window
+			} else {
+				delete(windows, endpointID)
+			}
+		}
+	}
+
+	return &proto.AuthDataResponse{Endpoints: merged}, windows, nil
+}
+
+// SubscribeAuthData subscribes to every data source and returns their merged GatewayEndpoints,
+// along with a channel that merges the updates from every data source from then on.
+// It may only be called once; subsequent calls return grpc_server.ErrAlreadySubscribed.
+func (c *compositeDataSource) SubscribeAuthData() (*proto.AuthDataResponse, <-chan *proto.AuthDataUpdate, error) {
+	c.gatewayEndpointsMu.Lock()
+	defer c.gatewayEndpointsMu.Unlock()
+
+	if c.subscribed {
+		return nil, nil, grpc_server.ErrAlreadySubscribed
+	}
+	c.subscribed = true
+
+	updatesChs := make([]<-chan *proto.AuthDataUpdate, len(c.sources))
+	for i, source := range c.sources {
+		authData, updatesCh, err := source.AuthDataSource.SubscribeAuthData()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to subscribe to %s data source: %w", source.Name, err)
+		}
+
+		gatewayEndpoints := make(map[string]*proto.GatewayEndpoint, len(authData.GetEndpoints()))
+		for endpointID, gatewayEndpoint := range authData.GetEndpoints() {
+			gatewayEndpoints[endpointID] = gatewayEndpoint
+		}
+		c.gatewayEndpoints[i] = gatewayEndpoints
+		updatesChs[i] = updatesCh
+	}
+
+	// The updates are only applied once the lock is released, on top of the subscribed GatewayEndpoints.
+	for i, updatesCh := range updatesChs {
+		go c.handleUpdates(i, updatesCh)
+	}
+
+	return &proto.AuthDataResponse{Endpoints: c.mergeGatewayEndpoints(c.gatewayEndpoints)}, c.authDataUpdatesCh, nil
+}
+
+// mergeGatewayEndpoints merges the GatewayEndpoints of each data source, indexed as sources,
+// serving the GatewayEndpoint from the data source with the highest precedence for each ID.
+func (c *compositeDataSource) mergeGatewayEndpoints(sourceGatewayEndpoints []map[string]*proto.GatewayEndpoint) map[string]*proto.GatewayEndpoint {
+	merged := make(map[string]*proto.GatewayEndpoint)
+	// Iterate from the lowest precedence so that higher precedence data sources overwrite it.
+	for i := len(sourceGatewayEndpoints) - 1; i >= 0; i-- {
+		for endpointID, gatewayEndpoint := range sourceGatewayEndpoints[i] {
+			if _, ok := merged[endpointID]; ok {
+				c.logger.Debug().Str("endpoint_id", endpointID).Str("data_source", c.sources[i].Name).Msg("gateway endpoint overrides lower precedence data source")
+			}
+			merged[endpointID] = gatewayEndpoint
+		}
+	}
+	return merged
+}
+
+/* ---------- Data Writer Funcs ---------- */
+
+// WriteGatewayEndpoints writes to the highest precedence data source which supports writes, so that written
+// GatewayEndpoints are served over those of any lower precedence data source. Lower precedence data sources
+// are never written to, eg. a GatewayEndpoint which is only in a lower precedence data source cannot be deleted.
+//
+// Writes to a GatewayEndpoint overridden by a higher precedence data source which does not support writes
+// are rejected with ErrUnsupportedWrite, as they would not change the served GatewayEndpoint.
+func (c *compositeDataSource) WriteGatewayEndpoints(ctx context.Context, writes []grpc_server.EndpointWrite) error {
+	for i, source := range c.sources {
+		writer, ok := source.AuthDataSource.(grpc_server.AuthDataWriter)
+		if !ok {
+			continue
+		}
+		if err := c.checkNotOverridden(i, writes); err != nil {
+			return err
+		}
+		if err := writer.WriteGatewayEndpoints(ctx, writes); err != nil {
+			return fmt.Errorf("failed to write to %s data source: %w", source.Name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: none of the data sources supports writes", grpc_server.ErrUnsupportedWrite)
+}
+
+// checkNotOverridden returns an error if any of the written GatewayEndpoints is contained by a data source
+// with a higher precedence than the written data source at the given index.
+//
+// The GatewayEndpoints of the data sources are only tracked once subscribed, so they are fetched until then.
+func (c *compositeDataSource) checkNotOverridden(writerIndex int, writes []grpc_server.EndpointWrite) error {
+	c.gatewayEndpointsMu.Lock()
+	defer c.gatewayEndpointsMu.Unlock()
+
+	sourceGatewayEndpoints := c.gatewayEndpoints
+	if !c.subscribed {
+		sourceGatewayEndpoints = make([]map[string]*proto.GatewayEndpoint, writerIndex)
+		for i, source := range c.sources[:writerIndex] {
+			authData, err := source.AuthDataSource.FetchAuthDataSync()
+			if err != nil {
+				return fmt.Errorf("failed to fetch auth data from %s data source: %w", source.Name, err)
+			}
+			sourceGatewayEndpoints[i] = authData.GetEndpoints()
+		}
+	}
+
+	for _, write := range writes {
+		for i := range writerIndex {
+			if _, ok := sourceGatewayEndpoints[i][write.EndpointID]; ok {
+				return fmt.Errorf("%w: endpoint %s is overridden by the higher precedence %s data source",
+					grpc_server.ErrUnsupportedWrite, write.EndpointID, c.sources[i].Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+/* ---------- Update Merge Funcs ---------- */
+
+// handleUpdates applies the updates from the data source at the given index until its channel is closed.
+func (c *compositeDataSource) handleUpdates(sourceIndex int, updatesCh <-chan *proto.AuthDataUpdate) {
+	for update := range updatesCh {
+		c.applyUpdate(sourceIndex, update)
+	}
+	c.logger.Warn().Str("data_source", c.sources[sourceIndex].Name).Msg("data source updates channel closed")
+}
+
+// applyUpdate records the update from the data source at the given index and
+// sends the resulting change to the served GatewayEndpoint, if there is one:
+//   - Updates to a GatewayEndpoint overridden by a higher precedence data source are dropped.
+//   - Deleting a GatewayEndpoint sends the GatewayEndpoint from the next highest precedence
+//     data source which contains it, or a delete if no other data source contains it.
+//
+// The update is sent while holding the lock, so that updates are sent in the order they are applied.
+// The span carried with the update is moved to the sent update, or dropped with the update.
+func (c *compositeDataSource) applyUpdate(sourceIndex int, update *proto.AuthDataUpdate) {
+	c.gatewayEndpointsMu.Lock()
+	defer c.gatewayEndpointsMu.Unlock()
+
+	endpointID := update.GetEndpointId()
+	if update.GetDelete() {
+		delete(c.gatewayEndpoints[sourceIndex], endpointID)
+	} else {
+		c.gatewayEndpoints[sourceIndex][endpointID] = update.GetGatewayEndpoint()
+	}
+
+	servedIndex, gatewayEndpoint := c.servedGatewayEndpoint(endpointID)
+
+	switch {
+	// No data source contains the GatewayEndpoint.
+	case servedIndex < 0:
+		c.sendUpdate(update, &proto.AuthDataUpdate{EndpointId: endpointID, Delete: true})
+
+	// A higher precedence data source overrides the GatewayEndpoint, so the served GatewayEndpoint is unchanged.
+	case servedIndex < sourceIndex:
+		tracing.Updates.Take(update)
+		c.logger.Debug().Str("endpoint_id", endpointID).Str("data_source", c.sources[sourceIndex].Name).Msg("dropping update to overridden gateway endpoint")
+
+	// The GatewayEndpoint from this data source, or from a lower precedence data source if it was deleted, is served.
+	default:
+		c.sendUpdate(update, &proto.AuthDataUpdate{EndpointId: endpointID, GatewayEndpoint: gatewayEndpoint})
+	}
+}
+
+// sendUpdate sends the update which results from applying the data source's update, carrying its span.
+func (c *compositeDataSource) sendUpdate(applied, update *proto.AuthDataUpdate) {
+	tracing.Updates.Move(applied, update)
+	c.authDataUpdatesCh <- update
+}
+
+// servedGatewayEndpoint returns the index of the highest precedence data source containing
+// the GatewayEndpoint and the GatewayEndpoint, or -1 if no data source contains it.
+// It must be called with the lock held.
+func (c *compositeDataSource) servedGatewayEndpoint(endpointID string) (int, *proto.GatewayEndpoint) {
+	for i, gatewayEndpoints := range c.gatewayEndpoints {
+		if gatewayEndpoint, ok := gatewayEndpoints[endpointID]; ok {
+			return i, gatewayEndpoint
+		}
+	}
+	return -1, nil
+}
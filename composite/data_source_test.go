@@ -0,0 +1,358 @@
+package composite
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+	protobuf "google.golang.org/protobuf/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
+)
+
+// newTestDataSource returns an in-memory data source which stores the GatewayEndpoints.
+func newTestDataSource(gatewayEndpoints ...*proto.GatewayEndpoint) *datasourcetest.DataSource {
+	return datasourcetest.New(gatewayEndpoints, grpc_server.NewUpdateFeed)
+}
+
+// newTestWriter returns an in-memory data source which records the writes made to it.
+func newTestWriter() *datasourcetest.Writer[grpc_server.EndpointWrite] {
+	return &datasourcetest.Writer[grpc_server.EndpointWrite]{DataSource: newTestDataSource()}
+}
+
+func gatewayEndpoint(endpointID, accountID string) *proto.GatewayEndpoint {
+	return &proto.GatewayEndpoint{
+		EndpointId: endpointID,
+		Auth:       &proto.Auth{AuthType: &proto.Auth_NoAuth{}},
+		Metadata:   &proto.Metadata{AccountId: accountID},
+	}
+}
+
+func Test_FetchAuthDataSync(t *testing.T) {
+	c := require.New(t)
+
+	yamlSource := newTestDataSource(
+		gatewayEndpoint("endpoint_1", "ops"),
+		gatewayEndpoint("endpoint_2", "ops"),
+	)
+	postgresSource := newTestDataSource(
+		gatewayEndpoint("endpoint_2", "customer"),
+		gatewayEndpoint("endpoint_3", "customer"),
+	)
+
+	dataSource, err := NewCompositeDataSource([]Source{
+		{Name: "yaml", AuthDataSource: yamlSource},
+		{Name: "postgres", AuthDataSource: postgresSource},
+	}, polyzero.NewLogger())
+	c.NoError(err)
+
+	authData, err := dataSource.FetchAuthDataSync()
+	c.NoError(err)
+	c.Equal(map[string]*proto.GatewayEndpoint{
+		"endpoint_1": gatewayEndpoint("endpoint_1", "ops"),
+		"endpoint_2": gatewayEndpoint("endpoint_2", "ops"),
+		"endpoint_3": gatewayEndpoint("endpoint_3", "customer"),
+	}, authData.Endpoints)
+}
+
+// testExporter is an in-memory data source which exports its GatewayEndpoints with their validity windows.
+type testExporter struct {
+	*datasourcetest.DataSource
+	windows map[string]validity.Window
+}
+
+func (e *testExporter) ExportAuthData() (*proto.AuthDataResponse, map[string]validity.Window, error) {
+	authData, err := e.FetchAuthDataSync()
+	return authData, e.windows, err
+}
+
+func Test_ExportAuthData(t *testing.T) {
+	c := require.New(t)
+
+	startsAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	yamlSource := &testExporter{
+		DataSource: newTestDataSource(
+			gatewayEndpoint("endpoint_1", "ops"),
+			gatewayEndpoint("endpoint_2", "ops"),
+		),
+		windows: map[string]validity.Window{"endpoint_1": {StartsAt: startsAt}},
+	}
+	postgresSource := &testExporter{
+		DataSource: newTestDataSource(
+			gatewayEndpoint("endpoint_2", "customer"),
+			gatewayEndpoint("endpoint_3", "customer"),
+		),
+		windows: map[string]validity.Window{
+			"endpoint_2": {ExpiresAt: expiresAt},
+			"endpoint_3": {ExpiresAt: expiresAt},
+		},
+	}
+
+	dataSource, err := NewCompositeDataSource([]Source{
+		{Name: "yaml", AuthDataSource: yamlSource},
+		{Name: "postgres", AuthDataSource: postgresSource},
+	}, polyzero.NewLogger())
+	c.NoError(err)
+
+	authData, windows, err := dataSource.ExportAuthData()
+	c.NoError(err)
+	c.Equal(map[string]*proto.GatewayEndpoint{
+		"endpoint_1": gatewayEndpoint("endpoint_1", "ops"),
+		"endpoint_2": gatewayEndpoint("endpoint_2", "ops"),
+		"endpoint_3": gatewayEndpoint("endpoint_3", "customer"),
+	}, authData.Endpoints)
+	// endpoint_2 is exported from the yaml data source, which does not bound it in time.
+	c.Equal(map[string]validity.Window{
+		"endpoint_1": {StartsAt: startsAt},
+		"endpoint_3": {ExpiresAt: expiresAt},
+	}, windows)
+}
+
+func Test_ExportAuthData_unsupported(t *testing.T) {
+	c := require.New(t)
+
+	dataSource, err := NewCompositeDataSource([]Source{
+		{Name: "yaml", AuthDataSource: &testExporter{DataSource: newTestDataSource()}},
+		{Name: "postgres", AuthDataSource: newTestDataSource()},
+	}, polyzero.NewLogger())
+	c.NoError(err)
+
+	_, _, err = dataSource.ExportAuthData()
+	c.EqualError(err, "postgres data source does not support exports")
+}
+
+func Test_SubscribeAuthData(t *testing.T) {
+	tests := []struct {
+		name           string
+		highUpdate     *proto.AuthDataUpdate
+		lowUpdate      *proto.AuthDataUpdate
+		expectedUpdate *proto.AuthDataUpdate
+	}{
+		{
+			name:      "should send update to endpoint only in lower precedence data source",
+			lowUpdate: &proto.AuthDataUpdate{EndpointId: "endpoint_3", GatewayEndpoint: gatewayEndpoint("endpoint_3", "customer_updated")},
+			expectedUpdate: &proto.AuthDataUpdate{
+				EndpointId:      "endpoint_3",
+				GatewayEndpoint: gatewayEndpoint("endpoint_3", "customer_updated"),
+			},
+		},
+		{
+			name:           "should drop update to endpoint overridden by higher precedence data source",
+			lowUpdate:      &proto.AuthDataUpdate{EndpointId: "endpoint_2", GatewayEndpoint: gatewayEndpoint("endpoint_2", "customer_updated")},
+			expectedUpdate: nil,
+		},
+		{
+			name:           "should drop delete of endpoint overridden by higher precedence data source",
+			lowUpdate:      &proto.AuthDataUpdate{EndpointId: "endpoint_2", Delete: true},
+			expectedUpdate: nil,
+		},
+		{
+			name:       "should send update to overriding endpoint",
+			highUpdate: &proto.AuthDataUpdate{EndpointId: "endpoint_2", GatewayEndpoint: gatewayEndpoint("endpoint_2", "ops_updated")},
+			expectedUpdate: &proto.AuthDataUpdate{
+				EndpointId:      "endpoint_2",
+				GatewayEndpoint: gatewayEndpoint("endpoint_2", "ops_updated"),
+			},
+		},
+		{
+			name:       "should restore overridden endpoint when overriding endpoint is deleted",
+			highUpdate: &proto.AuthDataUpdate{EndpointId: "endpoint_2", Delete: true},
+			expectedUpdate: &proto.AuthDataUpdate{
+				EndpointId:      "endpoint_2",
+				GatewayEndpoint: gatewayEndpoint("endpoint_2", "customer"),
+			},
+		},
+		{
+			name:           "should send delete when no data source contains the endpoint",
+			highUpdate:     &proto.AuthDataUpdate{EndpointId: "endpoint_1", Delete: true},
+			expectedUpdate: &proto.AuthDataUpdate{EndpointId: "endpoint_1", Delete: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			highSource := newTestDataSource(
+				gatewayEndpoint("endpoint_1", "ops"),
+				gatewayEndpoint("endpoint_2", "ops"),
+			)
+			lowSource := newTestDataSource(
+				gatewayEndpoint("endpoint_2", "customer"),
+				gatewayEndpoint("endpoint_3", "customer"),
+			)
+
+			dataSource, err := NewCompositeDataSource([]Source{
+				{Name: "high", AuthDataSource: highSource},
+				{Name: "low", AuthDataSource: lowSource},
+			}, polyzero.NewLogger())
+			c.NoError(err)
+
+			authData, updatesCh, err := dataSource.SubscribeAuthData()
+			c.NoError(err)
+			c.Equal(map[string]*proto.GatewayEndpoint{
+				"endpoint_1": gatewayEndpoint("endpoint_1", "ops"),
+				"endpoint_2": gatewayEndpoint("endpoint_2", "ops"),
+				"endpoint_3": gatewayEndpoint("endpoint_3", "customer"),
+			}, authData.Endpoints)
+
+			if test.highUpdate != nil {
+				highSource.Apply(test.highUpdate)
+			}
+			if test.lowUpdate != nil {
+				lowSource.Apply(test.lowUpdate)
+			}
+
+			select {
+			case update := <-updatesCh:
+				// The update is compared with protobuf.Equal, as the update feed's comparison of the
+				// updated GatewayEndpoint with the stored one initializes its internal message state.
+				c.True(protobuf.Equal(test.expectedUpdate, update), "expected %v, got %v", test.expectedUpdate, update)
+			case <-time.After(100 * time.Millisecond):
+				c.Nil(test.expectedUpdate, "expected update not received")
+			}
+		})
+	}
+}
+
+func Test_SubscribeAuthData_subscribeOnce(t *testing.T) {
+	c := require.New(t)
+
+	dataSource, err := NewCompositeDataSource([]Source{
+		{Name: "yaml", AuthDataSource: newTestDataSource()},
+	}, polyzero.NewLogger())
+	c.NoError(err)
+
+	_, _, err = dataSource.SubscribeAuthData()
+	c.NoError(err)
+
+	_, _, err = dataSource.SubscribeAuthData()
+	c.ErrorIs(err, grpc_server.ErrAlreadySubscribed)
+}
+
+func Test_NewCompositeDataSource_noSources(t *testing.T) {
+	c := require.New(t)
+
+	_, err := NewCompositeDataSource(nil, polyzero.NewLogger())
+	c.Error(err)
+}
+
+func Test_WriteGatewayEndpoints(t *testing.T) {
+	writes := []grpc_server.EndpointWrite{{
+		Op:              grpc_server.WriteOpUpsert,
+		EndpointID:      "endpoint_1",
+		GatewayEndpoint: gatewayEndpoint("endpoint_1", "ops"),
+	}}
+
+	tests := []struct {
+		name           string
+		writable       []bool
+		expectedWriter int
+		expectedErr    error
+	}{
+		{
+			name:           "should write to the highest precedence data source",
+			writable:       []bool{true, true},
+			expectedWriter: 0,
+		},
+		{
+			name:           "should write to the highest precedence data source which supports writes",
+			writable:       []bool{false, true},
+			expectedWriter: 1,
+		},
+		{
+			name:           "should reject writes if no data source supports writes",
+			writable:       []bool{false, false},
+			expectedWriter: -1,
+			expectedErr:    grpc_server.ErrUnsupportedWrite,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			sources := make([]Source, len(test.writable))
+			writers := make([]*datasourcetest.Writer[grpc_server.EndpointWrite], len(test.writable))
+			for i, writable := range test.writable {
+				var authDataSource grpc_server.AuthDataSource = newTestDataSource()
+				if writable {
+					writers[i] = newTestWriter()
+					authDataSource = writers[i]
+				}
+				sources[i] = Source{Name: fmt.Sprintf("source_%d", i), AuthDataSource: authDataSource}
+			}
+
+			dataSource, err := NewCompositeDataSource(sources, polyzero.NewLogger())
+			c.NoError(err)
+
+			err = dataSource.WriteGatewayEndpoints(context.Background(), writes)
+			if test.expectedErr != nil {
+				c.ErrorIs(err, test.expectedErr)
+			} else {
+				c.NoError(err)
+			}
+
+			for i, writer := range writers {
+				if writer == nil {
+					continue
+				}
+				if i == test.expectedWriter {
+					c.Equal(writes, writer.Writes())
+				} else {
+					c.Empty(writer.Writes())
+				}
+			}
+		})
+	}
+}
+
+func Test_WriteGatewayEndpoints_overridden(t *testing.T) {
+	tests := []struct {
+		name       string
+		subscribed bool
+	}{
+		{name: "should reject writes to overridden gateway endpoints before subscribing"},
+		{name: "should reject writes to overridden gateway endpoints once subscribed", subscribed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			writer := newTestWriter()
+			dataSource, err := NewCompositeDataSource([]Source{
+				{Name: "yaml", AuthDataSource: newTestDataSource(gatewayEndpoint("endpoint_1", "ops"))},
+				{Name: "postgres", AuthDataSource: writer},
+			}, polyzero.NewLogger())
+			c.NoError(err)
+
+			if test.subscribed {
+				_, _, err = dataSource.SubscribeAuthData()
+				c.NoError(err)
+			}
+
+			// The write would not change the served GatewayEndpoint, as the yaml data source overrides it.
+			err = dataSource.WriteGatewayEndpoints(context.Background(), []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpUpsert, EndpointID: "endpoint_2", GatewayEndpoint: gatewayEndpoint("endpoint_2", "customer")},
+				{Op: grpc_server.WriteOpUpsert, EndpointID: "endpoint_1", GatewayEndpoint: gatewayEndpoint("endpoint_1", "customer")},
+			})
+			c.ErrorIs(err, grpc_server.ErrUnsupportedWrite)
+			c.Empty(writer.Writes())
+
+			writes := []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpUpsert, EndpointID: "endpoint_2", GatewayEndpoint: gatewayEndpoint("endpoint_2", "customer")},
+			}
+			c.NoError(dataSource.WriteGatewayEndpoints(context.Background(), writes))
+			c.Equal(writes, writer.Writes())
+		})
+	}
+}
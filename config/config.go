@@ -0,0 +1,644 @@
+/*
+Package config loads the configuration of the PATH Auth Data Server.
+
+The configuration is read from an optional versioned YAML config file, then overridden by any
+set environment variables, so that PADS may be configured by a config file, environment
+variables, or both. The effective configuration is validated as a whole, and all problems
+are reported together.
+
+See config/testdata/config.example.yaml for an example config file.
+*/
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the version of the config file format supported by this version of PADS.
+// It must be incremented whenever a change to the format is not backwards compatible.
+const CurrentVersion = 1
+
+const (
+	// DataSourcePostgres and DataSourceYAML are the names of the data sources, as used in the data source precedence.
+	DataSourcePostgres = "postgres"
+	DataSourceYAML     = "yaml"
+
+	DefaultPort     = "10002"
+	DefaultLogLevel = "info"
+
+	// DefaultTenant is the name of the tenant of data_sources, served to clients which do not select a tenant.
+	DefaultTenant = "default"
+)
+
+// The defaults of the server options, used for any value which is not set. They are the defaults of the
+// packages which the options configure (eg. grpc and keepalive), which are not imported by this package.
+const (
+	DefaultStreamQueueSize          = 10_000
+	DefaultStreamSendTimeout        = 30 * time.Second
+	DefaultStreamSlowConsumerPolicy = "disconnect"
+
+	DefaultKeepaliveTime        = 30 * time.Second
+	DefaultKeepaliveInterval    = 10 * time.Second
+	DefaultKeepaliveCount       = 3
+	DefaultKeepalivePingTime    = time.Minute
+	DefaultKeepalivePingTimeout = 20 * time.Second
+
+	DefaultMaxRecvMessageSize    = 4 << 20
+	DefaultMaxSendMessageSize    = math.MaxInt32
+	DefaultFullSyncChunkSize     = 1_000
+	DefaultFullSyncMaxChunkBytes = 1 << 20
+
+	DefaultSnapshotInterval      = time.Minute
+	DefaultStartupInitialBackoff = time.Second
+	DefaultStartupMaxBackoff     = 30 * time.Second
+
+	DefaultTracingExporter    = "none"
+	DefaultTracingProtocol    = "grpc"
+	DefaultTracingSampleRatio = 1.0
+)
+
+var (
+	// slowConsumerPolicies are the supported stream slow consumer policies.
+	slowConsumerPolicies = []string{"disconnect", "resync"}
+	// tracingExporters and tracingProtocols are the supported span exporters and OTLP protocols.
+	tracingExporters = []string{"none", "otlp"}
+	tracingProtocols = []string{"grpc", "http/protobuf"}
+)
+
+// logLevels are the supported log levels, as accepted by polyzero.ParseLevel.
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// tenantNamePattern matches the valid tenant names, which are used in gRPC metadata, HTTP paths and metric labels.
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+type (
+	// Config is the full configuration of the PATH Auth Data Server.
+	Config struct {
+		// Version is the version of the config file format. It is required in config files.
+		Version int `yaml:"version"`
+
+		Server Server `yaml:"server"`
+		// DataSources configures the data sources of the default tenant, served to clients which do not select a tenant.
+		// They may be left unset if Tenants are set, in which case every client must select a tenant.
+		DataSources DataSources `yaml:"data_sources"`
+		// Tenants configures additional named tenants, each served the GatewayEndpoints of its own data sources
+		// to the clients which select it. They may only be set in the config file.
+		Tenants map[string]Tenant `yaml:"tenants,omitempty"`
+		// ClientAuth configures the bearer tokens accepted from the gRPC clients of the default tenant.
+		// If no tokens are set for any tenant, gRPC clients are not authenticated.
+		ClientAuth Tokens   `yaml:"client_auth"`
+		Admin      Admin    `yaml:"admin"`
+		Snapshot   Snapshot `yaml:"snapshot"`
+		Startup    Startup  `yaml:"startup"`
+		Tracing    Tracing  `yaml:"tracing"`
+		Logging    Logging  `yaml:"logging"`
+	}
+
+	// Server configures the listener of the gRPC server, health check and metrics.
+	Server struct {
+		Port string `yaml:"port"`
+		// TLS is only used if a certificate or key file is set; otherwise plaintext h2c is served.
+		TLS TLS `yaml:"tls"`
+		// Stream configures how updates are streamed to gRPC clients.
+		Stream Stream `yaml:"stream"`
+		// Keepalive configures how dead client connections are detected.
+		Keepalive Keepalive `yaml:"keepalive"`
+		// GRPC configures the message size limits of the gRPC server.
+		GRPC GRPC `yaml:"grpc"`
+		// FullSync configures how the full set of GatewayEndpoints is chunked by the FullSync service.
+		FullSync FullSync `yaml:"full_sync"`
+	}
+
+	// TLS configures the certificate served by the listener, and the client certificates it accepts.
+	TLS struct {
+		// CertFile and KeyFile are the paths to the PEM encoded server certificate and key.
+		CertFile string `yaml:"cert_file"`
+		KeyFile  string `yaml:"key_file"`
+		// ClientCAFile is the optional path to a PEM encoded CA bundle.
+		// If set, clients must present a certificate signed by one of its CAs.
+		ClientCAFile string `yaml:"client_ca_file"`
+		// AllowedClientNames optionally restricts the accepted client certificates to those whose
+		// Common Name or one of whose Subject Alternative Names is in the list.
+		AllowedClientNames []string `yaml:"allowed_client_names"`
+	}
+
+	// Stream configures how updates are streamed to gRPC clients. Any value which is not set is defaulted.
+	Stream struct {
+		// QueueSize is the number of updates which may be queued for a client before it is considered slow.
+		QueueSize int `yaml:"queue_size"`
+		// SendTimeout is how long sending a single update may take before the client is disconnected.
+		SendTimeout time.Duration `yaml:"send_timeout"`
+		// SlowConsumerPolicy is one of disconnect or resync.
+		SlowConsumerPolicy string `yaml:"slow_consumer_policy"`
+		// MaxAge is how long a client's stream is served before the client is asked to reconnect. Zero disables it.
+		MaxAge time.Duration `yaml:"max_age"`
+	}
+
+	// Keepalive configures how dead client connections are detected using TCP keepalive probes and HTTP/2 pings,
+	// and how long a connection may be served. Any value which is not set is defaulted.
+	Keepalive struct {
+		// Time is how long a connection must be idle before it is probed.
+		Time time.Duration `yaml:"time"`
+		// Interval is the time between probes.
+		Interval time.Duration `yaml:"interval"`
+		// Count is the number of unanswered probes after which the connection is closed.
+		Count int `yaml:"count"`
+		// PingTime is how long a connection may go without a frame being read before it is sent an HTTP/2 ping.
+		PingTime time.Duration `yaml:"ping_time"`
+		// PingTimeout is how long an HTTP/2 ping may go unanswered before the connection is closed.
+		PingTimeout time.Duration `yaml:"ping_timeout"`
+		// MaxConnectionAge is how long a connection is served before the client is asked to open a new one.
+		// Zero disables it.
+		MaxConnectionAge time.Duration `yaml:"max_connection_age"`
+		// MaxConnectionAgeGrace is how long a connection which reached its max age is given before it is
+		// forcibly closed. Zero waits for its requests to be done.
+		MaxConnectionAgeGrace time.Duration `yaml:"max_connection_age_grace"`
+	}
+
+	// GRPC configures the message size limits of the gRPC server, in bytes. Any value which is not set is defaulted.
+	GRPC struct {
+		MaxRecvMessageSize int `yaml:"max_recv_message_size"`
+		MaxSendMessageSize int `yaml:"max_send_message_size"`
+	}
+
+	// FullSync configures how the full set of GatewayEndpoints is chunked by the FullSync service.
+	// Any value which is not set is defaulted.
+	FullSync struct {
+		// ChunkSize is the max number of GatewayEndpoints in a chunk.
+		ChunkSize int `yaml:"chunk_size"`
+		// MaxChunkBytes is the max serialized size of the GatewayEndpoints in a chunk.
+		MaxChunkBytes int `yaml:"max_chunk_bytes"`
+	}
+
+	// DataSources configures the data sources of GatewayEndpoints. At least one data source must be set.
+	DataSources struct {
+		Postgres Postgres `yaml:"postgres"`
+		YAML     YAMLFile `yaml:"yaml"`
+
+		// Precedence lists the set data sources in order of precedence, highest first.
+		// If more than one data source is set, GatewayEndpoints with the same ID are served
+		// from the data source with the highest precedence.
+		Precedence []string `yaml:"precedence"`
+	}
+	// Tenant configures a named tenant, served to the clients which select it.
+	Tenant struct {
+		DataSources DataSources `yaml:"data_sources"`
+		// ClientAuth configures the bearer tokens accepted from the gRPC clients of the tenant, which are
+		// only authorized for the tenant. It must be set if client auth is enabled for any tenant.
+		ClientAuth Tokens `yaml:"client_auth"`
+	}
+
+	Postgres struct {
+		ConnectionString string `yaml:"connection_string"`
+	}
+	YAMLFile struct {
+		Filepath string `yaml:"filepath"`
+	}
+
+	// Tokens configures a set of accepted bearer tokens, set directly or read from a file.
+	Tokens struct {
+		Tokens     []string `yaml:"tokens"`
+		TokensFile string   `yaml:"tokens_file"`
+	}
+
+	// Admin configures the admin HTTP API. It is disabled if no port is set.
+	Admin struct {
+		Port string `yaml:"port"`
+		// Auth configures the bearer tokens accepted by the admin API.
+		// If no tokens are set, admin API requests are not authenticated.
+		Auth Tokens `yaml:"auth"`
+		// WritesEnabled enables the admin API routes which write GatewayEndpoints to the data source.
+		WritesEnabled bool `yaml:"writes_enabled"`
+	}
+
+	// Snapshot configures the encrypted snapshot of the served GatewayEndpoints, which is served at startup
+	// until the data source is available. It is disabled if no file is set.
+	Snapshot struct {
+		// File is the snapshot file of the default tenant; see TenantFile for the snapshot files of the other tenants.
+		File string `yaml:"file"`
+		// Key is the base64-encoded AES-256 key which encrypts the snapshot, set directly or read from the key file.
+		Key     string `yaml:"key"`
+		KeyFile string `yaml:"key_file"`
+		// Interval is how often the served GatewayEndpoints are persisted to the file.
+		Interval time.Duration `yaml:"interval"`
+	}
+
+	// Startup configures how PADS connects to the data sources at startup. Any backoff value which is not set is defaulted.
+	Startup struct {
+		// InitialBackoff is the delay before retrying to connect to the data sources,
+		// doubled after each failed attempt up to MaxBackoff, with jitter.
+		InitialBackoff time.Duration `yaml:"initial_backoff"`
+		MaxBackoff     time.Duration `yaml:"max_backoff"`
+		// StartEmpty serves no GatewayEndpoints until the data sources are connected if no snapshot is available,
+		// rather than rejecting requests as not ready. It is intended for development environments only.
+		StartEmpty bool `yaml:"start_empty"`
+	}
+
+	// Tracing configures how the OpenTelemetry spans of the updates to GatewayEndpoints are exported.
+	// Any value which is not set is defaulted.
+	Tracing struct {
+		// Exporter is one of none or otlp.
+		Exporter string `yaml:"exporter"`
+		OTLP     OTLP   `yaml:"otlp"`
+		// SampleRatio is the ratio of the updates which are traced, greater than 0 and at most 1.
+		SampleRatio float64 `yaml:"sample_ratio"`
+	}
+	// OTLP configures the OTLP exporter of spans.
+	OTLP struct {
+		// Protocol is one of grpc or http/protobuf.
+		Protocol string `yaml:"protocol"`
+		// Endpoint is the URL of the OTLP collector. If not set, the exporter's default endpoint is used,
+		// unless it is set by the OTEL_EXPORTER_OTLP_ENDPOINT environment variable.
+		Endpoint string `yaml:"endpoint"`
+	}
+
+	Logging struct {
+		// Level is one of debug, info, warn or error.
+		Level string `yaml:"level"`
+	}
+)
+
+// ValidationError reports all of the problems found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load returns the configuration read from the config file, if set, overridden by the set environment variables.
+// The returned configuration is validated and hydrated with defaults; a *ValidationError lists all problems found.
+func Load(filename string) (Config, error) {
+	return load(filename, os.Getenv)
+}
+
+func load(filename string, getenv func(string) string) (Config, error) {
+	var config Config
+
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return config, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if config, err = parse(data); err != nil {
+			return config, fmt.Errorf("failed to parse config file %s: %w", filename, err)
+		}
+	}
+
+	if err := config.applyEnv(getenv); err != nil {
+		return config, err
+	}
+
+	return config, config.validateAndHydrate()
+}
+
+// parse decodes a config file, rejecting unknown fields and unsupported versions.
+func parse(data []byte) (Config, error) {
+	var config Config
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return config, err
+	}
+
+	switch config.Version {
+	case CurrentVersion:
+		return config, nil
+	case 0:
+		return config, fmt.Errorf("version is required; the current version is %d", CurrentVersion)
+	default:
+		return config, fmt.Errorf("unsupported version %d; the current version is %d", config.Version, CurrentVersion)
+	}
+}
+
+/* ---------------------------------- Validation ---------------------------------- */
+
+// validateAndHydrate validates the configuration as a whole and hydrates defaults
+// for any optional values that are not set.
+func (c *Config) validateAndHydrate() error {
+	var problems []string
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Version == 0 {
+		c.Version = CurrentVersion
+	}
+	if c.Server.Port == "" {
+		c.Server.Port = DefaultPort
+	}
+	if c.Logging.Level == "" {
+		c.Logging.Level = DefaultLogLevel
+	}
+	if c.Server.Stream.QueueSize == 0 {
+		c.Server.Stream.QueueSize = DefaultStreamQueueSize
+	}
+	if c.Server.Stream.SendTimeout == 0 {
+		c.Server.Stream.SendTimeout = DefaultStreamSendTimeout
+	}
+	if c.Server.Stream.SlowConsumerPolicy == "" {
+		c.Server.Stream.SlowConsumerPolicy = DefaultStreamSlowConsumerPolicy
+	}
+	if c.Server.Keepalive.Time == 0 {
+		c.Server.Keepalive.Time = DefaultKeepaliveTime
+	}
+	if c.Server.Keepalive.Interval == 0 {
+		c.Server.Keepalive.Interval = DefaultKeepaliveInterval
+	}
+	if c.Server.Keepalive.Count == 0 {
+		c.Server.Keepalive.Count = DefaultKeepaliveCount
+	}
+	if c.Server.Keepalive.PingTime == 0 {
+		c.Server.Keepalive.PingTime = DefaultKeepalivePingTime
+	}
+	if c.Server.Keepalive.PingTimeout == 0 {
+		c.Server.Keepalive.PingTimeout = DefaultKeepalivePingTimeout
+	}
+	if c.Server.GRPC.MaxRecvMessageSize == 0 {
+		c.Server.GRPC.MaxRecvMessageSize = DefaultMaxRecvMessageSize
+	}
+	if c.Server.GRPC.MaxSendMessageSize == 0 {
+		c.Server.GRPC.MaxSendMessageSize = DefaultMaxSendMessageSize
+	}
+	if c.Server.FullSync.ChunkSize == 0 {
+		c.Server.FullSync.ChunkSize = DefaultFullSyncChunkSize
+	}
+	if c.Server.FullSync.MaxChunkBytes == 0 {
+		c.Server.FullSync.MaxChunkBytes = DefaultFullSyncMaxChunkBytes
+	}
+
+	if c.Snapshot.Interval == 0 {
+		c.Snapshot.Interval = DefaultSnapshotInterval
+	}
+	if c.Startup.InitialBackoff == 0 {
+		c.Startup.InitialBackoff = DefaultStartupInitialBackoff
+	}
+	if c.Startup.MaxBackoff == 0 {
+		c.Startup.MaxBackoff = DefaultStartupMaxBackoff
+	}
+
+	if c.Tracing.Exporter == "" {
+		c.Tracing.Exporter = DefaultTracingExporter
+	}
+	if c.Tracing.OTLP.Protocol == "" {
+		c.Tracing.OTLP.Protocol = DefaultTracingProtocol
+	}
+	if c.Tracing.SampleRatio == 0 {
+		c.Tracing.SampleRatio = DefaultTracingSampleRatio
+	}
+
+	dataSources := c.DataSources.set()
+	if len(dataSources) == 0 && len(c.Tenants) == 0 {
+		problem("no data source is set: set data_sources.postgres.connection_string (%s) or data_sources.yaml.filepath (%s)", postgresConnectionStringEnv, yamlFilepathEnv)
+	}
+	if len(c.DataSources.Precedence) == 0 {
+		c.DataSources.Precedence = dataSources
+	} else if !isPermutation(c.DataSources.Precedence, dataSources) {
+		problem("data_sources.precedence (%s) must list each set data source exactly once: %v", dataSourcePrecedenceEnv, dataSources)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Tenants)) {
+		tenant := c.Tenants[name]
+		if name == DefaultTenant {
+			problem("tenants.%s: the tenant name %q is reserved for the tenant of data_sources", name, name)
+		} else if !tenantNamePattern.MatchString(name) {
+			problem("tenants.%s: the tenant name must only contain lowercase letters, digits, '_' and '-'", name)
+		}
+
+		tenantDataSources := tenant.DataSources.set()
+		if len(tenantDataSources) == 0 {
+			problem("tenants.%s: no data source is set: set data_sources.postgres.connection_string or data_sources.yaml.filepath", name)
+		}
+		if len(tenant.DataSources.Precedence) == 0 {
+			tenant.DataSources.Precedence = tenantDataSources
+		} else if !isPermutation(tenant.DataSources.Precedence, tenantDataSources) {
+			problem("tenants.%s.data_sources.precedence must list each set data source exactly once: %v", name, tenantDataSources)
+		}
+		if c.ClientAuthEnabled() && !tenant.ClientAuth.Enabled() {
+			problem("tenants.%s.client_auth must be set, as client auth is enabled", name)
+		}
+		c.Tenants[name] = tenant
+	}
+
+	// The client_auth tokens are only authorized for the default tenant, so that the clients of a tenant
+	// cannot be served the GatewayEndpoints of another.
+	if c.ClientAuthEnabled() {
+		if len(dataSources) > 0 && !c.ClientAuth.Enabled() {
+			problem("client_auth (%s or %s) must be set for the tenant of data_sources, as client auth is enabled", clientAuthTokensEnv, clientAuthTokensFileEnv)
+		}
+		if len(dataSources) == 0 && c.ClientAuth.Enabled() {
+			problem("client_auth (%s or %s) is only accepted for the tenant of data_sources, which is not set: set tenants.<name>.client_auth instead", clientAuthTokensEnv, clientAuthTokensFileEnv)
+		}
+	}
+
+	if c.TLSEnabled() {
+		if c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "" {
+			problem("server.tls.cert_file (%s) and server.tls.key_file (%s) must both be set", tlsCertFileEnv, tlsKeyFileEnv)
+		}
+		if len(c.Server.TLS.AllowedClientNames) > 0 && c.Server.TLS.ClientCAFile == "" {
+			problem("server.tls.allowed_client_names (%s) requires server.tls.client_ca_file (%s)", tlsAllowedClientNamesEnv, tlsClientCAFileEnv)
+		}
+	} else if c.Server.TLS.ClientCAFile != "" || len(c.Server.TLS.AllowedClientNames) > 0 {
+		problem("server.tls.client_ca_file (%s) and server.tls.allowed_client_names (%s) require server.tls.cert_file (%s) and server.tls.key_file (%s)",
+			tlsClientCAFileEnv, tlsAllowedClientNamesEnv, tlsCertFileEnv, tlsKeyFileEnv)
+	}
+
+	if c.Server.Stream.QueueSize < 0 {
+		problem("server.stream.queue_size (%s) must be positive", streamQueueSizeEnv)
+	}
+	if c.Server.Stream.SendTimeout < 0 {
+		problem("server.stream.send_timeout (%s) must be positive", streamSendTimeoutEnv)
+	}
+	if !slices.Contains(slowConsumerPolicies, c.Server.Stream.SlowConsumerPolicy) {
+		problem("server.stream.slow_consumer_policy (%s) must be one of %v", streamSlowConsumerPolicyEnv, slowConsumerPolicies)
+	}
+	if c.Server.Stream.MaxAge < 0 {
+		problem("server.stream.max_age (%s) must be positive", streamMaxAgeEnv)
+	}
+
+	if c.Server.Keepalive.Time < 0 {
+		problem("server.keepalive.time (%s) must be positive", keepaliveTimeEnv)
+	}
+	if c.Server.Keepalive.Interval < 0 {
+		problem("server.keepalive.interval (%s) must be positive", keepaliveIntervalEnv)
+	}
+	if c.Server.Keepalive.Count < 0 {
+		problem("server.keepalive.count (%s) must be positive", keepaliveCountEnv)
+	}
+	if c.Server.Keepalive.PingTime < 0 {
+		problem("server.keepalive.ping_time (%s) must be positive", keepalivePingTimeEnv)
+	}
+	if c.Server.Keepalive.PingTimeout < 0 {
+		problem("server.keepalive.ping_timeout (%s) must be positive", keepalivePingTimeoutEnv)
+	}
+	if c.Server.Keepalive.MaxConnectionAge < 0 {
+		problem("server.keepalive.max_connection_age (%s) must not be negative", keepaliveMaxConnectionAgeEnv)
+	}
+	if c.Server.Keepalive.MaxConnectionAgeGrace < 0 {
+		problem("server.keepalive.max_connection_age_grace (%s) must not be negative", keepaliveMaxConnectionAgeGraceEnv)
+	}
+
+	if c.Server.GRPC.MaxRecvMessageSize < 0 {
+		problem("server.grpc.max_recv_message_size (%s) must be positive", grpcMaxRecvMessageSizeEnv)
+	}
+	if c.Server.GRPC.MaxSendMessageSize < 0 {
+		problem("server.grpc.max_send_message_size (%s) must be positive", grpcMaxSendMessageSizeEnv)
+	}
+	if c.Server.FullSync.ChunkSize < 0 {
+		problem("server.full_sync.chunk_size (%s) must be positive", fullSyncChunkSizeEnv)
+	}
+	if c.Server.FullSync.MaxChunkBytes < 0 {
+		problem("server.full_sync.max_chunk_bytes (%s) must be positive", fullSyncMaxChunkBytesEnv)
+	} else if c.Server.FullSync.MaxChunkBytes >= c.Server.GRPC.MaxSendMessageSize {
+		problem("server.full_sync.max_chunk_bytes (%s) must be less than server.grpc.max_send_message_size (%s)", fullSyncMaxChunkBytesEnv, grpcMaxSendMessageSizeEnv)
+	}
+
+	if c.Admin.Port == "" && c.Admin.Auth.Enabled() {
+		problem("admin.auth (%s, %s) requires admin.port (%s)", adminAuthTokensEnv, adminAuthTokensFileEnv, adminPortEnv)
+	}
+	if c.Admin.Port == "" && c.Admin.WritesEnabled {
+		problem("admin.writes_enabled (%s) requires admin.port (%s)", adminWritesEnabledEnv, adminPortEnv)
+	}
+	if c.Admin.Port != "" && c.Admin.Port == c.Server.Port {
+		problem("admin.port (%s) must be different from server.port (%s)", adminPortEnv, portEnv)
+	}
+
+	if c.Snapshot.Enabled() && (c.Snapshot.Key == "") == (c.Snapshot.KeyFile == "") {
+		problem("snapshot.file (%s) requires exactly one of snapshot.key (%s) or snapshot.key_file (%s)", snapshotFileEnv, snapshotKeyEnv, snapshotKeyFileEnv)
+	}
+	if !c.Snapshot.Enabled() && (c.Snapshot.Key != "" || c.Snapshot.KeyFile != "") {
+		problem("snapshot.key (%s) and snapshot.key_file (%s) require snapshot.file (%s)", snapshotKeyEnv, snapshotKeyFileEnv, snapshotFileEnv)
+	}
+	if c.Snapshot.Interval < 0 {
+		problem("snapshot.interval (%s) must be positive", snapshotIntervalEnv)
+	}
+
+	if c.Startup.InitialBackoff < 0 {
+		problem("startup.initial_backoff (%s) must be positive", startupInitialBackoffEnv)
+	}
+	if c.Startup.MaxBackoff < c.Startup.InitialBackoff {
+		problem("startup.max_backoff (%s) must be at least startup.initial_backoff (%s)", startupMaxBackoffEnv, startupInitialBackoffEnv)
+	}
+
+	if !slices.Contains(tracingExporters, c.Tracing.Exporter) {
+		problem("tracing.exporter (%s) must be one of %v", tracingExporterEnv, tracingExporters)
+	}
+	if !slices.Contains(tracingProtocols, c.Tracing.OTLP.Protocol) {
+		problem("tracing.otlp.protocol (%s) must be one of %v", tracingOTLPProtocolEnv, tracingProtocols)
+	}
+	if c.Tracing.SampleRatio <= 0 || c.Tracing.SampleRatio > 1 {
+		problem("tracing.sample_ratio (%s) must be greater than 0 and at most 1", tracingSampleRatioEnv)
+	}
+
+	if !slices.Contains(logLevels, c.Logging.Level) {
+		problem("logging.level (%s) must be one of %v", logLevelEnv, logLevels)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// set returns the names of the set data sources, in their default order of precedence:
+// the YAML file takes precedence over the Postgres database.
+func (d DataSources) set() []string {
+	var dataSources []string
+	if d.YAML.Filepath != "" {
+		dataSources = append(dataSources, DataSourceYAML)
+	}
+	if d.Postgres.ConnectionString != "" {
+		dataSources = append(dataSources, DataSourcePostgres)
+	}
+	return dataSources
+}
+
+// TenantDataSources returns the data sources of every served tenant, by tenant name,
+// including those of data_sources as the default tenant if they are set.
+func (c *Config) TenantDataSources() map[string]DataSources {
+	tenantDataSources := make(map[string]DataSources, len(c.Tenants)+1)
+	if len(c.DataSources.set()) > 0 {
+		tenantDataSources[DefaultTenant] = c.DataSources
+	}
+	for name, tenant := range c.Tenants {
+		tenantDataSources[name] = tenant.DataSources
+	}
+	return tenantDataSources
+}
+
+// ClientAuthEnabled returns true if client auth tokens are set for any tenant, ie. if gRPC clients must authenticate.
+func (c *Config) ClientAuthEnabled() bool {
+	if c.ClientAuth.Enabled() {
+		return true
+	}
+	for _, tenant := range c.Tenants {
+		if tenant.ClientAuth.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantClientAuth returns the bearer tokens accepted from the gRPC clients of every tenant for which they are set,
+// by tenant name. The tokens of client_auth are those of the default tenant.
+func (c *Config) TenantClientAuth() map[string]Tokens {
+	tenantClientAuth := make(map[string]Tokens, len(c.Tenants)+1)
+	if c.ClientAuth.Enabled() {
+		tenantClientAuth[DefaultTenant] = c.ClientAuth
+	}
+	for name, tenant := range c.Tenants {
+		if tenant.ClientAuth.Enabled() {
+			tenantClientAuth[name] = tenant.ClientAuth
+		}
+	}
+	return tenantClientAuth
+}
+
+// TLSEnabled returns true if the listener should serve TLS instead of plaintext h2c.
+func (c *Config) TLSEnabled() bool {
+	return c.Server.TLS.CertFile != "" || c.Server.TLS.KeyFile != ""
+}
+
+// Enabled returns true if any tokens are set, ie. if requests must authenticate using a bearer token.
+func (t Tokens) Enabled() bool {
+	return len(t.Tokens) > 0 || t.TokensFile != ""
+}
+
+// Enabled returns true if the served GatewayEndpoints are persisted to a snapshot file.
+func (s Snapshot) Enabled() bool {
+	return s.File != ""
+}
+
+// TenantFile returns the snapshot file of the tenant: the snapshot file itself for the default tenant,
+// so that its snapshot is unchanged by adding tenants, or the snapshot file suffixed with the tenant name.
+func (s Snapshot) TenantFile(tenant string) string {
+	if tenant == DefaultTenant {
+		return s.File
+	}
+	return s.File + "." + tenant
+}
+
+// isPermutation returns true if list contains each of the values exactly once, and nothing else.
+func isPermutation(list, values []string) bool {
+	if len(list) != len(values) {
+		return false
+	}
+	for i, value := range list {
+		if !slices.Contains(values, value) || slices.Contains(list[:i], value) {
+			return false
+		}
+	}
+	return true
+}
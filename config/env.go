@@ -0,0 +1,243 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file handles overriding the configuration with environment variables.
+// Each environment variable overrides a single config file field, and is ignored if empty.
+
+const (
+	// FileEnv is the path to the config file, used if the --config flag is not set.
+	FileEnv = "CONFIG_FILE"
+
+	postgresConnectionStringEnv = "POSTGRES_CONNECTION_STRING"
+	yamlFilepathEnv             = "YAML_FILEPATH"
+	dataSourcePrecedenceEnv     = "DATA_SOURCE_PRECEDENCE"
+
+	portEnv = "PORT"
+
+	streamQueueSizeEnv          = "STREAM_QUEUE_SIZE"
+	streamSendTimeoutEnv        = "STREAM_SEND_TIMEOUT"
+	streamSlowConsumerPolicyEnv = "STREAM_SLOW_CONSUMER_POLICY"
+	streamMaxAgeEnv             = "STREAM_MAX_AGE"
+
+	keepaliveTimeEnv                  = "KEEPALIVE_TIME"
+	keepaliveIntervalEnv              = "KEEPALIVE_INTERVAL"
+	keepaliveCountEnv                 = "KEEPALIVE_COUNT"
+	keepalivePingTimeEnv              = "KEEPALIVE_PING_TIME"
+	keepalivePingTimeoutEnv           = "KEEPALIVE_PING_TIMEOUT"
+	keepaliveMaxConnectionAgeEnv      = "KEEPALIVE_MAX_CONNECTION_AGE"
+	keepaliveMaxConnectionAgeGraceEnv = "KEEPALIVE_MAX_CONNECTION_AGE_GRACE"
+
+	grpcMaxRecvMessageSizeEnv = "GRPC_MAX_RECV_MESSAGE_SIZE"
+	grpcMaxSendMessageSizeEnv = "GRPC_MAX_SEND_MESSAGE_SIZE"
+	fullSyncChunkSizeEnv      = "FULL_SYNC_CHUNK_SIZE"
+	fullSyncMaxChunkBytesEnv  = "FULL_SYNC_MAX_CHUNK_BYTES"
+
+	tlsCertFileEnv           = "TLS_CERT_FILE"
+	tlsKeyFileEnv            = "TLS_KEY_FILE"
+	tlsClientCAFileEnv       = "TLS_CLIENT_CA_FILE"
+	tlsAllowedClientNamesEnv = "TLS_ALLOWED_CLIENT_NAMES"
+
+	clientAuthTokensEnv     = "CLIENT_AUTH_TOKENS"
+	clientAuthTokensFileEnv = "CLIENT_AUTH_TOKENS_FILE"
+
+	adminPortEnv           = "ADMIN_PORT"
+	adminAuthTokensEnv     = "ADMIN_AUTH_TOKENS"
+	adminAuthTokensFileEnv = "ADMIN_AUTH_TOKENS_FILE"
+	adminWritesEnabledEnv  = "ADMIN_WRITES_ENABLED"
+
+	snapshotFileEnv     = "SNAPSHOT_FILE"
+	snapshotKeyEnv      = "SNAPSHOT_KEY"
+	snapshotKeyFileEnv  = "SNAPSHOT_KEY_FILE"
+	snapshotIntervalEnv = "SNAPSHOT_INTERVAL"
+
+	startupInitialBackoffEnv = "STARTUP_INITIAL_BACKOFF"
+	startupMaxBackoffEnv     = "STARTUP_MAX_BACKOFF"
+	startupStartEmptyEnv     = "STARTUP_START_EMPTY"
+
+	tracingExporterEnv     = "TRACING_EXPORTER"
+	tracingOTLPProtocolEnv = "TRACING_OTLP_PROTOCOL"
+	tracingOTLPEndpointEnv = "TRACING_OTLP_ENDPOINT"
+	tracingSampleRatioEnv  = "TRACING_SAMPLE_RATIO"
+
+	logLevelEnv = "LOG_LEVEL"
+)
+
+// applyEnv overrides the configuration with the set environment variables.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	overrideString(&c.DataSources.Postgres.ConnectionString, getenv(postgresConnectionStringEnv))
+	overrideString(&c.DataSources.YAML.Filepath, getenv(yamlFilepathEnv))
+	overrideList(&c.DataSources.Precedence, getenv(dataSourcePrecedenceEnv))
+
+	overrideString(&c.Server.Port, getenv(portEnv))
+	overrideString(&c.Server.TLS.CertFile, getenv(tlsCertFileEnv))
+	overrideString(&c.Server.TLS.KeyFile, getenv(tlsKeyFileEnv))
+	overrideString(&c.Server.TLS.ClientCAFile, getenv(tlsClientCAFileEnv))
+	overrideList(&c.Server.TLS.AllowedClientNames, getenv(tlsAllowedClientNamesEnv))
+	if err := overrideInt(&c.Server.Stream.QueueSize, streamQueueSizeEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideDuration(&c.Server.Stream.SendTimeout, streamSendTimeoutEnv, getenv); err != nil {
+		return err
+	}
+	overrideString(&c.Server.Stream.SlowConsumerPolicy, getenv(streamSlowConsumerPolicyEnv))
+	if err := overrideDuration(&c.Server.Stream.MaxAge, streamMaxAgeEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideDuration(&c.Server.Keepalive.Time, keepaliveTimeEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideDuration(&c.Server.Keepalive.Interval, keepaliveIntervalEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideInt(&c.Server.Keepalive.Count, keepaliveCountEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideDuration(&c.Server.Keepalive.PingTime, keepalivePingTimeEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideDuration(&c.Server.Keepalive.PingTimeout, keepalivePingTimeoutEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideDuration(&c.Server.Keepalive.MaxConnectionAge, keepaliveMaxConnectionAgeEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideDuration(&c.Server.Keepalive.MaxConnectionAgeGrace, keepaliveMaxConnectionAgeGraceEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideInt(&c.Server.GRPC.MaxRecvMessageSize, grpcMaxRecvMessageSizeEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideInt(&c.Server.GRPC.MaxSendMessageSize, grpcMaxSendMessageSizeEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideInt(&c.Server.FullSync.ChunkSize, fullSyncChunkSizeEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideInt(&c.Server.FullSync.MaxChunkBytes, fullSyncMaxChunkBytesEnv, getenv); err != nil {
+		return err
+	}
+
+	overrideList(&c.ClientAuth.Tokens, getenv(clientAuthTokensEnv))
+	overrideString(&c.ClientAuth.TokensFile, getenv(clientAuthTokensFileEnv))
+
+	overrideString(&c.Admin.Port, getenv(adminPortEnv))
+	overrideList(&c.Admin.Auth.Tokens, getenv(adminAuthTokensEnv))
+	overrideString(&c.Admin.Auth.TokensFile, getenv(adminAuthTokensFileEnv))
+	if err := overrideBool(&c.Admin.WritesEnabled, adminWritesEnabledEnv, getenv); err != nil {
+		return err
+	}
+
+	overrideString(&c.Snapshot.File, getenv(snapshotFileEnv))
+	overrideString(&c.Snapshot.Key, getenv(snapshotKeyEnv))
+	overrideString(&c.Snapshot.KeyFile, getenv(snapshotKeyFileEnv))
+	if err := overrideDuration(&c.Snapshot.Interval, snapshotIntervalEnv, getenv); err != nil {
+		return err
+	}
+
+	if err := overrideDuration(&c.Startup.InitialBackoff, startupInitialBackoffEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideDuration(&c.Startup.MaxBackoff, startupMaxBackoffEnv, getenv); err != nil {
+		return err
+	}
+	if err := overrideBool(&c.Startup.StartEmpty, startupStartEmptyEnv, getenv); err != nil {
+		return err
+	}
+
+	overrideString(&c.Tracing.Exporter, getenv(tracingExporterEnv))
+	overrideString(&c.Tracing.OTLP.Protocol, getenv(tracingOTLPProtocolEnv))
+	overrideString(&c.Tracing.OTLP.Endpoint, getenv(tracingOTLPEndpointEnv))
+	if err := overrideFloat(&c.Tracing.SampleRatio, tracingSampleRatioEnv, getenv); err != nil {
+		return err
+	}
+
+	overrideString(&c.Logging.Level, getenv(logLevelEnv))
+
+	return nil
+}
+
+func overrideString(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+// overrideInt overrides the field with the integer value of the environment variable, if it is set.
+func overrideInt(field *int, env string, getenv func(string) string) error {
+	value := getenv(env)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", env, err)
+	}
+	*field = parsed
+	return nil
+}
+
+// overrideDuration overrides the field with the duration value of the environment variable, if it is set.
+func overrideDuration(field *time.Duration, env string, getenv func(string) string) error {
+	value := getenv(env)
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", env, err)
+	}
+	*field = parsed
+	return nil
+}
+
+// overrideFloat overrides the field with the floating-point value of the environment variable, if it is set.
+func overrideFloat(field *float64, env string, getenv func(string) string) error {
+	value := getenv(env)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", env, err)
+	}
+	*field = parsed
+	return nil
+}
+
+// overrideBool overrides the field with the boolean value of the environment variable, if it is set,
+// eg. "true" or "false". Setting it to "false" overrides a field which is enabled in the config file.
+func overrideBool(field *bool, env string, getenv func(string) string) error {
+	value := getenv(env)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", env, err)
+	}
+	*field = parsed
+	return nil
+}
+
+func overrideList(field *[]string, value string) {
+	if list := splitList(value); len(list) > 0 {
+		*field = list
+	}
+}
+
+// splitList splits a comma-separated environment variable value, ignoring empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/buildwithgrove/path-auth-data-server/redact"
+)
+
+// Redacted returns a copy of the configuration with all secret values redacted,
+// so that it may be printed or logged, eg. by `pads --print-config`.
+func (c Config) Redacted() Config {
+	c.DataSources.Postgres.ConnectionString = redact.ConnectionString(c.DataSources.Postgres.ConnectionString)
+	if c.Tenants != nil {
+		tenants := make(map[string]Tenant, len(c.Tenants))
+		for name, tenant := range c.Tenants {
+			tenant.DataSources.Postgres.ConnectionString = redact.ConnectionString(tenant.DataSources.Postgres.ConnectionString)
+			tenant.ClientAuth.Tokens = redactTokens(tenant.ClientAuth.Tokens)
+			tenants[name] = tenant
+		}
+		c.Tenants = tenants
+	}
+	c.ClientAuth.Tokens = redactTokens(c.ClientAuth.Tokens)
+	c.Admin.Auth.Tokens = redactTokens(c.Admin.Auth.Tokens)
+	if c.Snapshot.Key != "" {
+		c.Snapshot.Key = redact.Redacted
+	}
+	return c
+}
+
+// RedactedYAML returns the configuration with all secret values redacted, in the config file format.
+func (c Config) RedactedYAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// redactTokens returns a list of the same length as tokens, with every token redacted.
+func redactTokens(tokens []string) []string {
+	if tokens == nil {
+		return nil
+	}
+	redacted := make([]string, len(tokens))
+	for i := range redacted {
+		redacted[i] = redact.Redacted
+	}
+	return redacted
+}
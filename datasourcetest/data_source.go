@@ -0,0 +1,128 @@
+// Package datasourcetest provides an in-memory AuthDataSource and GatewayEndpoint fixtures
+// which are shared by the tests of the packages which serve or wrap data sources.
+//
+// It does not import the grpc package, so that it may also be used by the grpc package's own tests.
+package datasourcetest
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+)
+
+// Feed serves a data source's GatewayEndpoints with its updates applied, eg. a grpc.UpdateFeed.
+type Feed interface {
+	Snapshot() map[string]*proto.GatewayEndpoint
+	Subscribe() (map[string]*proto.GatewayEndpoint, <-chan *proto.AuthDataUpdate, error)
+}
+
+// DataSource is an in-memory AuthDataSource which, like the YAML and Postgres data sources,
+// sends an update for every change to its store and relies on its Feed to drop no-op updates.
+type DataSource struct {
+	gatewayEndpoints   map[string]*proto.GatewayEndpoint
+	gatewayEndpointsMu sync.Mutex
+
+	updatesCh chan *proto.AuthDataUpdate
+	feed      Feed
+
+	// notReady is set to report that the data source is not ready, eg. as if still connecting at startup.
+	notReady atomic.Bool
+}
+
+// New returns a DataSource which stores the GatewayEndpoints and serves them through the Feed
+// returned by newFeed, eg. grpc.NewUpdateFeed.
+func New[F Feed](
+	gatewayEndpoints []*proto.GatewayEndpoint,
+	newFeed func(map[string]*proto.GatewayEndpoint, <-chan *proto.AuthDataUpdate) F,
+) *DataSource {
+	dataSource := &DataSource{
+		gatewayEndpoints: make(map[string]*proto.GatewayEndpoint),
+		updatesCh:        make(chan *proto.AuthDataUpdate, 100),
+	}
+	for _, gatewayEndpoint := range gatewayEndpoints {
+		dataSource.gatewayEndpoints[gatewayEndpoint.GetEndpointId()] = gatewayEndpoint
+	}
+	dataSource.feed = newFeed(dataSource.gatewayEndpoints, dataSource.updatesCh)
+	return dataSource
+}
+
+func (d *DataSource) FetchAuthDataSync() (*proto.AuthDataResponse, error) {
+	return &proto.AuthDataResponse{Endpoints: d.feed.Snapshot()}, nil
+}
+
+func (d *DataSource) SubscribeAuthData() (*proto.AuthDataResponse, <-chan *proto.AuthDataUpdate, error) {
+	gatewayEndpoints, updatesCh, err := d.feed.Subscribe()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &proto.AuthDataResponse{Endpoints: gatewayEndpoints}, updatesCh, nil
+}
+
+func (d *DataSource) Ready() bool {
+	return !d.notReady.Load()
+}
+
+// SetReady sets whether the data source reports that it is ready.
+func (d *DataSource) SetReady(ready bool) {
+	d.notReady.Store(!ready)
+}
+
+// GatewayEndpoints returns a copy of the stored GatewayEndpoints.
+func (d *DataSource) GatewayEndpoints() map[string]*proto.GatewayEndpoint {
+	d.gatewayEndpointsMu.Lock()
+	defer d.gatewayEndpointsMu.Unlock()
+
+	return maps.Clone(d.gatewayEndpoints)
+}
+
+// Put stores the GatewayEndpoint and sends an update for it.
+func (d *DataSource) Put(gatewayEndpoint *proto.GatewayEndpoint) {
+	d.Apply(&proto.AuthDataUpdate{EndpointId: gatewayEndpoint.GetEndpointId(), GatewayEndpoint: gatewayEndpoint})
+}
+
+// Delete deletes the GatewayEndpoint and sends an update for it.
+func (d *DataSource) Delete(endpointID string) {
+	d.Apply(&proto.AuthDataUpdate{EndpointId: endpointID, Delete: true})
+}
+
+// Apply applies the update to the stored GatewayEndpoints and sends it.
+func (d *DataSource) Apply(update *proto.AuthDataUpdate) {
+	d.gatewayEndpointsMu.Lock()
+	defer d.gatewayEndpointsMu.Unlock()
+
+	if update.GetDelete() {
+		delete(d.gatewayEndpoints, update.GetEndpointId())
+	} else {
+		d.gatewayEndpoints[update.GetEndpointId()] = update.GetGatewayEndpoint()
+	}
+	d.updatesCh <- update
+}
+
+// Writer is a DataSource which records the writes made to it, eg. a Writer[grpc.EndpointWrite] is an AuthDataWriter.
+//
+// The writes are only recorded; they do not change the stored GatewayEndpoints.
+type Writer[W any] struct {
+	*DataSource
+
+	writes   []W
+	writesMu sync.Mutex
+}
+
+func (w *Writer[W]) WriteGatewayEndpoints(_ context.Context, writes []W) error {
+	w.writesMu.Lock()
+	defer w.writesMu.Unlock()
+
+	w.writes = append(w.writes, writes...)
+	return nil
+}
+
+// Writes returns the writes made so far, in order.
+func (w *Writer[W]) Writes() []W {
+	w.writesMu.Lock()
+	defer w.writesMu.Unlock()
+
+	return w.writes
+}
@@ -0,0 +1,23 @@
+package datasourcetest
+
+import (
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+)
+
+// StaticKeyEndpoint returns a GatewayEndpoint authorized by the static API key.
+func StaticKeyEndpoint(endpointID, apiKey string) *proto.GatewayEndpoint {
+	return &proto.GatewayEndpoint{
+		EndpointId: endpointID,
+		Auth: &proto.Auth{AuthType: &proto.Auth_StaticApiKey{
+			StaticApiKey: &proto.StaticAPIKey{ApiKey: apiKey},
+		}},
+	}
+}
+
+// NoAuthEndpoint returns a GatewayEndpoint which requires no authorization.
+func NoAuthEndpoint(endpointID string) *proto.GatewayEndpoint {
+	return &proto.GatewayEndpoint{
+		EndpointId: endpointID,
+		Auth:       &proto.Auth{AuthType: &proto.Auth_NoAuth{}},
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Package diff compares two sets of GatewayEndpoints field by field, eg. to preview what PEAS would
+see change before a new YAML file is applied or PADS is pointed at a new database.
+
+The values of secret fields, such as API keys and emails, are compared as they are but are only
+ever reported redacted, so that a diff may be shared or logged safely.
+*/
+package diff
+
+import (
+	"slices"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/redact"
+)
+
+// ChangeType is the type of change made to a GatewayEndpoint.
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeRemoved ChangeType = "removed"
+	ChangeChanged ChangeType = "changed"
+)
+
+// The values of the auth_type field.
+const (
+	AuthTypeStaticAPIKey = "static_api_key"
+	AuthTypeNoAuth       = "no_auth"
+)
+
+// EndpointChange is a change to a single GatewayEndpoint.
+type EndpointChange struct {
+	EndpointID string     `json:"endpoint_id"`
+	Type       ChangeType `json:"type"`
+	// Fields are the changed fields. For added and removed GatewayEndpoints, they are all of the set fields.
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is a change to a single field of a GatewayEndpoint.
+// The values of secret fields are redacted, so a changed secret may have the same From and To values.
+type FieldChange struct {
+	// Field is the name of the field, as in the YAML file format, eg. `metadata.plan_type`.
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Summary counts the changes of each type.
+type Summary struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+}
+
+// field is a single field of a GatewayEndpoint, along with how its value is redacted.
+type field struct {
+	name   string
+	value  func(*proto.GatewayEndpoint) string
+	redact func(string) string
+}
+
+// fields are the fields of a GatewayEndpoint which are compared, in the order they are reported.
+var fields = []field{
+	{name: "auth_type", value: authType},
+	{name: "auth.api_key", value: func(e *proto.GatewayEndpoint) string { return e.GetAuth().GetStaticApiKey().GetApiKey() }, redact: redact.APIKey},
+	{name: "metadata.name", value: func(e *proto.GatewayEndpoint) string { return e.GetMetadata().GetName() }},
+	{name: "metadata.account_id", value: func(e *proto.GatewayEndpoint) string { return e.GetMetadata().GetAccountId() }},
+	{name: "metadata.user_id", value: func(e *proto.GatewayEndpoint) string { return e.GetMetadata().GetUserId() }},
+	{name: "metadata.plan_type", value: func(e *proto.GatewayEndpoint) string { return e.GetMetadata().GetPlanType() }},
+	{name: "metadata.email", value: func(e *proto.GatewayEndpoint) string { return e.GetMetadata().GetEmail() }, redact: redact.Email},
+	{name: "metadata.environment", value: func(e *proto.GatewayEndpoint) string { return e.GetMetadata().GetEnvironment() }},
+}
+
+// GatewayEndpoints returns the changes from one set of GatewayEndpoints to another, ordered by endpoint ID.
+// GatewayEndpoints which are the same in both sets are not included.
+func GatewayEndpoints(from, to map[string]*proto.GatewayEndpoint) []EndpointChange {
+	endpointIDs := make([]string, 0, len(from)+len(to))
+	for endpointID := range from {
+		endpointIDs = append(endpointIDs, endpointID)
+	}
+	for endpointID := range to {
+		if _, ok := from[endpointID]; !ok {
+			endpointIDs = append(endpointIDs, endpointID)
+		}
+	}
+	slices.Sort(endpointIDs)
+
+	var changes []EndpointChange
+	for _, endpointID := range endpointIDs {
+		fromEndpoint, inFrom := from[endpointID]
+		toEndpoint, inTo := to[endpointID]
+
+		change := EndpointChange{EndpointID: endpointID, Fields: fieldChanges(fromEndpoint, toEndpoint)}
+		switch {
+		case !inFrom:
+			change.Type = ChangeAdded
+		case !inTo:
+			change.Type = ChangeRemoved
+		case len(change.Fields) > 0:
+			change.Type = ChangeChanged
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Summarize counts the changes of each type.
+func Summarize(changes []EndpointChange) Summary {
+	var summary Summary
+	for _, change := range changes {
+		switch change.Type {
+		case ChangeAdded:
+			summary.Added++
+		case ChangeRemoved:
+			summary.Removed++
+		case ChangeChanged:
+			summary.Changed++
+		}
+	}
+	return summary
+}
+
+// fieldChanges returns the redacted changes to each field. Either GatewayEndpoint may be nil,
+// in which case all of its fields are empty.
+func fieldChanges(from, to *proto.GatewayEndpoint) []FieldChange {
+	var changes []FieldChange
+	for _, field := range fields {
+		fromValue, toValue := valueOf(field, from), valueOf(field, to)
+		if fromValue == toValue {
+			continue
+		}
+		if field.redact != nil {
+			fromValue, toValue = field.redact(fromValue), field.redact(toValue)
+		}
+		changes = append(changes, FieldChange{Field: field.name, From: fromValue, To: toValue})
+	}
+	return changes
+}
+
+func valueOf(field field, endpoint *proto.GatewayEndpoint) string {
+	if endpoint == nil {
+		return ""
+	}
+	return field.value(endpoint)
+}
+
+// authType returns the auth type of the GatewayEndpoint. A GatewayEndpoint without
+// an API key is served with no auth, whether or not its auth is set.
+func authType(endpoint *proto.GatewayEndpoint) string {
+	if endpoint.GetAuth().GetStaticApiKey() != nil {
+		return AuthTypeStaticAPIKey
+	}
+	return AuthTypeNoAuth
+}
@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GatewayEndpoints(t *testing.T) {
+	staticKeyEndpoint := func(endpointID, apiKey string, metadata *proto.Metadata) *proto.GatewayEndpoint {
+		return &proto.GatewayEndpoint{
+			EndpointId: endpointID,
+			Auth: &proto.Auth{
+				AuthType: &proto.Auth_StaticApiKey{StaticApiKey: &proto.StaticAPIKey{ApiKey: apiKey}},
+			},
+			Metadata: metadata,
+		}
+	}
+	noAuthEndpoint := func(endpointID string, metadata *proto.Metadata) *proto.GatewayEndpoint {
+		return &proto.GatewayEndpoint{
+			EndpointId: endpointID,
+			Auth:       &proto.Auth{AuthType: &proto.Auth_NoAuth{NoAuth: &proto.NoAuth{}}},
+			Metadata:   metadata,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from     map[string]*proto.GatewayEndpoint
+		to       map[string]*proto.GatewayEndpoint
+		expected []EndpointChange
+	}{
+		{
+			name: "should return no changes for the same gateway endpoints",
+			from: map[string]*proto.GatewayEndpoint{
+				"endpoint_1": staticKeyEndpoint("endpoint_1", "api_key_1", &proto.Metadata{AccountId: "account_1"}),
+			},
+			to: map[string]*proto.GatewayEndpoint{
+				"endpoint_1": staticKeyEndpoint("endpoint_1", "api_key_1", &proto.Metadata{AccountId: "account_1"}),
+			},
+		},
+		{
+			name: "should treat unset auth and metadata as no auth and empty metadata",
+			from: map[string]*proto.GatewayEndpoint{
+				"endpoint_1": {EndpointId: "endpoint_1"},
+			},
+			to: map[string]*proto.GatewayEndpoint{
+				"endpoint_1": noAuthEndpoint("endpoint_1", &proto.Metadata{}),
+			},
+		},
+		{
+			name: "should return added, removed and changed gateway endpoints in order with redacted secrets",
+			from: map[string]*proto.GatewayEndpoint{
+				"endpoint_1": staticKeyEndpoint("endpoint_1", "api_key_1", &proto.Metadata{AccountId: "account_1", PlanType: "PLAN_FREE"}),
+				"endpoint_2": noAuthEndpoint("endpoint_2", &proto.Metadata{Email: "amos.burton@opa.belt"}),
+				"endpoint_4": noAuthEndpoint("endpoint_4", nil),
+			},
+			to: map[string]*proto.GatewayEndpoint{
+				"endpoint_1": staticKeyEndpoint("endpoint_1", "api_key_2", &proto.Metadata{AccountId: "account_1", PlanType: "PLAN_UNLIMITED"}),
+				"endpoint_3": staticKeyEndpoint("endpoint_3", "argon2id:m=65536,t=1,p=4:c2FsdHNhbHRzYWx0c2FsdA:aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g", &proto.Metadata{Environment: "production"}),
+				"endpoint_4": staticKeyEndpoint("endpoint_4", "api_key_4", nil),
+			},
+			expected: []EndpointChange{
+				{
+					EndpointID: "endpoint_1",
+					Type:       ChangeChanged,
+					Fields: []FieldChange{
+						{Field: "auth.api_key", From: "[REDACTED]", To: "[REDACTED]"},
+						{Field: "metadata.plan_type", From: "PLAN_FREE", To: "PLAN_UNLIMITED"},
+					},
+				},
+				{
+					EndpointID: "endpoint_2",
+					Type:       ChangeRemoved,
+					Fields: []FieldChange{
+						{Field: "auth_type", From: AuthTypeNoAuth, To: ""},
+						{Field: "metadata.email", From: "a***@opa.belt", To: ""},
+					},
+				},
+				{
+					EndpointID: "endpoint_3",
+					Type:       ChangeAdded,
+					Fields: []FieldChange{
+						{Field: "auth_type", From: "", To: AuthTypeStaticAPIKey},
+						{Field: "auth.api_key", From: "", To: "argon2id:[REDACTED]"},
+						{Field: "metadata.environment", From: "", To: "production"},
+					},
+				},
+				{
+					EndpointID: "endpoint_4",
+					Type:       ChangeChanged,
+					Fields: []FieldChange{
+						{Field: "auth_type", From: AuthTypeNoAuth, To: AuthTypeStaticAPIKey},
+						{Field: "auth.api_key", From: "", To: "[REDACTED]"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			c.Equal(test.expected, GatewayEndpoints(test.from, test.to))
+		})
+	}
+}
+
+func Test_Summarize(t *testing.T) {
+	c := require.New(t)
+
+	summary := Summarize([]EndpointChange{
+		{EndpointID: "endpoint_1", Type: ChangeAdded},
+		{EndpointID: "endpoint_2", Type: ChangeChanged},
+		{EndpointID: "endpoint_3", Type: ChangeAdded},
+	})
+	c.Equal(Summary{Added: 2, Removed: 0, Changed: 1}, summary)
+}
@@ -0,0 +1,388 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
+)
+
+// SlowConsumerPolicy is the action taken when a client falls behind the updates, ie. when its send queue is full.
+type SlowConsumerPolicy string
+
+const (
+	// SlowConsumerPolicyDisconnect disconnects the client, which then reconnects and fetches the full set of GatewayEndpoints.
+	// The queued updates are kept as pending updates, and are sent to the next client to connect.
+	SlowConsumerPolicyDisconnect SlowConsumerPolicy = "disconnect"
+	// SlowConsumerPolicyResync drops the queued updates and, once the client catches up, sends
+	// the current GatewayEndpoint, or a delete, for each endpoint ID whose updates were dropped.
+	SlowConsumerPolicyResync SlowConsumerPolicy = "resync"
+)
+
+// SlowConsumerPolicies are the supported slow consumer policies.
+var SlowConsumerPolicies = []SlowConsumerPolicy{SlowConsumerPolicyDisconnect, SlowConsumerPolicyResync}
+
+// The default StreamConfig values, used for any value which is not set.
+const (
+	DefaultStreamQueueSize          = 10_000
+	DefaultStreamSendTimeout        = 30 * time.Second
+	DefaultStreamSlowConsumerPolicy = SlowConsumerPolicyDisconnect
+)
+
+// StreamConfig configures how updates are streamed to a client.
+type StreamConfig struct {
+	// QueueSize is the number of updates which may be queued for a client before it is considered slow.
+	QueueSize int
+	// SendTimeout is how long sending a single update may take before the client is disconnected.
+	// A send which times out always disconnects the client, whatever the SlowConsumerPolicy,
+	// as no further update can be sent on the stream.
+	SendTimeout time.Duration
+	// SlowConsumerPolicy is the action taken when the client's queue is full.
+	SlowConsumerPolicy SlowConsumerPolicy
+	// MaxAge is how long a client's stream is served before it is closed with codes.Unavailable,
+	// so that the client reconnects, eg. to be rebalanced. It is jittered by up to 10%. Zero disables it.
+	MaxAge time.Duration
+	// ResendWindow is how long before a client disconnects its sent updates may have been lost,
+	// eg. on a half-open connection which took this long to detect. The endpoints of the updates sent
+	// within the window are resent to the next client to connect, with their current GatewayEndpoint
+	// or a delete. Zero disables it.
+	ResendWindow time.Duration
+}
+
+// withDefaults returns the StreamConfig with the default value of any value which is not set.
+func (c StreamConfig) withDefaults() StreamConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultStreamQueueSize
+	}
+	if c.SendTimeout <= 0 {
+		c.SendTimeout = DefaultStreamSendTimeout
+	}
+	if c.SlowConsumerPolicy == "" {
+		c.SlowConsumerPolicy = DefaultStreamSlowConsumerPolicy
+	}
+	return c
+}
+
+// clientStream sends updates to a single connected client from a dedicated goroutine, so that
+// a slow or stuck client never blocks the updates applied to the store or sent to other clients.
+//
+// Updates are queued by enqueue, which never blocks, and are sent in order by run.
+// The client is closed once it disconnects, a send fails or times out, or it falls behind
+// and the SlowConsumerPolicy is to disconnect it; undelivered then returns the updates it was not sent.
+type clientStream struct {
+	id     string
+	stream proto.GatewayEndpoints_StreamAuthDataUpdatesServer
+	config StreamConfig
+	// filter selects the GatewayEndpoints sent to the client. Updates are filtered before they are queued.
+	filter Filter
+	// store is read to resync the endpoint IDs whose updates were dropped.
+	store *endpointStore
+
+	// queue holds the updates to send, in order.
+	queue []*proto.AuthDataUpdate
+	// inflight is the update being sent, if any. It is kept until the send succeeds,
+	// so that an update is never lost if the client is closed while sending it.
+	inflight *proto.AuthDataUpdate
+	// resyncIDs holds the endpoint IDs whose updates were dropped and must be resynced.
+	// While it is not empty, updates are added to it instead of the queue, so that they are not sent out of order.
+	resyncIDs map[string]struct{}
+	// sent holds the updates sent within the ResendWindow, oldest first.
+	sent []sentUpdate
+	mu   sync.Mutex
+
+	// wake signals run that there are updates to send.
+	wake chan struct{}
+
+	closed    chan struct{}
+	closeErr  error
+	closedAt  time.Time
+	closeOnce sync.Once
+
+	logger polylog.Logger
+}
+
+// newClientStream returns a clientStream which sends updates to the stream. run must be called to start sending.
+func newClientStream(id string, stream proto.GatewayEndpoints_StreamAuthDataUpdatesServer, config StreamConfig, filter Filter, store *endpointStore, logger polylog.Logger) *clientStream {
+	return &clientStream{
+		id:        id,
+		stream:    stream,
+		config:    config,
+		filter:    filter,
+		store:     store,
+		resyncIDs: make(map[string]struct{}),
+		wake:      make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+		logger:    logger.With("client_id", id),
+	}
+}
+
+// enqueue queues the updates to be sent to the client, without blocking.
+// If the queue is full, the SlowConsumerPolicy is applied; enqueue returns true if it was applied,
+// which happens at most once for a client which is disconnected by it.
+//
+// Updates must be applied to the store before they are queued, so that a resync never sends an older version.
+func (c *clientStream) enqueue(updates ...*proto.AuthDataUpdate) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.signal()
+
+	// Once closed, the updates are only kept to be returned by undelivered, until the stream handler stores
+	// them as pending, so the SlowConsumerPolicy is never applied again to a client which was disconnected.
+	select {
+	case <-c.closed:
+		for _, update := range updates {
+			if len(c.resyncIDs) > 0 {
+				c.resyncIDs[update.GetEndpointId()] = struct{}{}
+			} else {
+				c.queue = append(c.queue, update)
+			}
+		}
+		return false
+	default:
+	}
+
+	applied := false
+	for _, update := range updates {
+		switch {
+		case len(c.resyncIDs) > 0:
+			c.resyncIDs[update.GetEndpointId()] = struct{}{}
+
+		case len(c.queue) < c.config.QueueSize:
+			c.queue = append(c.queue, update)
+
+		case c.config.SlowConsumerPolicy == SlowConsumerPolicyResync:
+			for _, queued := range c.queue {
+				c.resyncIDs[queued.GetEndpointId()] = struct{}{}
+			}
+			c.queue = nil
+			c.resyncIDs[update.GetEndpointId()] = struct{}{}
+			applied = true
+
+		default:
+			// The update is kept, so that it is returned by undelivered along with the queued updates.
+			c.queue = append(c.queue, update)
+			c.close(status.Errorf(codes.ResourceExhausted, "client fell behind by more than %d updates", c.config.QueueSize))
+			applied = true
+		}
+	}
+	return applied
+}
+
+// enqueuePending queues the updates which were pending while no client was connected, without blocking.
+// If there are more than fit in the queue, they are resynced instead, so that a backlog accumulated while
+// no client was connected never causes the client to be considered slow.
+//
+// The pending updates are not filtered, and the GatewayEndpoints they replaced are not known, so any update
+// which does not match the client's filter is sent as a delete, as the client may have the GatewayEndpoint.
+func (c *clientStream) enqueuePending(updates []*proto.AuthDataUpdate) {
+	if len(updates) <= c.config.QueueSize {
+		filtered := make([]*proto.AuthDataUpdate, 0, len(updates))
+		for _, update := range updates {
+			filteredUpdate, _ := c.filter.applyToUpdate(update, true)
+			updateTraces.Move(update, filteredUpdate)
+			filtered = append(filtered, filteredUpdate)
+		}
+		c.enqueue(filtered...)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.signal()
+
+	for _, update := range updates {
+		c.resyncIDs[update.GetEndpointId()] = struct{}{}
+	}
+}
+
+// run sends the queued updates to the client until it is closed.
+func (c *clientStream) run() {
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-c.wake:
+		}
+
+		for {
+			update, ok := c.next()
+			if !ok {
+				break
+			}
+			if err := c.send(update); err != nil {
+				c.close(err)
+				return
+			}
+			c.logger.Info().Str("endpoint_id", update.GetEndpointId()).Msg("sent update to client")
+		}
+	}
+}
+
+// next returns the next update to send and marks it as in flight, or false if there is none or the client is closed.
+//
+// Once there are no queued updates, the endpoint IDs to resync are queued as their current GatewayEndpoint
+// or a delete. The endpoint IDs and the store snapshot are taken together under the lock, and updates are
+// only queued after being applied to the store, so the snapshot includes every dropped update.
+func (c *clientStream) next() (*proto.AuthDataUpdate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.closed:
+		return nil, false
+	default:
+	}
+
+	if len(c.queue) == 0 && len(c.resyncIDs) > 0 {
+		c.queue = c.resyncUpdates(c.filter)
+		c.resyncIDs = make(map[string]struct{})
+	}
+	if len(c.queue) == 0 {
+		return nil, false
+	}
+
+	c.inflight = c.queue[0]
+	c.queue[0] = nil
+	c.queue = c.queue[1:]
+	return c.inflight, true
+}
+
+// send sends the update to the client, closing the client if the send takes longer than the SendTimeout.
+// Closing the client returns from the stream handler, which cancels the stream and unblocks the send.
+func (c *clientStream) send(update *proto.AuthDataUpdate) error {
+	timeout := time.AfterFunc(c.config.SendTimeout, func() {
+		c.close(status.Errorf(codes.DeadlineExceeded, "sending update to client took longer than %s", c.config.SendTimeout))
+	})
+	defer timeout.Stop()
+
+	span := traceSend(update)
+	defer span.End()
+
+	if err := c.stream.Send(update); err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, "failed to send update")
+		return fmt.Errorf("failed to send update for endpoint %s: %w", update.GetEndpointId(), err)
+	}
+
+	c.mu.Lock()
+	c.inflight = nil
+	c.recordSent(update)
+	c.mu.Unlock()
+	return nil
+}
+
+// traceSend traces the update's time in the pending queue, until now, and starts the span of its send,
+// which must be ended once it is sent. The spans are only recorded if the update is traced.
+func traceSend(update *proto.AuthDataUpdate) trace.Span {
+	carried, ok := updateTraces.Take(update)
+	if !ok {
+		return trace.SpanFromContext(context.Background())
+	}
+
+	ctx := carried.Context(context.Background())
+	endpointID := attribute.String("pads.endpoint_id", update.GetEndpointId())
+
+	_, queueSpan := tracing.Tracer().Start(ctx, "pads.pendingQueue", trace.WithTimestamp(carried.At), trace.WithAttributes(endpointID))
+	queueSpan.End()
+
+	_, sendSpan := tracing.Tracer().Start(ctx, "pads.stream.Send", trace.WithAttributes(endpointID))
+	return sendSpan
+}
+
+// sentUpdate is the endpoint ID of an update sent to the client, along with when it was sent.
+type sentUpdate struct {
+	endpointID string
+	sentAt     time.Time
+}
+
+// recordSent records the sent update, and forgets the updates sent before the ResendWindow.
+// It must be called with the lock held.
+func (c *clientStream) recordSent(update *proto.AuthDataUpdate) {
+	if c.config.ResendWindow <= 0 {
+		return
+	}
+
+	now := time.Now()
+	c.sent = append(c.sent, sentUpdate{endpointID: update.GetEndpointId(), sentAt: now})
+
+	expired := 0
+	for expired < len(c.sent) && now.Sub(c.sent[expired].sentAt) > c.config.ResendWindow {
+		expired++
+	}
+	c.sent = c.sent[expired:]
+}
+
+// undelivered returns the updates which were not, or may not have been, delivered to the client, in order:
+// the update being sent, the queued updates, and the current GatewayEndpoint or a delete for each endpoint ID
+// to resync or whose update was sent within the ResendWindow before the client was closed.
+// It must only be called once the client is closed.
+//
+// The updates are sent to the next client to connect, which may have another filter, so they are never filtered:
+// if the client has a filter, the current GatewayEndpoint or a delete is returned for each undelivered endpoint ID.
+func (c *clientStream) undelivered() []*proto.AuthDataUpdate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var updates []*proto.AuthDataUpdate
+	if c.inflight != nil {
+		updates = append(updates, c.inflight)
+	}
+	updates = append(updates, c.queue...)
+
+	if !c.filter.Empty() {
+		for _, update := range updates {
+			c.resyncIDs[update.GetEndpointId()] = struct{}{}
+		}
+		updates = nil
+	}
+
+	for _, sent := range c.sent {
+		if c.closedAt.Sub(sent.sentAt) <= c.config.ResendWindow {
+			c.resyncIDs[sent.endpointID] = struct{}{}
+		}
+	}
+	return append(updates, c.resyncUpdates(Filter{})...)
+}
+
+// close closes the client with the error. Only the first error is kept.
+func (c *clientStream) close(err error) {
+	c.closeOnce.Do(func() {
+		c.closeErr = err
+		c.closedAt = time.Now()
+		close(c.closed)
+	})
+}
+
+// signal wakes run without blocking, if it is not already due to wake.
+func (c *clientStream) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// resyncUpdates returns the current GatewayEndpoint, or a delete, for each endpoint ID to resync.
+// A GatewayEndpoint which does not match the filter is sent as a delete, as the client may have it.
+// It must be called with the lock held.
+func (c *clientStream) resyncUpdates(filter Filter) []*proto.AuthDataUpdate {
+	snapshot := c.store.Snapshot()
+	updates := make([]*proto.AuthDataUpdate, 0, len(c.resyncIDs))
+	for endpointID := range c.resyncIDs {
+		if gatewayEndpoint, ok := snapshot.Get(endpointID); ok && filter.Matches(gatewayEndpoint) {
+			updates = append(updates, &proto.AuthDataUpdate{EndpointId: endpointID, GatewayEndpoint: gatewayEndpoint})
+		} else {
+			updates = append(updates, &proto.AuthDataUpdate{EndpointId: endpointID, Delete: true})
+		}
+	}
+	return updates
+}
@@ -0,0 +1,267 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_clientStream_slowConsumerPolicy(t *testing.T) {
+	tests := []struct {
+		name                string
+		policy              SlowConsumerPolicy
+		expectedCode        codes.Code
+		expectedUndelivered []string
+		expectedResynced    map[string]*proto.GatewayEndpoint
+	}{
+		{
+			name:         "should disconnect a client which falls behind and keep its undelivered updates",
+			policy:       SlowConsumerPolicyDisconnect,
+			expectedCode: codes.ResourceExhausted,
+			// The update being sent, the queued updates, the update which did not fit in the queue,
+			// and the update queued once the client was disconnected.
+			expectedUndelivered: []string{"endpoint_0", "endpoint_1", "endpoint_2", "endpoint_3", "endpoint_4"},
+		},
+		{
+			name:   "should resync the current state of the dropped endpoints once the client catches up",
+			policy: SlowConsumerPolicyResync,
+			expectedResynced: map[string]*proto.GatewayEndpoint{
+				"endpoint_1": nil, // deleted after its update was dropped
+				"endpoint_2": conformanceEndpoint("endpoint_2", "api_key_2_rotated", "account_1", "PLAN_FREE"),
+				"endpoint_3": newTestGatewayEndpoint("endpoint_3"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			store := newEndpointStore(nil)
+			stream := newFakeUpdatesStream(t)
+			client := newClientStream("client_1", stream, StreamConfig{QueueSize: 2, SendTimeout: time.Minute, SlowConsumerPolicy: test.policy}, Filter{}, store, polyzero.NewLogger())
+			go client.run()
+
+			// Updates are applied to the store before they are queued, as by the gRPC server.
+			apply := func(update *proto.AuthDataUpdate) bool {
+				store.Apply(update)
+				return client.enqueue(update)
+			}
+
+			// The first update is sent, but the client does not receive it until it is released.
+			c.False(apply(newTestUpdate("endpoint_0")))
+			<-stream.sending
+
+			c.False(apply(newTestUpdate("endpoint_1")))
+			c.False(apply(newTestUpdate("endpoint_2")))
+			c.True(apply(newTestUpdate("endpoint_3")))
+
+			if test.policy == SlowConsumerPolicyDisconnect {
+				<-client.closed
+				c.Equal(test.expectedCode, status.Code(client.closeErr))
+
+				// The policy is not applied again to the disconnected client, whose updates are still kept.
+				c.False(apply(newTestUpdate("endpoint_4")))
+
+				var undelivered []string
+				for _, update := range client.undelivered() {
+					undelivered = append(undelivered, update.GetEndpointId())
+				}
+				c.Equal(test.expectedUndelivered, undelivered)
+				return
+			}
+
+			// Changes made while the client is behind are resynced rather than queued.
+			c.False(apply(&proto.AuthDataUpdate{EndpointId: "endpoint_1", Delete: true}))
+			c.False(apply(&proto.AuthDataUpdate{
+				EndpointId:      "endpoint_2",
+				GatewayEndpoint: conformanceEndpoint("endpoint_2", "api_key_2_rotated", "account_1", "PLAN_FREE"),
+			}))
+
+			close(stream.release)
+			c.Equal("endpoint_0", stream.recv(t).GetEndpointId())
+
+			resynced := make(map[string]*proto.GatewayEndpoint)
+			for range test.expectedResynced {
+				update := stream.recv(t)
+				resynced[update.GetEndpointId()] = update.GetGatewayEndpoint()
+			}
+			c.Equal(test.expectedResynced, resynced)
+
+			// Once resynced, updates are queued again.
+			c.False(apply(newTestUpdate("endpoint_4")))
+			c.Equal("endpoint_4", stream.recv(t).GetEndpointId())
+		})
+	}
+}
+
+func Test_clientStream_sendTimeout(t *testing.T) {
+	c := require.New(t)
+
+	stream := newFakeUpdatesStream(t)
+	client := newClientStream("client_1", stream, StreamConfig{QueueSize: 10, SendTimeout: 50 * time.Millisecond, SlowConsumerPolicy: SlowConsumerPolicyResync}, Filter{}, newEndpointStore(nil), polyzero.NewLogger())
+	go client.run()
+
+	client.enqueue(newTestUpdate("endpoint_1"), newTestUpdate("endpoint_2"))
+
+	// A stuck send disconnects the client whatever the slow consumer policy, keeping the update being sent.
+	select {
+	case <-client.closed:
+	case <-time.After(time.Second):
+		t.Fatal("client was not closed after the send timed out")
+	}
+	c.Equal(codes.DeadlineExceeded, status.Code(client.closeErr))
+	c.Len(client.undelivered(), 2)
+}
+
+func Test_clientStream_enqueuePending(t *testing.T) {
+	c := require.New(t)
+
+	store := newEndpointStore(newTestGatewayEndpoints(3))
+	stream := newFakeUpdatesStream(t)
+	close(stream.release)
+	client := newClientStream("client_1", stream, StreamConfig{QueueSize: 2, SendTimeout: time.Minute, SlowConsumerPolicy: SlowConsumerPolicyDisconnect}, Filter{}, store, polyzero.NewLogger())
+
+	// More pending updates than fit in the queue are resynced, rather than disconnecting the client.
+	var pending []*proto.AuthDataUpdate
+	for range 2 {
+		for i := range 3 {
+			pending = append(pending, newTestUpdate(testEndpointID(i)))
+		}
+	}
+	client.enqueuePending(pending)
+	go client.run()
+
+	received := make(map[string]*proto.GatewayEndpoint)
+	for range 3 {
+		update := stream.recv(t)
+		received[update.GetEndpointId()] = update.GetGatewayEndpoint()
+	}
+	c.Equal(store.Snapshot().Map(), received)
+
+	select {
+	case <-client.closed:
+		t.Fatal("client was closed")
+	default:
+	}
+}
+
+func Test_clientStream_resendWindow(t *testing.T) {
+	c := require.New(t)
+
+	store := newEndpointStore(newTestGatewayEndpoints(3))
+	stream := newFakeUpdatesStream(t)
+	close(stream.release)
+	client := newClientStream("client_1", stream, StreamConfig{QueueSize: 10, SendTimeout: time.Minute, ResendWindow: 100 * time.Millisecond}, Filter{}, store, polyzero.NewLogger())
+	go client.run()
+
+	client.enqueue(newTestUpdate(testEndpointID(0)))
+	stream.recv(t)
+	time.Sleep(200 * time.Millisecond)
+
+	client.enqueue(newTestUpdate(testEndpointID(1)))
+	stream.recv(t)
+	store.Apply(&proto.AuthDataUpdate{EndpointId: testEndpointID(1), Delete: true})
+
+	// The connection is found to be half-open: the update sent within the window may have been lost,
+	// so its endpoint is resent with its current state. The update sent before the window is not.
+	client.close(status.Error(codes.Canceled, "client context canceled"))
+	c.Equal([]*proto.AuthDataUpdate{{EndpointId: testEndpointID(1), Delete: true}}, client.undelivered())
+}
+
+func Test_clientStream_undeliveredFiltered(t *testing.T) {
+	c := require.New(t)
+
+	staging := filterTestEndpoint("endpoint_1", "staging", "account_1")
+	production := filterTestEndpoint("endpoint_2", "production", "account_1")
+	store := newEndpointStore(map[string]*proto.GatewayEndpoint{"endpoint_1": staging, "endpoint_2": production})
+
+	stream := newFakeUpdatesStream(t)
+	client := newClientStream("client_1", stream, StreamConfig{QueueSize: 10, SendTimeout: time.Minute}, Filter{Environments: []string{"staging"}}, store, polyzero.NewLogger())
+
+	// Pending updates are filtered as the GatewayEndpoints they replaced are not known:
+	// a GatewayEndpoint which does not match the filter is deleted, as the client may have it.
+	client.enqueuePending([]*proto.AuthDataUpdate{
+		{EndpointId: "endpoint_1", GatewayEndpoint: staging},
+		{EndpointId: "endpoint_2", GatewayEndpoint: production},
+	})
+	c.Len(client.queue, 2)
+	c.Same(staging, client.queue[0].GetGatewayEndpoint())
+	c.True(client.queue[1].GetDelete())
+
+	// The undelivered updates are returned unfiltered, for the next client to connect, which may have no filter.
+	client.close(status.Error(codes.Canceled, "client context canceled"))
+	undelivered := client.undelivered()
+	c.ElementsMatch([]string{"endpoint_1", "endpoint_2"}, []string{undelivered[0].GetEndpointId(), undelivered[1].GetEndpointId()})
+	for _, update := range undelivered {
+		c.False(update.GetDelete())
+	}
+}
+
+/* ---------------------------- Test Helpers ---------------------------- */
+
+// fakeUpdatesStream is a client update stream whose sends block until release is closed,
+// or until the test completes.
+type fakeUpdatesStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	// cancel cancels the stream's context, as when the client disconnects or its connection is closed.
+	cancel context.CancelFunc
+
+	// sending receives every update when its send starts.
+	sending chan *proto.AuthDataUpdate
+	release chan struct{}
+	sent    chan *proto.AuthDataUpdate
+}
+
+func newFakeUpdatesStream(t *testing.T) *fakeUpdatesStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return &fakeUpdatesStream{
+		ctx:     ctx,
+		cancel:  cancel,
+		sending: make(chan *proto.AuthDataUpdate, 100),
+		release: make(chan struct{}),
+		sent:    make(chan *proto.AuthDataUpdate, 100),
+	}
+}
+
+func (s *fakeUpdatesStream) Send(update *proto.AuthDataUpdate) error {
+	s.sending <- update
+	select {
+	case <-s.release:
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+	s.sent <- update
+	return nil
+}
+
+func (s *fakeUpdatesStream) Context() context.Context {
+	return s.ctx
+}
+
+// recv returns the next update sent on the stream, failing the test if none is sent in time.
+func (s *fakeUpdatesStream) recv(t *testing.T) *proto.AuthDataUpdate {
+	t.Helper()
+
+	select {
+	case update := <-s.sent:
+		return update
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for update")
+		return nil
+	}
+}
+
+func newTestUpdate(endpointID string) *proto.AuthDataUpdate {
+	return &proto.AuthDataUpdate{EndpointId: endpointID, GatewayEndpoint: newTestGatewayEndpoint(endpointID)}
+}
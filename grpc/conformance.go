@@ -0,0 +1,282 @@
+package grpc
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildwithgrove/path-auth-data-server/diff"
+)
+
+// The default timeouts of the conformance tests, used if they are not set in the ConformanceHarness.
+const (
+	defaultConformanceUpdateTimeout = 5 * time.Second
+	defaultConformanceQuietPeriod   = 500 * time.Millisecond
+)
+
+// ConformanceHarness adapts an AuthDataSource implementation to the conformance tests run by RunConformanceTests.
+//
+// Put and Delete must change the data source's underlying store, eg. by rewriting a YAML file or running
+// SQL against a database, so that the tests check the updates the data source sends for external changes.
+type ConformanceHarness struct {
+	// NewDataSource returns a new AuthDataSource whose store contains exactly the given GatewayEndpoints.
+	// It is called once for each test, so that the changes made by one test are not seen by another.
+	NewDataSource func(t *testing.T, gatewayEndpoints []*proto.GatewayEndpoint) AuthDataSource
+	// Put creates or replaces the GatewayEndpoint in the store of the last data source returned by NewDataSource.
+	Put func(t *testing.T, gatewayEndpoint *proto.GatewayEndpoint)
+	// Delete deletes the GatewayEndpoint from the store of the last data source returned by NewDataSource.
+	Delete func(t *testing.T, endpointID string)
+
+	// UpdateTimeout is how long to wait for an expected update. Defaults to 5 seconds.
+	UpdateTimeout time.Duration
+	// QuietPeriod is how long to wait for unexpected updates before concluding none were sent. Defaults to 500 milliseconds.
+	QuietPeriod time.Duration
+}
+
+// RunConformanceTests checks that an AuthDataSource implementation behaves as the gRPC server and PEAS expect:
+//   - FetchAuthDataSync and SubscribeAuthData return exactly the GatewayEndpoints in the store.
+//   - Changes made to the store while subscribing are either in the subscribed GatewayEndpoints or sent as updates, never both.
+//   - Creating, updating and deleting a GatewayEndpoint in the store sends a single matching update.
+//   - The updates for a GatewayEndpoint are sent in the order its changes were made.
+//   - Changes which leave a GatewayEndpoint unchanged send no updates.
+//
+// The GatewayEndpoints used by the tests only set the fields supported by every data source:
+// static API key or no auth, and the account ID and plan type metadata.
+//
+// eg. in a data source's tests:
+//
+//	func Test_Conformance(t *testing.T) {
+//		grpc_server.RunConformanceTests(t, grpc_server.ConformanceHarness{...})
+//	}
+func RunConformanceTests(t *testing.T, h ConformanceHarness) {
+	if h.UpdateTimeout == 0 {
+		h.UpdateTimeout = defaultConformanceUpdateTimeout
+	}
+	if h.QuietPeriod == 0 {
+		h.QuietPeriod = defaultConformanceQuietPeriod
+	}
+
+	t.Run("initial sync returns exactly the GatewayEndpoints in the store", func(t *testing.T) {
+		c := require.New(t)
+
+		dataSource := h.NewDataSource(t, conformanceEndpoints())
+
+		authData, err := dataSource.FetchAuthDataSync()
+		c.NoError(err)
+		c.Empty(diff.GatewayEndpoints(conformanceEndpointsMap(), authData.GetEndpoints()))
+
+		authData, _, err = dataSource.SubscribeAuthData()
+		c.NoError(err)
+		c.Empty(diff.GatewayEndpoints(conformanceEndpointsMap(), authData.GetEndpoints()))
+
+		_, _, err = dataSource.SubscribeAuthData()
+		c.ErrorIs(err, ErrAlreadySubscribed)
+	})
+
+	t.Run("changes made while subscribing are neither lost nor sent twice", func(t *testing.T) {
+		c := require.New(t)
+
+		dataSource := h.NewDataSource(t, conformanceEndpoints())
+
+		// The changes race with the subscription, so each may land before or after it.
+		expected := conformanceEndpointsMap()
+		changesDone := make(chan struct{})
+		go func() {
+			defer close(changesDone)
+			for i, apiKey := range []string{"api_key_1_v1", "api_key_1_v2", "api_key_1_v3"} {
+				h.Put(t, conformanceEndpoint("endpoint_1", apiKey, "account_1", "PLAN_FREE"))
+				if i == 1 {
+					h.Delete(t, "endpoint_2")
+				}
+			}
+		}()
+		expected["endpoint_1"] = conformanceEndpoint("endpoint_1", "api_key_1_v3", "account_1", "PLAN_FREE")
+		delete(expected, "endpoint_2")
+
+		authData, updatesCh, err := dataSource.SubscribeAuthData()
+		c.NoError(err)
+		<-changesDone
+
+		// Applying the updates to the subscribed GatewayEndpoints must reach the final state of the store,
+		// and every update must change them: an update already reflected in them was sent twice.
+		mirror := authData.GetEndpoints()
+		for len(diff.GatewayEndpoints(expected, mirror)) > 0 {
+			update := h.expectUpdate(t, updatesCh)
+			current, ok := mirror[update.GetEndpointId()]
+			if update.GetDelete() {
+				c.True(ok, "delete of endpoint %s which was not subscribed", update.GetEndpointId())
+				delete(mirror, update.GetEndpointId())
+				continue
+			}
+			c.False(ok && len(diff.GatewayEndpoints(conformanceMap(current), conformanceMap(update.GetGatewayEndpoint()))) == 0,
+				"update for endpoint %s which was already subscribed", update.GetEndpointId())
+			mirror[update.GetEndpointId()] = update.GetGatewayEndpoint()
+		}
+		h.expectNoUpdates(t, updatesCh)
+	})
+
+	t.Run("creating a GatewayEndpoint sends an update", func(t *testing.T) {
+		c := require.New(t)
+
+		updatesCh := subscribeConformance(t, h, conformanceEndpoints())
+
+		created := conformanceEndpoint("endpoint_3", "api_key_3", "account_3", "PLAN_FREE")
+		h.Put(t, created)
+
+		update := h.expectUpdate(t, updatesCh)
+		c.Equal("endpoint_3", update.GetEndpointId())
+		c.False(update.GetDelete())
+		requireSameGatewayEndpoint(t, created, update.GetGatewayEndpoint())
+		h.expectNoUpdates(t, updatesCh)
+	})
+
+	t.Run("updating a GatewayEndpoint sends an update", func(t *testing.T) {
+		c := require.New(t)
+
+		updatesCh := subscribeConformance(t, h, conformanceEndpoints())
+
+		updated := conformanceEndpoint("endpoint_1", "api_key_1_rotated", "account_1", "PLAN_FREE")
+		h.Put(t, updated)
+
+		update := h.expectUpdate(t, updatesCh)
+		c.Equal("endpoint_1", update.GetEndpointId())
+		c.False(update.GetDelete())
+		requireSameGatewayEndpoint(t, updated, update.GetGatewayEndpoint())
+		h.expectNoUpdates(t, updatesCh)
+	})
+
+	t.Run("deleting a GatewayEndpoint sends a delete", func(t *testing.T) {
+		c := require.New(t)
+
+		updatesCh := subscribeConformance(t, h, conformanceEndpoints())
+
+		h.Delete(t, "endpoint_2")
+
+		update := h.expectUpdate(t, updatesCh)
+		c.Equal("endpoint_2", update.GetEndpointId())
+		c.True(update.GetDelete())
+		h.expectNoUpdates(t, updatesCh)
+	})
+
+	t.Run("updates to a GatewayEndpoint are sent in the order the changes were made", func(t *testing.T) {
+		c := require.New(t)
+
+		updatesCh := subscribeConformance(t, h, conformanceEndpoints())
+
+		// A data source may skip intermediate versions, eg. if it reads the store after several
+		// changes were made, but must never send an older version after a newer one.
+		versions := []*proto.GatewayEndpoint{
+			conformanceEndpoint("endpoint_1", "api_key_1_v1", "account_1", "PLAN_FREE"),
+			conformanceEndpoint("endpoint_1", "api_key_1_v2", "account_1", "PLAN_FREE"),
+			conformanceEndpoint("endpoint_1", "", "account_1", "PLAN_FREE"),
+		}
+		for _, version := range versions {
+			h.Put(t, version)
+		}
+
+		lastVersion := -1
+		for lastVersion != len(versions)-1 {
+			update := h.expectUpdate(t, updatesCh)
+			c.Equal("endpoint_1", update.GetEndpointId())
+			c.False(update.GetDelete())
+
+			version := slices.IndexFunc(versions, func(v *proto.GatewayEndpoint) bool {
+				return len(diff.GatewayEndpoints(conformanceMap(v), conformanceMap(update.GetGatewayEndpoint()))) == 0
+			})
+			c.NotEqual(-1, version, "update does not match any version of the GatewayEndpoint")
+			c.GreaterOrEqual(version, lastVersion, "update was sent after an update for a later version")
+			lastVersion = version
+		}
+		h.expectNoUpdates(t, updatesCh)
+	})
+
+	t.Run("changes which leave a GatewayEndpoint unchanged send no updates", func(t *testing.T) {
+		updatesCh := subscribeConformance(t, h, conformanceEndpoints())
+
+		for _, gatewayEndpoint := range conformanceEndpoints() {
+			h.Put(t, gatewayEndpoint)
+		}
+
+		h.expectNoUpdates(t, updatesCh)
+	})
+}
+
+/* ---------------------------- Conformance Helpers ---------------------------- */
+
+// subscribeConformance creates a new data source and subscribes to it, as the gRPC server does.
+func subscribeConformance(t *testing.T, h ConformanceHarness, gatewayEndpoints []*proto.GatewayEndpoint) <-chan *proto.AuthDataUpdate {
+	t.Helper()
+
+	_, updatesCh, err := h.NewDataSource(t, gatewayEndpoints).SubscribeAuthData()
+	require.NoError(t, err)
+
+	return updatesCh
+}
+
+// expectUpdate returns the next update, failing the test if none is sent within the update timeout.
+func (h ConformanceHarness) expectUpdate(t *testing.T, updatesCh <-chan *proto.AuthDataUpdate) *proto.AuthDataUpdate {
+	t.Helper()
+
+	select {
+	case update := <-updatesCh:
+		return update
+	case <-time.After(h.UpdateTimeout):
+		t.Fatalf("timed out after %s waiting for an update", h.UpdateTimeout)
+		return nil
+	}
+}
+
+// expectNoUpdates fails the test if any update is sent within the quiet period.
+func (h ConformanceHarness) expectNoUpdates(t *testing.T, updatesCh <-chan *proto.AuthDataUpdate) {
+	t.Helper()
+
+	select {
+	case update := <-updatesCh:
+		t.Fatalf("unexpected update for endpoint %s (delete: %t)", update.GetEndpointId(), update.GetDelete())
+	case <-time.After(h.QuietPeriod):
+	}
+}
+
+// requireSameGatewayEndpoint compares GatewayEndpoints field by field, so that equivalent
+// representations, such as a nil or empty NoAuth, are considered the same.
+func requireSameGatewayEndpoint(t *testing.T, expected, actual *proto.GatewayEndpoint) {
+	t.Helper()
+	require.Empty(t, diff.GatewayEndpoints(conformanceMap(expected), conformanceMap(actual)))
+}
+
+// conformanceEndpoints returns the GatewayEndpoints each test's data source is created with.
+func conformanceEndpoints() []*proto.GatewayEndpoint {
+	return []*proto.GatewayEndpoint{
+		conformanceEndpoint("endpoint_1", "api_key_1", "account_1", "PLAN_FREE"),
+		conformanceEndpoint("endpoint_2", "", "account_2", "PLAN_UNLIMITED"),
+	}
+}
+
+func conformanceEndpointsMap() map[string]*proto.GatewayEndpoint {
+	gatewayEndpoints := make(map[string]*proto.GatewayEndpoint)
+	for _, gatewayEndpoint := range conformanceEndpoints() {
+		gatewayEndpoints[gatewayEndpoint.GetEndpointId()] = gatewayEndpoint
+	}
+	return gatewayEndpoints
+}
+
+func conformanceMap(gatewayEndpoint *proto.GatewayEndpoint) map[string]*proto.GatewayEndpoint {
+	return map[string]*proto.GatewayEndpoint{gatewayEndpoint.GetEndpointId(): gatewayEndpoint}
+}
+
+// conformanceEndpoint returns a GatewayEndpoint with a static API key, or no auth if the API key is empty.
+func conformanceEndpoint(endpointID, apiKey, accountID, planType string) *proto.GatewayEndpoint {
+	auth := &proto.Auth{AuthType: &proto.Auth_NoAuth{NoAuth: &proto.NoAuth{}}}
+	if apiKey != "" {
+		auth = &proto.Auth{AuthType: &proto.Auth_StaticApiKey{StaticApiKey: &proto.StaticAPIKey{ApiKey: apiKey}}}
+	}
+
+	return &proto.GatewayEndpoint{
+		EndpointId: endpointID,
+		Auth:       auth,
+		Metadata:   &proto.Metadata{AccountId: accountID, PlanType: planType},
+	}
+}
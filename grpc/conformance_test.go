@@ -0,0 +1,26 @@
+package grpc
+
+import (
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+)
+
+func Test_Conformance_MemoryDataSource(t *testing.T) {
+	var dataSource *datasourcetest.DataSource
+
+	RunConformanceTests(t, ConformanceHarness{
+		NewDataSource: func(t *testing.T, gatewayEndpoints []*proto.GatewayEndpoint) AuthDataSource {
+			dataSource = datasourcetest.New(gatewayEndpoints, NewUpdateFeed)
+			return dataSource
+		},
+		Put: func(t *testing.T, gatewayEndpoint *proto.GatewayEndpoint) {
+			dataSource.Put(gatewayEndpoint)
+		},
+		Delete: func(t *testing.T, endpointID string) {
+			dataSource.Delete(endpointID)
+		},
+	})
+}
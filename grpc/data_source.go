@@ -1,22 +1,49 @@
 package grpc
 
 import (
+	"errors"
+
 	"github.com/buildwithgrove/path-external-auth-server/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/validity"
 )
 
+// ErrAlreadySubscribed is returned by SubscribeAuthData if the data source has already been subscribed to.
+var ErrAlreadySubscribed = errors.New("data source is already subscribed to")
+
 // AuthDataSource is an interface that abstracts the data source.
 // It can be implemented by any data provider or source (e.g., YAML, Postgres).
 type AuthDataSource interface {
 
 	// FetchAuthDataSync fetches the full set of GatewayEndpoints from the data source.
-	// It is called from PADS and is used to warm up the data store from the data source.
+	// It is used for one-off reads of the data source, eg. to diff its GatewayEndpoints.
 	//
 	// eg. PADS -- requests Gateway Endpoints data --> Data Source -- responds with Gateway Endpoints --> PADS
 	FetchAuthDataSync() (*proto.AuthDataResponse, error)
 
-	// AuthDataUpdatesChan returns a channel that emits updates to the GatewayEndpoints.
-	// These updates are streamed from the data source to the gRPC server.
+	// SubscribeAuthData returns the full set of GatewayEndpoints along with a channel that emits every
+	// subsequent update to them. It is called once by PADS to warm up its data store and stream updates.
+	//
+	// The GatewayEndpoints and the channel are taken atomically: every change to the data source is
+	// either reflected in the returned GatewayEndpoints or emitted on the channel, and never both.
+	// A data source may only be subscribed to once; subsequent calls return ErrAlreadySubscribed.
 	//
 	// eg. Data Source -- data changes --> PADS -- streams updates --> Go External Authorization Server
-	AuthDataUpdatesChan() (<-chan *proto.AuthDataUpdate, error)
+	SubscribeAuthData() (*proto.AuthDataResponse, <-chan *proto.AuthDataUpdate, error)
+}
+
+// ReadinessReporter is implemented by AuthDataSources which may not be ready to serve their GatewayEndpoints
+// when subscribed to, eg. while connecting to a database which is unavailable at startup.
+//
+// Until the data source is ready, the gRPC server rejects requests with codes.Unavailable and the readiness
+// check fails, so that PEAS never syncs an incomplete set of GatewayEndpoints.
+type ReadinessReporter interface {
+	Ready() bool
+}
+
+// AuthDataExporter is implemented by AuthDataSources which can export every stored GatewayEndpoint,
+// including those outside of their validity window, along with the validity windows of all time-bounded
+// GatewayEndpoints. Unlike FetchAuthDataSync, an export may be imported into a data source without loss.
+type AuthDataExporter interface {
+	ExportAuthData() (*proto.AuthDataResponse, map[string]validity.Window, error)
 }
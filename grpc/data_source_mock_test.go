@@ -40,21 +40,6 @@ func (m *MockAuthDataSource) EXPECT() *MockAuthDataSourceMockRecorder {
 	return m.recorder
 }
 
-// AuthDataUpdatesChan mocks base method.
-func (m *MockAuthDataSource) AuthDataUpdatesChan() (<-chan *proto.AuthDataUpdate, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AuthDataUpdatesChan")
-	ret0, _ := ret[0].(<-chan *proto.AuthDataUpdate)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// AuthDataUpdatesChan indicates an expected call of AuthDataUpdatesChan.
-func (mr *MockAuthDataSourceMockRecorder) AuthDataUpdatesChan() *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthDataUpdatesChan", reflect.TypeOf((*MockAuthDataSource)(nil).AuthDataUpdatesChan))
-}
-
 // FetchAuthDataSync mocks base method.
 func (m *MockAuthDataSource) FetchAuthDataSync() (*proto.AuthDataResponse, error) {
 	m.ctrl.T.Helper()
@@ -69,3 +54,19 @@ func (mr *MockAuthDataSourceMockRecorder) FetchAuthDataSync() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchAuthDataSync", reflect.TypeOf((*MockAuthDataSource)(nil).FetchAuthDataSync))
 }
+
+// SubscribeAuthData mocks base method.
+func (m *MockAuthDataSource) SubscribeAuthData() (*proto.AuthDataResponse, <-chan *proto.AuthDataUpdate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeAuthData")
+	ret0, _ := ret[0].(*proto.AuthDataResponse)
+	ret1, _ := ret[1].(<-chan *proto.AuthDataUpdate)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// SubscribeAuthData indicates an expected call of SubscribeAuthData.
+func (mr *MockAuthDataSourceMockRecorder) SubscribeAuthData() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeAuthData", reflect.TypeOf((*MockAuthDataSource)(nil).SubscribeAuthData))
+}
@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/validity"
+)
+
+// WriteOp is the operation performed by an EndpointWrite.
+type WriteOp string
+
+const (
+	// WriteOpCreate creates a GatewayEndpoint, failing with ErrEndpointExists if it already exists.
+	WriteOpCreate WriteOp = "create"
+	// WriteOpUpdate replaces a GatewayEndpoint, failing with ErrEndpointNotFound if it does not exist.
+	WriteOpUpdate WriteOp = "update"
+	// WriteOpUpsert creates or replaces a GatewayEndpoint.
+	WriteOpUpsert WriteOp = "upsert"
+	// WriteOpDelete deletes a GatewayEndpoint, failing with ErrEndpointNotFound if it does not exist.
+	WriteOpDelete WriteOp = "delete"
+)
+
+var (
+	// ErrEndpointExists is returned when creating a GatewayEndpoint which already exists.
+	ErrEndpointExists = errors.New("gateway endpoint already exists")
+	// ErrEndpointNotFound is returned when updating or deleting a GatewayEndpoint which does not exist.
+	ErrEndpointNotFound = errors.New("gateway endpoint not found")
+	// ErrUnsupportedWrite is returned when a write cannot be stored by the data source,
+	// eg. a GatewayEndpoint with a field that the data source has no place to store.
+	ErrUnsupportedWrite = errors.New("gateway endpoint write not supported by data source")
+	// ErrDataSourceUnavailable is returned when a write cannot be made because the data source is not available,
+	// eg. while GatewayEndpoints are served from a snapshot until the data source is connected.
+	ErrDataSourceUnavailable = errors.New("data source is unavailable")
+)
+
+// EndpointWrite is a single change to a GatewayEndpoint, written to an AuthDataWriter.
+type EndpointWrite struct {
+	Op         WriteOp
+	EndpointID string
+
+	// GatewayEndpoint and Window are the written GatewayEndpoint and its optional validity window.
+	// They are not set for deletes.
+	GatewayEndpoint *proto.GatewayEndpoint
+	Window          validity.Window
+}
+
+// AuthDataWriter is implemented by AuthDataSources which support writing GatewayEndpoints,
+// eg. from the admin API.
+//
+// Written changes are not sent to the gRPC server directly; they are streamed to PEAS through
+// the data source's SubscribeAuthData updates, in the same way as changes made directly to the data source.
+//
+// eg. Admin API -- writes --> Data Source -- data changes --> PADS -- streams updates --> PEAS
+type AuthDataWriter interface {
+	// WriteGatewayEndpoints atomically applies the writes in order: either all of them are applied, or none are.
+	// All written GatewayEndpoints must have been validated by the caller.
+	WriteGatewayEndpoints(ctx context.Context, writes []EndpointWrite) error
+}
+
+// UpsertWrites returns the writes which create or replace each of the GatewayEndpoints with its optional
+// validity window, ordered by endpoint ID so that bulk writes are deterministic.
+func UpsertWrites(gatewayEndpoints map[string]*proto.GatewayEndpoint, windows map[string]validity.Window) []EndpointWrite {
+	endpointIDs := make([]string, 0, len(gatewayEndpoints))
+	for endpointID := range gatewayEndpoints {
+		endpointIDs = append(endpointIDs, endpointID)
+	}
+	slices.Sort(endpointIDs)
+
+	writes := make([]EndpointWrite, 0, len(endpointIDs))
+	for _, endpointID := range endpointIDs {
+		writes = append(writes, EndpointWrite{
+			Op:              WriteOpUpsert,
+			EndpointID:      endpointID,
+			GatewayEndpoint: gatewayEndpoints[endpointID],
+			Window:          windows[endpointID],
+		})
+	}
+	return writes
+}
@@ -0,0 +1,565 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+	"github.com/buildwithgrove/path-auth-data-server/diff"
+	"github.com/buildwithgrove/path-auth-data-server/padsproto"
+)
+
+/* ---------------------------- End-to-End Harness ---------------------------- */
+
+// e2eServer is a PADS server running in-process and served on a loopback address, so that tests drive it
+// as PEAS does over a real connection. Its gRPC server and HTTP handler are built by the same functions as
+// in main.go, but without the options main.go reads from the config file, ie. the message size limits,
+// client authentication and connection keepalive.
+//
+// An in-memory listener such as bufconn is not used, as its read deadlines race with the deadlines
+// set by the HTTP server when it hijacks a connection for h2c, intermittently closing the connection.
+type e2eServer struct {
+	dataSource *datasourcetest.DataSource
+	server     *grpcServer
+	address    string
+
+	// tenants are the servers of each tenant, which share the address.
+	tenants map[string]*e2eServer
+}
+
+// newE2EServer starts a server for a data source containing the GatewayEndpoints, served as the default tenant.
+// The server is stopped when the test completes.
+func newE2EServer(t *testing.T, gatewayEndpoints []*proto.GatewayEndpoint) *e2eServer {
+	return newE2ETenantsServer(t, map[string][]*proto.GatewayEndpoint{DefaultTenant: gatewayEndpoints})
+}
+
+// newE2ETenantsServer starts a server for multiple tenants, each with a data source containing its GatewayEndpoints.
+// The returned server is the default tenant's, if it is one of the tenants. The server is stopped when the test completes.
+func newE2ETenantsServer(t *testing.T, tenants map[string][]*proto.GatewayEndpoint) *e2eServer {
+	c := require.New(t)
+	logger := polyzero.NewLogger()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.NoError(err)
+	e2e := &e2eServer{
+		address: listener.Addr().String(),
+		tenants: make(map[string]*e2eServer, len(tenants)),
+	}
+
+	dataSources := make(map[string]AuthDataSource, len(tenants))
+	for tenant, gatewayEndpoints := range tenants {
+		dataSources[tenant] = datasourcetest.New(gatewayEndpoints, NewUpdateFeed)
+	}
+	router, err := NewTenantRouter(dataSources, StreamConfig{}, FullSyncConfig{ChunkSize: 2}, logger)
+	c.NoError(err)
+
+	for tenant, dataSource := range dataSources {
+		server, _ := router.Server(tenant)
+		e2e.tenants[tenant] = &e2eServer{dataSource: dataSource.(*datasourcetest.DataSource), server: server, address: e2e.address}
+	}
+	if defaultTenant, ok := e2e.tenants[DefaultTenant]; ok {
+		e2e.dataSource, e2e.server = defaultTenant.dataSource, defaultTenant.server
+	}
+
+	grpcServer := router.NewServer()
+
+	httpServer := &http.Server{Handler: NewHTTPHandler(grpcServer, router, &http2.Server{}, logger)}
+	go func() {
+		_ = httpServer.Serve(listener) // returns once the server is closed
+	}()
+	t.Cleanup(func() {
+		grpcServer.Stop()
+		httpServer.Close()
+	})
+
+	return e2e
+}
+
+// dial returns a generated GatewayEndpoints client connected to the server, as PEAS connects.
+// The connection is closed when the test completes, if it was not closed already.
+func (s *e2eServer) dial(t *testing.T) (proto.GatewayEndpointsClient, *grpc.ClientConn) {
+	conn, err := grpc.NewClient("passthrough:///"+s.address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return proto.NewGatewayEndpointsClient(conn), conn
+}
+
+// subscribe fetches the GatewayEndpoints and subscribes to updates, in the order PEAS does.
+func (s *e2eServer) subscribe(t *testing.T, client proto.GatewayEndpointsClient) (map[string]*proto.GatewayEndpoint, proto.GatewayEndpoints_StreamAuthDataUpdatesClient) {
+	return s.subscribeWithContext(t, context.Background(), client)
+}
+
+// subscribeWithContext subscribes as subscribe does, sending both requests with the context, eg. to set gRPC metadata.
+func (s *e2eServer) subscribeWithContext(t *testing.T, ctx context.Context, client proto.GatewayEndpointsClient) (map[string]*proto.GatewayEndpoint, proto.GatewayEndpoints_StreamAuthDataUpdatesClient) {
+	c := require.New(t)
+
+	authData, err := client.FetchAuthDataSync(ctx, &proto.AuthDataRequest{})
+	c.NoError(err)
+
+	previousClientID, _ := s.streamState()
+	stream, err := client.StreamAuthDataUpdates(ctx, &proto.AuthDataUpdatesRequest{})
+	c.NoError(err)
+
+	// The stream is only registered by the server once the server handler runs,
+	// which is not guaranteed to have happened when StreamAuthDataUpdates returns.
+	c.Eventually(func() bool {
+		clientID, active := s.streamState()
+		return active && clientID != previousClientID
+	}, 2*time.Second, 10*time.Millisecond)
+
+	return authData.GetEndpoints(), stream
+}
+
+// waitForDisconnect waits until the server has noticed that the client stream was closed.
+func (s *e2eServer) waitForDisconnect(t *testing.T) {
+	require.Eventually(t, func() bool {
+		_, active := s.streamState()
+		return !active
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// streamState returns the ID of the last client to subscribe to updates, and whether its stream is active.
+func (s *e2eServer) streamState() (string, bool) {
+	s.server.currentStreamMu.Lock()
+	defer s.server.currentStreamMu.Unlock()
+	return s.server.currentClientID, s.server.isStreamActive
+}
+
+// httpClient returns an HTTP client connected to the server over HTTP/1.1, or over HTTP/2 without TLS (h2c).
+func (s *e2eServer) httpClient(h2c bool) *http.Client {
+	if h2c {
+		return &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, address string, _ *tls.Config) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, address)
+			},
+		}}
+	}
+	return &http.Client{Transport: &http.Transport{}}
+}
+
+// recvUpdate returns the next update on the stream, failing the test if none is received in time.
+func recvUpdate(t *testing.T, stream proto.GatewayEndpoints_StreamAuthDataUpdatesClient) *proto.AuthDataUpdate {
+	t.Helper()
+
+	updateCh := make(chan *proto.AuthDataUpdate, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		update, err := stream.Recv()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		updateCh <- update
+	}()
+
+	select {
+	case update := <-updateCh:
+		return update
+	case err := <-errCh:
+		t.Fatalf("failed to receive update: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for update")
+	}
+	return nil
+}
+
+/* ---------------------------- End-to-End Tests ---------------------------- */
+
+func Test_E2E_HTTPEndpoints(t *testing.T) {
+	server := newE2EServer(t, conformanceEndpoints())
+
+	tests := []struct {
+		name           string
+		h2c            bool
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "should serve the health check over HTTP/1.1",
+			path:           HealthCheckPath,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "OK",
+		},
+		{
+			name:           "should serve the health check over h2c without routing it to the gRPC server",
+			h2c:            true,
+			path:           HealthCheckPath,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "OK",
+		},
+		{
+			name:           "should serve the readiness check over h2c",
+			h2c:            true,
+			path:           ReadinessCheckPath,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "OK",
+		},
+		{
+			name:           "should serve metrics over HTTP/1.1",
+			path:           "/metrics",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "# HELP",
+		},
+		{
+			name:           "should return not found for unknown paths over h2c",
+			h2c:            true,
+			path:           "/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			resp, err := server.httpClient(test.h2c).Get("http://" + server.address + test.path)
+			c.NoError(err)
+			defer resp.Body.Close()
+
+			if test.h2c {
+				c.Equal(2, resp.ProtoMajor)
+			}
+			c.Equal(test.expectedStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			c.NoError(err)
+			c.Contains(string(body), test.expectedBody)
+		})
+	}
+}
+
+func Test_E2E_NotReadyUntilDataSourceReady(t *testing.T) {
+	c := require.New(t)
+
+	server := newE2EServer(t, conformanceEndpoints())
+	server.dataSource.SetReady(false)
+	client, _ := server.dial(t)
+
+	// Requests are rejected, so that PEAS never syncs an incomplete set of GatewayEndpoints,
+	// but PADS is still live.
+	_, err := client.FetchAuthDataSync(context.Background(), &proto.AuthDataRequest{})
+	c.Equal(codes.Unavailable, status.Code(err))
+
+	stream, err := client.StreamAuthDataUpdates(context.Background(), &proto.AuthDataUpdatesRequest{})
+	c.NoError(err)
+	_, err = stream.Recv()
+	c.Equal(codes.Unavailable, status.Code(err))
+
+	requireStatusCode := func(path string, expectedStatus int) {
+		resp, err := server.httpClient(false).Get("http://" + server.address + path)
+		c.NoError(err)
+		resp.Body.Close()
+		c.Equal(expectedStatus, resp.StatusCode)
+	}
+	requireStatusCode(HealthCheckPath, http.StatusOK)
+	requireStatusCode(ReadinessCheckPath, http.StatusServiceUnavailable)
+
+	// Once the data source is ready, requests are served.
+	server.dataSource.SetReady(true)
+	requireStatusCode(ReadinessCheckPath, http.StatusOK)
+
+	gatewayEndpoints, _ := server.subscribe(t, client)
+	requireSameGatewayEndpoints(t, conformanceEndpointsMap(), gatewayEndpoints)
+}
+
+func Test_E2E_FetchAndStreamUpdates(t *testing.T) {
+	c := require.New(t)
+
+	server := newE2EServer(t, conformanceEndpoints())
+	client, _ := server.dial(t)
+
+	gatewayEndpoints, stream := server.subscribe(t, client)
+	requireSameGatewayEndpoints(t, conformanceEndpointsMap(), gatewayEndpoints)
+
+	created := conformanceEndpoint("endpoint_3", "api_key_3", "account_3", "PLAN_FREE")
+	server.dataSource.Put(created)
+	update := recvUpdate(t, stream)
+	c.Equal("endpoint_3", update.GetEndpointId())
+	requireSameGatewayEndpoint(t, created, update.GetGatewayEndpoint())
+
+	server.dataSource.Delete("endpoint_1")
+	update = recvUpdate(t, stream)
+	c.Equal("endpoint_1", update.GetEndpointId())
+	c.True(update.GetDelete())
+
+	// A full fetch reflects the streamed updates.
+	authData, err := client.FetchAuthDataSync(context.Background(), &proto.AuthDataRequest{})
+	c.NoError(err)
+	requireSameGatewayEndpoints(t, map[string]*proto.GatewayEndpoint{
+		"endpoint_2": conformanceEndpointsMap()["endpoint_2"],
+		"endpoint_3": created,
+	}, authData.GetEndpoints())
+}
+
+func Test_E2E_FullSync(t *testing.T) {
+	c := require.New(t)
+
+	server := newE2EServer(t, conformanceEndpoints())
+	_, conn := server.dial(t)
+	client := padsproto.NewFullSyncClient(conn)
+
+	// The GatewayEndpoints are streamed in chunks, compressed if the client compresses its request.
+	for _, callOptions := range [][]grpc.CallOption{nil, {grpc.UseCompressor(gzip.Name)}} {
+		stream, err := client.StreamFullSync(context.Background(), &padsproto.FullSyncRequest{ChunkSize: 1}, callOptions...)
+		c.NoError(err)
+
+		gatewayEndpoints, err := ReceiveFullSync(stream)
+		c.NoError(err)
+		requireSameGatewayEndpoints(t, conformanceEndpointsMap(), gatewayEndpoints)
+	}
+}
+
+func Test_E2E_FilteredSubscription(t *testing.T) {
+	c := require.New(t)
+
+	staging1 := filterTestEndpoint("endpoint_1", "staging", "account_1")
+	staging2 := filterTestEndpoint("endpoint_2", "staging", "account_2")
+	production := filterTestEndpoint("endpoint_3", "production", "account_1")
+	server := newE2EServer(t, []*proto.GatewayEndpoint{staging1, staging2, production})
+	_, conn := server.dial(t)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), FilterEnvironmentKey, "staging")
+	expected := map[string]*proto.GatewayEndpoint{"endpoint_1": staging1, "endpoint_2": staging2}
+
+	// The filter is applied to the full sync of both services.
+	gatewayEndpoints, stream := server.subscribeWithContext(t, ctx, proto.NewGatewayEndpointsClient(conn))
+	requireSameGatewayEndpoints(t, expected, gatewayEndpoints)
+
+	fullSyncStream, err := padsproto.NewFullSyncClient(conn).StreamFullSync(ctx, &padsproto.FullSyncRequest{})
+	c.NoError(err)
+	gatewayEndpoints, err = ReceiveFullSync(fullSyncStream)
+	c.NoError(err)
+	requireSameGatewayEndpoints(t, expected, gatewayEndpoints)
+
+	// Updates to GatewayEndpoints which neither match nor matched the filter are not sent.
+	server.dataSource.Put(filterTestEndpoint("endpoint_3", "production", "account_2"))
+	server.dataSource.Delete("endpoint_3")
+
+	// A GatewayEndpoint which moves into the filter is sent, and one which moves out of it is deleted.
+	movedIn := filterTestEndpoint("endpoint_4", "staging", "account_1")
+	server.dataSource.Put(movedIn)
+	update := recvUpdate(t, stream)
+	c.Equal("endpoint_4", update.GetEndpointId())
+	requireSameGatewayEndpoint(t, movedIn, update.GetGatewayEndpoint())
+
+	server.dataSource.Put(filterTestEndpoint("endpoint_1", "production", "account_1"))
+	update = recvUpdate(t, stream)
+	c.Equal("endpoint_1", update.GetEndpointId())
+	c.True(update.GetDelete())
+}
+
+func Test_E2E_PendingUpdatesDeliveredOnConnect(t *testing.T) {
+	c := require.New(t)
+
+	server := newE2EServer(t, conformanceEndpoints())
+
+	// Updates made before any client subscribes are queued by the server.
+	rotated := conformanceEndpoint("endpoint_1", "api_key_1_rotated", "account_1", "PLAN_FREE")
+	server.dataSource.Put(rotated)
+	server.dataSource.Delete("endpoint_2")
+	c.Eventually(func() bool {
+		server.server.pendingUpdatesMu.Lock()
+		defer server.server.pendingUpdatesMu.Unlock()
+		return len(server.server.pendingUpdates) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	client, _ := server.dial(t)
+	_, stream := server.subscribe(t, client)
+
+	update := recvUpdate(t, stream)
+	c.Equal("endpoint_1", update.GetEndpointId())
+	requireSameGatewayEndpoint(t, rotated, update.GetGatewayEndpoint())
+
+	update = recvUpdate(t, stream)
+	c.Equal("endpoint_2", update.GetEndpointId())
+	c.True(update.GetDelete())
+}
+
+func Test_E2E_Reconnection(t *testing.T) {
+	c := require.New(t)
+
+	server := newE2EServer(t, conformanceEndpoints())
+
+	// The first client receives updates until it disconnects.
+	client, conn := server.dial(t)
+	_, stream := server.subscribe(t, client)
+
+	server.dataSource.Put(conformanceEndpoint("endpoint_3", "api_key_3", "account_3", "PLAN_FREE"))
+	c.Equal("endpoint_3", recvUpdate(t, stream).GetEndpointId())
+
+	c.NoError(conn.Close())
+	server.waitForDisconnect(t)
+
+	// Updates made while disconnected are delivered to the reconnected client before any later update.
+	server.dataSource.Delete("endpoint_3")
+
+	reconnectedClient, _ := server.dial(t)
+	gatewayEndpoints, reconnectedStream := server.subscribe(t, reconnectedClient)
+	c.NotContains(gatewayEndpoints, "endpoint_3")
+
+	update := recvUpdate(t, reconnectedStream)
+	c.Equal("endpoint_3", update.GetEndpointId())
+	c.True(update.GetDelete())
+
+	updated := conformanceEndpoint("endpoint_2", "api_key_2", "account_2", "PLAN_UNLIMITED")
+	server.dataSource.Put(updated)
+	update = recvUpdate(t, reconnectedStream)
+	c.Equal("endpoint_2", update.GetEndpointId())
+	requireSameGatewayEndpoint(t, updated, update.GetGatewayEndpoint())
+}
+
+func Test_E2E_NewClientReplacesConnectedClient(t *testing.T) {
+	c := require.New(t)
+
+	server := newE2EServer(t, conformanceEndpoints())
+
+	firstClient, _ := server.dial(t)
+	_, firstStream := server.subscribe(t, firstClient)
+
+	// The server streams to a single client, so a new client takes over the stream.
+	secondClient, _ := server.dial(t)
+	_, secondStream := server.subscribe(t, secondClient)
+
+	// The replaced client's stream is closed, so that it reconnects rather than stay connected without updates.
+	_, err := firstStream.Recv()
+	c.Equal(codes.Unavailable, status.Code(err))
+
+	server.dataSource.Put(conformanceEndpoint("endpoint_3", "api_key_3", "account_3", "PLAN_FREE"))
+	c.Equal("endpoint_3", recvUpdate(t, secondStream).GetEndpointId())
+}
+
+func Test_E2E_ObserverStreamsAlongsideClient(t *testing.T) {
+	c := require.New(t)
+
+	server := newE2EServer(t, conformanceEndpoints())
+
+	client, _ := server.dial(t)
+	_, stream := server.subscribe(t, client)
+	clientID, _ := server.streamState()
+
+	// An observer is registered once its stream's header is received, without replacing the client.
+	observerClient, _ := server.dial(t)
+	observerCtx := metadata.AppendToOutgoingContext(context.Background(), ObserverKey, "true")
+	observerStream, err := observerClient.StreamAuthDataUpdates(observerCtx, &proto.AuthDataUpdatesRequest{})
+	c.NoError(err)
+	header, err := observerStream.Header()
+	c.NoError(err)
+	c.Equal([]string{"true"}, header.Get(ObserverKey))
+
+	currentClientID, active := server.streamState()
+	c.Equal(clientID, currentClientID)
+	c.True(active)
+
+	// Both the client and the observer are streamed every update.
+	server.dataSource.Put(conformanceEndpoint("endpoint_3", "api_key_3", "account_3", "PLAN_FREE"))
+	c.Equal("endpoint_3", recvUpdate(t, stream).GetEndpointId())
+	c.Equal("endpoint_3", recvUpdate(t, observerStream).GetEndpointId())
+
+	// Updates made while only an observer is connected are still kept for the next client.
+	client2, conn := server.dial(t)
+	_, stream = server.subscribe(t, client2)
+	c.NoError(conn.Close())
+	server.waitForDisconnect(t)
+
+	server.dataSource.Delete("endpoint_3")
+	update := recvUpdate(t, observerStream)
+	c.Equal("endpoint_3", update.GetEndpointId())
+	c.True(update.GetDelete())
+
+	reconnectedClient, _ := server.dial(t)
+	_, stream = server.subscribe(t, reconnectedClient)
+	update = recvUpdate(t, stream)
+	c.Equal("endpoint_3", update.GetEndpointId())
+	c.True(update.GetDelete())
+}
+
+func Test_E2E_MultiTenant(t *testing.T) {
+	c := require.New(t)
+
+	tenantA := []*proto.GatewayEndpoint{conformanceEndpoint("endpoint_1", "api_key_1", "account_1", "PLAN_FREE")}
+	tenantB := []*proto.GatewayEndpoint{conformanceEndpoint("endpoint_2", "api_key_2", "account_2", "PLAN_UNLIMITED")}
+	server := newE2ETenantsServer(t, map[string][]*proto.GatewayEndpoint{"gateway_a": tenantA, "gateway_b": tenantB})
+	_, conn := server.dial(t)
+	client := proto.NewGatewayEndpointsClient(conn)
+
+	ctxA := metadata.AppendToOutgoingContext(context.Background(), TenantKey, "gateway_a")
+	ctxB := metadata.AppendToOutgoingContext(context.Background(), TenantKey, "gateway_b")
+
+	// Each client is served its tenant's GatewayEndpoints, and clients of different tenants do not replace each other.
+	gatewayEndpoints, streamA := server.tenants["gateway_a"].subscribeWithContext(t, ctxA, client)
+	requireSameGatewayEndpoints(t, map[string]*proto.GatewayEndpoint{"endpoint_1": tenantA[0]}, gatewayEndpoints)
+	gatewayEndpoints, streamB := server.tenants["gateway_b"].subscribeWithContext(t, ctxB, client)
+	requireSameGatewayEndpoints(t, map[string]*proto.GatewayEndpoint{"endpoint_2": tenantB[0]}, gatewayEndpoints)
+
+	fullSyncStream, err := padsproto.NewFullSyncClient(conn).StreamFullSync(ctxB, &padsproto.FullSyncRequest{})
+	c.NoError(err)
+	gatewayEndpoints, err = ReceiveFullSync(fullSyncStream)
+	c.NoError(err)
+	requireSameGatewayEndpoints(t, map[string]*proto.GatewayEndpoint{"endpoint_2": tenantB[0]}, gatewayEndpoints)
+
+	// Updates are only streamed to the clients of the tenant whose data source changed.
+	server.tenants["gateway_b"].dataSource.Delete("endpoint_2")
+	server.tenants["gateway_a"].dataSource.Put(conformanceEndpoint("endpoint_3", "api_key_3", "account_3", "PLAN_FREE"))
+	c.Equal("endpoint_3", recvUpdate(t, streamA).GetEndpointId())
+	update := recvUpdate(t, streamB)
+	c.Equal("endpoint_2", update.GetEndpointId())
+	c.True(update.GetDelete())
+
+	// Requests without a tenant are rejected if no default tenant is served, as are requests for unknown tenants.
+	_, err = client.FetchAuthDataSync(context.Background(), &proto.AuthDataRequest{})
+	c.Equal(codes.NotFound, status.Code(err))
+	ctxUnknown := metadata.AppendToOutgoingContext(context.Background(), TenantKey, "gateway_c")
+	fullSyncStream, err = padsproto.NewFullSyncClient(conn).StreamFullSync(ctxUnknown, &padsproto.FullSyncRequest{})
+	c.NoError(err)
+	_, err = fullSyncStream.Recv()
+	c.Equal(codes.NotFound, status.Code(err))
+
+	// The readiness of each tenant is served separately, and PADS is ready once all tenants are.
+	server.tenants["gateway_b"].dataSource.SetReady(false)
+	for path, expectedStatus := range map[string]int{
+		ReadinessCheckPath:                http.StatusServiceUnavailable,
+		ReadinessCheckPath + "/gateway_a": http.StatusOK,
+		ReadinessCheckPath + "/gateway_b": http.StatusServiceUnavailable,
+		ReadinessCheckPath + "/gateway_c": http.StatusNotFound,
+	} {
+		resp, err := server.httpClient(false).Get("http://" + server.address + path)
+		c.NoError(err)
+		resp.Body.Close()
+		c.Equal(expectedStatus, resp.StatusCode, path)
+	}
+}
+
+// filterTestEndpoint returns a GatewayEndpoint in the environment and account, to test filtered subscriptions.
+func filterTestEndpoint(endpointID, environment, accountID string) *proto.GatewayEndpoint {
+	gatewayEndpoint := conformanceEndpoint(endpointID, "api_key_"+endpointID, accountID, "PLAN_FREE")
+	gatewayEndpoint.Metadata.Environment = environment
+	return gatewayEndpoint
+}
+
+// requireSameGatewayEndpoints compares sets of GatewayEndpoints field by field,
+// as GatewayEndpoints received over the wire are not the same proto messages.
+func requireSameGatewayEndpoints(t *testing.T, expected, actual map[string]*proto.GatewayEndpoint) {
+	t.Helper()
+	require.Empty(t, diff.GatewayEndpoints(expected, actual))
+}
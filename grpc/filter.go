@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// The gRPC metadata keys with which a client declares the Filter of the GatewayEndpoints it is sent.
+// Each key may be set more than once, or to a comma-separated list of values.
+const (
+	FilterEnvironmentKey = "pads-filter-environment"
+	FilterAccountIDKey   = "pads-filter-account-id"
+	FilterPlanTypeKey    = "pads-filter-plan-type"
+)
+
+// Filter selects the GatewayEndpoints sent to a client, by the environment, account ID and plan type
+// of their metadata. A GatewayEndpoint matches if it matches every set field, ie. if its metadata value
+// is one of the field's values. An empty Filter matches every GatewayEndpoint.
+type Filter struct {
+	Environments []string
+	AccountIDs   []string
+	PlanTypes    []string
+}
+
+// FilterFromContext returns the Filter declared in the gRPC metadata of the request.
+func FilterFromContext(ctx context.Context) Filter {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return Filter{
+		Environments: filterValues(md, FilterEnvironmentKey),
+		AccountIDs:   filterValues(md, FilterAccountIDKey),
+		PlanTypes:    filterValues(md, FilterPlanTypeKey),
+	}
+}
+
+// filterValues returns the values of the metadata key, splitting comma-separated values and ignoring empty ones.
+func filterValues(md metadata.MD, key string) []string {
+	var values []string
+	for _, value := range md.Get(key) {
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				values = append(values, entry)
+			}
+		}
+	}
+	return values
+}
+
+// Empty returns true if the Filter matches every GatewayEndpoint.
+func (f Filter) Empty() bool {
+	return len(f.Environments) == 0 && len(f.AccountIDs) == 0 && len(f.PlanTypes) == 0
+}
+
+// Matches returns true if the GatewayEndpoint matches every set field of the Filter.
+func (f Filter) Matches(gatewayEndpoint *proto.GatewayEndpoint) bool {
+	metadata := gatewayEndpoint.GetMetadata()
+	return matchesField(f.Environments, metadata.GetEnvironment()) &&
+		matchesField(f.AccountIDs, metadata.GetAccountId()) &&
+		matchesField(f.PlanTypes, metadata.GetPlanType())
+}
+
+// matchesField returns true if the field is not set, or if the value is one of its values.
+func matchesField(field []string, value string) bool {
+	return len(field) == 0 || slices.Contains(field, value)
+}
+
+// Apply returns the GatewayEndpoints which match the Filter.
+func (f Filter) Apply(gatewayEndpoints map[string]*proto.GatewayEndpoint) map[string]*proto.GatewayEndpoint {
+	if f.Empty() {
+		return gatewayEndpoints
+	}
+
+	filtered := make(map[string]*proto.GatewayEndpoint)
+	for endpointID, gatewayEndpoint := range gatewayEndpoints {
+		if f.Matches(gatewayEndpoint) {
+			filtered[endpointID] = gatewayEndpoint
+		}
+	}
+	return filtered
+}
+
+// applyToUpdate returns the update to send to a client with the Filter, or false if the client must not be sent it.
+//
+// previouslyMatched is true if the endpoint's GatewayEndpoint matched the Filter before the update, ie. if the client
+// may have it. An update which moves the GatewayEndpoint out of the Filter is sent as a delete, and an update to a
+// GatewayEndpoint which neither matches nor matched the Filter is not sent at all.
+func (f Filter) applyToUpdate(update *proto.AuthDataUpdate, previouslyMatched bool) (*proto.AuthDataUpdate, bool) {
+	if f.Empty() {
+		return update, true
+	}
+	if !update.GetDelete() && f.Matches(update.GetGatewayEndpoint()) {
+		return update, true
+	}
+	if previouslyMatched {
+		return &proto.AuthDataUpdate{EndpointId: update.GetEndpointId(), Delete: true}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,127 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func Test_FilterFromContext(t *testing.T) {
+	c := require.New(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		FilterEnvironmentKey, "staging",
+		FilterAccountIDKey, "account_1, account_2,",
+		FilterAccountIDKey, "account_3",
+	))
+
+	c.Equal(Filter{
+		Environments: []string{"staging"},
+		AccountIDs:   []string{"account_1", "account_2", "account_3"},
+	}, FilterFromContext(ctx))
+	c.True(FilterFromContext(context.Background()).Empty())
+}
+
+func Test_Filter_Matches(t *testing.T) {
+	gatewayEndpoint := filterTestEndpoint("endpoint_1", "staging", "account_1")
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected bool
+	}{
+		{
+			name:     "should match every gateway endpoint if the filter is empty",
+			expected: true,
+		},
+		{
+			name:     "should match gateway endpoint with one of the values of a field",
+			filter:   Filter{Environments: []string{"production", "staging"}},
+			expected: true,
+		},
+		{
+			name:     "should match gateway endpoint which matches every set field",
+			filter:   Filter{Environments: []string{"staging"}, AccountIDs: []string{"account_1"}, PlanTypes: []string{"PLAN_FREE"}},
+			expected: true,
+		},
+		{
+			name:     "should not match gateway endpoint which does not match a set field",
+			filter:   Filter{Environments: []string{"staging"}, PlanTypes: []string{"PLAN_UNLIMITED"}},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, test.filter.Matches(gatewayEndpoint))
+		})
+	}
+}
+
+func Test_Filter_applyToUpdate(t *testing.T) {
+	filter := Filter{Environments: []string{"staging"}}
+	deleteUpdate := &proto.AuthDataUpdate{EndpointId: "endpoint_1", Delete: true}
+	stagingUpdate := &proto.AuthDataUpdate{EndpointId: "endpoint_1", GatewayEndpoint: filterTestEndpoint("endpoint_1", "staging", "account_1")}
+	productionUpdate := &proto.AuthDataUpdate{EndpointId: "endpoint_1", GatewayEndpoint: filterTestEndpoint("endpoint_1", "production", "account_1")}
+
+	tests := []struct {
+		name              string
+		filter            Filter
+		update            *proto.AuthDataUpdate
+		previouslyMatched bool
+		expected          *proto.AuthDataUpdate
+	}{
+		{
+			name:     "should send every update if the filter is empty",
+			update:   productionUpdate,
+			expected: productionUpdate,
+		},
+		{
+			name:     "should send update to gateway endpoint which matches the filter",
+			filter:   filter,
+			update:   stagingUpdate,
+			expected: stagingUpdate,
+		},
+		{
+			name:              "should send delete for gateway endpoint which moves out of the filter",
+			filter:            filter,
+			update:            productionUpdate,
+			previouslyMatched: true,
+			expected:          deleteUpdate,
+		},
+		{
+			name:              "should send delete of gateway endpoint which matched the filter",
+			filter:            filter,
+			update:            deleteUpdate,
+			previouslyMatched: true,
+			expected:          deleteUpdate,
+		},
+		{
+			name:   "should not send update to gateway endpoint which neither matches nor matched the filter",
+			filter: filter,
+			update: productionUpdate,
+		},
+		{
+			name:   "should not send delete of gateway endpoint which did not match the filter",
+			filter: filter,
+			update: deleteUpdate,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			update, ok := test.filter.applyToUpdate(test.update, test.previouslyMatched)
+			c.Equal(test.expected != nil, ok)
+			if test.expected != nil {
+				c.Equal(test.expected.GetEndpointId(), update.GetEndpointId())
+				c.Equal(test.expected.GetDelete(), update.GetDelete())
+				c.Same(test.expected.GetGatewayEndpoint(), update.GetGatewayEndpoint())
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+	// Registers the gzip compressor, so that the responses to clients which send gzip-compressed requests,
+	// eg. using grpc.UseCompressor(gzip.Name), are also gzip-compressed.
+	_ "google.golang.org/grpc/encoding/gzip"
+	protobuf "google.golang.org/protobuf/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/padsproto"
+)
+
+// fullSyncServer implements the PADS-owned FullSync service
+var _ padsproto.FullSyncServer = &fullSyncServer{}
+
+const (
+	// DefaultMaxRecvMessageSize is gRPC's default max size of a received message, in bytes.
+	// Clients such as PEAS reject a larger FetchAuthDataSync response unless configured otherwise.
+	DefaultMaxRecvMessageSize = 4 << 20
+	// DefaultMaxSendMessageSize is gRPC's default max size of a sent message, in bytes.
+	DefaultMaxSendMessageSize = math.MaxInt32
+)
+
+// The default FullSyncConfig values, used for any value which is not set.
+const (
+	DefaultFullSyncChunkSize     = 1_000
+	DefaultFullSyncMaxChunkBytes = 1 << 20
+)
+
+// FullSyncConfig configures how the full set of GatewayEndpoints is chunked by StreamFullSync.
+type FullSyncConfig struct {
+	// ChunkSize is the max number of GatewayEndpoints in a chunk. Clients may request smaller chunks.
+	ChunkSize int
+	// MaxChunkBytes is the max serialized size of the GatewayEndpoints in a chunk, so that a chunk never
+	// exceeds the client's max message size. A single GatewayEndpoint larger than it is sent in its own chunk.
+	MaxChunkBytes int
+}
+
+// withDefaults returns the FullSyncConfig with the default value of any value which is not set.
+func (c FullSyncConfig) withDefaults() FullSyncConfig {
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = DefaultFullSyncChunkSize
+	}
+	if c.MaxChunkBytes <= 0 {
+		c.MaxChunkBytes = DefaultFullSyncMaxChunkBytes
+	}
+	return c
+}
+
+// fullSyncServer streams the full set of GatewayEndpoints served by the grpcServer in chunks,
+// so that a full sync is never limited by the client's max message size, unlike FetchAuthDataSync.
+type fullSyncServer struct {
+	padsproto.UnimplementedFullSyncServer
+
+	server *grpcServer
+	config FullSyncConfig
+
+	logger polylog.Logger
+}
+
+// NewFullSyncServer returns the FullSync service of the GatewayEndpoints served by the grpcServer.
+// Any FullSyncConfig value which is not set is defaulted.
+func NewFullSyncServer(server *grpcServer, config FullSyncConfig, logger polylog.Logger) *fullSyncServer {
+	return &fullSyncServer{
+		server: server,
+		config: config.withDefaults(),
+		logger: logger.With("component", "full_sync"),
+	}
+}
+
+// StreamFullSync streams the GatewayEndpoints in chunks, ordered by endpoint ID.
+// Only the GatewayEndpoints which match the Filter declared in the request's gRPC metadata are sent.
+//
+// The chunks are built from a single snapshot of the store, so they are consistent even if updates
+// are applied while they are sent. The last chunk is always sent, even if there are no GatewayEndpoints.
+func (s *fullSyncServer) StreamFullSync(req *padsproto.FullSyncRequest, stream padsproto.FullSync_StreamFullSyncServer) error {
+	if !s.server.Ready() {
+		return errNotReady
+	}
+
+	chunkSize := s.config.ChunkSize
+	if requested := int(req.GetChunkSize()); requested > 0 && requested < chunkSize {
+		chunkSize = requested
+	}
+
+	snapshot := s.server.store.Snapshot()
+	gatewayEndpoints := FilterFromContext(stream.Context()).Apply(snapshot.Map())
+
+	chunk := &padsproto.FullSyncChunk{TotalGatewayEndpoints: uint32(len(gatewayEndpoints))}
+	chunkBytes := 0
+	sendChunk := func(last bool) error {
+		chunk.Last = last
+		if err := stream.Send(chunk); err != nil {
+			return fmt.Errorf("failed to send full sync chunk %d: %w", chunk.GetIndex(), err)
+		}
+		chunk = &padsproto.FullSyncChunk{Index: chunk.GetIndex() + 1, TotalGatewayEndpoints: chunk.GetTotalGatewayEndpoints()}
+		chunkBytes = 0
+		return nil
+	}
+
+	for _, endpointID := range slices.Sorted(maps.Keys(gatewayEndpoints)) {
+		data, err := protobuf.Marshal(gatewayEndpoints[endpointID])
+		if err != nil {
+			return fmt.Errorf("failed to marshal gateway endpoint %s: %w", endpointID, err)
+		}
+
+		if len(chunk.GatewayEndpoints) > 0 && (len(chunk.GatewayEndpoints) == chunkSize || chunkBytes+len(data) > s.config.MaxChunkBytes) {
+			if err := sendChunk(false); err != nil {
+				return err
+			}
+		}
+		chunk.GatewayEndpoints = append(chunk.GatewayEndpoints, data)
+		chunkBytes += len(data)
+	}
+	if err := sendChunk(true); err != nil {
+		return err
+	}
+
+	s.logger.Info().
+		Int("num_gateway_endpoints", len(gatewayEndpoints)).
+		Int("num_chunks", int(chunk.GetIndex())).
+		Msg("streamed full sync")
+
+	return nil
+}
+
+/* ---------- Client Funcs ---------- */
+
+// ReceiveFullSync receives every chunk of a full sync from the client stream,
+// and returns the full set of GatewayEndpoints.
+func ReceiveFullSync(stream padsproto.FullSync_StreamFullSyncClient) (map[string]*proto.GatewayEndpoint, error) {
+	var gatewayEndpoints map[string]*proto.GatewayEndpoint
+
+	for index := uint32(0); ; index++ {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("full sync ended before its last chunk, after %d chunks", index)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive full sync chunk %d: %w", index, err)
+		}
+		if chunk.GetIndex() != index {
+			return nil, fmt.Errorf("received full sync chunk %d, expected chunk %d", chunk.GetIndex(), index)
+		}
+
+		if gatewayEndpoints == nil {
+			gatewayEndpoints = make(map[string]*proto.GatewayEndpoint, chunk.GetTotalGatewayEndpoints())
+		}
+		for _, data := range chunk.GetGatewayEndpoints() {
+			gatewayEndpoint := &proto.GatewayEndpoint{}
+			if err := protobuf.Unmarshal(data, gatewayEndpoint); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal gateway endpoint in full sync chunk %d: %w", index, err)
+			}
+			gatewayEndpoints[gatewayEndpoint.GetEndpointId()] = gatewayEndpoint
+		}
+
+		if chunk.GetLast() {
+			if len(gatewayEndpoints) != int(chunk.GetTotalGatewayEndpoints()) {
+				return nil, fmt.Errorf("received %d gateway endpoints in full sync, expected %d", len(gatewayEndpoints), chunk.GetTotalGatewayEndpoints())
+			}
+			return gatewayEndpoints, nil
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+	"github.com/buildwithgrove/path-auth-data-server/padsproto"
+)
+
+func Test_StreamFullSync(t *testing.T) {
+	endpointSize := protobuf.Size(newTestGatewayEndpoint(testEndpointID(0)))
+
+	tests := []struct {
+		name               string
+		numEndpoints       int
+		config             FullSyncConfig
+		requestedChunkSize uint32
+		expectedChunkLens  []int
+	}{
+		{
+			name:              "should send the gateway endpoints in chunks of the chunk size",
+			numEndpoints:      5,
+			config:            FullSyncConfig{ChunkSize: 2},
+			expectedChunkLens: []int{2, 2, 1},
+		},
+		{
+			name:               "should send smaller chunks if requested by the client",
+			numEndpoints:       5,
+			config:             FullSyncConfig{ChunkSize: 3},
+			requestedChunkSize: 1,
+			expectedChunkLens:  []int{1, 1, 1, 1, 1},
+		},
+		{
+			name:               "should not send chunks larger than the chunk size if requested by the client",
+			numEndpoints:       5,
+			config:             FullSyncConfig{ChunkSize: 3},
+			requestedChunkSize: 100,
+			expectedChunkLens:  []int{3, 2},
+		},
+		{
+			name:              "should not send chunks larger than the max chunk bytes",
+			numEndpoints:      5,
+			config:            FullSyncConfig{ChunkSize: 10, MaxChunkBytes: 2 * endpointSize},
+			expectedChunkLens: []int{2, 2, 1},
+		},
+		{
+			name:              "should send a gateway endpoint larger than the max chunk bytes in its own chunk",
+			numEndpoints:      2,
+			config:            FullSyncConfig{ChunkSize: 10, MaxChunkBytes: 1},
+			expectedChunkLens: []int{1, 1},
+		},
+		{
+			name:              "should send a single empty last chunk if there are no gateway endpoints",
+			expectedChunkLens: []int{0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			server, err := NewGRPCServer(datasourcetest.New(slices.Collect(maps.Values(newTestGatewayEndpoints(test.numEndpoints))), NewUpdateFeed), StreamConfig{}, polyzero.NewLogger())
+			c.NoError(err)
+			fullSyncServer := NewFullSyncServer(server, test.config, polyzero.NewLogger())
+
+			stream := &fakeFullSyncStream{ctx: context.Background()}
+			c.NoError(fullSyncServer.StreamFullSync(&padsproto.FullSyncRequest{ChunkSize: test.requestedChunkSize}, stream))
+
+			c.Len(stream.chunks, len(test.expectedChunkLens))
+			for i, chunk := range stream.chunks {
+				c.Equal(uint32(i), chunk.GetIndex())
+				c.Equal(uint32(test.numEndpoints), chunk.GetTotalGatewayEndpoints())
+				c.Len(chunk.GetGatewayEndpoints(), test.expectedChunkLens[i])
+				c.Equal(i == len(stream.chunks)-1, chunk.GetLast())
+			}
+
+			// The chunks are received as the full set of GatewayEndpoints.
+			gatewayEndpoints, err := ReceiveFullSync(&fakeFullSyncClientStream{chunks: stream.chunks})
+			c.NoError(err)
+			requireSameGatewayEndpoints(t, newTestGatewayEndpoints(test.numEndpoints), gatewayEndpoints)
+		})
+	}
+}
+
+func Test_StreamFullSync_notReady(t *testing.T) {
+	c := require.New(t)
+
+	dataSource := datasourcetest.New([]*proto.GatewayEndpoint{newTestGatewayEndpoint(testEndpointID(0))}, NewUpdateFeed)
+	dataSource.SetReady(false)
+	server, err := NewGRPCServer(dataSource, StreamConfig{}, polyzero.NewLogger())
+	c.NoError(err)
+
+	stream := &fakeFullSyncStream{ctx: context.Background()}
+	err = NewFullSyncServer(server, FullSyncConfig{}, polyzero.NewLogger()).StreamFullSync(&padsproto.FullSyncRequest{}, stream)
+	c.Equal(codes.Unavailable, status.Code(err))
+	c.Empty(stream.chunks)
+}
+
+func Test_ReceiveFullSync_invalidChunks(t *testing.T) {
+	chunk := func(index uint32, total uint32, last bool, endpointIDs ...string) *padsproto.FullSyncChunk {
+		fullSyncChunk := &padsproto.FullSyncChunk{Index: index, TotalGatewayEndpoints: total, Last: last}
+		for _, endpointID := range endpointIDs {
+			data, err := protobuf.Marshal(newTestGatewayEndpoint(endpointID))
+			require.NoError(t, err)
+			fullSyncChunk.GatewayEndpoints = append(fullSyncChunk.GatewayEndpoints, data)
+		}
+		return fullSyncChunk
+	}
+
+	tests := []struct {
+		name   string
+		chunks []*padsproto.FullSyncChunk
+	}{
+		{
+			name:   "should reject full sync which ends before its last chunk",
+			chunks: []*padsproto.FullSyncChunk{chunk(0, 2, false, "endpoint_1")},
+		},
+		{
+			name:   "should reject chunks received out of order",
+			chunks: []*padsproto.FullSyncChunk{chunk(0, 2, false, "endpoint_1"), chunk(2, 2, true, "endpoint_2")},
+		},
+		{
+			name:   "should reject full sync missing gateway endpoints",
+			chunks: []*padsproto.FullSyncChunk{chunk(0, 3, false, "endpoint_1"), chunk(1, 3, true, "endpoint_2")},
+		},
+		{
+			name:   "should reject chunk containing an invalid gateway endpoint",
+			chunks: []*padsproto.FullSyncChunk{{TotalGatewayEndpoints: 1, GatewayEndpoints: [][]byte{{0xff}}, Last: true}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReceiveFullSync(&fakeFullSyncClientStream{chunks: test.chunks})
+			require.Error(t, err)
+		})
+	}
+}
+
+/* ---------------------------- Test Helpers ---------------------------- */
+
+// fakeFullSyncStream records the chunks sent by StreamFullSync.
+type fakeFullSyncStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	chunks []*padsproto.FullSyncChunk
+}
+
+func (s *fakeFullSyncStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeFullSyncStream) Send(chunk *padsproto.FullSyncChunk) error {
+	s.chunks = append(s.chunks, chunk)
+	return nil
+}
+
+// fakeFullSyncClientStream returns the chunks, then io.EOF.
+type fakeFullSyncClientStream struct {
+	grpc.ClientStream
+	chunks []*padsproto.FullSyncChunk
+}
+
+func (s *fakeFullSyncClientStream) Recv() (*padsproto.FullSyncChunk, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
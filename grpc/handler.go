@@ -0,0 +1,90 @@
+package grpc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pokt-network/poktroll/pkg/polylog"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/buildwithgrove/path-auth-data-server/keepalive"
+	"github.com/buildwithgrove/path-auth-data-server/metrics"
+)
+
+const (
+	// HealthCheckPath is the HTTP path of the health check served alongside the gRPC server.
+	// It succeeds as long as PADS is running, so that it may be used as a liveness probe.
+	HealthCheckPath = "/healthz"
+	// ReadinessCheckPath is the HTTP path of the readiness check served alongside the gRPC server.
+	// It fails until the data source is ready, eg. while PADS is still connecting to it at startup.
+	// If multiple tenants are served, it fails until the data sources of all tenants are ready,
+	// and the readiness of a single tenant is served on ReadinessCheckPath + "/" + tenant.
+	ReadinessCheckPath = "/readyz"
+)
+
+// Readiness reports whether the GatewayEndpoints of every tenant, or of a single tenant, may be sent to clients.
+type Readiness interface {
+	Ready() bool
+	// TenantReady returns false for ok if the tenant is not served.
+	TenantReady(tenant string) (ready bool, ok bool)
+}
+
+// NewHTTPHandler returns an HTTP handler that serves both gRPC requests (for Gateway Endpoints),
+// using the provided gRPC server, and HTTP requests for the health and readiness checks and metrics.
+// The readiness checks succeed once the Readiness reports that every tenant, or the checked tenant, is ready.
+//
+// Requests are served over HTTP/2 without TLS (h2c), so that PEAS may connect without TLS,
+// as well as over HTTP/1.1 or HTTP/2 with TLS if the HTTP server is configured to serve TLS.
+// The h2c connections are served by the HTTP/2 server, eg. so that they are pinged (see keepalive.Config.HTTP2Server).
+//
+// Once a connection reaches its max age (see keepalive.ConnectionDeadline), the responses to its requests
+// close it, so that the client opens a new connection.
+func NewHTTPHandler(grpcServer http.Handler, readiness Readiness, http2Server *http2.Server, logger polylog.Logger) http.Handler {
+	// create a new HTTP server mux for health check on `/healthz`, readiness checks on `/readyz` and `/readyz/{tenant}` and metrics on `/metrics`
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthCheckPath, func(w http.ResponseWriter, r *http.Request) {
+		writeCheckResponse(w, http.StatusOK, "OK", logger)
+	})
+	mux.HandleFunc(ReadinessCheckPath, func(w http.ResponseWriter, r *http.Request) {
+		if !readiness.Ready() {
+			writeCheckResponse(w, http.StatusServiceUnavailable, "NOT READY", logger)
+			return
+		}
+		writeCheckResponse(w, http.StatusOK, "OK", logger)
+	})
+	mux.HandleFunc(ReadinessCheckPath+"/{tenant}", func(w http.ResponseWriter, r *http.Request) {
+		ready, ok := readiness.TenantReady(r.PathValue("tenant"))
+		switch {
+		case !ok:
+			writeCheckResponse(w, http.StatusNotFound, "UNKNOWN TENANT", logger)
+		case !ready:
+			writeCheckResponse(w, http.StatusServiceUnavailable, "NOT READY", logger)
+		default:
+			writeCheckResponse(w, http.StatusOK, "OK", logger)
+		}
+	})
+	mux.Handle(metrics.Path, metrics.Handler())
+
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The HTTP/2 server sends a GOAWAY for a Connection: close response header, and closes the connection
+		// once its streams are done.
+		if deadline, ok := keepalive.ConnectionDeadline(r.Context()); ok && !time.Now().Before(deadline) {
+			w.Header().Set("Connection", "close")
+		}
+
+		if IsRequestGRPC(r) {
+			grpcServer.ServeHTTP(w, r)
+		} else {
+			mux.ServeHTTP(w, r)
+		}
+	}), http2Server)
+}
+
+// writeCheckResponse writes the response of the health or readiness check.
+func writeCheckResponse(w http.ResponseWriter, statusCode int, body string, logger polylog.Logger) {
+	w.WriteHeader(statusCode)
+	if _, err := w.Write([]byte(body)); err != nil {
+		logger.Error().Err(err).Msg("failed to write health check response")
+	}
+}
@@ -3,6 +3,7 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"iter"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -10,10 +11,37 @@ import (
 
 	"github.com/buildwithgrove/path-external-auth-server/proto"
 	"github.com/pokt-network/poktroll/pkg/polylog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+
+	"github.com/buildwithgrove/path-auth-data-server/keepalive"
+	"github.com/buildwithgrove/path-auth-data-server/metrics"
+	"github.com/buildwithgrove/path-auth-data-server/redact"
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
 )
 
+// errNotReady is returned to gRPC requests until the data source is ready.
+var errNotReady = status.Error(codes.Unavailable, "PADS is not ready: still connecting to the data source")
+
+// ObserverKey is the gRPC metadata key with which a client of StreamAuthDataUpdates declares itself an observer,
+// eg. `pads watch`, which is streamed the updates alongside the current client rather than replacing it.
+// Once an observer is registered, the stream's header is sent with the same key.
+const ObserverKey = "pads-observer"
+
+// isObserver returns true if the client declared itself an observer in the gRPC metadata of the request.
+func isObserver(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(ObserverKey)
+	return len(values) > 0 && values[0] == "true"
+}
+
+// updateTraces carries the span of each update applied by handleDataSourceUpdates until the update is sent,
+// so that its time in the pending queue and its send to the client are traced.
+var updateTraces = tracing.NewCarrier[*proto.AuthDataUpdate](0)
+
 // Client ID counter for generating unique client IDs
 var clientIDCounter uint64
 
@@ -32,6 +60,14 @@ func generateUniqueClientID() string {
 // 2. When updates occur with no active client, they're stored in a pending updates queue
 // 3. When a client reconnects, all pending updates are sent immediately
 // 4. If the client disconnects during sending, the server marks the stream as inactive and stores further updates
+// 5. If a new client connects while a client is active, the active client is closed with codes.Unavailable,
+// and the updates it was not sent are sent to the new client
+//
+// Observers, eg. `pads watch`, are streamed the updates alongside the current client, without replacing it.
+//
+// Updates are sent to the client from a dedicated goroutine with a bounded queue (see clientStream),
+// so that a slow or stuck client never blocks the updates from the data source. A client which falls
+// behind is handled according to the StreamConfig's SlowConsumerPolicy.
 //
 // TODO_IMPROVE(@commoddity): Update this link to point to main once `envoy-grpc-auth-service` is merged.
 // See: https://github.com/buildwithgrove/path/blob/envoy-grpc-auth-service/envoy/auth_server/proto/gateway_endpoint.proto
@@ -41,46 +77,49 @@ type grpcServer struct {
 	authDataSource   AuthDataSource
 	authDataUpdateCh chan *proto.AuthDataUpdate
 
-	gatewayEndpoints   map[string]*proto.GatewayEndpoint
-	gatewayEndpointsMu sync.RWMutex
+	// store holds the served GatewayEndpoints as immutable snapshots, so that
+	// they may be read and serialized while updates are applied.
+	store *endpointStore
+
+	// streamConfig configures how updates are streamed to the client.
+	streamConfig StreamConfig
 
 	// For a single client connection
-	currentClientStream proto.GatewayEndpoints_StreamAuthDataUpdatesServer
-	currentClientID     string
-	currentStreamCtx    context.Context
-	currentStreamMu     sync.Mutex
-	pendingUpdates      []*proto.AuthDataUpdate
-	pendingUpdatesMu    sync.Mutex
-	isStreamActive      bool
+	currentClient    *clientStream
+	currentClientID  string
+	currentStreamMu  sync.Mutex
+	pendingUpdates   []*proto.AuthDataUpdate
+	pendingUpdatesMu sync.Mutex
+	isStreamActive   bool
+
+	// observers are streamed every update alongside the current client. They are guarded by currentStreamMu.
+	observers map[*clientStream]struct{}
 
 	logger polylog.Logger
 }
 
 // NewGRPCServer creates a new grpcServer instance using the provided AuthDataSource.
-func NewGRPCServer(authDataSource AuthDataSource, logger polylog.Logger) (*grpcServer, error) {
+// Any StreamConfig value which is not set is defaulted.
+func NewGRPCServer(authDataSource AuthDataSource, streamConfig StreamConfig, logger polylog.Logger) (*grpcServer, error) {
 
 	server := &grpcServer{
 		authDataSource:   authDataSource,
 		authDataUpdateCh: make(chan *proto.AuthDataUpdate, 1_000),
+		streamConfig:     streamConfig.withDefaults(),
 
-		gatewayEndpoints: make(map[string]*proto.GatewayEndpoint),
-		pendingUpdates:   make([]*proto.AuthDataUpdate, 0, 100),
+		pendingUpdates: make([]*proto.AuthDataUpdate, 0, 100),
+		observers:      make(map[*clientStream]struct{}),
 
 		logger: logger,
 	}
 
-	// Warm up the data store with the full set of GatewayEndpoints from the data source.
-	authDataResponse, err := authDataSource.FetchAuthDataSync()
-	if err != nil {
-		return nil, err
-	}
-	server.gatewayEndpoints = authDataResponse.Endpoints
-
-	// Start listening for updates from the data source.
-	authDataUpdatesCh, err := authDataSource.AuthDataUpdatesChan()
+	// Warm up the data store with the full set of GatewayEndpoints from the data source,
+	// and start listening for every subsequent update to them.
+	authDataResponse, authDataUpdatesCh, err := authDataSource.SubscribeAuthData()
 	if err != nil {
 		return nil, err
 	}
+	server.store = newEndpointStore(authDataResponse.Endpoints)
 	go server.handleDataSourceUpdates(authDataUpdatesCh)
 
 	return server, nil
@@ -88,83 +127,218 @@ func NewGRPCServer(authDataSource AuthDataSource, logger polylog.Logger) (*grpcS
 
 // FetchAuthDataSync handles the gRPC request to retrieve the full set of GatewayEndpoints data.
 // This method is called from PADS to warm up the data store on startup.
+//
+// The response is built from a single snapshot of the store, so it is consistent even if
+// updates are applied while it is serialized.
 func (s *grpcServer) FetchAuthDataSync(ctx context.Context, req *proto.AuthDataRequest) (*proto.AuthDataResponse, error) {
-	s.gatewayEndpointsMu.RLock()
-	defer s.gatewayEndpointsMu.RUnlock()
+	if !s.Ready() {
+		return nil, errNotReady
+	}
+
+	snapshot := s.store.Snapshot()
+	gatewayEndpoints := FilterFromContext(ctx).Apply(snapshot.Map())
+
+	s.logger.Info().Int("num_gateway_endpoints", len(gatewayEndpoints)).Msg("fetching auth data sync")
 
-	s.logger.Info().Int("num_gateway_endpoints", len(s.gatewayEndpoints)).Msg("fetching auth data sync")
+	return &proto.AuthDataResponse{Endpoints: gatewayEndpoints}, nil
+}
+
+// GatewayEndpoints returns a copy of the set of GatewayEndpoints currently served,
+// along with the revision of the data store, which is incremented for every applied update.
+// It is used to inspect the served GatewayEndpoints, eg. from the admin API.
+func (s *grpcServer) GatewayEndpoints() (map[string]*proto.GatewayEndpoint, uint64) {
+	snapshot := s.store.Snapshot()
+	return snapshot.Map(), snapshot.Revision()
+}
+
+// Get returns the GatewayEndpoint currently served with the endpoint ID, if any, without copying the set.
+func (s *grpcServer) Get(endpointID string) (*proto.GatewayEndpoint, bool) {
+	return s.store.Snapshot().Get(endpointID)
+}
 
-	return &proto.AuthDataResponse{Endpoints: s.gatewayEndpoints}, nil
+// Len returns the number of GatewayEndpoints currently served.
+func (s *grpcServer) Len() int {
+	return s.store.Snapshot().Len()
+}
+
+// Revision returns the current revision of the data store.
+func (s *grpcServer) Revision() uint64 {
+	return s.store.Snapshot().Revision()
+}
+
+// All returns an iterator over the GatewayEndpoints currently served, which reads a single snapshot
+// of the data store without copying it.
+func (s *grpcServer) All() iter.Seq2[string, *proto.GatewayEndpoint] {
+	return s.store.Snapshot().All()
+}
+
+// Ready returns true if the served GatewayEndpoints may be sent to clients, ie. unless the data source
+// implements ReadinessReporter and is not ready. Until then, gRPC requests are rejected with codes.Unavailable.
+func (s *grpcServer) Ready() bool {
+	if readinessReporter, ok := s.authDataSource.(ReadinessReporter); ok {
+		return readinessReporter.Ready()
+	}
+	return true
+}
+
+// clientConnected returns true if a client is currently streaming updates.
+func (s *grpcServer) clientConnected() bool {
+	s.currentStreamMu.Lock()
+	defer s.currentStreamMu.Unlock()
+	return s.isStreamActive
 }
 
 // StreamAuthDataUpdates streams GatewayEndpoint updates to PATH's
 // Go External Authorization Server whenever the data source changes.
 // It uses gRPC streaming to send updates to PATH's External Authorization Server.
 func (s *grpcServer) StreamAuthDataUpdates(req *proto.AuthDataUpdatesRequest, stream proto.GatewayEndpoints_StreamAuthDataUpdatesServer) error {
-	// Since we only have one client, we need to handle when a new client connects
-	// while the previous one is still "active" (from our perspective)
+	if !s.Ready() {
+		return errNotReady
+	}
+
+	filter := FilterFromContext(stream.Context())
+	if isObserver(stream.Context()) {
+		return s.streamToObserver(stream, filter)
+	}
+
+	// Since we only have one client, a new client replaces the client which is still active, eg. the same
+	// client reconnecting over a new connection. The replaced client is closed so that it does not stay
+	// connected without updates, and the updates it was not sent are sent to the new client instead.
 	clientID := generateUniqueClientID()
+	client := newClientStream(clientID, stream, s.streamConfig, filter, s.store, s.logger)
 
 	// Set the current stream and client ID
 	s.currentStreamMu.Lock()
-	s.currentClientStream = stream
+	var replacedUndelivered []*proto.AuthDataUpdate
+	if s.isStreamActive && s.currentClient != nil {
+		s.currentClient.close(status.Error(codes.Unavailable, "replaced by a newer client, reconnect to resume updates"))
+		replacedUndelivered = s.currentClient.undelivered()
+		s.logger.Info().
+			Str("replaced_client_id", s.currentClientID).
+			Int("undelivered_count", len(replacedUndelivered)).
+			Msg("new client replaced the active client")
+	}
+	s.currentClient = client
 	s.currentClientID = clientID
-	s.currentStreamCtx = stream.Context()
 	s.isStreamActive = true
 
-	// Process any pending updates that accumulated while no client was connected
-	pendingToProcess := make([]*proto.AuthDataUpdate, 0)
+	// Queue any pending updates that accumulated while no client was connected, to be sent first
 	s.pendingUpdatesMu.Lock()
-	if len(s.pendingUpdates) > 0 {
-		pendingToProcess = append(pendingToProcess, s.pendingUpdates...)
-		s.pendingUpdates = s.pendingUpdates[:0] // Clear the slice but keep capacity
-	}
+	pending := append(s.pendingUpdates, replacedUndelivered...)
+	pendingCount := len(pending)
+	client.enqueuePending(pending)
+	s.pendingUpdates = make([]*proto.AuthDataUpdate, 0, 100)
 	s.pendingUpdatesMu.Unlock()
 	s.currentStreamMu.Unlock()
 
 	s.logger.Info().
-		Int("pending_updates", len(pendingToProcess)).
+		Int("pending_updates", pendingCount).
+		Bool("filtered", !filter.Empty()).
 		Msg("client connected to stream auth data updates")
 
-	// Send any pending updates first
-	for _, update := range pendingToProcess {
-		if err := stream.Send(update); err != nil {
-			s.logger.Error().
-				Err(err).
-				Msg("failed to send pending update to client")
-
-			s.currentStreamMu.Lock()
-			s.isStreamActive = false
-			s.currentStreamMu.Unlock()
-			return err
-		}
-		s.logger.Info().
-			Str("endpoint_id", update.EndpointId).
-			Msg("sent pending update to client")
-	}
-
-	// Now wait for the context to be done
-	<-stream.Context().Done()
+	go client.run()
+	s.awaitClose(client, stream)
 
 	s.currentStreamMu.Lock()
-	if s.currentClientID == clientID {
+	if s.currentClient == client {
 		s.isStreamActive = false
-		s.logger.Info().Msg("client disconnected")
+
+		// Store the updates the client was not sent, to be sent when a client connects.
+		// No updates are stored while the client is current, so they are stored in order.
+		undelivered := client.undelivered()
+		s.pendingUpdatesMu.Lock()
+		s.pendingUpdates = append(s.pendingUpdates, undelivered...)
+		s.pendingUpdatesMu.Unlock()
+
+		s.logger.Info().
+			Err(client.closeErr).
+			Int("pending_count", len(undelivered)).
+			Msg("client disconnected")
 	} else {
 		s.logger.Info().Msg("old client stream closed, but a newer one is already active")
 	}
 	s.currentStreamMu.Unlock()
 
-	return status.Error(codes.Canceled, "client context canceled")
+	return client.closeErr
 }
 
-// sendUpdateToStream attempts to send an update to the current client stream if one exists
-// If no active stream exists, it stores the update to be sent when a client connects
-func (s *grpcServer) sendUpdateToStream(update *proto.AuthDataUpdate) {
+// streamToObserver streams the updates to an observer, alongside the current client, until it disconnects.
+//
+// An observer is only streamed the updates applied while it is registered: it is not sent the pending updates,
+// and the updates it was not sent are not kept for the next client. Its stream's header is sent once it is
+// registered, so that it may then fetch the GatewayEndpoints without missing any update applied afterwards.
+func (s *grpcServer) streamToObserver(stream proto.GatewayEndpoints_StreamAuthDataUpdatesServer, filter Filter) error {
+	client := newClientStream(generateUniqueClientID(), stream, s.streamConfig, filter, s.store, s.logger.With("observer", true))
+
+	s.currentStreamMu.Lock()
+	s.observers[client] = struct{}{}
+	s.currentStreamMu.Unlock()
+
+	defer func() {
+		s.currentStreamMu.Lock()
+		delete(s.observers, client)
+		s.currentStreamMu.Unlock()
+	}()
+
+	if err := stream.SendHeader(metadata.Pairs(ObserverKey, "true")); err != nil {
+		return fmt.Errorf("failed to send observer stream header: %w", err)
+	}
+
+	s.logger.Info().Bool("filtered", !filter.Empty()).Msg("observer connected to stream auth data updates")
+
+	go client.run()
+	s.awaitClose(client, stream)
+
+	s.logger.Info().Err(client.closeErr).Msg("observer disconnected")
+	return client.closeErr
+}
+
+// awaitClose waits for the client to be closed, for the stream's context to be done, eg. once a half-open
+// connection is detected and closed, or for the stream or its connection to reach its max age, and closes the client.
+func (s *grpcServer) awaitClose(client *clientStream, stream proto.GatewayEndpoints_StreamAuthDataUpdatesServer) {
+	// Close the stream once it reaches its max age, if set, so that the client reconnects
+	var maxAgeCh <-chan time.Time
+	if s.streamConfig.MaxAge > 0 {
+		maxAgeTimer := time.NewTimer(keepalive.Jitter(s.streamConfig.MaxAge))
+		defer maxAgeTimer.Stop()
+		maxAgeCh = maxAgeTimer.C
+	}
+
+	// Close the stream once its connection reaches its max age, if set, so that the connection may be closed
+	// and the client reconnects on a new connection.
+	var connMaxAgeCh <-chan time.Time
+	if deadline, ok := keepalive.ConnectionDeadline(stream.Context()); ok {
+		connMaxAgeTimer := time.NewTimer(time.Until(deadline))
+		defer connMaxAgeTimer.Stop()
+		connMaxAgeCh = connMaxAgeTimer.C
+	}
+
+	select {
+	case <-client.closed:
+	case <-stream.Context().Done():
+		client.close(status.Error(codes.Canceled, "client context canceled"))
+	case <-maxAgeCh:
+		client.close(status.Error(codes.Unavailable, "stream reached its max age, reconnect to resume updates"))
+	case <-connMaxAgeCh:
+		client.close(status.Error(codes.Unavailable, "connection reached its max age, reconnect to resume updates"))
+	}
+}
+
+// sendUpdateToStream queues an update to be sent to the current client stream if one exists
+// If no active stream exists, it stores the update to be sent when a client connects.
+// The update is also queued to be sent to every observer.
+//
+// The update is filtered by each client's filter, given the GatewayEndpoint it replaced, if any,
+// so that a GatewayEndpoint which moves out of the filter is deleted from the client.
+func (s *grpcServer) sendUpdateToStream(update *proto.AuthDataUpdate, previous *proto.GatewayEndpoint) {
 	s.currentStreamMu.Lock()
 	defer s.currentStreamMu.Unlock()
 
-	if !s.isStreamActive || s.currentClientStream == nil {
+	for observer := range s.observers {
+		s.enqueueFiltered(observer, update, previous)
+	}
+
+	if !s.isStreamActive || s.currentClient == nil {
 		// No active stream, store update for later
 		s.pendingUpdatesMu.Lock()
 		s.pendingUpdates = append(s.pendingUpdates, update)
@@ -178,28 +352,27 @@ func (s *grpcServer) sendUpdateToStream(update *proto.AuthDataUpdate) {
 		return
 	}
 
-	// We have an active stream, try to send the update
-	err := s.currentClientStream.Send(update)
-	if err != nil {
-		s.logger.Error().
-			Err(err).
-			Msg("failed to send update to client, marking stream as inactive")
-
-		// Client likely disconnected, store update and mark stream as inactive
-		s.isStreamActive = false
-
-		s.pendingUpdatesMu.Lock()
-		s.pendingUpdates = append(s.pendingUpdates, update)
-		count := len(s.pendingUpdates)
-		s.pendingUpdatesMu.Unlock()
+	// We have an active stream, queue the update without waiting for it to be sent
+	s.enqueueFiltered(s.currentClient, update, previous)
+}
 
-		s.logger.Info().
-			Int("pending_count", count).
-			Msg("added update to pending queue after send failure")
-	} else {
-		s.logger.Info().
-			Str("endpoint_id", update.EndpointId).
-			Msg("sent update to client")
+// enqueueFiltered queues the update, filtered by the client's filter, to be sent to the client,
+// and applies the slow consumer policy if the client fell behind. It must be called with currentStreamMu held.
+func (s *grpcServer) enqueueFiltered(client *clientStream, update *proto.AuthDataUpdate, previous *proto.GatewayEndpoint) {
+	filter := client.filter
+	filteredUpdate, ok := filter.applyToUpdate(update, previous != nil && filter.Matches(previous))
+	if !ok {
+		updateTraces.Take(update)
+		return
+	}
+	updateTraces.Move(update, filteredUpdate)
+
+	if client.enqueue(filteredUpdate) {
+		metrics.SlowConsumers.WithLabelValues(string(s.streamConfig.SlowConsumerPolicy)).Inc()
+		client.logger.Warn().
+			Int("queue_size", s.streamConfig.QueueSize).
+			Str("slow_consumer_policy", string(s.streamConfig.SlowConsumerPolicy)).
+			Msg("client fell behind the updates, applying slow consumer policy")
 	}
 }
 
@@ -210,22 +383,37 @@ func (s *grpcServer) handleDataSourceUpdates(authDataUpdatesCh <-chan *proto.Aut
 	for authDataUpdate := range authDataUpdatesCh {
 		logger := s.logger.With("endpoint_id", authDataUpdate.EndpointId)
 
-		s.gatewayEndpointsMu.Lock()
+		// The span continues the data source's span of the update, if it carried one.
+		ctx, span := tracing.Tracer().Start(
+			tracing.Updates.Context(context.Background(), authDataUpdate),
+			"pads.handleDataSourceUpdates",
+			trace.WithAttributes(
+				attribute.String("pads.endpoint_id", authDataUpdate.EndpointId),
+				attribute.Bool("pads.delete", authDataUpdate.Delete),
+			),
+		)
+
+		previous, _ := s.store.Snapshot().Get(authDataUpdate.EndpointId)
 		if authDataUpdate.Delete {
 			logger.Info().Msg("deleted gateway endpoint")
-			delete(s.gatewayEndpoints, authDataUpdate.EndpointId)
 		} else {
-			if _, ok := s.gatewayEndpoints[authDataUpdate.EndpointId]; !ok {
+			if previous == nil {
 				logger.Info().Msg("created gateway endpoint")
 			} else {
 				logger.Info().Msg("updated gateway endpoint")
 			}
-			s.gatewayEndpoints[authDataUpdate.EndpointId] = authDataUpdate.GatewayEndpoint
+
+			// GatewayEndpoint values must always be redacted before being logged.
+			logger.Debug().
+				Str("gateway_endpoint", redact.GatewayEndpoint(authDataUpdate.GatewayEndpoint).String()).
+				Msg("gateway endpoint details")
 		}
-		s.gatewayEndpointsMu.Unlock()
+		s.store.Apply(authDataUpdate)
+		span.End()
 
 		// Try to send the update directly to the client stream
-		s.sendUpdateToStream(authDataUpdate)
+		updateTraces.Carry(ctx, authDataUpdate)
+		s.sendUpdateToStream(authDataUpdate, previous)
 	}
 }
 
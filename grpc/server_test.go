@@ -3,14 +3,25 @@ package grpc
 import (
 	"context"
 	"errors"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/buildwithgrove/path-external-auth-server/proto"
 	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	gomock "go.uber.org/mock/gomock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+	"github.com/buildwithgrove/path-auth-data-server/keepalive"
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
 )
 
 func Test_FetchAuthDataSync(t *testing.T) {
@@ -57,12 +68,9 @@ func Test_FetchAuthDataSync(t *testing.T) {
 
 			mockDataSource := NewMockAuthDataSource(ctrl)
 
-			mockDataSource.EXPECT().FetchAuthDataSync().Return(test.expectedResponse, test.expectedError)
-			if test.expectedError == nil {
-				mockDataSource.EXPECT().AuthDataUpdatesChan().Return(nil, nil)
-			}
+			mockDataSource.EXPECT().SubscribeAuthData().Return(test.expectedResponse, nil, test.expectedError)
 
-			server, err := NewGRPCServer(mockDataSource, polyzero.NewLogger())
+			server, err := NewGRPCServer(mockDataSource, StreamConfig{}, polyzero.NewLogger())
 			c.Equal(test.expectedError, err)
 
 			if test.expectedError == nil {
@@ -81,6 +89,37 @@ func Test_FetchAuthDataSync(t *testing.T) {
 	}
 }
 
+// Test_FetchAuthDataSync_concurrentUpdates must be run with the race detector to be effective:
+// the response must not share any state which is modified by updates while it is serialized.
+func Test_FetchAuthDataSync_concurrentUpdates(t *testing.T) {
+	c := require.New(t)
+
+	dataSource := datasourcetest.New(conformanceEndpoints(), NewUpdateFeed)
+	server, err := NewGRPCServer(dataSource, StreamConfig{}, polyzero.NewLogger())
+	c.NoError(err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 1_000 {
+			dataSource.Put(newTestGatewayEndpoint(testEndpointID(i % 100)))
+		}
+	}()
+
+	for {
+		resp, err := server.FetchAuthDataSync(context.Background(), &proto.AuthDataRequest{})
+		c.NoError(err)
+		_, err = protobuf.Marshal(resp)
+		c.NoError(err)
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
 func Test_StreamUpdates(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -126,12 +165,11 @@ func Test_StreamUpdates(t *testing.T) {
 			}
 			close(updateCh)
 
-			mockDataSource.EXPECT().FetchAuthDataSync().Return(&proto.AuthDataResponse{
+			mockDataSource.EXPECT().SubscribeAuthData().Return(&proto.AuthDataResponse{
 				Endpoints: map[string]*proto.GatewayEndpoint{},
-			}, nil)
-			mockDataSource.EXPECT().AuthDataUpdatesChan().Return(updateCh, nil)
+			}, updateCh, nil)
 
-			server, err := NewGRPCServer(mockDataSource, polyzero.NewLogger())
+			server, err := NewGRPCServer(mockDataSource, StreamConfig{}, polyzero.NewLogger())
 			c.NoError(err)
 
 			mockStream := &mockStreamServer{
@@ -152,6 +190,182 @@ func Test_StreamUpdates(t *testing.T) {
 	}
 }
 
+func Test_StreamAuthDataUpdates_slowConsumerDisconnected(t *testing.T) {
+	c := require.New(t)
+
+	dataSource := datasourcetest.New(conformanceEndpoints(), NewUpdateFeed)
+	server, err := NewGRPCServer(dataSource, StreamConfig{QueueSize: 1, SlowConsumerPolicy: SlowConsumerPolicyDisconnect}, polyzero.NewLogger())
+	c.NoError(err)
+
+	stream := newFakeUpdatesStream(t)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.StreamAuthDataUpdates(&proto.AuthDataUpdatesRequest{}, stream)
+	}()
+	c.Eventually(func() bool {
+		server.currentStreamMu.Lock()
+		defer server.currentStreamMu.Unlock()
+		return server.isStreamActive
+	}, time.Second, 10*time.Millisecond)
+
+	// The client never receives the first update, so the third does not fit in its queue.
+	for i := range 3 {
+		dataSource.Put(newTestGatewayEndpoint(testEndpointID(i)))
+	}
+
+	select {
+	case err := <-errCh:
+		c.Equal(codes.ResourceExhausted, status.Code(err))
+	case <-time.After(time.Second):
+		t.Fatal("slow client was not disconnected")
+	}
+
+	// The updates the client was not sent are sent to the next client to connect.
+	server.pendingUpdatesMu.Lock()
+	defer server.pendingUpdatesMu.Unlock()
+	c.Len(server.pendingUpdates, 3)
+	for i, update := range server.pendingUpdates {
+		c.Equal(testEndpointID(i), update.GetEndpointId())
+	}
+}
+
+func Test_StreamAuthDataUpdates_halfOpenStream(t *testing.T) {
+	c := require.New(t)
+
+	dataSource := datasourcetest.New(conformanceEndpoints(), NewUpdateFeed)
+	server, err := NewGRPCServer(dataSource, StreamConfig{ResendWindow: time.Minute}, polyzero.NewLogger())
+	c.NoError(err)
+
+	stream := newFakeUpdatesStream(t)
+	close(stream.release)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.StreamAuthDataUpdates(&proto.AuthDataUpdatesRequest{}, stream)
+	}()
+	c.Eventually(func() bool {
+		server.currentStreamMu.Lock()
+		defer server.currentStreamMu.Unlock()
+		return server.isStreamActive
+	}, time.Second, 10*time.Millisecond)
+
+	// The updates are sent into a connection which was silently dropped, until it is detected and closed.
+	for i := range 2 {
+		dataSource.Put(newTestGatewayEndpoint(testEndpointID(i)))
+		stream.recv(t)
+	}
+	stream.cancel()
+
+	select {
+	case err := <-errCh:
+		c.Equal(codes.Canceled, status.Code(err))
+	case <-time.After(time.Second):
+		t.Fatal("stream was not closed")
+	}
+
+	// The updates which may have been lost are sent to the next client to connect.
+	server.pendingUpdatesMu.Lock()
+	defer server.pendingUpdatesMu.Unlock()
+	var resentIDs []string
+	for _, update := range server.pendingUpdates {
+		c.NotNil(update.GetGatewayEndpoint())
+		resentIDs = append(resentIDs, update.GetEndpointId())
+	}
+	c.ElementsMatch([]string{testEndpointID(0), testEndpointID(1)}, resentIDs)
+}
+
+func Test_StreamAuthDataUpdates_replacedClient(t *testing.T) {
+	c := require.New(t)
+
+	dataSource := datasourcetest.New(conformanceEndpoints(), NewUpdateFeed)
+	server, err := NewGRPCServer(dataSource, StreamConfig{}, polyzero.NewLogger())
+	c.NoError(err)
+
+	// The first client is stuck sending the first update, with the second queued behind it.
+	first := newFakeUpdatesStream(t)
+	firstErrCh := make(chan error, 1)
+	go func() {
+		firstErrCh <- server.StreamAuthDataUpdates(&proto.AuthDataUpdatesRequest{}, first)
+	}()
+	c.Eventually(server.clientConnected, time.Second, 10*time.Millisecond)
+
+	dataSource.Put(newTestGatewayEndpoint(testEndpointID(0)))
+	dataSource.Put(newTestGatewayEndpoint(testEndpointID(1)))
+	<-first.sending
+
+	second := newFakeUpdatesStream(t)
+	close(second.release)
+	go func() {
+		_ = server.StreamAuthDataUpdates(&proto.AuthDataUpdatesRequest{}, second)
+	}()
+
+	// The replaced client is closed, so that it reconnects.
+	select {
+	case err := <-firstErrCh:
+		c.Equal(codes.Unavailable, status.Code(err))
+	case <-time.After(time.Second):
+		t.Fatal("replaced client was not closed")
+	}
+
+	// The updates the replaced client was not sent are sent to the new client, in order.
+	c.Equal(testEndpointID(0), second.recv(t).GetEndpointId())
+	c.Equal(testEndpointID(1), second.recv(t).GetEndpointId())
+}
+
+func Test_StreamAuthDataUpdates_maxAge(t *testing.T) {
+	c := require.New(t)
+
+	server, err := NewGRPCServer(datasourcetest.New(conformanceEndpoints(), NewUpdateFeed), StreamConfig{MaxAge: 50 * time.Millisecond}, polyzero.NewLogger())
+	c.NoError(err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.StreamAuthDataUpdates(&proto.AuthDataUpdatesRequest{}, newFakeUpdatesStream(t))
+	}()
+
+	// The client is asked to reconnect once the stream reaches its max age.
+	select {
+	case err := <-errCh:
+		c.Equal(codes.Unavailable, status.Code(err))
+	case <-time.After(time.Second):
+		t.Fatal("stream was not closed at its max age")
+	}
+}
+
+func Test_StreamAuthDataUpdates_connectionMaxAge(t *testing.T) {
+	c := require.New(t)
+
+	server, err := NewGRPCServer(datasourcetest.New(conformanceEndpoints(), NewUpdateFeed), StreamConfig{}, polyzero.NewLogger())
+	c.NoError(err)
+
+	ln, err := keepalive.Listen(context.Background(), "127.0.0.1:0", keepalive.Config{MaxConnectionAge: 50 * time.Millisecond}, polyzero.NewLogger())
+	c.NoError(err)
+	defer ln.Close()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	c.NoError(err)
+	defer clientConn.Close()
+
+	conn, err := ln.Accept()
+	c.NoError(err)
+	defer conn.Close()
+
+	stream := newFakeUpdatesStream(t)
+	stream.ctx = keepalive.ConnContext(stream.ctx, conn)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.StreamAuthDataUpdates(&proto.AuthDataUpdatesRequest{}, stream)
+	}()
+
+	// The client is asked to reconnect once the stream's connection reaches its max age.
+	select {
+	case err := <-errCh:
+		c.Equal(codes.Unavailable, status.Code(err))
+	case <-time.After(time.Second):
+		t.Fatal("stream was not closed at its connection's max age")
+	}
+}
+
 func Test_handleDataSourceUpdates(t *testing.T) {
 	tests := []struct {
 		name                     string
@@ -216,21 +430,89 @@ func Test_handleDataSourceUpdates(t *testing.T) {
 			}
 			close(updateCh)
 
-			mockDataSource.EXPECT().FetchAuthDataSync().Return(&proto.AuthDataResponse{Endpoints: test.gatewayEndpoints}, nil)
-			mockDataSource.EXPECT().AuthDataUpdatesChan().Return(updateCh, nil)
+			mockDataSource.EXPECT().SubscribeAuthData().Return(&proto.AuthDataResponse{Endpoints: test.gatewayEndpoints}, updateCh, nil)
 
-			server, err := NewGRPCServer(mockDataSource, polyzero.NewLogger())
+			server, err := NewGRPCServer(mockDataSource, StreamConfig{}, polyzero.NewLogger())
 			c.NoError(err)
 
 			server.handleDataSourceUpdates(updateCh)
 
 			<-time.After(100 * time.Millisecond)
 
-			c.EqualValues(test.expectedDataAfterUpdates, server.gatewayEndpoints)
+			c.EqualValues(test.expectedDataAfterUpdates, server.store.Snapshot().Map())
+
+			gatewayEndpoints, revision := server.GatewayEndpoints()
+			c.EqualValues(test.expectedDataAfterUpdates, gatewayEndpoints)
+			c.Equal(uint64(len(test.updates)), revision)
 		})
 	}
 }
 
+func Test_handleDataSourceUpdates_tracing(t *testing.T) {
+	c := require.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previousTracerProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracerProvider)
+	t.Cleanup(func() { otel.SetTracerProvider(previousTracerProvider) })
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDataSource := NewMockAuthDataSource(ctrl)
+	updateCh := make(chan *proto.AuthDataUpdate, 1)
+	mockDataSource.EXPECT().SubscribeAuthData().Return(&proto.AuthDataResponse{
+		Endpoints: map[string]*proto.GatewayEndpoint{},
+	}, updateCh, nil)
+
+	server, err := NewGRPCServer(mockDataSource, StreamConfig{}, polyzero.NewLogger())
+	c.NoError(err)
+
+	// The data source carries the span in which it detected the change with the update.
+	update := &proto.AuthDataUpdate{
+		EndpointId:      "endpoint_1_static_key",
+		GatewayEndpoint: &proto.GatewayEndpoint{EndpointId: "endpoint_1_static_key"},
+	}
+	ctx, dataSourceSpan := tracerProvider.Tracer("test").Start(context.Background(), "postgres.notification")
+	tracing.Updates.Carry(ctx, update)
+	updateCh <- update
+	dataSourceSpan.End()
+
+	// The update is pending until a client connects.
+	c.Eventually(func() bool {
+		_, revision := server.GatewayEndpoints()
+		return revision == 1
+	}, time.Second, 10*time.Millisecond)
+
+	mockStream := &mockStreamServer{
+		updates:         []*proto.AuthDataUpdate{update},
+		updatesReceived: make(chan *proto.AuthDataUpdate, 1),
+	}
+	go func() { _ = server.StreamAuthDataUpdates(&proto.AuthDataUpdatesRequest{}, mockStream) }()
+	<-mockStream.updatesReceived
+
+	// Every span of the update is in the data source's trace, and is a child of the span which handed the update over.
+	spanNames := []string{"postgres.notification", "pads.handleDataSourceUpdates", "pads.pendingQueue", "pads.stream.Send"}
+	c.Eventually(func() bool { return len(exporter.GetSpans()) == len(spanNames) }, time.Second, 10*time.Millisecond)
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	for i, name := range spanNames[1:] {
+		span, ok := spans[name]
+		c.True(ok, "missing span %s", name)
+		c.Equal(dataSourceSpan.SpanContext().TraceID(), span.SpanContext.TraceID())
+		parentName := spanNames[i]
+		if name == "pads.stream.Send" {
+			// The update is sent once it leaves the pending queue, so both are children of the applied update's span.
+			parentName = "pads.handleDataSourceUpdates"
+		}
+		c.Equal(spans[parentName].SpanContext.SpanID(), span.Parent.SpanID(), "parent of span %s", name)
+	}
+}
+
 type mockStreamServer struct {
 	grpc.ServerStream
 	updates         []*proto.AuthDataUpdate
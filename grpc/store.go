@@ -0,0 +1,135 @@
+package grpc
+
+import (
+	"hash/maphash"
+	"iter"
+	"sync"
+	"sync/atomic"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+)
+
+// storeShardCount is the number of shards the GatewayEndpoints of a snapshot are split into.
+// Applying an update copies a single shard, so that updates remain cheap with millions of GatewayEndpoints.
+const storeShardCount = 4096
+
+// storeShardSeed is the seed of the hash which assigns endpoint IDs to shards.
+var storeShardSeed = maphash.MakeSeed()
+
+// endpointStore holds the GatewayEndpoints served by the gRPC server as a series of immutable snapshots.
+//
+// Reads load the current snapshot with a single atomic operation and never block, and a loaded snapshot
+// is never modified, so it may be read or serialized while updates are applied. Updates are applied by
+// copying the shard containing the endpoint ID and atomically swapping in a new snapshot.
+//
+// The GatewayEndpoint values are shared between snapshots and must never be modified once stored.
+type endpointStore struct {
+	current atomic.Pointer[endpointSnapshot]
+
+	// writeMu serializes updates, so that no update is lost between loading and swapping a snapshot.
+	writeMu sync.Mutex
+}
+
+// endpointSnapshot is an immutable set of GatewayEndpoints at a revision of the endpointStore.
+type endpointSnapshot struct {
+	shards [storeShardCount]map[string]*proto.GatewayEndpoint
+	size   int
+	// revision is incremented for every update applied to the endpointStore.
+	revision uint64
+}
+
+// newEndpointStore returns an endpointStore containing the GatewayEndpoints, at revision 0.
+func newEndpointStore(gatewayEndpoints map[string]*proto.GatewayEndpoint) *endpointStore {
+	snapshot := &endpointSnapshot{size: len(gatewayEndpoints)}
+	for i := range snapshot.shards {
+		snapshot.shards[i] = make(map[string]*proto.GatewayEndpoint, len(gatewayEndpoints)/storeShardCount)
+	}
+	for endpointID, gatewayEndpoint := range gatewayEndpoints {
+		snapshot.shards[shardIndex(endpointID)][endpointID] = gatewayEndpoint
+	}
+
+	store := &endpointStore{}
+	store.current.Store(snapshot)
+	return store
+}
+
+// Snapshot returns the current snapshot of the GatewayEndpoints.
+func (s *endpointStore) Snapshot() *endpointSnapshot {
+	return s.current.Load()
+}
+
+// Apply applies the update, creating, replacing or deleting a GatewayEndpoint, and returns the new snapshot.
+func (s *endpointStore) Apply(update *proto.AuthDataUpdate) *endpointSnapshot {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	previous := s.current.Load()
+	endpointID := update.GetEndpointId()
+	index := shardIndex(endpointID)
+
+	// Only the shard containing the endpoint ID is copied; all other shards are shared with the previous snapshot.
+	previousShard := previous.shards[index]
+	shard := make(map[string]*proto.GatewayEndpoint, len(previousShard)+1)
+	for id, gatewayEndpoint := range previousShard {
+		shard[id] = gatewayEndpoint
+	}
+
+	next := &endpointSnapshot{
+		shards:   previous.shards,
+		size:     previous.size - len(previousShard),
+		revision: previous.revision + 1,
+	}
+	if update.GetDelete() {
+		delete(shard, endpointID)
+	} else {
+		shard[endpointID] = update.GetGatewayEndpoint()
+	}
+	next.shards[index] = shard
+	next.size += len(shard)
+
+	s.current.Store(next)
+	return next
+}
+
+// Get returns the GatewayEndpoint with the endpoint ID, if it is in the snapshot.
+func (snapshot *endpointSnapshot) Get(endpointID string) (*proto.GatewayEndpoint, bool) {
+	gatewayEndpoint, ok := snapshot.shards[shardIndex(endpointID)][endpointID]
+	return gatewayEndpoint, ok
+}
+
+// Len returns the number of GatewayEndpoints in the snapshot.
+func (snapshot *endpointSnapshot) Len() int {
+	return snapshot.size
+}
+
+// Revision returns the revision of the endpointStore at which the snapshot was taken.
+func (snapshot *endpointSnapshot) Revision() uint64 {
+	return snapshot.revision
+}
+
+// All returns an iterator over the GatewayEndpoints in the snapshot, in no particular order.
+func (snapshot *endpointSnapshot) All() iter.Seq2[string, *proto.GatewayEndpoint] {
+	return func(yield func(string, *proto.GatewayEndpoint) bool) {
+		for _, shard := range snapshot.shards {
+			for endpointID, gatewayEndpoint := range shard {
+				if !yield(endpointID, gatewayEndpoint) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Map returns a new map of the GatewayEndpoints in the snapshot, which the caller may modify.
+func (snapshot *endpointSnapshot) Map() map[string]*proto.GatewayEndpoint {
+	gatewayEndpoints := make(map[string]*proto.GatewayEndpoint, snapshot.size)
+	for endpointID, gatewayEndpoint := range snapshot.All() {
+		gatewayEndpoints[endpointID] = gatewayEndpoint
+	}
+	return gatewayEndpoints
+}
+
+// shardIndex returns the index of the shard containing the endpoint ID.
+func shardIndex(endpointID string) int {
+	return int(maphash.String(storeShardSeed, endpointID) % storeShardCount)
+}
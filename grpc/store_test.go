@@ -0,0 +1,253 @@
+package grpc
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/stretchr/testify/require"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+func Test_endpointStore(t *testing.T) {
+	endpoint1 := conformanceEndpoint("endpoint_1", "api_key_1", "account_1", "PLAN_FREE")
+	endpoint1Rotated := conformanceEndpoint("endpoint_1", "api_key_1_rotated", "account_1", "PLAN_FREE")
+	endpoint2 := conformanceEndpoint("endpoint_2", "", "account_2", "PLAN_UNLIMITED")
+
+	tests := []struct {
+		name             string
+		gatewayEndpoints map[string]*proto.GatewayEndpoint
+		updates          []*proto.AuthDataUpdate
+		expected         map[string]*proto.GatewayEndpoint
+	}{
+		{
+			name:             "should create, replace and delete gateway endpoints",
+			gatewayEndpoints: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1},
+			updates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_2", GatewayEndpoint: endpoint2},
+				{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1Rotated},
+				{EndpointId: "endpoint_2", Delete: true},
+			},
+			expected: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1Rotated},
+		},
+		{
+			name:             "should ignore deletes of gateway endpoints not in the store",
+			gatewayEndpoints: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1},
+			updates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_3", Delete: true},
+			},
+			expected: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1},
+		},
+		{
+			name:     "should start empty",
+			expected: map[string]*proto.GatewayEndpoint{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			store := newEndpointStore(test.gatewayEndpoints)
+			for _, update := range test.updates {
+				store.Apply(update)
+			}
+
+			snapshot := store.Snapshot()
+			c.Equal(test.expected, snapshot.Map())
+			c.Equal(len(test.expected), snapshot.Len())
+			c.Equal(uint64(len(test.updates)), snapshot.Revision())
+			for endpointID, gatewayEndpoint := range test.expected {
+				stored, ok := snapshot.Get(endpointID)
+				c.True(ok)
+				c.Equal(gatewayEndpoint, stored)
+			}
+		})
+	}
+}
+
+func Test_endpointStore_snapshotsAreImmutable(t *testing.T) {
+	c := require.New(t)
+
+	store := newEndpointStore(newTestGatewayEndpoints(1_000))
+	before := store.Snapshot()
+	beforeMap := before.Map()
+
+	for i := range 1_000 {
+		store.Apply(&proto.AuthDataUpdate{EndpointId: testEndpointID(i), Delete: true})
+	}
+
+	// A snapshot loaded before the updates must be unchanged by them.
+	c.Equal(beforeMap, before.Map())
+	c.Equal(1_000, before.Len())
+	c.Equal(uint64(0), before.Revision())
+
+	after := store.Snapshot()
+	c.Equal(0, after.Len())
+	c.Equal(uint64(1_000), after.Revision())
+}
+
+// Test_endpointStore_concurrentReadsAndUpdates must be run with the race detector to be effective.
+func Test_endpointStore_concurrentReadsAndUpdates(t *testing.T) {
+	c := require.New(t)
+
+	store := newEndpointStore(newTestGatewayEndpoints(1_000))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	inconsistent := make(chan string, 1)
+
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// Every read of a snapshot must see the same GatewayEndpoints, however many updates are applied.
+				snapshot := store.Snapshot()
+				gatewayEndpoints := snapshot.Map()
+				if len(gatewayEndpoints) != snapshot.Len() {
+					select {
+					case inconsistent <- fmt.Sprintf("snapshot has %d gateway endpoints but reports %d", len(gatewayEndpoints), snapshot.Len()):
+					default:
+					}
+					return
+				}
+				if _, err := protobuf.Marshal(&proto.AuthDataResponse{Endpoints: gatewayEndpoints}); err != nil {
+					select {
+					case inconsistent <- err.Error():
+					default:
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	for i := range 5_000 {
+		endpointID := testEndpointID(i % 2_000)
+		if i%3 == 0 {
+			store.Apply(&proto.AuthDataUpdate{EndpointId: endpointID, Delete: true})
+			continue
+		}
+		store.Apply(&proto.AuthDataUpdate{EndpointId: endpointID, GatewayEndpoint: newTestGatewayEndpoint(endpointID)})
+	}
+	close(done)
+	wg.Wait()
+
+	select {
+	case message := <-inconsistent:
+		c.Fail(message)
+	default:
+	}
+	c.Equal(uint64(5_000), store.Snapshot().Revision())
+}
+
+/* ---------------------------- Benchmarks ---------------------------- */
+
+// benchmarkStoreSize is the number of GatewayEndpoints in the store used by the benchmarks.
+const benchmarkStoreSize = 1_000_000
+
+var (
+	benchmarkGatewayEndpoints     map[string]*proto.GatewayEndpoint
+	benchmarkGatewayEndpointsOnce sync.Once
+)
+
+// getBenchmarkGatewayEndpoints returns the GatewayEndpoints used by the benchmarks, which are only created once.
+func getBenchmarkGatewayEndpoints() map[string]*proto.GatewayEndpoint {
+	benchmarkGatewayEndpointsOnce.Do(func() {
+		benchmarkGatewayEndpoints = newTestGatewayEndpoints(benchmarkStoreSize)
+	})
+	return benchmarkGatewayEndpoints
+}
+
+func Benchmark_endpointStore_Apply(b *testing.B) {
+	store := newEndpointStore(getBenchmarkGatewayEndpoints())
+	updates := make([]*proto.AuthDataUpdate, 1_000)
+	for i := range updates {
+		endpointID := testEndpointID(i * 997 % benchmarkStoreSize)
+		updates[i] = &proto.AuthDataUpdate{EndpointId: endpointID, GatewayEndpoint: newTestGatewayEndpoint(endpointID)}
+	}
+
+	b.ResetTimer()
+	for i := range b.N {
+		store.Apply(updates[i%len(updates)])
+	}
+}
+
+func Benchmark_endpointStore_Get(b *testing.B) {
+	store := newEndpointStore(getBenchmarkGatewayEndpoints())
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			store.Snapshot().Get(testEndpointID(i % benchmarkStoreSize))
+			i++
+		}
+	})
+}
+
+// Benchmark_endpointStore_GetWhileApplying measures reads while updates are applied continuously, as reads never block on updates.
+func Benchmark_endpointStore_GetWhileApplying(b *testing.B) {
+	store := newEndpointStore(getBenchmarkGatewayEndpoints())
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			endpointID := testEndpointID(i % benchmarkStoreSize)
+			store.Apply(&proto.AuthDataUpdate{EndpointId: endpointID, GatewayEndpoint: newTestGatewayEndpoint(endpointID)})
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			store.Snapshot().Get(testEndpointID(i % benchmarkStoreSize))
+			i++
+		}
+	})
+}
+
+// Benchmark_endpointStore_Map measures building the full set of GatewayEndpoints, as for FetchAuthDataSync.
+func Benchmark_endpointStore_Map(b *testing.B) {
+	store := newEndpointStore(getBenchmarkGatewayEndpoints())
+
+	b.ResetTimer()
+	for range b.N {
+		store.Snapshot().Map()
+	}
+}
+
+/* ---------------------------- Test Helpers ---------------------------- */
+
+func testEndpointID(i int) string {
+	return fmt.Sprintf("endpoint_%d", i)
+}
+
+func newTestGatewayEndpoint(endpointID string) *proto.GatewayEndpoint {
+	return conformanceEndpoint(endpointID, "api_key_"+endpointID, "account_1", "PLAN_FREE")
+}
+
+// newTestGatewayEndpoints returns the given number of GatewayEndpoints, with the IDs returned by testEndpointID.
+func newTestGatewayEndpoints(count int) map[string]*proto.GatewayEndpoint {
+	gatewayEndpoints := make(map[string]*proto.GatewayEndpoint, count)
+	for i := range count {
+		endpointID := testEndpointID(i)
+		gatewayEndpoints[endpointID] = newTestGatewayEndpoint(endpointID)
+	}
+	return gatewayEndpoints
+}
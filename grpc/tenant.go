@@ -0,0 +1,210 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/buildwithgrove/path-auth-data-server/clientauth"
+	"github.com/buildwithgrove/path-auth-data-server/metrics"
+	"github.com/buildwithgrove/path-auth-data-server/padsproto"
+)
+
+const (
+	// TenantKey is the gRPC metadata key with which a client selects the tenant it is served.
+	TenantKey = "pads-tenant"
+	// DefaultTenant is the tenant served to clients which do not select one.
+	DefaultTenant = "default"
+)
+
+// tenantRouter and tenantFullSyncRouter route each request to the servers of the tenant selected by the client
+var (
+	_ proto.GatewayEndpointsServer = &tenantRouter{}
+	_ padsproto.FullSyncServer     = &tenantFullSyncRouter{}
+)
+
+// TenantFromContext returns the tenant selected in the gRPC metadata of the request, or DefaultTenant if none is.
+func TenantFromContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(TenantKey); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return DefaultTenant
+}
+
+// tenantRouter serves multiple named tenants, each with its own grpcServer, ie. its own data source,
+// store and client stream, so that a single PADS may serve distinct sets of GatewayEndpoints to
+// multiple gateways. Requests for a tenant which the client is not authorized for (see clientauth.TenantAuthorized)
+// are rejected with codes.PermissionDenied, and requests for a tenant which is not served with codes.NotFound.
+type tenantRouter struct {
+	proto.UnimplementedGatewayEndpointsServer
+
+	servers         map[string]*grpcServer
+	fullSyncServers map[string]*fullSyncServer
+
+	logger polylog.Logger
+}
+
+// NewTenantRouter returns a router of the requests of each tenant to a grpcServer of its AuthDataSource,
+// by tenant name, configured by the StreamConfig and FullSyncConfig.
+func NewTenantRouter(
+	authDataSources map[string]AuthDataSource,
+	streamConfig StreamConfig,
+	fullSyncConfig FullSyncConfig,
+	logger polylog.Logger,
+) (*tenantRouter, error) {
+	router := &tenantRouter{
+		servers:         make(map[string]*grpcServer, len(authDataSources)),
+		fullSyncServers: make(map[string]*fullSyncServer, len(authDataSources)),
+		logger:          logger,
+	}
+
+	for tenant, authDataSource := range authDataSources {
+		tenantLogger := logger.With("tenant", tenant)
+
+		server, err := NewGRPCServer(authDataSource, streamConfig, tenantLogger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create server of tenant %s: %w", tenant, err)
+		}
+		router.servers[tenant] = server
+		router.fullSyncServers[tenant] = NewFullSyncServer(server, fullSyncConfig, tenantLogger)
+	}
+
+	return router, nil
+}
+
+// FetchAuthDataSync returns the GatewayEndpoints of the tenant selected by the client.
+func (r *tenantRouter) FetchAuthDataSync(ctx context.Context, req *proto.AuthDataRequest) (*proto.AuthDataResponse, error) {
+	server, err := r.server(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return server.FetchAuthDataSync(ctx, req)
+}
+
+// StreamAuthDataUpdates streams the updates of the tenant selected by the client.
+// Each tenant streams to its own single client, so clients of different tenants never replace each other.
+func (r *tenantRouter) StreamAuthDataUpdates(req *proto.AuthDataUpdatesRequest, stream proto.GatewayEndpoints_StreamAuthDataUpdatesServer) error {
+	server, err := r.server(stream.Context())
+	if err != nil {
+		return err
+	}
+	return server.StreamAuthDataUpdates(req, stream)
+}
+
+// FullSync returns the FullSync service, which streams the GatewayEndpoints of the tenant selected by the client.
+func (r *tenantRouter) FullSync() *tenantFullSyncRouter {
+	return &tenantFullSyncRouter{router: r}
+}
+
+// NewServer returns a gRPC server with the options, which serves the GatewayEndpoints and FullSync
+// services of every tenant. It is served alongside the health and readiness checks by NewHTTPHandler.
+func (r *tenantRouter) NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	grpcServer := grpc.NewServer(opts...)
+	proto.RegisterGatewayEndpointsServer(grpcServer, r)
+	padsproto.RegisterFullSyncServer(grpcServer, r.FullSync())
+	return grpcServer
+}
+
+// server returns the grpcServer of the tenant selected in the gRPC metadata of the request.
+func (r *tenantRouter) server(ctx context.Context) (*grpcServer, error) {
+	tenant, err := r.authorizedTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	server, ok := r.servers[tenant]
+	if !ok {
+		r.logger.Warn().Str("tenant", tenant).Msg("rejected request for unknown tenant")
+		return nil, status.Errorf(codes.NotFound, "unknown tenant %q", tenant)
+	}
+	return server, nil
+}
+
+// authorizedTenant returns the tenant selected in the gRPC metadata of the request,
+// or a codes.PermissionDenied error if the client is not authorized for it.
+//
+// Authorization is checked before the tenant is looked up, so that clients cannot probe which tenants are served.
+func (r *tenantRouter) authorizedTenant(ctx context.Context) (string, error) {
+	tenant := TenantFromContext(ctx)
+	if !clientauth.TenantAuthorized(ctx, tenant) {
+		r.logger.Warn().Str("tenant", tenant).Msg("rejected request for unauthorized tenant")
+		return "", status.Errorf(codes.PermissionDenied, "not authorized for tenant %q", tenant)
+	}
+	return tenant, nil
+}
+
+// Server returns the grpcServer of the tenant, eg. to serve its GatewayEndpoints from the admin API.
+func (r *tenantRouter) Server(tenant string) (*grpcServer, bool) {
+	server, ok := r.servers[tenant]
+	return server, ok
+}
+
+// Tenants returns the names of the served tenants, in order.
+func (r *tenantRouter) Tenants() []string {
+	return slices.Sorted(maps.Keys(r.servers))
+}
+
+// Ready returns true if every tenant is ready, ie. if all of their data sources are ready.
+func (r *tenantRouter) Ready() bool {
+	for _, server := range r.servers {
+		if !server.Ready() {
+			return false
+		}
+	}
+	return true
+}
+
+// TenantReady returns true if the tenant is ready, and false for ok if the tenant is not served.
+func (r *tenantRouter) TenantReady(tenant string) (ready bool, ok bool) {
+	server, ok := r.servers[tenant]
+	if !ok {
+		return false, false
+	}
+	return server.Ready(), true
+}
+
+// TenantStats returns the current stats of every tenant, in order, to be exported as metrics.
+func (r *tenantRouter) TenantStats() []metrics.TenantStats {
+	stats := make([]metrics.TenantStats, 0, len(r.servers))
+	for _, tenant := range r.Tenants() {
+		server := r.servers[tenant]
+		snapshot := server.store.Snapshot()
+		stats = append(stats, metrics.TenantStats{
+			Tenant:           tenant,
+			Ready:            server.Ready(),
+			GatewayEndpoints: snapshot.Len(),
+			UpdatesApplied:   snapshot.Revision(),
+			ClientConnected:  server.clientConnected(),
+		})
+	}
+	return stats
+}
+
+// tenantFullSyncRouter routes each full sync to the FullSync service of the tenant selected by the client.
+type tenantFullSyncRouter struct {
+	padsproto.UnimplementedFullSyncServer
+
+	router *tenantRouter
+}
+
+// StreamFullSync streams the GatewayEndpoints of the tenant selected by the client in chunks.
+func (r *tenantFullSyncRouter) StreamFullSync(req *padsproto.FullSyncRequest, stream padsproto.FullSync_StreamFullSyncServer) error {
+	tenant, err := r.router.authorizedTenant(stream.Context())
+	if err != nil {
+		return err
+	}
+	fullSyncServer, ok := r.router.fullSyncServers[tenant]
+	if !ok {
+		r.router.logger.Warn().Str("tenant", tenant).Msg("rejected full sync for unknown tenant")
+		return status.Errorf(codes.NotFound, "unknown tenant %q", tenant)
+	}
+	return fullSyncServer.StreamFullSync(req, stream)
+}
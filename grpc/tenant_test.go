@@ -0,0 +1,118 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/buildwithgrove/path-auth-data-server/clientauth"
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+	"github.com/buildwithgrove/path-auth-data-server/metrics"
+)
+
+func Test_TenantFromContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		expected string
+	}{
+		{
+			name:     "should return the selected tenant",
+			md:       metadata.Pairs(TenantKey, "gateway_a"),
+			expected: "gateway_a",
+		},
+		{
+			name:     "should return the default tenant if no tenant is selected",
+			expected: DefaultTenant,
+		},
+		{
+			name:     "should return the default tenant if the selected tenant is empty",
+			md:       metadata.Pairs(TenantKey, ""),
+			expected: DefaultTenant,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), test.md)
+			require.Equal(t, test.expected, TenantFromContext(ctx))
+		})
+	}
+}
+
+func Test_tenantRouter_unauthorizedTenant(t *testing.T) {
+	c := require.New(t)
+
+	router, err := NewTenantRouter(map[string]AuthDataSource{
+		"gateway_a": datasourcetest.New(conformanceEndpoints(), NewUpdateFeed),
+		"gateway_b": datasourcetest.New(conformanceEndpoints(), NewUpdateFeed),
+	}, StreamConfig{}, FullSyncConfig{}, polyzero.NewLogger())
+	c.NoError(err)
+
+	authenticator, err := clientauth.NewTenantAuthenticator(map[string]clientauth.Tokens{
+		"gateway_a": {Tokens: []string{"token_a"}},
+		"gateway_b": {Tokens: []string{"token_b"}},
+	}, polyzero.NewLogger())
+	c.NoError(err)
+
+	fetch := func(token, tenant string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token, TenantKey, tenant))
+		_, err := authenticator.UnaryServerInterceptor()(ctx, &proto.AuthDataRequest{}, &grpc.UnaryServerInfo{FullMethod: "/test/Fetch"},
+			func(ctx context.Context, req any) (any, error) {
+				return router.FetchAuthDataSync(ctx, req.(*proto.AuthDataRequest))
+			},
+		)
+		return err
+	}
+
+	c.NoError(fetch("token_a", "gateway_a"))
+	c.NoError(fetch("token_b", "gateway_b"))
+
+	// A client is not served the GatewayEndpoints of a tenant its token is not accepted for.
+	c.Equal(codes.PermissionDenied, status.Code(fetch("token_a", "gateway_b")))
+	c.Equal(codes.PermissionDenied, status.Code(fetch("token_b", "gateway_a")))
+	// Authorization is checked before the tenant is looked up, so unknown tenants are not revealed.
+	c.Equal(codes.PermissionDenied, status.Code(fetch("token_a", "gateway_c")))
+}
+
+func Test_tenantRouter_TenantStats(t *testing.T) {
+	c := require.New(t)
+
+	dataSources := map[string]*datasourcetest.DataSource{
+		"gateway_a": datasourcetest.New(conformanceEndpoints(), NewUpdateFeed),
+		"gateway_b": datasourcetest.New(nil, NewUpdateFeed),
+	}
+	router, err := NewTenantRouter(map[string]AuthDataSource{
+		"gateway_a": dataSources["gateway_a"],
+		"gateway_b": dataSources["gateway_b"],
+	}, StreamConfig{}, FullSyncConfig{}, polyzero.NewLogger())
+	c.NoError(err)
+
+	dataSources["gateway_b"].SetReady(false)
+	dataSources["gateway_b"].Put(conformanceEndpoint("endpoint_1", "api_key_1", "account_1", "PLAN_FREE"))
+	c.Eventually(func() bool {
+		server, _ := router.Server("gateway_b")
+		return server.store.Snapshot().Revision() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	c.Equal([]string{"gateway_a", "gateway_b"}, router.Tenants())
+	c.False(router.Ready())
+	ready, ok := router.TenantReady("gateway_a")
+	c.True(ready)
+	c.True(ok)
+	_, ok = router.TenantReady("gateway_c")
+	c.False(ok)
+
+	c.Equal([]metrics.TenantStats{
+		{Tenant: "gateway_a", Ready: true, GatewayEndpoints: 2},
+		{Tenant: "gateway_b", Ready: false, GatewayEndpoints: 1, UpdatesApplied: 1},
+	}, router.TenantStats())
+}
@@ -0,0 +1,118 @@
+package grpc
+
+import (
+	"sync"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	protobuf "google.golang.org/protobuf/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
+)
+
+// UpdateFeed tracks the GatewayEndpoints served by a data source and implements the atomic
+// subscription of SubscribeAuthData on top of the data source's updates channel.
+//
+// The data source sends every update to a single updates channel, from which the UpdateFeed applies
+// them in order to the served GatewayEndpoints. Subscribe returns the served GatewayEndpoints along
+// with a channel of the updates applied afterwards, taken under the same lock, so that no update is
+// lost or sent twice between them.
+//
+// Updates which would not change the served GatewayEndpoints are dropped, eg. an unchanged GatewayEndpoint
+// sent again because its YAML file was rewritten or its database row was updated with the same values,
+// or a delete of a GatewayEndpoint which is not served.
+type UpdateFeed struct {
+	updatesCh <-chan *proto.AuthDataUpdate
+
+	// served holds the GatewayEndpoints with every applied update.
+	served   map[string]*proto.GatewayEndpoint
+	servedMu sync.Mutex
+	// subscriberCh receives the applied updates once Subscribe is called.
+	subscriberCh chan *proto.AuthDataUpdate
+}
+
+// NewUpdateFeed returns an UpdateFeed which serves the GatewayEndpoints and applies the updates from the updates channel.
+//
+// Updates are read from the updates channel from the start, so that the data source never blocks on a full
+// channel before it is subscribed to, until the updates channel is closed.
+func NewUpdateFeed(gatewayEndpoints map[string]*proto.GatewayEndpoint, updatesCh <-chan *proto.AuthDataUpdate) *UpdateFeed {
+	served := make(map[string]*proto.GatewayEndpoint, len(gatewayEndpoints))
+	for endpointID, gatewayEndpoint := range gatewayEndpoints {
+		served[endpointID] = gatewayEndpoint
+	}
+
+	f := &UpdateFeed{
+		updatesCh: updatesCh,
+		served:    served,
+	}
+	go f.applyUpdates()
+
+	return f
+}
+
+// Snapshot returns a copy of the served GatewayEndpoints, with every update applied so far.
+func (f *UpdateFeed) Snapshot() map[string]*proto.GatewayEndpoint {
+	f.servedMu.Lock()
+	defer f.servedMu.Unlock()
+
+	return f.copyServed()
+}
+
+// Subscribe returns a copy of the served GatewayEndpoints along with a channel of every update applied afterwards.
+// It may only be called once; subsequent calls return ErrAlreadySubscribed.
+func (f *UpdateFeed) Subscribe() (map[string]*proto.GatewayEndpoint, <-chan *proto.AuthDataUpdate, error) {
+	f.servedMu.Lock()
+	defer f.servedMu.Unlock()
+
+	if f.subscriberCh != nil {
+		return nil, nil, ErrAlreadySubscribed
+	}
+	f.subscriberCh = make(chan *proto.AuthDataUpdate, 100_000)
+
+	return f.copyServed(), f.subscriberCh, nil
+}
+
+// applyUpdates applies the updates from the updates channel in order, until it is closed.
+func (f *UpdateFeed) applyUpdates() {
+	for update := range f.updatesCh {
+		f.apply(update)
+	}
+}
+
+// apply applies the update to the served GatewayEndpoints and sends it to the subscriber, if there is one,
+// unless it does not change the served GatewayEndpoints, in which case the span carried with it is dropped.
+//
+// The update is sent while holding the lock, so that it is never sent to a subscriber whose snapshot already includes it.
+func (f *UpdateFeed) apply(update *proto.AuthDataUpdate) {
+	f.servedMu.Lock()
+	defer f.servedMu.Unlock()
+
+	endpointID := update.GetEndpointId()
+	served, ok := f.served[endpointID]
+
+	if update.GetDelete() {
+		if !ok {
+			tracing.Updates.Take(update)
+			return
+		}
+		delete(f.served, endpointID)
+	} else {
+		if ok && protobuf.Equal(served, update.GetGatewayEndpoint()) {
+			tracing.Updates.Take(update)
+			return
+		}
+		f.served[endpointID] = update.GetGatewayEndpoint()
+	}
+
+	if f.subscriberCh != nil {
+		f.subscriberCh <- update
+	}
+}
+
+// copyServed returns a copy of the served GatewayEndpoints. It must be called with the lock held.
+func (f *UpdateFeed) copyServed() map[string]*proto.GatewayEndpoint {
+	gatewayEndpoints := make(map[string]*proto.GatewayEndpoint, len(f.served))
+	for endpointID, gatewayEndpoint := range f.served {
+		gatewayEndpoints[endpointID] = gatewayEndpoint
+	}
+	return gatewayEndpoints
+}
@@ -0,0 +1,247 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	protobuf "google.golang.org/protobuf/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
+)
+
+func Test_UpdateFeed(t *testing.T) {
+	endpoint1 := conformanceEndpoint("endpoint_1", "api_key_1", "account_1", "PLAN_FREE")
+	endpoint1Rotated := conformanceEndpoint("endpoint_1", "api_key_1_rotated", "account_1", "PLAN_FREE")
+	endpoint2 := conformanceEndpoint("endpoint_2", "", "account_2", "PLAN_UNLIMITED")
+
+	tests := []struct {
+		name             string
+		gatewayEndpoints map[string]*proto.GatewayEndpoint
+		updates          []*proto.AuthDataUpdate
+		expectedUpdates  []*proto.AuthDataUpdate
+		expectedSnapshot map[string]*proto.GatewayEndpoint
+	}{
+		{
+			name:             "should send updates which change the served endpoints",
+			gatewayEndpoints: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1},
+			updates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1Rotated},
+				{EndpointId: "endpoint_2", GatewayEndpoint: endpoint2},
+				{EndpointId: "endpoint_1", Delete: true},
+			},
+			expectedUpdates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1Rotated},
+				{EndpointId: "endpoint_2", GatewayEndpoint: endpoint2},
+				{EndpointId: "endpoint_1", Delete: true},
+			},
+			expectedSnapshot: map[string]*proto.GatewayEndpoint{"endpoint_2": endpoint2},
+		},
+		{
+			name:             "should drop updates which do not change the served endpoints",
+			gatewayEndpoints: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1},
+			updates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1},
+				{EndpointId: "endpoint_2", GatewayEndpoint: endpoint2},
+				{EndpointId: "endpoint_2", GatewayEndpoint: endpoint2},
+				{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1Rotated},
+			},
+			expectedUpdates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_2", GatewayEndpoint: endpoint2},
+				{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1Rotated},
+			},
+			expectedSnapshot: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1Rotated, "endpoint_2": endpoint2},
+		},
+		{
+			name:             "should drop deletes of endpoints which are not served",
+			gatewayEndpoints: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1},
+			updates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_1", Delete: true},
+				{EndpointId: "endpoint_1", Delete: true},
+				{EndpointId: "endpoint_3", Delete: true},
+				{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1},
+			},
+			expectedUpdates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_1", Delete: true},
+				{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1},
+			},
+			expectedSnapshot: map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			updatesCh := make(chan *proto.AuthDataUpdate, len(test.updates))
+			feed := NewUpdateFeed(test.gatewayEndpoints, updatesCh)
+
+			snapshot, subscriberCh, err := feed.Subscribe()
+			c.NoError(err)
+			c.Equal(test.gatewayEndpoints, snapshot)
+
+			for _, update := range test.updates {
+				updatesCh <- update
+			}
+
+			for _, expectedUpdate := range test.expectedUpdates {
+				select {
+				case update := <-subscriberCh:
+					c.Equal(expectedUpdate, update)
+				case <-time.After(time.Second):
+					t.Fatal("timed out waiting for update")
+				}
+			}
+
+			select {
+			case update := <-subscriberCh:
+				t.Fatalf("unexpected update for endpoint %s", update.GetEndpointId())
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			c.Equal(test.expectedSnapshot, feed.Snapshot())
+		})
+	}
+}
+
+func Test_UpdateFeed_subscribeOnce(t *testing.T) {
+	c := require.New(t)
+
+	feed := NewUpdateFeed(nil, make(chan *proto.AuthDataUpdate))
+
+	_, _, err := feed.Subscribe()
+	c.NoError(err)
+
+	_, _, err = feed.Subscribe()
+	c.ErrorIs(err, ErrAlreadySubscribed)
+}
+
+func Test_UpdateFeed_appliesUpdatesBeforeSubscribe(t *testing.T) {
+	c := require.New(t)
+
+	// The updates channel is unbuffered, so every send blocks until the update is read.
+	updatesCh := make(chan *proto.AuthDataUpdate)
+	feed := NewUpdateFeed(nil, updatesCh)
+
+	endpoint1 := conformanceEndpoint("endpoint_1", "api_key_1", "account_1", "PLAN_FREE")
+	select {
+	case updatesCh <- &proto.AuthDataUpdate{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1}:
+	case <-time.After(time.Second):
+		t.Fatal("timed out sending update before subscribing")
+	}
+
+	c.Eventually(func() bool {
+		return len(feed.Snapshot()) == 1
+	}, time.Second, time.Millisecond)
+
+	snapshot, _, err := feed.Subscribe()
+	c.NoError(err)
+	c.Equal(map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1}, snapshot)
+}
+
+func Test_UpdateFeed_droppedUpdateTrace(t *testing.T) {
+	c := require.New(t)
+
+	endpoint1 := conformanceEndpoint("endpoint_1", "api_key_1", "account_1", "PLAN_FREE")
+	feed := NewUpdateFeed(map[string]*proto.GatewayEndpoint{"endpoint_1": endpoint1}, make(chan *proto.AuthDataUpdate))
+	_, updatesCh, err := feed.Subscribe()
+	c.NoError(err)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	// The span of a dropped update is not carried any longer.
+	unchanged := &proto.AuthDataUpdate{EndpointId: "endpoint_1", GatewayEndpoint: endpoint1}
+	tracing.Updates.Carry(ctx, unchanged)
+	feed.apply(unchanged)
+	_, ok := tracing.Updates.Take(unchanged)
+	c.False(ok)
+
+	// The span of an applied update is carried until the update is handled.
+	deleted := &proto.AuthDataUpdate{EndpointId: "endpoint_1", Delete: true}
+	tracing.Updates.Carry(ctx, deleted)
+	feed.apply(deleted)
+	c.Same(deleted, <-updatesCh)
+	_, ok = tracing.Updates.Take(deleted)
+	c.True(ok)
+}
+
+// Test_UpdateFeed_subscribeWhileUpdating subscribes while updates are being applied: the snapshot with the
+// subscribed updates applied must always reach the final state, and every subscribed update must change it.
+func Test_UpdateFeed_subscribeWhileUpdating(t *testing.T) {
+	c := require.New(t)
+
+	const updateCount = 200
+
+	for iteration := range 50 {
+		updatesCh := make(chan *proto.AuthDataUpdate, updateCount)
+		feed := NewUpdateFeed(newTestGatewayEndpoints(10), updatesCh)
+		// Start applying updates before subscribing, so that the subscription lands between updates.
+		feed.Snapshot()
+
+		expected := newTestGatewayEndpoints(10)
+		var updates []*proto.AuthDataUpdate
+		for i := range updateCount {
+			endpointID := testEndpointID(i % 20)
+			update := &proto.AuthDataUpdate{EndpointId: endpointID, Delete: true}
+			if i%3 != 0 {
+				update = &proto.AuthDataUpdate{EndpointId: endpointID, GatewayEndpoint: conformanceEndpoint(endpointID, testEndpointID(i), "account_1", "PLAN_FREE")}
+			}
+			updates = append(updates, update)
+
+			if update.GetDelete() {
+				delete(expected, endpointID)
+			} else {
+				expected[endpointID] = update.GetGatewayEndpoint()
+			}
+		}
+
+		go func() {
+			for i, update := range updates {
+				updatesCh <- update
+				if i == updateCount/2+iteration {
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}()
+		time.Sleep(time.Duration(iteration%5) * 100 * time.Microsecond)
+
+		mirror, subscriberCh, err := feed.Subscribe()
+		c.NoError(err)
+
+		deadline := time.After(2 * time.Second)
+		for !sameGatewayEndpoints(expected, mirror) {
+			select {
+			case update := <-subscriberCh:
+				served, ok := mirror[update.GetEndpointId()]
+				if update.GetDelete() {
+					c.True(ok, "delete of endpoint %s which is not in the snapshot", update.GetEndpointId())
+					delete(mirror, update.GetEndpointId())
+					continue
+				}
+				c.False(ok && protobuf.Equal(served, update.GetGatewayEndpoint()), "update for endpoint %s which is already in the snapshot", update.GetEndpointId())
+				mirror[update.GetEndpointId()] = update.GetGatewayEndpoint()
+			case <-deadline:
+				t.Fatalf("iteration %d: snapshot with subscribed updates did not reach the final state", iteration)
+			}
+		}
+	}
+}
+
+func sameGatewayEndpoints(expected, actual map[string]*proto.GatewayEndpoint) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for endpointID, gatewayEndpoint := range expected {
+		if !protobuf.Equal(gatewayEndpoint, actual[endpointID]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+/*
+Package keepalive detects half-open client connections, eg. from a PEAS behind a NAT which silently dropped
+the connection, so that the gRPC server notices the client is gone and stores its updates as pending, and
+limits the age of client connections, eg. so that clients are rebalanced across PADS replicas.
+
+gRPC is served through net/http (see grpc.NewHTTPHandler), where grpc-go's keepalive server options do not
+apply, so dead peers are detected by the HTTP/2 server and at the TCP level instead:
+
+  - Connections from which no frame was read for PingTime are sent an HTTP/2 ping, and closed if it is not
+    answered within PingTimeout. Pings are answered by clients at the HTTP/2 level, so they detect a dead
+    peer behind a proxy which answers TCP keepalives itself.
+  - Idle connections are probed with TCP keepalives, and closed if Count probes are not answered.
+  - On Linux, connections with unacknowledged data, eg. updates sent into a dropped NAT mapping, are closed
+    once the data has been unacknowledged for the same time, using TCP_USER_TIMEOUT.
+
+Closing the connection cancels the context of the client's update stream.
+
+Connections may also be limited to a max age, as with grpc-go's MaxConnectionAge. Once a connection reaches its
+max age, a GOAWAY is sent with the response to its next request, so that the client opens a new connection for
+any later request, and the connection is closed once its streams are done, or forcibly once the grace period ends.
+The gRPC server closes the update stream of a connection which reached its max age (see ConnectionDeadline),
+so that the client reconnects.
+*/
+package keepalive
+
+import (
+	"context"
+	"crypto/tls"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pokt-network/poktroll/pkg/polylog"
+	"golang.org/x/net/http2"
+)
+
+// The default Config values, used for any value which is not set.
+// The max connection age and its grace period are not limited by default.
+const (
+	DefaultTime        = 30 * time.Second
+	DefaultInterval    = 10 * time.Second
+	DefaultCount       = 3
+	DefaultPingTime    = time.Minute
+	DefaultPingTimeout = 20 * time.Second
+)
+
+// Config configures how dead client connections are detected.
+type Config struct {
+	// Time is how long a connection must be idle before it is probed.
+	Time time.Duration
+	// Interval is the time between probes.
+	Interval time.Duration
+	// Count is the number of unanswered probes after which the connection is closed.
+	Count int
+
+	// PingTime is how long a connection may go without a frame being read before it is sent an HTTP/2 ping.
+	PingTime time.Duration
+	// PingTimeout is how long an HTTP/2 ping may go unanswered before the connection is closed.
+	PingTimeout time.Duration
+
+	// MaxConnectionAge is how long a connection is served before the client is asked to open a new one.
+	// It is jittered by up to 10%. Zero disables it.
+	MaxConnectionAge time.Duration
+	// MaxConnectionAgeGrace is how long a connection which reached its max age is given for its streams
+	// to be done before it is forcibly closed. Zero waits for them indefinitely.
+	MaxConnectionAgeGrace time.Duration
+}
+
+// withDefaults returns the Config with the default value of any value which is not set.
+func (c Config) withDefaults() Config {
+	if c.Time <= 0 {
+		c.Time = DefaultTime
+	}
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Count <= 0 {
+		c.Count = DefaultCount
+	}
+	if c.PingTime <= 0 {
+		c.PingTime = DefaultPingTime
+	}
+	if c.PingTimeout <= 0 {
+		c.PingTimeout = DefaultPingTimeout
+	}
+	return c
+}
+
+// DetectionTime is the longest a dead connection may go undetected, ie. the time until an idle connection
+// is closed for not answering probes, or until a connection is closed for not acknowledging sent data.
+// Any value which is not set is defaulted.
+func (c Config) DetectionTime() time.Duration {
+	c = c.withDefaults()
+	return c.Time + time.Duration(c.Count)*c.Interval
+}
+
+// HTTP2Server returns the HTTP/2 server configuration which pings the connections, to serve gRPC with.
+// Any value which is not set is defaulted.
+func (c Config) HTTP2Server() *http2.Server {
+	c = c.withDefaults()
+	return &http2.Server{
+		ReadIdleTimeout: c.PingTime,
+		PingTimeout:     c.PingTimeout,
+	}
+}
+
+// Listen listens on the TCP address, and detects dead client connections on every accepted connection,
+// whose age is limited if MaxConnectionAge is set. Any Config value which is not set is defaulted.
+func Listen(ctx context.Context, address string, config Config, logger polylog.Logger) (net.Listener, error) {
+	config = config.withDefaults()
+
+	listenConfig := net.ListenConfig{
+		KeepAliveConfig: net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     config.Time,
+			Interval: config.Interval,
+			Count:    config.Count,
+		},
+	}
+	ln, err := listenConfig.Listen(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &listener{Listener: ln, userTimeout: config.DetectionTime(), config: config, logger: logger}, nil
+}
+
+// listener sets the user timeout on every accepted connection, and limits its age if configured.
+type listener struct {
+	net.Listener
+	userTimeout time.Duration
+	config      Config
+
+	logger polylog.Logger
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	// A connection whose user timeout cannot be set is still served, relying on the keepalive probes and pings:
+	// returning the error would stop the server from accepting any other connection.
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := setUserTimeout(tcpConn, l.userTimeout); err != nil {
+			l.logger.Warn().Err(err).Str("remote_addr", conn.RemoteAddr().String()).Msg("failed to set TCP user timeout on accepted connection")
+		}
+	}
+
+	if l.config.MaxConnectionAge <= 0 {
+		return conn, nil
+	}
+	return newAgedConn(conn, Jitter(l.config.MaxConnectionAge), l.config.MaxConnectionAgeGrace), nil
+}
+
+/* ---------- Connection Age ---------- */
+
+// agedConn is a connection with a max age, which is forcibly closed once the grace period after it ends, if set.
+type agedConn struct {
+	net.Conn
+	deadline time.Time
+
+	closeTimer *time.Timer
+	closeOnce  sync.Once
+}
+
+func newAgedConn(conn net.Conn, maxAge, grace time.Duration) *agedConn {
+	c := &agedConn{Conn: conn, deadline: time.Now().Add(maxAge)}
+	if grace > 0 {
+		c.closeTimer = time.AfterFunc(maxAge+grace, func() { c.Close() })
+	}
+	return c
+}
+
+func (c *agedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		if c.closeTimer != nil {
+			c.closeTimer.Stop()
+		}
+	})
+	return err
+}
+
+// connDeadlineKey is the context key of the time at which a connection reaches its max age.
+type connDeadlineKey struct{}
+
+// ConnContext returns the context of a connection accepted by a listener returned by Listen, with the time at which
+// the connection reaches its max age, if it has one. It must be set as the http.Server's ConnContext.
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if agedConn, ok := conn.(*agedConn); ok {
+		return context.WithValue(ctx, connDeadlineKey{}, agedConn.deadline)
+	}
+	return ctx
+}
+
+// ConnectionDeadline returns the time at which the connection of a request reaches its max age,
+// or false if its age is not limited.
+func ConnectionDeadline(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Value(connDeadlineKey{}).(time.Time)
+	return deadline, ok
+}
+
+// Jitter returns the duration jittered by up to 10% either way, so that connections or streams
+// which were opened together are not all closed together.
+func Jitter(d time.Duration) time.Duration {
+	return d + time.Duration(rand.Int64N(int64(d)/5+1)) - d/10
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+package keepalive
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func Test_Listen(t *testing.T) {
+	c := require.New(t)
+
+	config := Config{Time: 5 * time.Second, Interval: 2 * time.Second, Count: 4}
+	c.Equal(13*time.Second, config.DetectionTime())
+
+	ln, err := Listen(context.Background(), "127.0.0.1:0", config, polyzero.NewLogger())
+	c.NoError(err)
+	defer ln.Close()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	c.NoError(err)
+	defer clientConn.Close()
+
+	conn, err := ln.Accept()
+	c.NoError(err)
+	defer conn.Close()
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	c.NoError(err)
+
+	sockopt := func(level, opt int) int {
+		var value int
+		var sockoptErr error
+		c.NoError(rawConn.Control(func(fd uintptr) {
+			value, sockoptErr = unix.GetsockoptInt(int(fd), level, opt)
+		}))
+		c.NoError(sockoptErr)
+		return value
+	}
+
+	c.Equal(1, sockopt(unix.SOL_SOCKET, unix.SO_KEEPALIVE))
+	c.Equal(5, sockopt(unix.IPPROTO_TCP, unix.TCP_KEEPIDLE))
+	c.Equal(2, sockopt(unix.IPPROTO_TCP, unix.TCP_KEEPINTVL))
+	c.Equal(4, sockopt(unix.IPPROTO_TCP, unix.TCP_KEEPCNT))
+	c.Equal(13_000, sockopt(unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT))
+}
+
+func Test_listener_Accept_userTimeoutFailure(t *testing.T) {
+	c := require.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.NoError(err)
+	defer ln.Close()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	c.NoError(err)
+	defer clientConn.Close()
+
+	// The accepted connection is closed, so that its user timeout cannot be set.
+	conn, err := ln.Accept()
+	c.NoError(err)
+	c.NoError(conn.Close())
+
+	keepaliveListener := &listener{
+		Listener:    &acceptedListener{Listener: ln, conn: conn},
+		userTimeout: time.Second,
+		logger:      polyzero.NewLogger(),
+	}
+
+	// The connection is still returned, so that the server keeps accepting connections.
+	accepted, err := keepaliveListener.Accept()
+	c.NoError(err)
+	c.Equal(conn, accepted)
+}
+
+// acceptedListener returns an already accepted connection.
+type acceptedListener struct {
+	net.Listener
+	conn net.Conn
+}
+
+func (l *acceptedListener) Accept() (net.Conn, error) { return l.conn, nil }
+
+func Test_Config_DetectionTime_defaults(t *testing.T) {
+	require.Equal(t, DefaultTime+DefaultCount*DefaultInterval, Config{}.DetectionTime())
+}
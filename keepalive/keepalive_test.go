@@ -0,0 +1,79 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Config_HTTP2Server_defaults(t *testing.T) {
+	c := require.New(t)
+
+	http2Server := Config{}.HTTP2Server()
+	c.Equal(DefaultPingTime, http2Server.ReadIdleTimeout)
+	c.Equal(DefaultPingTimeout, http2Server.PingTimeout)
+
+	http2Server = Config{PingTime: time.Second, PingTimeout: 2 * time.Second}.HTTP2Server()
+	c.Equal(time.Second, http2Server.ReadIdleTimeout)
+	c.Equal(2*time.Second, http2Server.PingTimeout)
+}
+
+func Test_Listen_maxConnectionAge(t *testing.T) {
+	c := require.New(t)
+
+	ln, err := Listen(context.Background(), "127.0.0.1:0", Config{
+		MaxConnectionAge:      time.Hour,
+		MaxConnectionAgeGrace: 50 * time.Millisecond,
+	}, polyzero.NewLogger())
+	c.NoError(err)
+	defer ln.Close()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	c.NoError(err)
+	defer clientConn.Close()
+
+	before := time.Now()
+	conn, err := ln.Accept()
+	c.NoError(err)
+	defer conn.Close()
+
+	// The connection's deadline is its jittered max age.
+	deadline, ok := ConnectionDeadline(ConnContext(context.Background(), conn))
+	c.True(ok)
+	c.WithinRange(deadline, before.Add(54*time.Minute), time.Now().Add(66*time.Minute))
+
+	// Connections accepted without a max age have no deadline.
+	_, ok = ConnectionDeadline(ConnContext(context.Background(), clientConn))
+	c.False(ok)
+}
+
+func Test_Listen_maxConnectionAgeGrace(t *testing.T) {
+	c := require.New(t)
+
+	ln, err := Listen(context.Background(), "127.0.0.1:0", Config{
+		MaxConnectionAge:      50 * time.Millisecond,
+		MaxConnectionAgeGrace: 50 * time.Millisecond,
+	}, polyzero.NewLogger())
+	c.NoError(err)
+	defer ln.Close()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	c.NoError(err)
+	defer clientConn.Close()
+
+	conn, err := ln.Accept()
+	c.NoError(err)
+	defer conn.Close()
+
+	// The connection is forcibly closed once the grace period after its max age ends.
+	c.NoError(clientConn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = clientConn.Read(make([]byte, 1))
+	c.Error(err)
+	var netErr net.Error
+	c.False(errors.As(err, &netErr) && netErr.Timeout(), "connection was not closed after its grace period")
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package keepalive
+
+import (
+	"net"
+	"time"
+)
+
+// setUserTimeout is a no-op outside of Linux: dead connections are only detected by keepalive probes
+// once idle, and by the stream send timeout if sends block.
+func setUserTimeout(conn *net.TCPConn, timeout time.Duration) error {
+	return nil
+}
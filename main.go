@@ -2,102 +2,501 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
-	"github.com/buildwithgrove/path-external-auth-server/proto"
 	"github.com/pokt-network/poktroll/pkg/polylog"
 	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
 	"golang.org/x/net/http2"
-	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
 
+	"github.com/buildwithgrove/path-auth-data-server/admin"
+	"github.com/buildwithgrove/path-auth-data-server/clientauth"
+	"github.com/buildwithgrove/path-auth-data-server/composite"
+	"github.com/buildwithgrove/path-auth-data-server/config"
 	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/keepalive"
+	"github.com/buildwithgrove/path-auth-data-server/metrics"
 	grove_postgres "github.com/buildwithgrove/path-auth-data-server/postgres/grove"
+	"github.com/buildwithgrove/path-auth-data-server/redact"
+	"github.com/buildwithgrove/path-auth-data-server/snapshot"
+	"github.com/buildwithgrove/path-auth-data-server/startup"
+	"github.com/buildwithgrove/path-auth-data-server/tlsconfig"
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
 	"github.com/buildwithgrove/path-auth-data-server/yaml"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
 func main() {
-	logger := polyzero.NewLogger()
-
-	env, err := gatherEnvVars()
-	if err != nil {
-		panic(fmt.Errorf("failed to gather environment variables: %v", err))
+	// If a subcommand is provided (eg. `pads hash-keys`), run it instead of the server.
+	if len(os.Args) > 1 && isCommand(os.Args[1]) {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
 	}
 
-	// 1. Load the data source
-	authDataSource, cleanup, err := getAuthDataSource(env, logger)
+	// Load the configuration from the optional config file, overridden by environment variables.
+	cfg := loadConfig(os.Args[1:])
+
+	logger := newLogger(cfg.Logging.Level)
+
+	// Export the spans of every update to the OTLP collector, if tracing is enabled
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingConfig(cfg))
 	if err != nil {
 		panic(err)
 	}
-	defer cleanup()
+	defer shutdownTracing(context.Background())
+	if tracingConfig(cfg).Enabled() {
+		logger.Info().
+			Str("protocol", cfg.Tracing.OTLP.Protocol).
+			Float64("sample_ratio", cfg.Tracing.SampleRatio).
+			Msg("Tracing enabled.")
+	}
 
-	// 2. Initialize the gRPC server that will serve the Gateway Endpoints
-	ln, err := net.Listen("tcp", fmt.Sprintf(":%s", env.port))
+	// 1. Load the data sources of each tenant, retrying in the background if they are unavailable
+	authDataSources := make(map[string]startupAuthDataSource)
+	for tenant, dataSources := range cfg.TenantDataSources() {
+		authDataSource, err := getStartupAuthDataSource(cfg, tenant, dataSources, logger.With("tenant", tenant))
+		if err != nil {
+			panic(err)
+		}
+		defer authDataSource.Close()
+		authDataSources[tenant] = authDataSource
+	}
+
+	// 2. Initialize the gRPC server that will serve the Gateway Endpoints of each tenant
+	// Dead client connections are detected by the listener, so that their streams are closed and updates kept pending.
+	ln, err := keepalive.Listen(context.Background(), fmt.Sprintf(":%s", cfg.Server.Port), keepaliveConfig(cfg), logger)
 	if err != nil {
 		panic(fmt.Sprintf("failed to listen: %v", err))
 	}
 
-	server, err := grpc_server.NewGRPCServer(authDataSource, logger)
+	router, err := grpc_server.NewTenantRouter(tenantAuthDataSources(authDataSources), streamConfig(cfg), fullSyncConfig(cfg), logger)
 	if err != nil {
 		panic(fmt.Sprintf("failed to create server: %v", err))
 	}
+	metrics.RegisterTenants(router.TenantStats)
+	logger.Info().Str("tenants", strings.Join(router.Tenants(), ",")).Msg("Serving tenants.")
+
+	// Periodically persist the GatewayEndpoints served to each tenant to its snapshot file, if configured
+	if cfg.Snapshot.Enabled() {
+		for _, tenant := range router.Tenants() {
+			server, _ := router.Server(tenant) // Every tenant listed by the router is served.
+			persister, err := getSnapshotPersister(cfg, tenant, server.GatewayEndpoints, authDataSources[tenant].Live, logger.With("tenant", tenant))
+			if err != nil {
+				panic(err)
+			}
+			go persister.Run(context.Background())
+		}
+	}
 
-	grpcServer := grpc.NewServer()
-	proto.RegisterGatewayEndpointsServer(grpcServer, server)
+	grpcServerOpts, err := getGRPCServerOptions(cfg, logger)
+	if err != nil {
+		panic(err)
+	}
 
-	// create a new HTTP server mux for health check on `/healthz`
-	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			logger.Error().Err(err).Msg("failed to write health check response")
+	grpcServer := router.NewServer(grpcServerOpts...)
+
+	// Start the admin API of each tenant on its own port, if enabled
+	if cfg.Admin.Port != "" {
+		stores := make(map[string]admin.EndpointStore)
+		for _, tenant := range router.Tenants() {
+			server, _ := router.Server(tenant) // Every tenant listed by the router is served.
+			stores[tenant] = server
 		}
-	})
-
-	// create a new HTTP handler that serves both gRPC (for Gateway Endpoints) and HTTP (for health check)
-	grpcAndHTTPHandler := h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if grpc_server.IsRequestGRPC(r) {
-			grpcServer.ServeHTTP(w, r)
-		} else {
-			mux.ServeHTTP(w, r)
+		adminHandler, err := getAdminHandler(cfg, stores, tenantAuthDataSources(authDataSources), logger)
+		if err != nil {
+			panic(err)
 		}
-	}), &http2.Server{})
+		go serveAdminAPI(cfg.Admin.Port, adminHandler, logger)
+	}
+
+	// create a new HTTP handler that serves both gRPC (for Gateway Endpoints) and HTTP (for health check and metrics)
+	// Connections are pinged by the HTTP/2 server, and their age is limited by the listener, if configured.
+	http2Server := keepaliveConfig(cfg).HTTP2Server()
+	grpcAndHTTPHandler := grpc_server.NewHTTPHandler(grpcServer, router, http2Server, logger)
+
+	httpServer := &http.Server{Handler: grpcAndHTTPHandler, ConnContext: keepalive.ConnContext}
 
-	logger.Info().Str(portEnv, env.port).Msg("PATH Auth Data Server listening.")
+	// 3. Serve plaintext h2c, or TLS if a certificate and key are configured
+	if !cfg.TLSEnabled() {
+		logger.Info().Str("port", cfg.Server.Port).Msg("PATH Auth Data Server listening.")
 
-	httpServer := &http.Server{Handler: grpcAndHTTPHandler}
-	if err := httpServer.Serve(ln); err != nil {
+		if err := httpServer.Serve(ln); err != nil {
+			panic(fmt.Sprintf("failed to serve: %v", err))
+		}
+		return
+	}
+
+	tlsReloader, err := tlsconfig.NewReloader(tlsConfig(cfg), logger)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load TLS configuration: %v", err))
+	}
+	defer tlsReloader.Close()
+
+	httpServer.TLSConfig = tlsReloader.TLSConfig()
+	if err := http2.ConfigureServer(httpServer, http2Server); err != nil {
+		panic(fmt.Sprintf("failed to configure HTTP/2 over TLS: %v", err))
+	}
+
+	logger.Info().
+		Str("port", cfg.Server.Port).
+		Bool("mtls", cfg.Server.TLS.ClientCAFile != "").
+		Msg("PATH Auth Data Server listening with TLS.")
+
+	// The certificate and key are provided by the TLS config, which reloads them on change.
+	if err := httpServer.ServeTLS(ln, "", ""); err != nil {
 		panic(fmt.Sprintf("failed to serve: %v", err))
 	}
 }
 
+/* ------------------------------- Load Configuration ------------------------------- */
+
+// loadConfig parses the server flags and loads the configuration, exiting with an error report if it is invalid.
+// If --print-config is set, the effective configuration is printed with all secret values redacted, and the process exits.
+func loadConfig(args []string) config.Config {
+	flags := flag.NewFlagSet("pads", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pads [flags]\n\nStarts the PATH Auth Data Server.\n\nFlags:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "\nRun `pads help` for the list of commands.\n")
+	}
+	configFile := addConfigFlag(flags)
+	printConfig := flags.Bool("print-config", false, "Print the effective configuration, with all secret values redacted, and exit.")
+	_ = flags.Parse(args) // exits on error
+
+	cfg, err := config.Load(*configFile)
+
+	// An invalid configuration is still printed, as it is useful to see where the problems are.
+	var validationErr *config.ValidationError
+	if *printConfig && (err == nil || errors.As(err, &validationErr)) {
+		redactedYAML, marshalErr := cfg.RedactedYAML()
+		if marshalErr != nil {
+			fmt.Fprintf(os.Stderr, "failed to print configuration: %v\n", marshalErr)
+			os.Exit(1)
+		}
+		os.Stdout.Write(redactedYAML)
+		if err == nil {
+			os.Exit(0)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	return cfg
+}
+
+// newLogger returns a logger at the given level, which is one of debug, info, warn or error.
+// All logs are redacted to ensure secret values, such as API keys, are never logged.
+func newLogger(level string) polylog.Logger {
+	return redact.NewLogger(polyzero.NewLogger(polyzero.WithLevel(polyzero.ParseLevel(level))))
+}
+
+/* ------------------------------- Server Options ------------------------------- */
+
+// The options of the server packages are mapped from the configuration here, so that the config
+// package does not depend on the packages it configures.
+
+// streamConfig returns the configuration of the update streams of the gRPC server.
+//
+// The updates sent within the time it may take to detect a dead client connection are resent
+// to the next client to connect, as they may have been sent into the dead connection.
+func streamConfig(cfg config.Config) grpc_server.StreamConfig {
+	return grpc_server.StreamConfig{
+		QueueSize:          cfg.Server.Stream.QueueSize,
+		SendTimeout:        cfg.Server.Stream.SendTimeout,
+		SlowConsumerPolicy: grpc_server.SlowConsumerPolicy(cfg.Server.Stream.SlowConsumerPolicy),
+		MaxAge:             cfg.Server.Stream.MaxAge,
+		ResendWindow:       keepaliveConfig(cfg).DetectionTime(),
+	}
+}
+
+// fullSyncConfig returns the configuration of how the full set of GatewayEndpoints is chunked by the FullSync service.
+func fullSyncConfig(cfg config.Config) grpc_server.FullSyncConfig {
+	return grpc_server.FullSyncConfig{
+		ChunkSize:     cfg.Server.FullSync.ChunkSize,
+		MaxChunkBytes: cfg.Server.FullSync.MaxChunkBytes,
+	}
+}
+
+// keepaliveConfig returns the configuration of how the gRPC server detects dead client connections
+// and limits their age.
+func keepaliveConfig(cfg config.Config) keepalive.Config {
+	return keepalive.Config{
+		Time:                  cfg.Server.Keepalive.Time,
+		Interval:              cfg.Server.Keepalive.Interval,
+		Count:                 cfg.Server.Keepalive.Count,
+		PingTime:              cfg.Server.Keepalive.PingTime,
+		PingTimeout:           cfg.Server.Keepalive.PingTimeout,
+		MaxConnectionAge:      cfg.Server.Keepalive.MaxConnectionAge,
+		MaxConnectionAgeGrace: cfg.Server.Keepalive.MaxConnectionAgeGrace,
+	}
+}
+
+// tlsConfig returns the certificate and client restrictions of the TLS listener.
+func tlsConfig(cfg config.Config) tlsconfig.Config {
+	return tlsconfig.Config{
+		CertFile:           cfg.Server.TLS.CertFile,
+		KeyFile:            cfg.Server.TLS.KeyFile,
+		ClientCAFile:       cfg.Server.TLS.ClientCAFile,
+		AllowedClientNames: cfg.Server.TLS.AllowedClientNames,
+	}
+}
+
+// startupBackoff returns the backoff between attempts to connect to the data sources at startup.
+func startupBackoff(cfg config.Config) startup.Backoff {
+	return startup.Backoff{
+		Initial: cfg.Startup.InitialBackoff,
+		Max:     cfg.Startup.MaxBackoff,
+	}
+}
+
+// tracingConfig returns the configuration of how the spans of the updates to GatewayEndpoints are exported.
+func tracingConfig(cfg config.Config) tracing.Config {
+	return tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Protocol:    cfg.Tracing.OTLP.Protocol,
+		Endpoint:    cfg.Tracing.OTLP.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}
+}
+
+/* ------------------------------- Get gRPC Server Options ------------------------------- */
+
+// getGRPCServerOptions returns the options for the gRPC server.
+// If client auth tokens are configured, all gRPC requests must contain one of the tokens of the selected tenant.
+func getGRPCServerOptions(cfg config.Config, logger polylog.Logger) ([]grpc.ServerOption, error) {
+	serverOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(cfg.Server.GRPC.MaxRecvMessageSize),
+		grpc.MaxSendMsgSize(cfg.Server.GRPC.MaxSendMessageSize),
+	}
+
+	if !cfg.ClientAuthEnabled() {
+		logger.Warn().Msg("No client auth tokens are configured, gRPC clients will not be authenticated.")
+		return serverOpts, nil
+	}
+
+	// The tokens of each tenant only authorize its clients for that tenant.
+	tenantTokens := make(map[string]clientauth.Tokens)
+	for tenant, tokens := range cfg.TenantClientAuth() {
+		tenantTokens[tenant] = clientauth.Tokens{Tokens: tokens.Tokens, TokensFile: tokens.TokensFile}
+	}
+
+	authenticator, err := clientauth.NewTenantAuthenticator(tenantTokens, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client authenticator: %v", err)
+	}
+
+	logger.Info().Msg("gRPC client authentication enabled")
+
+	return append(serverOpts,
+		grpc.ChainUnaryInterceptor(authenticator.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(authenticator.StreamServerInterceptor()),
+	), nil
+}
+
+/* ------------------------------- Admin API ------------------------------- */
+
+// getAdminHandler returns the admin API handler, which requires a bearer token if admin auth tokens are configured.
+// If admin writes are enabled, the data source must support writing GatewayEndpoints.
+func getAdminHandler(
+	cfg config.Config,
+	stores map[string]admin.EndpointStore,
+	authDataSources map[string]grpc_server.AuthDataSource,
+	logger polylog.Logger,
+) (http.Handler, error) {
+	tenantHandlers := make(map[string]http.Handler, len(stores))
+	for tenant, store := range stores {
+		var writer grpc_server.AuthDataWriter
+		if cfg.Admin.WritesEnabled {
+			var ok bool
+			if writer, ok = authDataSources[tenant].(grpc_server.AuthDataWriter); !ok {
+				return nil, fmt.Errorf("admin writes are enabled but the data source of tenant %s does not support writes", tenant)
+			}
+		}
+		tenantHandlers[tenant] = admin.NewHandler(store, writer, logger.With("tenant", tenant))
+	}
+	if cfg.Admin.WritesEnabled {
+		logger.Info().Msg("Admin API writes are enabled.")
+	}
+
+	adminHandler := admin.NewTenantHandler(tenantHandlers, logger)
+
+	if !cfg.Admin.Auth.Enabled() {
+		logger.Warn().Msg("No admin auth tokens are configured, admin API requests will not be authenticated.")
+		return adminHandler, nil
+	}
+
+	authenticator, err := clientauth.NewAuthenticator(cfg.Admin.Auth.Tokens, cfg.Admin.Auth.TokensFile, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin API authenticator: %v", err)
+	}
+
+	return authenticator.HTTPHandler("admin", adminHandler), nil
+}
+
+// serveAdminAPI serves the admin API on the admin port.
+func serveAdminAPI(adminPort string, adminHandler http.Handler, logger polylog.Logger) {
+	logger.Info().Str("admin_port", adminPort).Msg("PATH Auth Data Server admin API listening.")
+
+	adminServer := &http.Server{
+		Addr:              fmt.Sprintf(":%s", adminPort),
+		Handler:           adminHandler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := adminServer.ListenAndServe(); err != nil {
+		panic(fmt.Sprintf("failed to serve admin API: %v", err))
+	}
+}
+
+/* ------------------------------- Startup ------------------------------- */
+
+// startupAuthDataSource is the AuthDataSource returned by startup.NewDataSource.
+type startupAuthDataSource interface {
+	grpc_server.AuthDataSource
+	Live() bool
+	Close()
+}
+
+// getStartupAuthDataSource returns an AuthDataSource which connects to the tenant's data sources,
+// retrying with backoff in the background if they are unavailable, so that PADS never crash-loops at startup.
+//
+// Until the data sources are connected, it serves the tenant's snapshot if one is available,
+// or no GatewayEndpoints if start empty is enabled. Otherwise, it is not ready, so gRPC requests for the tenant
+// and the readiness check fail.
+func getStartupAuthDataSource(cfg config.Config, tenant string, dataSources config.DataSources, logger polylog.Logger) (startupAuthDataSource, error) {
+	initial, err := getInitialGatewayEndpoints(cfg, tenant, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	connect := func() (grpc_server.AuthDataSource, func(), error) {
+		return getAuthDataSource(dataSources, logger)
+	}
+
+	return startup.NewDataSource(initial, connect, startupBackoff(cfg), logger), nil
+}
+
+// tenantAuthDataSources returns the startup AuthDataSources of the tenants as AuthDataSources.
+func tenantAuthDataSources(authDataSources map[string]startupAuthDataSource) map[string]grpc_server.AuthDataSource {
+	tenantAuthDataSources := make(map[string]grpc_server.AuthDataSource, len(authDataSources))
+	for tenant, authDataSource := range authDataSources {
+		tenantAuthDataSources[tenant] = authDataSource
+	}
+	return tenantAuthDataSources
+}
+
+// getInitialGatewayEndpoints returns the GatewayEndpoints served until the data sources are connected:
+// the tenant's snapshot if a valid one is available, or no GatewayEndpoints, which are ready only if start empty is enabled.
+func getInitialGatewayEndpoints(cfg config.Config, tenant string, logger polylog.Logger) (startup.Initial, error) {
+	if cfg.Snapshot.Enabled() {
+		key, err := snapshot.LoadKey(cfg.Snapshot.Key, cfg.Snapshot.KeyFile)
+		if err != nil {
+			return startup.Initial{}, err
+		}
+
+		snapshotFile := cfg.Snapshot.TenantFile(tenant)
+		endpointSnapshot, err := snapshot.Read(snapshotFile, key)
+		if err == nil {
+			logger.Info().
+				Int("endpoints", len(endpointSnapshot.GatewayEndpoints)).
+				Time("snapshot_created_at", endpointSnapshot.CreatedAt).
+				Msg("Serving GatewayEndpoints from snapshot until the data source is connected.")
+
+			return startup.Initial{Source: "snapshot", GatewayEndpoints: endpointSnapshot.GatewayEndpoints, Ready: true}, nil
+		}
+
+		// A missing or invalid snapshot is not fatal, eg. on the first start.
+		logger.Warn().Err(err).Str("snapshot_file", snapshotFile).Msg("No valid snapshot to serve until the data source is connected.")
+	}
+
+	if cfg.Startup.StartEmpty {
+		logger.Warn().Msg("Start empty is enabled: serving no GatewayEndpoints until the data source is connected.")
+		return startup.Initial{Source: "empty", Ready: true}, nil
+	}
+
+	return startup.Initial{Source: "none"}, nil
+}
+
+// getSnapshotPersister returns a Persister which writes the GatewayEndpoints served to the tenant to its snapshot file.
+func getSnapshotPersister(cfg config.Config, tenant string, endpoints snapshot.EndpointsFunc, isLive func() bool, logger polylog.Logger) (*snapshot.Persister, error) {
+	key, err := snapshot.LoadKey(cfg.Snapshot.Key, cfg.Snapshot.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotFile := cfg.Snapshot.TenantFile(tenant)
+	logger.Info().
+		Str("snapshot_file", snapshotFile).
+		Dur("snapshot_interval", cfg.Snapshot.Interval).
+		Msg("Persisting served GatewayEndpoints to snapshot file.")
+
+	return snapshot.NewPersister(snapshotFile, key, cfg.Snapshot.Interval, endpoints, isLive, logger), nil
+}
+
 /* ------------------------------- Get Auth Data Source ------------------------------- */
 
 // getAuthDataSource returns an AuthDataSource and a cleanup function.
 // The cleanup function must be invoked by the caller to ensure resources are released.
-func getAuthDataSource(env envVars, logger polylog.Logger) (grpc_server.AuthDataSource, func(), error) {
+//
+// If more than one data source is set, they are merged by a composite data source
+// in the order of precedence validated when loading the configuration.
+func getAuthDataSource(dataSources config.DataSources, logger polylog.Logger) (grpc_server.AuthDataSource, func(), error) {
+	if len(dataSources.Precedence) == 1 {
+		return getNamedAuthDataSource(dataSources.Precedence[0], dataSources, logger)
+	}
+
+	logger.Info().Str("data_source_precedence", strings.Join(dataSources.Precedence, ",")).Msg("Using composite data source")
+
+	var sources []composite.Source
+	var cleanups []func()
+	cleanup := func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}
 
-	// Environment variables are validated in gatherEnvVars, so
-	// only one variable is checked in the switch statement at a time.
+	for _, name := range dataSources.Precedence {
+		authDataSource, sourceCleanup, err := getNamedAuthDataSource(name, dataSources, logger)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		sources = append(sources, composite.Source{Name: name, AuthDataSource: authDataSource})
+		cleanups = append(cleanups, sourceCleanup)
+	}
+
+	authDataSource, err := composite.NewCompositeDataSource(sources, logger)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to create composite data source: %v", err)
+	}
+
+	return authDataSource, cleanup, nil
+}
 
-	// The auth data source used depends on which environment variable is set.
-	switch {
+// getNamedAuthDataSource returns the named AuthDataSource and a cleanup function.
+func getNamedAuthDataSource(name string, dataSources config.DataSources, logger polylog.Logger) (grpc_server.AuthDataSource, func(), error) {
+	switch name {
 
-	// POSTGRES_CONNECTION_STRING - use a Postgres database as the data source
-	case env.postgresConnectionString != "":
-		return getPostgresAuthDataSource(env, logger)
+	// data_sources.postgres - use a Postgres database as the data source
+	case config.DataSourcePostgres:
+		return getPostgresAuthDataSource(dataSources.Postgres, logger)
 
-	// YAML_FILEPATH - use a local YAML file as the data source
-	case env.yamlFilepath != "":
-		return getYAMLAuthDataSource(env, logger)
+	// data_sources.yaml - use a local YAML file as the data source
+	case config.DataSourceYAML:
+		return getYAMLAuthDataSource(dataSources.YAML, logger)
 
 	// This should never happen.
 	default:
-		return nil, nil, fmt.Errorf("neither POSTGRES_CONNECTION_STRING nor YAML_FILEPATH is set")
+		return nil, nil, fmt.Errorf("unknown data source %q", name)
 	}
 }
 
@@ -105,12 +504,12 @@ func getAuthDataSource(env envVars, logger polylog.Logger) (grpc_server.AuthData
 // The cleanup function must be invoked by the caller to ensure resources are released.
 //
 // DEV_NOTE: This data source is highly opionionated and comatible with the Grove Portal DB's schema.
-func getPostgresAuthDataSource(env envVars, logger polylog.Logger) (grpc_server.AuthDataSource, func(), error) {
+func getPostgresAuthDataSource(postgres config.Postgres, logger polylog.Logger) (grpc_server.AuthDataSource, func(), error) {
 	logger.Info().Msg("Using Postgres data source")
 
 	authDataSource, cleanup, err := grove_postgres.NewGrovePostgresDataSource(
 		context.Background(),
-		env.postgresConnectionString,
+		postgres.ConnectionString,
 		logger,
 	)
 	if err != nil {
@@ -121,10 +520,10 @@ func getPostgresAuthDataSource(env envVars, logger polylog.Logger) (grpc_server.
 }
 
 // getYAMLAuthDataSource initializes a YAML data source and returns it.
-func getYAMLAuthDataSource(env envVars, logger polylog.Logger) (grpc_server.AuthDataSource, func(), error) {
+func getYAMLAuthDataSource(yamlFile config.YAMLFile, logger polylog.Logger) (grpc_server.AuthDataSource, func(), error) {
 	logger.Info().Msg("Using YAML data source")
 
-	authDataSource, err := yaml.NewYAMLDataSource(env.yamlFilepath, logger)
+	authDataSource, err := yaml.NewYAMLDataSource(yamlFile.Filepath, logger)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create YAML data source: %v", err)
 	}
@@ -0,0 +1,124 @@
+/*
+Package metrics defines the Prometheus metrics exported by PADS.
+
+All metrics are registered with the default Prometheus registry and
+are served by the Handler on the `/metrics` path of the PADS port.
+*/
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "pads"
+
+// Path is the HTTP path on which the metrics are served.
+const Path = "/metrics"
+
+// Label values for the result of a client authentication attempt.
+const (
+	ClientAuthResultAuthenticated   = "authenticated"
+	ClientAuthResultUnauthenticated = "unauthenticated"
+)
+
+// ClientAuthRequests counts the requests checked by client authentication, labeled by
+// the gRPC method or HTTP handler name (eg. "admin") and the result of the check.
+var ClientAuthRequests = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "client_auth_requests_total",
+		Help:      "Total number of requests checked by client authentication, by gRPC method or HTTP handler and result.",
+	},
+	[]string{"method", "result"},
+)
+
+// SlowConsumers counts the times a gRPC client fell behind the updates streamed to it,
+// labeled by the slow consumer policy applied, ie. "disconnect" or "resync".
+var SlowConsumers = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slow_consumers_total",
+		Help:      "Total number of times a gRPC client fell behind the updates streamed to it, by slow consumer policy.",
+	},
+	[]string{"policy"},
+)
+
+// TenantStats are the current stats of a tenant, exported as metrics labeled by tenant.
+type TenantStats struct {
+	Tenant string
+	// Ready is true if the tenant's GatewayEndpoints may be sent to clients.
+	Ready bool
+	// GatewayEndpoints is the number of GatewayEndpoints served to the tenant's clients.
+	GatewayEndpoints int
+	// UpdatesApplied is the number of updates from the tenant's data source applied since startup.
+	UpdatesApplied uint64
+	// ClientConnected is true if a client of the tenant is streaming updates.
+	ClientConnected bool
+}
+
+var (
+	tenantReadyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "tenant", "ready"),
+		"Whether the tenant's GatewayEndpoints may be sent to clients (1) or not (0), by tenant.",
+		[]string{"tenant"}, nil,
+	)
+	tenantGatewayEndpointsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "tenant", "gateway_endpoints"),
+		"Number of GatewayEndpoints served to the tenant's clients, by tenant.",
+		[]string{"tenant"}, nil,
+	)
+	tenantUpdatesAppliedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "tenant", "updates_applied_total"),
+		"Total number of updates from the tenant's data source applied since startup, by tenant.",
+		[]string{"tenant"}, nil,
+	)
+	tenantClientConnectedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "tenant", "client_connected"),
+		"Whether a client of the tenant is streaming updates (1) or not (0), by tenant.",
+		[]string{"tenant"}, nil,
+	)
+)
+
+// RegisterTenants registers the metrics of the served tenants, whose stats are read
+// from the stats function whenever the metrics are collected.
+func RegisterTenants(stats func() []TenantStats) {
+	prometheus.MustRegister(tenantCollector{stats: stats})
+}
+
+// tenantCollector collects the metrics of the served tenants.
+type tenantCollector struct {
+	stats func() []TenantStats
+}
+
+func (c tenantCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tenantReadyDesc
+	ch <- tenantGatewayEndpointsDesc
+	ch <- tenantUpdatesAppliedDesc
+	ch <- tenantClientConnectedDesc
+}
+
+func (c tenantCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range c.stats() {
+		ch <- prometheus.MustNewConstMetric(tenantReadyDesc, prometheus.GaugeValue, boolValue(stats.Ready), stats.Tenant)
+		ch <- prometheus.MustNewConstMetric(tenantGatewayEndpointsDesc, prometheus.GaugeValue, float64(stats.GatewayEndpoints), stats.Tenant)
+		ch <- prometheus.MustNewConstMetric(tenantUpdatesAppliedDesc, prometheus.CounterValue, float64(stats.UpdatesApplied), stats.Tenant)
+		ch <- prometheus.MustNewConstMetric(tenantClientConnectedDesc, prometheus.GaugeValue, boolValue(stats.ClientConnected), stats.Tenant)
+	}
+}
+
+// boolValue returns 1 if b is true, and 0 otherwise.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Handler returns the HTTP handler which serves all PADS metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,225 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        v5.28.3
+// source: padsproto/full_sync.proto
+
+package padsproto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type FullSyncRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The max number of GatewayEndpoints in a chunk.
+	// If not set, or greater than the server's chunk size, the server's chunk size is used.
+	ChunkSize uint32 `protobuf:"varint,1,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+}
+
+func (x *FullSyncRequest) Reset() {
+	*x = FullSyncRequest{}
+	mi := &file_padsproto_full_sync_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FullSyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FullSyncRequest) ProtoMessage() {}
+
+func (x *FullSyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_padsproto_full_sync_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FullSyncRequest.ProtoReflect.Descriptor instead.
+func (*FullSyncRequest) Descriptor() ([]byte, []int) {
+	return file_padsproto_full_sync_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *FullSyncRequest) GetChunkSize() uint32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+type FullSyncChunk struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The serialized GatewayEndpoint messages, as defined in PEAS's `gateway_endpoint.proto` file.
+	// The field is wire-compatible with `repeated GatewayEndpoint gateway_endpoints = 1`, so clients
+	// which import PEAS's `gateway_endpoint.proto` may declare it as such.
+	GatewayEndpoints [][]byte `protobuf:"bytes,1,rep,name=gateway_endpoints,json=gatewayEndpoints,proto3" json:"gateway_endpoints,omitempty"`
+	// The index of the chunk, starting from 0.
+	Index uint32 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	// The total number of GatewayEndpoints in the full sync, set in every chunk.
+	TotalGatewayEndpoints uint32 `protobuf:"varint,3,opt,name=total_gateway_endpoints,json=totalGatewayEndpoints,proto3" json:"total_gateway_endpoints,omitempty"`
+	// True for the last chunk of the full sync.
+	Last bool `protobuf:"varint,4,opt,name=last,proto3" json:"last,omitempty"`
+}
+
+func (x *FullSyncChunk) Reset() {
+	*x = FullSyncChunk{}
+	mi := &file_padsproto_full_sync_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FullSyncChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FullSyncChunk) ProtoMessage() {}
+
+func (x *FullSyncChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_padsproto_full_sync_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FullSyncChunk.ProtoReflect.Descriptor instead.
+func (*FullSyncChunk) Descriptor() ([]byte, []int) {
+	return file_padsproto_full_sync_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *FullSyncChunk) GetGatewayEndpoints() [][]byte {
+	if x != nil {
+		return x.GatewayEndpoints
+	}
+	return nil
+}
+
+func (x *FullSyncChunk) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *FullSyncChunk) GetTotalGatewayEndpoints() uint32 {
+	if x != nil {
+		return x.TotalGatewayEndpoints
+	}
+	return 0
+}
+
+func (x *FullSyncChunk) GetLast() bool {
+	if x != nil {
+		return x.Last
+	}
+	return false
+}
+
+var File_padsproto_full_sync_proto protoreflect.FileDescriptor
+
+var file_padsproto_full_sync_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x70, 0x61, 0x64, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x66, 0x75, 0x6c, 0x6c,
+	0x5f, 0x73, 0x79, 0x6e, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x04, 0x70, 0x61, 0x64,
+	0x73, 0x22, 0x30, 0x0a, 0x0f, 0x46, 0x75, 0x6c, 0x6c, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x5f, 0x73, 0x69,
+	0x7a, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x53,
+	0x69, 0x7a, 0x65, 0x22, 0x9e, 0x01, 0x0a, 0x0d, 0x46, 0x75, 0x6c, 0x6c, 0x53, 0x79, 0x6e, 0x63,
+	0x43, 0x68, 0x75, 0x6e, 0x6b, 0x12, 0x2b, 0x0a, 0x11, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79,
+	0x5f, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0c,
+	0x52, 0x10, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e,
+	0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x36, 0x0a, 0x17, 0x74, 0x6f, 0x74, 0x61,
+	0x6c, 0x5f, 0x67, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x5f, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x15, 0x74, 0x6f, 0x74, 0x61, 0x6c,
+	0x47, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73,
+	0x12, 0x12, 0x0a, 0x04, 0x6c, 0x61, 0x73, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04,
+	0x6c, 0x61, 0x73, 0x74, 0x32, 0x4a, 0x0a, 0x08, 0x46, 0x75, 0x6c, 0x6c, 0x53, 0x79, 0x6e, 0x63,
+	0x12, 0x3e, 0x0a, 0x0e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x46, 0x75, 0x6c, 0x6c, 0x53, 0x79,
+	0x6e, 0x63, 0x12, 0x15, 0x2e, 0x70, 0x61, 0x64, 0x73, 0x2e, 0x46, 0x75, 0x6c, 0x6c, 0x53, 0x79,
+	0x6e, 0x63, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e, 0x70, 0x61, 0x64, 0x73,
+	0x2e, 0x46, 0x75, 0x6c, 0x6c, 0x53, 0x79, 0x6e, 0x63, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x30, 0x01,
+	0x42, 0x3b, 0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62,
+	0x75, 0x69, 0x6c, 0x64, 0x77, 0x69, 0x74, 0x68, 0x67, 0x72, 0x6f, 0x76, 0x65, 0x2f, 0x70, 0x61,
+	0x74, 0x68, 0x2d, 0x61, 0x75, 0x74, 0x68, 0x2d, 0x64, 0x61, 0x74, 0x61, 0x2d, 0x73, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x2f, 0x70, 0x61, 0x64, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_padsproto_full_sync_proto_rawDescOnce sync.Once
+	file_padsproto_full_sync_proto_rawDescData = file_padsproto_full_sync_proto_rawDesc
+)
+
+func file_padsproto_full_sync_proto_rawDescGZIP() []byte {
+	file_padsproto_full_sync_proto_rawDescOnce.Do(func() {
+		file_padsproto_full_sync_proto_rawDescData = protoimpl.X.CompressGZIP(file_padsproto_full_sync_proto_rawDescData)
+	})
+	return file_padsproto_full_sync_proto_rawDescData
+}
+
+var file_padsproto_full_sync_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_padsproto_full_sync_proto_goTypes = []any{
+	(*FullSyncRequest)(nil), // 0: pads.FullSyncRequest
+	(*FullSyncChunk)(nil),   // 1: pads.FullSyncChunk
+}
+var file_padsproto_full_sync_proto_depIdxs = []int32{
+	0, // 0: pads.FullSync.StreamFullSync:input_type -> pads.FullSyncRequest
+	1, // 1: pads.FullSync.StreamFullSync:output_type -> pads.FullSyncChunk
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_padsproto_full_sync_proto_init() }
+func file_padsproto_full_sync_proto_init() {
+	if File_padsproto_full_sync_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_padsproto_full_sync_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_padsproto_full_sync_proto_goTypes,
+		DependencyIndexes: file_padsproto_full_sync_proto_depIdxs,
+		MessageInfos:      file_padsproto_full_sync_proto_msgTypes,
+	}.Build()
+	File_padsproto_full_sync_proto = out.File
+	file_padsproto_full_sync_proto_rawDesc = nil
+	file_padsproto_full_sync_proto_goTypes = nil
+	file_padsproto_full_sync_proto_depIdxs = nil
+}
@@ -0,0 +1,134 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: padsproto/full_sync.proto
+
+package padsproto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FullSync_StreamFullSync_FullMethodName = "/pads.FullSync/StreamFullSync"
+)
+
+// FullSyncClient is the client API for FullSync service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// FullSync serves the full set of GatewayEndpoints in chunks, as an alternative to PEAS's
+// GatewayEndpoints.FetchAuthDataSync, whose single response may exceed the client's max message size.
+type FullSyncClient interface {
+	// StreamFullSync streams the GatewayEndpoints served at the time of the request, in chunks.
+	// The chunks are taken from a single snapshot, so they are consistent even if updates are applied meanwhile.
+	StreamFullSync(ctx context.Context, in *FullSyncRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FullSyncChunk], error)
+}
+
+type fullSyncClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFullSyncClient(cc grpc.ClientConnInterface) FullSyncClient {
+	return &fullSyncClient{cc}
+}
+
+func (c *fullSyncClient) StreamFullSync(ctx context.Context, in *FullSyncRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FullSyncChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FullSync_ServiceDesc.Streams[0], FullSync_StreamFullSync_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FullSyncRequest, FullSyncChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FullSync_StreamFullSyncClient = grpc.ServerStreamingClient[FullSyncChunk]
+
+// FullSyncServer is the server API for FullSync service.
+// All implementations must embed UnimplementedFullSyncServer
+// for forward compatibility.
+//
+// FullSync serves the full set of GatewayEndpoints in chunks, as an alternative to PEAS's
+// GatewayEndpoints.FetchAuthDataSync, whose single response may exceed the client's max message size.
+type FullSyncServer interface {
+	// StreamFullSync streams the GatewayEndpoints served at the time of the request, in chunks.
+	// The chunks are taken from a single snapshot, so they are consistent even if updates are applied meanwhile.
+	StreamFullSync(*FullSyncRequest, grpc.ServerStreamingServer[FullSyncChunk]) error
+	mustEmbedUnimplementedFullSyncServer()
+}
+
+// UnimplementedFullSyncServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFullSyncServer struct{}
+
+func (UnimplementedFullSyncServer) StreamFullSync(*FullSyncRequest, grpc.ServerStreamingServer[FullSyncChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamFullSync not implemented")
+}
+func (UnimplementedFullSyncServer) mustEmbedUnimplementedFullSyncServer() {}
+func (UnimplementedFullSyncServer) testEmbeddedByValue()                  {}
+
+// UnsafeFullSyncServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FullSyncServer will
+// result in compilation errors.
+type UnsafeFullSyncServer interface {
+	mustEmbedUnimplementedFullSyncServer()
+}
+
+func RegisterFullSyncServer(s grpc.ServiceRegistrar, srv FullSyncServer) {
+	// If the following call pancis, it indicates UnimplementedFullSyncServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FullSync_ServiceDesc, srv)
+}
+
+func _FullSync_StreamFullSync_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FullSyncRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FullSyncServer).StreamFullSync(m, &grpc.GenericServerStream[FullSyncRequest, FullSyncChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FullSync_StreamFullSyncServer = grpc.ServerStreamingServer[FullSyncChunk]
+
+// FullSync_ServiceDesc is the grpc.ServiceDesc for FullSync service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FullSync_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pads.FullSync",
+	HandlerType: (*FullSyncServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamFullSync",
+			Handler:       _FullSync_StreamFullSync_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "padsproto/full_sync.proto",
+}
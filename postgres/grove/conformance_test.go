@@ -0,0 +1,127 @@
+package grove
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/postgres/grove/sqlc"
+)
+
+func Test_Integration_Conformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping driver integration test")
+	}
+
+	var (
+		pool          *pgxpool.Pool
+		databaseCount int
+	)
+
+	grpc_server.RunConformanceTests(t, grpc_server.ConformanceHarness{
+		NewDataSource: func(t *testing.T, gatewayEndpoints []*proto.GatewayEndpoint) grpc_server.AuthDataSource {
+			c := require.New(t)
+			ctx := context.Background()
+
+			// Each data source is given its own database, so that the seeded test data is not changed.
+			databaseCount++
+			databaseURL := newConformanceDatabase(t, fmt.Sprintf("conformance_%d", databaseCount))
+
+			var err error
+			pool, err = pgxpool.New(ctx, databaseURL)
+			c.NoError(err)
+			t.Cleanup(pool.Close)
+
+			for _, gatewayEndpoint := range gatewayEndpoints {
+				writeConformanceEndpoint(t, pool, grpc_server.EndpointWrite{
+					Op:              grpc_server.WriteOpCreate,
+					EndpointID:      gatewayEndpoint.GetEndpointId(),
+					GatewayEndpoint: gatewayEndpoint,
+				})
+			}
+			// The changes made while seeding the database are not updates to the data source.
+			_, err = pool.Exec(ctx, "DELETE FROM portal_application_changes")
+			c.NoError(err)
+
+			dataSource, cleanup, err := NewGrovePostgresDataSource(ctx, databaseURL, polyzero.NewLogger())
+			c.NoError(err)
+			t.Cleanup(cleanup)
+
+			// Changes made before the data source is listening for notifications would not be sent until the next notification.
+			c.Eventually(func() bool {
+				var listening bool
+				err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_stat_activity WHERE datname = current_database() AND query ILIKE 'listen%')").Scan(&listening)
+				return err == nil && listening
+			}, 5*time.Second, 50*time.Millisecond)
+
+			return dataSource
+		},
+		Put: func(t *testing.T, gatewayEndpoint *proto.GatewayEndpoint) {
+			writeConformanceEndpoint(t, pool, grpc_server.EndpointWrite{
+				Op:              grpc_server.WriteOpUpsert,
+				EndpointID:      gatewayEndpoint.GetEndpointId(),
+				GatewayEndpoint: gatewayEndpoint,
+			})
+		},
+		Delete: func(t *testing.T, endpointID string) {
+			writeConformanceEndpoint(t, pool, grpc_server.EndpointWrite{
+				Op:         grpc_server.WriteOpDelete,
+				EndpointID: endpointID,
+			})
+		},
+	})
+}
+
+// newConformanceDatabase creates an empty database with the Grove Portal DB schema and triggers,
+// returning its connection string.
+func newConformanceDatabase(t *testing.T, name string) string {
+	c := require.New(t)
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, connectionString)
+	c.NoError(err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize())
+	c.NoError(err)
+
+	databaseURL, err := url.Parse(connectionString)
+	c.NoError(err)
+	databaseURL.Path = "/" + name
+
+	databaseConn, err := pgx.Connect(ctx, databaseURL.String())
+	c.NoError(err)
+	defer databaseConn.Close(ctx)
+
+	for _, filename := range []string{schemaLocation, triggersLocation} {
+		sql, err := os.ReadFile(filename)
+		c.NoError(err)
+		_, err = databaseConn.Exec(ctx, string(sql))
+		c.NoError(err)
+	}
+
+	return databaseURL.String()
+}
+
+// writeConformanceEndpoint applies the write to the database in its own transaction, as another writer to the database would.
+func writeConformanceEndpoint(t *testing.T, pool *pgxpool.Pool, write grpc_server.EndpointWrite) {
+	c := require.New(t)
+	ctx := context.Background()
+
+	tx, err := pool.Begin(ctx)
+	c.NoError(err)
+	defer tx.Rollback(ctx)
+
+	c.NoError(writePortalApplication(ctx, sqlc.New(tx), write))
+	c.NoError(tx.Commit(ctx))
+}
@@ -2,8 +2,11 @@ package grove
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
+	"sync"
+	"time"
 
 	"github.com/buildwithgrove/path-external-auth-server/proto"
 	"github.com/jackc/pgx/v5"
@@ -11,13 +14,21 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgxlisten"
 	"github.com/pokt-network/poktroll/pkg/polylog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
 	"github.com/buildwithgrove/path-auth-data-server/postgres/grove/sqlc"
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
 )
 
-// postgresDataSource implements the grpc_server.AuthDataSource interface.
-var _ grpc_server.AuthDataSource = &postgresDataSource{}
+// postgresDataSource implements the grpc_server.AuthDataSource and grpc_server.AuthDataExporter interfaces.
+var (
+	_ grpc_server.AuthDataSource   = &postgresDataSource{}
+	_ grpc_server.AuthDataExporter = &postgresDataSource{}
+)
 
 type (
 	// postgresDataSource implements the AuthDataSource interface for a Postgres database.
@@ -34,6 +45,14 @@ type (
 
 		notificationCh chan *Notification
 		updatesCh      chan *proto.AuthDataUpdate
+		// updateFeed serves the active GatewayEndpoints with every update from updatesCh applied, and drops the
+		// updates sent for unchanged portal applications, as the triggers notify of every update to a row,
+		// even if its values are unchanged.
+		updateFeed *grpc_server.UpdateFeed
+
+		// scheduler sends the updates of the portal applications to updatesCh once they are loaded,
+		// ordered with the updates it sends when time-bounded portal applications activate or expire.
+		scheduler *validity.Scheduler
 
 		logger polylog.Logger
 	}
@@ -55,9 +74,12 @@ var postgresConnectionStringRegex = regexp.MustCompile(`^postgres(?:ql)?:\/\/[^:
 NewGrovePostgresDataSource returns a opinionated Postgres data source that is compatible with the Grove Portal DB.
 
 - Ensures the connection string is valid.
+- Does not serve the portal applications with hashed secret keys, as PEAS does not yet verify them (see the apikey package).
 - Parses the connection string into a pgx pool configuration object.
 - Creates a pool of connections to a PostgreSQL database using the provided connection string.
 - Creates an instance of postgresDriver using the provided pgx connection and sqlc queries.
+- Loads the full set of GatewayEndpoints and schedules the activation and expiry of time-bounded portal applications.
+- Starts listening for changes, after which the changes made since the load are processed.
 - Returns the created postgresDataSource instance.
 */
 func NewGrovePostgresDataSource(ctx context.Context, connectionString string, logger polylog.Logger) (*postgresDataSource, func(), error) {
@@ -76,25 +98,47 @@ func NewGrovePostgresDataSource(ctx context.Context, connectionString string, lo
 		return nil, nil, fmt.Errorf("pgxpool.NewWithConfig: %v", err)
 	}
 
-	cleanup := func() {
-		pool.Close()
-	}
-
 	driver := &postgresDriver{
 		Queries: sqlc.New(pool),
 		DB:      pool,
 	}
 
+	updatesCh := make(chan *proto.AuthDataUpdate, 100_000)
+
 	postgresDataSource := &postgresDataSource{
 		driver:         driver,
 		listener:       newPGXPoolListener(pool, logger),
 		notificationCh: make(chan *Notification),
-		updatesCh:      make(chan *proto.AuthDataUpdate, 100_000),
+		updatesCh:      updatesCh,
+		scheduler:      validity.NewScheduler(updatesCh, logger),
 		logger:         logger,
 	}
 
+	// listenCtx is canceled by cleanup, so that the listener and the processing of its notifications stop,
+	// and no longer use the pool, before the pool is closed. Otherwise, a data source which fails to start
+	// and is retried, or which is closed, would leak its listener goroutines.
+	listenCtx, cancelListen := context.WithCancel(ctx)
+	var listening sync.WaitGroup
+
+	cleanup := func() {
+		cancelListen()
+		listening.Wait()
+		postgresDataSource.scheduler.Stop()
+		pool.Close()
+	}
+
+	// Warm up the data store with the full set of active GatewayEndpoints from the database.
+	// Changes made after this load are recorded in the portal_application_changes table by the
+	// triggers, and are processed as the backlog once the listener starts listening.
+	activeEndpoints, err := postgresDataSource.loadGatewayEndpoints(ctx, true)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to load portal applications: %w", err)
+	}
+	postgresDataSource.updateFeed = grpc_server.NewUpdateFeed(activeEndpoints, updatesCh)
+
 	// Start listening for updates from the Postgres database
-	go postgresDataSource.listenForUpdates(ctx)
+	postgresDataSource.listenForUpdates(listenCtx, &listening)
 
 	return postgresDataSource, cleanup, nil
 }
@@ -106,20 +150,79 @@ func isValidPostgresConnectionString(s string) bool {
 
 /* ---------- Data Source Funcs ---------- */
 
-// FetchAuthDataSync loads the full set of GatewayEndpoints from the Postgres database.
+// FetchAuthDataSync loads the full set of currently active GatewayEndpoints from the Postgres database.
 func (d *postgresDataSource) FetchAuthDataSync() (*proto.AuthDataResponse, error) {
+	gatewayEndpoints, err := d.loadGatewayEndpoints(context.Background(), false)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.AuthDataResponse{Endpoints: gatewayEndpoints}, nil
+}
 
+// ExportAuthData loads every portal application from the Postgres database, including those outside of their
+// validity window, along with the validity windows of all time-bounded portal applications.
+// Unlike FetchAuthDataSync, portal applications with an invalid secret key fail the export rather than being skipped.
+func (d *postgresDataSource) ExportAuthData() (*proto.AuthDataResponse, map[string]validity.Window, error) {
 	rows, err := d.driver.Queries.SelectPortalApplications(context.Background())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	windows := make(map[string]validity.Window)
+	for _, row := range rows {
+		portalApp := sqlcPortalAppsToPortalAppRow(row)
+		if err := portalApp.checkSecretKey(); err != nil {
+			return nil, nil, fmt.Errorf("portal application %s: %w", row.ID, err)
+		}
+		if window := portalApp.validityWindow(); !window.IsZero() {
+			windows[row.ID] = window
+		}
 	}
 
-	return sqlcPortalAppsToProto(rows), nil
+	return sqlcPortalAppsToProto(rows), windows, nil
+}
+
+// SubscribeAuthData returns the full set of currently active GatewayEndpoints, along with
+// a channel that streams updates when the Postgres database changes from then on.
+// Updates which would not change the served GatewayEndpoints are dropped.
+func (d *postgresDataSource) SubscribeAuthData() (*proto.AuthDataResponse, <-chan *proto.AuthDataUpdate, error) {
+	gatewayEndpoints, updatesCh, err := d.updateFeed.Subscribe()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &proto.AuthDataResponse{Endpoints: gatewayEndpoints}, updatesCh, nil
 }
 
-// AuthDataUpdatesChan returns a channel that streams updates when the Postgres database changes.
-func (d *postgresDataSource) AuthDataUpdatesChan() (<-chan *proto.AuthDataUpdate, error) {
-	return d.updatesCh, nil
+// loadGatewayEndpoints loads the GatewayEndpoints of all portal applications within their validity window.
+// If schedule is true, the activation and expiry of all time-bounded portal applications are (re)scheduled,
+// so that scheduled updates are recomputed from the database after a restart.
+func (d *postgresDataSource) loadGatewayEndpoints(ctx context.Context, schedule bool) (map[string]*proto.GatewayEndpoint, error) {
+	rows, err := d.driver.Queries.SelectPortalApplications(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	gatewayEndpoints := sqlcPortalAppsToProto(rows).Endpoints
+	for _, row := range rows {
+		portalApp := sqlcPortalAppsToPortalAppRow(row)
+		if err := portalApp.checkSecretKey(); err != nil {
+			d.logger.Error().Err(err).Str("endpoint_id", row.ID).Msg("not serving portal application with an invalid secret key")
+			delete(gatewayEndpoints, row.ID)
+			continue
+		}
+
+		window := portalApp.validityWindow()
+		active := window.IsActive(now)
+		if schedule {
+			active = d.scheduler.Schedule(gatewayEndpoints[row.ID], window)
+		}
+		if !active {
+			delete(gatewayEndpoints, row.ID)
+		}
+	}
+
+	return gatewayEndpoints, nil
 }
 
 /* ---------- Data Update Listener Funcs ---------- */
@@ -128,6 +231,10 @@ const portalApplicationChangesChannel = "portal_application_changes"
 
 type Notification struct {
 	Payload string
+	// ReceivedAt is when the notification was received, so that the time until it is processed is traced.
+	ReceivedAt time.Time
+	// Backlog is true if the notification was sent for the changes made while not listening, rather than for a change.
+	Backlog bool
 }
 
 type PGXNotificationHandler struct {
@@ -135,8 +242,23 @@ type PGXNotificationHandler struct {
 }
 
 func (h *PGXNotificationHandler) HandleNotification(ctx context.Context, n *pgconn.Notification, conn *pgx.Conn) error {
-	h.outCh <- &Notification{Payload: n.Payload}
-	return nil
+	return h.send(ctx, &Notification{Payload: n.Payload, ReceivedAt: time.Now()})
+}
+
+// HandleBacklog is called by the listener once it starts listening, including after reconnecting,
+// so that the changes made while it was not listening are processed without waiting for a new change.
+func (h *PGXNotificationHandler) HandleBacklog(ctx context.Context, channel string, conn *pgx.Conn) error {
+	return h.send(ctx, &Notification{ReceivedAt: time.Now(), Backlog: true})
+}
+
+// send sends the notification to be processed, unless the listener is stopped first.
+func (h *PGXNotificationHandler) send(ctx context.Context, notification *Notification) error {
+	select {
+	case h.outCh <- notification:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // newPGXPoolListener creates a new pgxlisten.Listener with a connection from the provided pool and output channel.
@@ -160,32 +282,57 @@ func newPGXPoolListener(pool *pgxpool.Pool, logger polylog.Logger) *pgxlisten.Li
 	return listener
 }
 
-func (d *postgresDataSource) listenForUpdates(ctx context.Context) {
+// listenForUpdates starts the listener and the processing of its notifications, which run until the context
+// is canceled. The listening WaitGroup is done once both have stopped.
+func (d *postgresDataSource) listenForUpdates(ctx context.Context, listening *sync.WaitGroup) {
 	handler := &PGXNotificationHandler{outCh: d.notificationCh}
 	d.listener.Handle(portalApplicationChangesChannel, handler)
 
+	listening.Add(2)
 	go func() {
-		if err := d.listener.Listen(ctx); err != nil {
-			d.logger.Error().Err(err).Msg("error ini")
+		defer listening.Done()
+		if err := d.listener.Listen(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			d.logger.Error().Err(err).Msg("error listening for portal application changes")
 		}
 	}()
 
 	go func() {
-		for range d.notificationCh {
+		defer listening.Done()
+		for {
+			var notification *Notification
+			select {
+			case notification = <-d.notificationCh:
+			case <-ctx.Done():
+				return
+			}
+
+			// The span of the notification starts once it is received, so that it includes the time it waited to be processed.
+			notificationCtx, span := tracing.Tracer().Start(ctx, "postgres.notification",
+				trace.WithTimestamp(notification.ReceivedAt),
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(attribute.Bool("pads.backlog", notification.Backlog)),
+			)
+
 			// Process the notification
-			if err := d.processPortalApplicationChanges(ctx); err != nil {
+			if err := d.processPortalApplicationChanges(notificationCtx); err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "failed to process portal application changes")
 				d.logger.Error().Err(err).Msg("failed to process portal application changes")
 			}
+			span.End()
 		}
 	}()
 }
 
 func (d *postgresDataSource) processPortalApplicationChanges(ctx context.Context) error {
+	ctx, span := tracing.Tracer().Start(ctx, "postgres.processPortalApplicationChanges")
+	defer span.End()
 
 	changes, err := d.driver.GetPortalApplicationChanges(ctx)
 	if err != nil {
 		return err
 	}
+	span.SetAttributes(attribute.Int("pads.changes", len(changes)))
 
 	if len(changes) == 0 {
 		return nil
@@ -193,29 +340,36 @@ func (d *postgresDataSource) processPortalApplicationChanges(ctx context.Context
 
 	var changeIDs []int32
 
+	// Each change is processed by reading the current state of the portal application, rather than
+	// applying the change itself, so that changes are applied in order whatever order they are
+	// read in, and a change already included in the initial load never reverts a later change.
 	for _, change := range changes {
-		if change.IsDelete {
-			update := &proto.AuthDataUpdate{
-				EndpointId: change.PortalAppID,
-				Delete:     true,
-			}
-			d.updatesCh <- update
-		} else {
-			portalAppRow, err := d.driver.SelectPortalApplication(ctx, change.PortalAppID)
-			if err != nil {
-				d.logger.Error().Err(err).Msg("failed to get portal application")
-				continue
-			}
-
-			gatewayEndpointProto := sqlcPortalAppToPortalAppRow(portalAppRow).convertToProto()
+		portalAppRow, err := d.driver.SelectPortalApplication(ctx, change.PortalAppID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The portal application was deleted, or marked as deleted.
+			d.scheduler.Delete(ctx, change.PortalAppID)
+			changeIDs = append(changeIDs, change.ID)
+			continue
+		}
+		if err != nil {
+			d.logger.Error().Err(err).Msg("failed to get portal application")
+			continue
+		}
 
-			// Send the update
-			update := &proto.AuthDataUpdate{
-				EndpointId:      gatewayEndpointProto.EndpointId,
-				GatewayEndpoint: gatewayEndpointProto,
-			}
-			d.updatesCh <- update
+		portalApp := sqlcPortalAppToPortalAppRow(portalAppRow)
+		if err := portalApp.checkSecretKey(); err != nil {
+			// The portal application is no longer served, rather than served with its previous secret key.
+			d.logger.Error().Err(err).Str("endpoint_id", change.PortalAppID).Msg("not serving portal application with an invalid secret key")
+			d.scheduler.Delete(ctx, change.PortalAppID)
+			changeIDs = append(changeIDs, change.ID)
+			continue
 		}
+		gatewayEndpointProto := portalApp.convertToProto()
+
+		// Send the update through the scheduler, carrying the span so that it is continued by the server which
+		// applies the update. Portal applications outside of their validity window are sent as deletes
+		// and are created by the scheduler once they activate.
+		d.scheduler.Update(ctx, gatewayEndpointProto, portalApp.validityWindow())
 
 		changeIDs = append(changeIDs, change.ID)
 	}
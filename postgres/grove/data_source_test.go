@@ -2,10 +2,15 @@ package grove
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgxlisten"
 	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
 	"github.com/stretchr/testify/require"
 
@@ -116,8 +121,9 @@ func Test_Integration_FetchAuthDataSync(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			c := require.New(t)
 
-			dataSource, _, err := NewGrovePostgresDataSource(context.Background(), connectionString, polyzero.NewLogger())
+			dataSource, cleanup, err := NewGrovePostgresDataSource(context.Background(), connectionString, polyzero.NewLogger())
 			c.NoError(err)
+			defer cleanup()
 
 			authData, err := dataSource.FetchAuthDataSync()
 			c.NoError(err)
@@ -125,3 +131,36 @@ func Test_Integration_FetchAuthDataSync(t *testing.T) {
 		})
 	}
 }
+
+func Test_listenForUpdates_stopsOnCancel(t *testing.T) {
+	c := require.New(t)
+
+	// The listener never connects, as when the database is unavailable, so that it keeps retrying until stopped.
+	dataSource := &postgresDataSource{
+		listener: &pgxlisten.Listener{
+			Connect: func(ctx context.Context) (*pgx.Conn, error) {
+				return nil, errors.New("database unavailable")
+			},
+			ReconnectDelay: 10 * time.Millisecond,
+		},
+		notificationCh: make(chan *Notification),
+		logger:         polyzero.NewLogger(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var listening sync.WaitGroup
+	dataSource.listenForUpdates(ctx, &listening)
+
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		listening.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		c.Fail("listener did not stop once its context was canceled")
+	}
+}
@@ -0,0 +1,7 @@
+-- This migration adds the columns used by PADS to support time-bounded endpoints to an existing Grove Portal DB.
+-- It must be applied before PADS is started against the Grove Portal DB, as PADS selects these columns.
+-- See the `portal_applications` table in ../sqlc/grove_schema.sql.
+
+-- The columns are nullable, so that existing portal applications are always valid.
+ALTER TABLE portal_applications ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ NULL;
+ALTER TABLE portal_applications ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ NULL;
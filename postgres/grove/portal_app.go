@@ -1,9 +1,14 @@
 package grove
 
 import (
+	"time"
+
 	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/jackc/pgx/v5/pgtype"
 
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
 	"github.com/buildwithgrove/path-auth-data-server/postgres/grove/sqlc"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
 )
 
 // portalApplicationRow is a struct that represents a row from the portal_applications table
@@ -11,10 +16,13 @@ import (
 // table schema to the new `GatewayEndpoint` struct expected by the PATH Go External Authorization Server.
 type portalApplicationRow struct {
 	ID                string `json:"id"`                  // The PortalApp ID maps to the GatewayEndpoint.EndpointId
-	SecretKey         string `json:"secret_key"`          // The PortalApp SecretKey maps to the GatewayEndpoint.Auth.AuthType.StaticApiKey.ApiKey
+	SecretKey         string `json:"secret_key"`          // The PortalApp SecretKey maps to the GatewayEndpoint.Auth.AuthType.StaticApiKey.ApiKey and may be a hashed API key
 	SecretKeyRequired bool   `json:"secret_key_required"` // The PortalApp SecretKeyRequired determines whether the auth type is StaticApiKey or NoAuth
 	AccountID         string `json:"account_id"`          // The PortalApp AccountID maps to the GatewayEndpoint.Metadata.AccountId
 	Plan              string `json:"plan"`                // The PortalApp Plan maps to the GatewayEndpoint.Metadata.PlanType
+
+	StartsAt  time.Time `json:"starts_at"`  // The optional time from which the PortalApp is served; zero if not set
+	ExpiresAt time.Time `json:"expires_at"` // The optional time after which the PortalApp is no longer served; zero if not set
 }
 
 // sqlcPortalAppsToPortalAppRow (not the plurality of Apps) converts a row from the
@@ -28,6 +36,8 @@ func sqlcPortalAppsToPortalAppRow(r sqlc.SelectPortalApplicationsRow) *portalApp
 		SecretKeyRequired: r.SecretKeyRequired.Bool,
 		AccountID:         r.AccountID.String,
 		Plan:              r.Plan.String,
+		StartsAt:          timestamptzToTime(r.StartsAt),
+		ExpiresAt:         timestamptzToTime(r.ExpiresAt),
 	}
 }
 
@@ -42,6 +52,8 @@ func sqlcPortalAppToPortalAppRow(r sqlc.SelectPortalApplicationRow) *portalAppli
 		SecretKeyRequired: r.SecretKeyRequired.Bool,
 		AccountID:         r.AccountID.String,
 		Plan:              r.Plan.String,
+		StartsAt:          timestamptzToTime(r.StartsAt),
+		ExpiresAt:         timestamptzToTime(r.ExpiresAt),
 	}
 }
 
@@ -56,6 +68,22 @@ func (r *portalApplicationRow) convertToProto() *proto.GatewayEndpoint {
 	}
 }
 
+// checkSecretKey returns an error if the PortalApp's required secret key is a hashed API key, which PEAS cannot verify.
+func (r *portalApplicationRow) checkSecretKey() error {
+	if !r.SecretKeyRequired {
+		return nil
+	}
+	return apikey.Check(r.SecretKey)
+}
+
+// validityWindow returns the optional time window during which the PortalApp is served.
+func (r *portalApplicationRow) validityWindow() validity.Window {
+	return validity.Window{
+		StartsAt:  r.StartsAt,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
 func (r *portalApplicationRow) getAuthDetails() *proto.Auth {
 	if r.SecretKeyRequired {
 		return &proto.Auth{
@@ -81,3 +109,11 @@ func sqlcPortalAppsToProto(rows []sqlc.SelectPortalApplicationsRow) *proto.AuthD
 
 	return &proto.AuthDataResponse{Endpoints: endpointsProto}
 }
+
+// timestamptzToTime returns the time of a nullable timestamp column, or the zero time if it is NULL.
+func timestamptzToTime(t pgtype.Timestamptz) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return t.Time
+}
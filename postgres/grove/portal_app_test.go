@@ -2,12 +2,14 @@ package grove
 
 import (
 	"testing"
+	"time"
 
 	"github.com/buildwithgrove/path-external-auth-server/proto"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/require"
 
 	"github.com/buildwithgrove/path-auth-data-server/postgres/grove/sqlc"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
 )
 
 func Test_sqlcPortalAppsToProto(t *testing.T) {
@@ -65,6 +67,37 @@ func Test_sqlcPortalAppsToProto(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "should pass hashed secret keys through unchanged",
+			rows: []sqlc.SelectPortalApplicationsRow{
+				{
+					ID:                "endpoint_3_hashed_key",
+					AccountID:         pgtype.Text{String: "account_3", Valid: true},
+					Plan:              pgtype.Text{String: "PLAN_UNLIMITED", Valid: true},
+					SecretKeyRequired: pgtype.Bool{Bool: true, Valid: true},
+					SecretKey:         pgtype.Text{String: "argon2id:m=65536,t=1,p=4:c2FsdHNhbHRzYWx0c2FsdA:aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g", Valid: true},
+				},
+			},
+			expected: &proto.AuthDataResponse{
+				Endpoints: map[string]*proto.GatewayEndpoint{
+					"endpoint_3_hashed_key": {
+						EndpointId: "endpoint_3_hashed_key",
+						Auth: &proto.Auth{
+							AuthType: &proto.Auth_StaticApiKey{
+								StaticApiKey: &proto.StaticAPIKey{
+									ApiKey: "argon2id:m=65536,t=1,p=4:c2FsdHNhbHRzYWx0c2FsdA:aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g",
+								},
+							},
+						},
+						Metadata: &proto.Metadata{
+							AccountId: "account_3",
+							PlanType:  "PLAN_UNLIMITED",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -74,3 +107,72 @@ func Test_sqlcPortalAppsToProto(t *testing.T) {
 		})
 	}
 }
+
+func Test_sqlcPortalAppsToPortalAppRow_validityWindow(t *testing.T) {
+	startsAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		row      sqlc.SelectPortalApplicationsRow
+		expected validity.Window
+	}{
+		{
+			name: "should convert starts_at and expires_at to a validity window",
+			row: sqlc.SelectPortalApplicationsRow{
+				ID:        "endpoint_1_trial",
+				StartsAt:  pgtype.Timestamptz{Time: startsAt, Valid: true},
+				ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+			},
+			expected: validity.Window{StartsAt: startsAt, ExpiresAt: expiresAt},
+		},
+		{
+			name: "should convert NULL starts_at and expires_at to an empty validity window",
+			row: sqlc.SelectPortalApplicationsRow{
+				ID: "endpoint_2_no_window",
+			},
+			expected: validity.Window{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, sqlcPortalAppsToPortalAppRow(test.row).validityWindow())
+		})
+	}
+}
+
+func Test_portalApplicationRow_checkSecretKey(t *testing.T) {
+	const hashedSecretKey = "argon2id:m=65536,t=1,p=4:c2FsdHNhbHRzYWx0c2FsdA:aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
+
+	tests := []struct {
+		name    string
+		row     portalApplicationRow
+		wantErr bool
+	}{
+		{
+			name: "should accept plaintext secret key",
+			row:  portalApplicationRow{ID: "endpoint_1", SecretKey: "secret_key_1", SecretKeyRequired: true},
+		},
+		{
+			name:    "should reject hashed secret key",
+			row:     portalApplicationRow{ID: "endpoint_2", SecretKey: hashedSecretKey, SecretKeyRequired: true},
+			wantErr: true,
+		},
+		{
+			name: "should accept hashed secret key which is not required",
+			row:  portalApplicationRow{ID: "endpoint_3", SecretKey: hashedSecretKey},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.row.checkSecretKey()
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -8,7 +8,9 @@ SELECT
     pas.secret_key,
     pas.secret_key_required,
     pa.account_id,
-    a.plan_type AS plan
+    a.plan_type AS plan,
+    pa.starts_at,
+    pa.expires_at
 FROM portal_applications pa
 LEFT JOIN portal_application_settings pas
     ON pa.id = pas.application_id
@@ -27,7 +29,9 @@ SELECT
     pas.secret_key,
     pas.secret_key_required,
     pa.account_id,
-    a.plan_type AS plan
+    a.plan_type AS plan,
+    pa.starts_at,
+    pa.expires_at
 FROM portal_applications pa
 LEFT JOIN portal_application_settings pas
     ON pa.id = pas.application_id
@@ -49,3 +53,46 @@ FROM portal_application_changes;
 -- name: DeletePortalApplicationChanges :exec
 DELETE FROM portal_application_changes
 WHERE id = ANY(@change_ids::int []);
+
+-- The following queries are used by the admin API to write Gateway Endpoints to the Grove Portal DB.
+-- All writes are made in a single transaction and are streamed to PEAS by the triggers in grove_triggers.sql.
+
+-- name: PortalApplicationExists :one
+SELECT EXISTS (
+    SELECT 1
+    FROM portal_applications
+    WHERE id = $1 AND deleted = false
+);
+
+-- name: InsertAccountIfNotExists :exec
+INSERT INTO accounts (id, plan_type)
+VALUES ($1, $2)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: SelectAccountPlanType :one
+SELECT plan_type
+FROM accounts
+WHERE id = $1;
+
+-- name: UpsertPortalApplication :exec
+INSERT INTO portal_applications (id, account_id, starts_at, expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE
+SET account_id = EXCLUDED.account_id,
+    starts_at = EXCLUDED.starts_at,
+    expires_at = EXCLUDED.expires_at,
+    deleted = false,
+    deleted_at = NULL;
+
+-- name: UpsertPortalApplicationSettings :exec
+INSERT INTO portal_application_settings (application_id, secret_key, secret_key_required)
+VALUES ($1, $2, $3)
+ON CONFLICT (application_id) DO UPDATE
+SET secret_key = EXCLUDED.secret_key,
+    secret_key_required = EXCLUDED.secret_key_required;
+
+-- name: DeletePortalApplication :execrows
+UPDATE portal_applications
+SET deleted = true,
+    deleted_at = NOW()
+WHERE id = $1 AND deleted = false;
@@ -11,6 +11,21 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const deletePortalApplication = `-- name: DeletePortalApplication :execrows
+UPDATE portal_applications
+SET deleted = true,
+    deleted_at = NOW()
+WHERE id = $1 AND deleted = false
+`
+
+func (q *Queries) DeletePortalApplication(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePortalApplication, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deletePortalApplicationChanges = `-- name: DeletePortalApplicationChanges :exec
 DELETE FROM portal_application_changes
 WHERE id = ANY($1::int [])
@@ -54,13 +69,62 @@ func (q *Queries) GetPortalApplicationChanges(ctx context.Context) ([]GetPortalA
 	return items, nil
 }
 
+const insertAccountIfNotExists = `-- name: InsertAccountIfNotExists :exec
+INSERT INTO accounts (id, plan_type)
+VALUES ($1, $2)
+ON CONFLICT (id) DO NOTHING
+`
+
+type InsertAccountIfNotExistsParams struct {
+	ID       string      `json:"id"`
+	PlanType pgtype.Text `json:"plan_type"`
+}
+
+func (q *Queries) InsertAccountIfNotExists(ctx context.Context, arg InsertAccountIfNotExistsParams) error {
+	_, err := q.db.Exec(ctx, insertAccountIfNotExists, arg.ID, arg.PlanType)
+	return err
+}
+
+const portalApplicationExists = `-- name: PortalApplicationExists :one
+
+SELECT EXISTS (
+    SELECT 1
+    FROM portal_applications
+    WHERE id = $1 AND deleted = false
+)
+`
+
+// The following queries are used by the admin API to write Gateway Endpoints to the Grove Portal DB.
+// All writes are made in a single transaction and are streamed to PEAS by the triggers in grove_triggers.sql.
+func (q *Queries) PortalApplicationExists(ctx context.Context, id string) (bool, error) {
+	row := q.db.QueryRow(ctx, portalApplicationExists, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const selectAccountPlanType = `-- name: SelectAccountPlanType :one
+SELECT plan_type
+FROM accounts
+WHERE id = $1
+`
+
+func (q *Queries) SelectAccountPlanType(ctx context.Context, id string) (pgtype.Text, error) {
+	row := q.db.QueryRow(ctx, selectAccountPlanType, id)
+	var plan_type pgtype.Text
+	err := row.Scan(&plan_type)
+	return plan_type, err
+}
+
 const selectPortalApplication = `-- name: SelectPortalApplication :one
 SELECT 
     pa.id,
     pas.secret_key,
     pas.secret_key_required,
     pa.account_id,
-    a.plan_type AS plan
+    a.plan_type AS plan,
+    pa.starts_at,
+    pa.expires_at
 FROM portal_applications pa
 LEFT JOIN portal_application_settings pas
     ON pa.id = pas.application_id
@@ -75,11 +139,13 @@ GROUP BY
 `
 
 type SelectPortalApplicationRow struct {
-	ID                string      `json:"id"`
-	SecretKey         pgtype.Text `json:"secret_key"`
-	SecretKeyRequired pgtype.Bool `json:"secret_key_required"`
-	AccountID         pgtype.Text `json:"account_id"`
-	Plan              pgtype.Text `json:"plan"`
+	ID                string             `json:"id"`
+	SecretKey         pgtype.Text        `json:"secret_key"`
+	SecretKeyRequired pgtype.Bool        `json:"secret_key_required"`
+	AccountID         pgtype.Text        `json:"account_id"`
+	Plan              pgtype.Text        `json:"plan"`
+	StartsAt          pgtype.Timestamptz `json:"starts_at"`
+	ExpiresAt         pgtype.Timestamptz `json:"expires_at"`
 }
 
 func (q *Queries) SelectPortalApplication(ctx context.Context, id string) (SelectPortalApplicationRow, error) {
@@ -91,6 +157,8 @@ func (q *Queries) SelectPortalApplication(ctx context.Context, id string) (Selec
 		&i.SecretKeyRequired,
 		&i.AccountID,
 		&i.Plan,
+		&i.StartsAt,
+		&i.ExpiresAt,
 	)
 	return i, err
 }
@@ -102,7 +170,9 @@ SELECT
     pas.secret_key,
     pas.secret_key_required,
     pa.account_id,
-    a.plan_type AS plan
+    a.plan_type AS plan,
+    pa.starts_at,
+    pa.expires_at
 FROM portal_applications pa
 LEFT JOIN portal_application_settings pas
     ON pa.id = pas.application_id
@@ -117,11 +187,13 @@ GROUP BY
 `
 
 type SelectPortalApplicationsRow struct {
-	ID                string      `json:"id"`
-	SecretKey         pgtype.Text `json:"secret_key"`
-	SecretKeyRequired pgtype.Bool `json:"secret_key_required"`
-	AccountID         pgtype.Text `json:"account_id"`
-	Plan              pgtype.Text `json:"plan"`
+	ID                string             `json:"id"`
+	SecretKey         pgtype.Text        `json:"secret_key"`
+	SecretKeyRequired pgtype.Bool        `json:"secret_key_required"`
+	AccountID         pgtype.Text        `json:"account_id"`
+	Plan              pgtype.Text        `json:"plan"`
+	StartsAt          pgtype.Timestamptz `json:"starts_at"`
+	ExpiresAt         pgtype.Timestamptz `json:"expires_at"`
 }
 
 // This file is used by SQLC to autogenerate the Go code needed by the database driver.
@@ -142,6 +214,8 @@ func (q *Queries) SelectPortalApplications(ctx context.Context) ([]SelectPortalA
 			&i.SecretKeyRequired,
 			&i.AccountID,
 			&i.Plan,
+			&i.StartsAt,
+			&i.ExpiresAt,
 		); err != nil {
 			return nil, err
 		}
@@ -152,3 +226,50 @@ func (q *Queries) SelectPortalApplications(ctx context.Context) ([]SelectPortalA
 	}
 	return items, nil
 }
+
+const upsertPortalApplication = `-- name: UpsertPortalApplication :exec
+INSERT INTO portal_applications (id, account_id, starts_at, expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE
+SET account_id = EXCLUDED.account_id,
+    starts_at = EXCLUDED.starts_at,
+    expires_at = EXCLUDED.expires_at,
+    deleted = false,
+    deleted_at = NULL
+`
+
+type UpsertPortalApplicationParams struct {
+	ID        string             `json:"id"`
+	AccountID pgtype.Text        `json:"account_id"`
+	StartsAt  pgtype.Timestamptz `json:"starts_at"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) UpsertPortalApplication(ctx context.Context, arg UpsertPortalApplicationParams) error {
+	_, err := q.db.Exec(ctx, upsertPortalApplication,
+		arg.ID,
+		arg.AccountID,
+		arg.StartsAt,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const upsertPortalApplicationSettings = `-- name: UpsertPortalApplicationSettings :exec
+INSERT INTO portal_application_settings (application_id, secret_key, secret_key_required)
+VALUES ($1, $2, $3)
+ON CONFLICT (application_id) DO UPDATE
+SET secret_key = EXCLUDED.secret_key,
+    secret_key_required = EXCLUDED.secret_key_required
+`
+
+type UpsertPortalApplicationSettingsParams struct {
+	ApplicationID     string      `json:"application_id"`
+	SecretKey         pgtype.Text `json:"secret_key"`
+	SecretKeyRequired pgtype.Bool `json:"secret_key_required"`
+}
+
+func (q *Queries) UpsertPortalApplicationSettings(ctx context.Context, arg UpsertPortalApplicationSettingsParams) error {
+	_, err := q.db.Exec(ctx, upsertPortalApplicationSettings, arg.ApplicationID, arg.SecretKey, arg.SecretKeyRequired)
+	return err
+}
@@ -7,7 +7,10 @@
 -- data from the existing Grove Portal Postgres database.
 -- See: https://github.com/pokt-foundation/portal-http-db/blob/master/postgres-driver/sqlc/schema.sql
 
--- IMPORTANT - All tables and columns defined in this file exist in the existing Grove Portal DB.
+-- IMPORTANT - All tables and columns defined in this file exist in the existing Grove Portal DB,
+-- except for the `portal_applications.starts_at` and `portal_applications.expires_at` columns,
+-- which are added by PADS to support time-bounded endpoints. They must be added to an existing Grove Portal DB
+-- using the migration in ../migrations/001_add_portal_application_validity.sql before PADS is started against it.
 
 -- The `portal_applications` and its associated tables are converted to the `proto.GatewayEndpoint` format.
 -- The inline comments indicate the fields in the `proto.GatewayEndpoint` that correspond to the columns in the `portal_applications` table.
@@ -23,13 +26,15 @@ CREATE TABLE portal_applications (
     id VARCHAR(24) PRIMARY KEY UNIQUE, -- GatewayEndpoint.EndpointId
     account_id VARCHAR(10) REFERENCES accounts(id),
     deleted BOOLEAN NOT NULL DEFAULT false,
-    deleted_at TIMESTAMPTZ NULL
+    deleted_at TIMESTAMPTZ NULL,
+    starts_at TIMESTAMPTZ NULL, -- Optional time from which the GatewayEndpoint is served
+    expires_at TIMESTAMPTZ NULL -- Optional time after which the GatewayEndpoint is no longer served
 ); 
 
 -- Portal Application Settings Table
 CREATE TABLE portal_application_settings (
     id SERIAL PRIMARY KEY,
     application_id VARCHAR(24) NOT NULL UNIQUE REFERENCES portal_applications(id) ON DELETE CASCADE,
-    secret_key VARCHAR(64), -- GatewayEndpoint.Auth.AuthType.StaticApiKey.ApiKey
+    secret_key VARCHAR(64), -- GatewayEndpoint.Auth.AuthType.StaticApiKey.ApiKey (plaintext or a `sha256:` hashed key; see the apikey package)
     secret_key_required BOOLEAN
 );
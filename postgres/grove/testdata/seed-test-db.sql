@@ -15,6 +15,11 @@ VALUES ('endpoint_1_no_auth', 'account_1'),
     ('endpoint_4_no_auth', 'account_1'),
     ('endpoint_5_static_key', 'account_2');
 
+-- Insert time-bounded portal applications, which must not be returned outside of their validity window
+INSERT INTO portal_applications (id, account_id, starts_at, expires_at)
+VALUES ('endpoint_6_expired', 'account_1', NULL, '2000-01-01 00:00:00+00'),
+    ('endpoint_7_pending', 'account_1', '2999-01-01 00:00:00+00', NULL);
+
 -- Insert into the 'portal_application_settings' table
 INSERT INTO portal_application_settings (application_id, secret_key_required, secret_key)
 VALUES ('endpoint_1_no_auth', FALSE, NULL),
@@ -0,0 +1,197 @@
+package grove
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/postgres/grove/sqlc"
+)
+
+// postgresDataSource implements the grpc_server.AuthDataWriter interface.
+var _ grpc_server.AuthDataWriter = &postgresDataSource{}
+
+// maxSecretKeyLength is the length of the `portal_application_settings.secret_key` VARCHAR(64) column.
+const maxSecretKeyLength = 64
+
+// WriteGatewayEndpoints applies the writes to the Grove Portal DB in a single transaction.
+//
+// The changes are not sent to the updates channel directly; they are picked up by the
+// triggers defined in ./postgres/sqlc/grove_triggers.sql once the transaction is committed.
+func (d *postgresDataSource) WriteGatewayEndpoints(ctx context.Context, writes []grpc_server.EndpointWrite) error {
+	tx, err := d.driver.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rolling back is a no-op once the transaction has been committed.
+	defer tx.Rollback(ctx)
+
+	queries := d.driver.WithTx(tx)
+
+	for _, write := range writes {
+		if err := writePortalApplication(ctx, queries, write); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// writePortalApplication applies a single write to the portal application tables.
+// Hashed secret keys are rejected with ErrUnsupportedWrite, as PEAS does not yet verify them.
+func writePortalApplication(ctx context.Context, queries *sqlc.Queries, write grpc_server.EndpointWrite) error {
+	if write.Op == grpc_server.WriteOpDelete {
+		rowsAffected, err := queries.DeletePortalApplication(ctx, write.EndpointID)
+		if err != nil {
+			return fmt.Errorf("failed to delete portal application %s: %w", write.EndpointID, err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("%w: %s", grpc_server.ErrEndpointNotFound, write.EndpointID)
+		}
+		return nil
+	}
+
+	exists, err := queries.PortalApplicationExists(ctx, write.EndpointID)
+	if err != nil {
+		return fmt.Errorf("failed to check portal application %s: %w", write.EndpointID, err)
+	}
+
+	switch write.Op {
+	case grpc_server.WriteOpCreate:
+		if exists {
+			return fmt.Errorf("%w: %s", grpc_server.ErrEndpointExists, write.EndpointID)
+		}
+	case grpc_server.WriteOpUpdate:
+		if !exists {
+			return fmt.Errorf("%w: %s", grpc_server.ErrEndpointNotFound, write.EndpointID)
+		}
+	case grpc_server.WriteOpUpsert:
+	default:
+		return fmt.Errorf("%w: unknown write operation %q for endpoint %s", grpc_server.ErrUnsupportedWrite, write.Op, write.EndpointID)
+	}
+
+	metadata := write.GatewayEndpoint.GetMetadata()
+	if err := validateWritableMetadata(write.EndpointID, metadata); err != nil {
+		return err
+	}
+
+	apiKey := write.GatewayEndpoint.GetAuth().GetStaticApiKey().GetApiKey()
+	if err := validateWritableSecretKey(write.EndpointID, apiKey); err != nil {
+		return err
+	}
+
+	if accountID := metadata.GetAccountId(); accountID != "" {
+		if err := ensureAccount(ctx, queries, accountID, metadata.GetPlanType()); err != nil {
+			return fmt.Errorf("endpoint %s: %w", write.EndpointID, err)
+		}
+	}
+
+	err = queries.UpsertPortalApplication(ctx, sqlc.UpsertPortalApplicationParams{
+		ID:        write.EndpointID,
+		AccountID: optionalText(metadata.GetAccountId()),
+		StartsAt:  optionalTimestamptz(write.Window.StartsAt),
+		ExpiresAt: optionalTimestamptz(write.Window.ExpiresAt),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write portal application %s: %w", write.EndpointID, err)
+	}
+
+	// A portal application with a secret key that is not required is served with no auth,
+	// so the secret key is only stored for GatewayEndpoints that use a static API key.
+	err = queries.UpsertPortalApplicationSettings(ctx, sqlc.UpsertPortalApplicationSettingsParams{
+		ApplicationID:     write.EndpointID,
+		SecretKey:         optionalText(apiKey),
+		SecretKeyRequired: pgtype.Bool{Bool: apiKey != "", Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write portal application settings %s: %w", write.EndpointID, err)
+	}
+
+	return nil
+}
+
+// validateWritableMetadata returns an error if the GatewayEndpoint metadata contains fields
+// which have no corresponding column in the Grove Portal DB and so would be silently dropped.
+func validateWritableMetadata(endpointID string, metadata *proto.Metadata) error {
+	unsupportedFields := []struct {
+		name  string
+		value string
+	}{
+		{"name", metadata.GetName()},
+		{"user_id", metadata.GetUserId()},
+		{"email", metadata.GetEmail()},
+		{"environment", metadata.GetEnvironment()},
+	}
+	for _, field := range unsupportedFields {
+		if field.value != "" {
+			return fmt.Errorf("%w: endpoint %s: metadata field %s is not supported by the Grove Portal DB", grpc_server.ErrUnsupportedWrite, endpointID, field.name)
+		}
+	}
+
+	if metadata.GetPlanType() != "" && metadata.GetAccountId() == "" {
+		return fmt.Errorf("%w: endpoint %s: a plan type requires an account ID, as plans are stored on the account", grpc_server.ErrUnsupportedWrite, endpointID)
+	}
+
+	return nil
+}
+
+// validateWritableSecretKey returns an error if the API key cannot be stored as the secret key of a portal application:
+// if it is a hashed API key, which PEAS cannot verify, or does not fit in the secret_key column,
+// which would otherwise fail the write or truncate the API key.
+func validateWritableSecretKey(endpointID, apiKey string) error {
+	if err := apikey.Check(apiKey); err != nil {
+		return fmt.Errorf("%w: endpoint %s: %v", grpc_server.ErrUnsupportedWrite, endpointID, err)
+	}
+
+	if len(apiKey) > maxSecretKeyLength {
+		return fmt.Errorf("%w: endpoint %s: the API key is %d characters long, but the Grove Portal DB stores at most %d",
+			grpc_server.ErrUnsupportedWrite, endpointID, len(apiKey), maxSecretKeyLength)
+	}
+
+	return nil
+}
+
+// ensureAccount creates the account with the plan type if it does not exist.
+//
+// The plan type is stored on the account and so is shared by all of its portal applications.
+// Writing a GatewayEndpoint with a different plan type than its existing account is therefore
+// rejected, rather than silently changing the plan type of the account's other GatewayEndpoints.
+func ensureAccount(ctx context.Context, queries *sqlc.Queries, accountID, planType string) error {
+	err := queries.InsertAccountIfNotExists(ctx, sqlc.InsertAccountIfNotExistsParams{
+		ID:       accountID,
+		PlanType: optionalText(planType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write account %s: %w", accountID, err)
+	}
+
+	accountPlanType, err := queries.SelectAccountPlanType(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to get account %s: %w", accountID, err)
+	}
+
+	if accountPlanType.String != planType {
+		return fmt.Errorf("%w: plan type %q does not match plan type %q of existing account %s", grpc_server.ErrUnsupportedWrite, planType, accountPlanType.String, accountID)
+	}
+
+	return nil
+}
+
+// optionalText returns a nullable text column value, which is NULL if the string is empty.
+func optionalText(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
+
+// optionalTimestamptz returns a nullable timestamp column value, which is NULL if the time is zero.
+func optionalTimestamptz(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,210 @@
+package grove
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/yaml"
+)
+
+func Test_Integration_WriteGatewayEndpoints(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping driver integration test")
+	}
+
+	newEndpoint := &proto.GatewayEndpoint{
+		EndpointId: "endpoint_6_static_key",
+		Auth: &proto.Auth{
+			AuthType: &proto.Auth_StaticApiKey{
+				StaticApiKey: &proto.StaticAPIKey{ApiKey: "secret_key_6"},
+			},
+		},
+		Metadata: &proto.Metadata{AccountId: "account_1", PlanType: "PLAN_FREE"},
+	}
+
+	tests := []struct {
+		name            string
+		writes          []grpc_server.EndpointWrite
+		wantErr         error
+		expectedPresent []string
+		expectedAbsent  []string
+	}{
+		{
+			name: "should create a gateway endpoint",
+			writes: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpCreate, EndpointID: "endpoint_6_static_key", GatewayEndpoint: newEndpoint},
+			},
+			expectedPresent: []string{"endpoint_6_static_key"},
+		},
+		{
+			name: "should fail to create an existing gateway endpoint",
+			writes: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpCreate, EndpointID: "endpoint_6_static_key", GatewayEndpoint: newEndpoint},
+			},
+			wantErr: grpc_server.ErrEndpointExists,
+		},
+		{
+			name: "should roll back all writes if any write fails",
+			writes: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpDelete, EndpointID: "endpoint_6_static_key"},
+				{Op: grpc_server.WriteOpUpdate, EndpointID: "endpoint_7_no_auth", GatewayEndpoint: newEndpoint},
+			},
+			wantErr:         grpc_server.ErrEndpointNotFound,
+			expectedPresent: []string{"endpoint_6_static_key"},
+		},
+		{
+			name: "should delete a gateway endpoint",
+			writes: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpDelete, EndpointID: "endpoint_6_static_key"},
+			},
+			expectedAbsent: []string{"endpoint_6_static_key"},
+		},
+	}
+
+	dataSource, cleanup, err := NewGrovePostgresDataSource(context.Background(), connectionString, polyzero.NewLogger())
+	require.NoError(t, err)
+	defer cleanup()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			err := dataSource.WriteGatewayEndpoints(context.Background(), test.writes)
+			if test.wantErr != nil {
+				c.True(errors.Is(err, test.wantErr))
+			} else {
+				c.NoError(err)
+			}
+
+			authData, err := dataSource.FetchAuthDataSync()
+			c.NoError(err)
+			for _, endpointID := range test.expectedPresent {
+				c.Contains(authData.Endpoints, endpointID)
+			}
+			for _, endpointID := range test.expectedAbsent {
+				c.NotContains(authData.Endpoints, endpointID)
+			}
+		})
+	}
+}
+
+// Test_Integration_ExportImportRoundTrip ensures that exporting the Grove Portal DB to the YAML file format
+// and importing the file back into the Grove Portal DB is lossless, as done by `pads export` and `pads import`.
+func Test_Integration_ExportImportRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping driver integration test")
+	}
+	c := require.New(t)
+
+	dataSource, cleanup, err := NewGrovePostgresDataSource(context.Background(), connectionString, polyzero.NewLogger())
+	c.NoError(err)
+	defer cleanup()
+
+	export := func() []byte {
+		authData, err := dataSource.FetchAuthDataSync()
+		c.NoError(err)
+		exported, err := yaml.EncodeGatewayEndpoints(authData, nil)
+		c.NoError(err)
+		return exported
+	}
+
+	exported := export()
+
+	authData, windows, err := yaml.DecodeGatewayEndpoints(exported)
+	c.NoError(err)
+	c.NotEmpty(authData.Endpoints)
+	c.NoError(dataSource.WriteGatewayEndpoints(context.Background(), grpc_server.UpsertWrites(authData.Endpoints, windows)))
+
+	c.Equal(string(exported), string(export()))
+}
+
+func Test_validateWritableMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata *proto.Metadata
+		wantErr  bool
+	}{
+		{
+			name:     "should accept account ID and plan type",
+			metadata: &proto.Metadata{AccountId: "account_1", PlanType: "PLAN_FREE"},
+		},
+		{
+			name:     "should accept no metadata",
+			metadata: nil,
+		},
+		{
+			name:     "should reject metadata fields without a Grove Portal DB column",
+			metadata: &proto.Metadata{AccountId: "account_1", Email: "user@example.com"},
+			wantErr:  true,
+		},
+		{
+			name:     "should reject plan type without account ID",
+			metadata: &proto.Metadata{PlanType: "PLAN_FREE"},
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			err := validateWritableMetadata("endpoint_1", test.metadata)
+			if test.wantErr {
+				c.Error(err)
+			} else {
+				c.NoError(err)
+			}
+		})
+	}
+}
+
+func Test_validateWritableSecretKey(t *testing.T) {
+	argon2idHash, err := apikey.Hash("secret_key_1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		wantErr bool
+	}{
+		{
+			name:   "should accept plaintext API key",
+			apiKey: "secret_key_1",
+		},
+		{
+			name:   "should accept no API key",
+			apiKey: "",
+		},
+		{
+			name:    "should reject argon2id hashed API key",
+			apiKey:  argon2idHash,
+			wantErr: true,
+		},
+		{
+			name:    "should reject API key which does not fit in the secret_key column",
+			apiKey:  strings.Repeat("k", maxSecretKeyLength+1),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			err := validateWritableSecretKey("endpoint_1", test.apiKey)
+			if test.wantErr {
+				c.ErrorIs(err, grpc_server.ErrUnsupportedWrite)
+			} else {
+				c.NoError(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package redact
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pokt-network/poktroll/pkg/polylog"
+)
+
+// sensitiveFields maps the log field keys whose values are always masked to their masking function.
+var sensitiveFields = map[string]func(string) string{
+	"api_key":    APIKey,
+	"secret_key": APIKey,
+	"email":      Email,
+}
+
+// redactField returns the masked value for the log field key if the key is sensitive.
+func redactField(key, value string) string {
+	if mask, ok := sensitiveFields[key]; ok {
+		return mask(value)
+	}
+	return value
+}
+
+// redactKeyVals masks the values of sensitive keys in a list of alternating keys and values.
+func redactKeyVals(keyVals []any) []any {
+	redacted := make([]any, len(keyVals))
+	copy(redacted, keyVals)
+
+	for i := 0; i+1 < len(redacted); i += 2 {
+		key, keyOK := redacted[i].(string)
+		value, valueOK := redacted[i+1].(string)
+		if keyOK && valueOK {
+			redacted[i+1] = redactField(key, value)
+		}
+	}
+	return redacted
+}
+
+// redactingLogger wraps a polylog.Logger so that the values of sensitive log fields are masked.
+type redactingLogger struct {
+	polylog.Logger
+}
+
+// NewLogger wraps the logger so that the values of sensitive fields (eg. "api_key" or "email")
+// are always masked, regardless of whether the caller remembered to redact them.
+// Logged errors are masked with Text, which cannot recognize plaintext API keys: callers must never
+// log an error which contains one.
+func NewLogger(logger polylog.Logger) polylog.Logger {
+	if _, ok := logger.(*redactingLogger); ok {
+		return logger
+	}
+	return &redactingLogger{Logger: logger}
+}
+
+func (l *redactingLogger) Debug() polylog.Event { return wrapEvent(l.Logger.Debug()) }
+func (l *redactingLogger) Info() polylog.Event  { return wrapEvent(l.Logger.Info()) }
+func (l *redactingLogger) Warn() polylog.Event  { return wrapEvent(l.Logger.Warn()) }
+func (l *redactingLogger) Error() polylog.Event { return wrapEvent(l.Logger.Error()) }
+
+func (l *redactingLogger) WithLevel(level polylog.Level) polylog.Event {
+	return wrapEvent(l.Logger.WithLevel(level))
+}
+
+func (l *redactingLogger) With(keyVals ...any) polylog.Logger {
+	return &redactingLogger{Logger: l.Logger.With(redactKeyVals(keyVals)...)}
+}
+
+func (l *redactingLogger) WithContext(ctx context.Context) context.Context {
+	return l.Logger.WithContext(ctx)
+}
+
+// redactingEvent wraps a polylog.Event so that the values of sensitive log fields are masked.
+type redactingEvent struct {
+	event polylog.Event
+}
+
+func wrapEvent(event polylog.Event) polylog.Event {
+	return &redactingEvent{event: event}
+}
+
+func (e *redactingEvent) Str(key, value string) polylog.Event {
+	e.event = e.event.Str(key, redactField(key, value))
+	return e
+}
+
+func (e *redactingEvent) Fields(fields any) polylog.Event {
+	switch f := fields.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(f))
+		for key, value := range f {
+			if s, ok := value.(string); ok {
+				value = redactField(key, s)
+			}
+			redacted[key] = value
+		}
+		fields = redacted
+	case map[string]string:
+		redacted := make(map[string]string, len(f))
+		for key, value := range f {
+			redacted[key] = redactField(key, value)
+		}
+		fields = redacted
+	case []any:
+		fields = redactKeyVals(f)
+	}
+	e.event = e.event.Fields(fields)
+	return e
+}
+
+func (e *redactingEvent) Func(fn func(polylog.Event)) polylog.Event {
+	e.event = e.event.Func(func(event polylog.Event) { fn(wrapEvent(event)) })
+	return e
+}
+
+func (e *redactingEvent) Bool(key string, value bool) polylog.Event {
+	e.event = e.event.Bool(key, value)
+	return e
+}
+
+func (e *redactingEvent) Int(key string, value int) polylog.Event {
+	e.event = e.event.Int(key, value)
+	return e
+}
+
+func (e *redactingEvent) Int8(key string, value int8) polylog.Event {
+	e.event = e.event.Int8(key, value)
+	return e
+}
+
+func (e *redactingEvent) Int16(key string, value int16) polylog.Event {
+	e.event = e.event.Int16(key, value)
+	return e
+}
+
+func (e *redactingEvent) Int32(key string, value int32) polylog.Event {
+	e.event = e.event.Int32(key, value)
+	return e
+}
+
+func (e *redactingEvent) Int64(key string, value int64) polylog.Event {
+	e.event = e.event.Int64(key, value)
+	return e
+}
+
+func (e *redactingEvent) Uint(key string, value uint) polylog.Event {
+	e.event = e.event.Uint(key, value)
+	return e
+}
+
+func (e *redactingEvent) Uint8(key string, value uint8) polylog.Event {
+	e.event = e.event.Uint8(key, value)
+	return e
+}
+
+func (e *redactingEvent) Uint16(key string, value uint16) polylog.Event {
+	e.event = e.event.Uint16(key, value)
+	return e
+}
+
+func (e *redactingEvent) Uint32(key string, value uint32) polylog.Event {
+	e.event = e.event.Uint32(key, value)
+	return e
+}
+
+func (e *redactingEvent) Uint64(key string, value uint64) polylog.Event {
+	e.event = e.event.Uint64(key, value)
+	return e
+}
+
+func (e *redactingEvent) Float32(key string, value float32) polylog.Event {
+	e.event = e.event.Float32(key, value)
+	return e
+}
+
+func (e *redactingEvent) Float64(key string, value float64) polylog.Event {
+	e.event = e.event.Float64(key, value)
+	return e
+}
+
+// Err masks the secret values which Text recognizes in the error's message, eg. an email quoted by a validation error.
+func (e *redactingEvent) Err(err error) polylog.Event {
+	if err != nil {
+		err = errors.New(Text(err.Error()))
+	}
+	e.event = e.event.Err(err)
+	return e
+}
+
+func (e *redactingEvent) Timestamp() polylog.Event {
+	e.event = e.event.Timestamp()
+	return e
+}
+
+func (e *redactingEvent) Time(key string, value time.Time) polylog.Event {
+	e.event = e.event.Time(key, value)
+	return e
+}
+
+func (e *redactingEvent) Dur(key string, value time.Duration) polylog.Event {
+	e.event = e.event.Dur(key, value)
+	return e
+}
+
+func (e *redactingEvent) Enabled() bool {
+	return e.event.Enabled()
+}
+
+func (e *redactingEvent) Discard() polylog.Event {
+	e.event = e.event.Discard()
+	return e
+}
+
+func (e *redactingEvent) Msg(message string) {
+	e.event.Msg(message)
+}
+
+func (e *redactingEvent) Msgf(format string, keyVals ...interface{}) {
+	e.event.Msgf(format, keyVals...)
+}
+
+func (e *redactingEvent) Send() {
+	e.event.Send()
+}
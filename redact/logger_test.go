@@ -0,0 +1,82 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/pokt-network/poktroll/pkg/polylog"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewLogger(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(logger polylog.Logger)
+	}{
+		{
+			name: "should redact sensitive Str fields",
+			log: func(logger polylog.Logger) {
+				logger.Info().Str("api_key", "api_key_1").Str("email", "amos.burton@opa.belt").Msg("test")
+			},
+		},
+		{
+			name: "should redact sensitive With fields",
+			log: func(logger polylog.Logger) {
+				logger.With("secret_key", "api_key_1", "email", "amos.burton@opa.belt").Info().Msg("test")
+			},
+		},
+		{
+			name: "should redact sensitive Fields",
+			log: func(logger polylog.Logger) {
+				logger.Info().Fields(map[string]any{"api_key": "api_key_1", "email": "amos.burton@opa.belt"}).Msg("test")
+			},
+		},
+		{
+			name: "should redact errors",
+			log: func(logger polylog.Logger) {
+				logger.Error().Err(errors.New("invalid metadata for amos.burton@opa.belt")).Msg("test")
+			},
+		},
+		{
+			name: "should redact sensitive fields set in Func",
+			log: func(logger polylog.Logger) {
+				logger.Info().Func(func(event polylog.Event) {
+					event.Str("api_key", "api_key_1").Str("email", "amos.burton@opa.belt")
+				}).Msg("test")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			var logs bytes.Buffer
+			logger := NewLogger(polyzero.NewLogger(polyzero.WithOutput(&logs)))
+
+			test.log(logger)
+
+			c.NotEmpty(logs.String())
+			c.NotContains(logs.String(), "api_key_1")
+			c.NotContains(logs.String(), "amos.burton@opa.belt")
+		})
+	}
+}
+
+func Test_NewLogger_preservesOtherFields(t *testing.T) {
+	c := require.New(t)
+
+	var logs bytes.Buffer
+	logger := NewLogger(polyzero.NewLogger(polyzero.WithOutput(&logs)))
+
+	logger.With("endpoint_id", "endpoint_1_static_key").Info().Int("count", 2).Str("account_id", "account_1").Msg("test")
+
+	c.Contains(logs.String(), `"endpoint_id":"endpoint_1_static_key"`)
+	c.Contains(logs.String(), `"account_id":"account_1"`)
+	c.Contains(logs.String(), `"count":2`)
+
+	// Wrapping a redacting logger again must return the same logger.
+	c.Equal(logger, NewLogger(logger))
+}
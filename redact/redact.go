@@ -0,0 +1,154 @@
+/*
+Package redact masks the secret values of GatewayEndpoints, such as API keys and emails,
+so that they never appear in logs or debug output.
+
+All logging of GatewayEndpoint values must use the helpers in this package, and all PADS
+loggers should be wrapped using NewLogger, which redacts the values of sensitive log fields.
+*/
+package redact
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
+)
+
+// Redacted is the value that replaces a secret value.
+const Redacted = "[REDACTED]"
+
+// APIKey returns a masked version of the API key.
+// The scheme of hashed API keys is preserved to aid debugging, eg. "argon2id:[REDACTED]".
+func APIKey(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	if apikey.IsHashed(apiKey) {
+		scheme, _, _ := strings.Cut(apiKey, ":")
+		return scheme + ":" + Redacted
+	}
+	return Redacted
+}
+
+// Email returns a masked version of the email address, preserving only the
+// first character of the local part and the domain, eg. "a***@opa.belt".
+func Email(email string) string {
+	if email == "" {
+		return ""
+	}
+	localPart, domain, ok := strings.Cut(email, "@")
+	if !ok || localPart == "" {
+		return Redacted
+	}
+	return localPart[:1] + "***@" + domain
+}
+
+// ConnectionString returns a masked version of the connection string, eg. of a Postgres
+// database, with its password redacted. If the connection string cannot be parsed, it is redacted entirely.
+func ConnectionString(connectionString string) string {
+	if connectionString == "" {
+		return ""
+	}
+
+	connectionURL, err := url.Parse(connectionString)
+	if err != nil {
+		return Redacted
+	}
+	if _, ok := connectionURL.User.Password(); ok {
+		connectionURL.User = url.UserPassword(connectionURL.User.Username(), Redacted)
+	}
+	// Passwords may also be set as a query parameter.
+	if query := connectionURL.Query(); query.Has("password") {
+		query.Set("password", Redacted)
+		connectionURL.RawQuery = query.Encode()
+	}
+	// The redaction marker is escaped in the URL, so it is unescaped to be recognizable.
+	return strings.ReplaceAll(connectionURL.String(), url.QueryEscape(Redacted), Redacted)
+}
+
+var (
+	// urlPasswordRegex matches the password of a URL's userinfo in free text, eg. in a Postgres connection string.
+	urlPasswordRegex = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.\-]*://[^\s:/@]*:)[^\s@/]+@`)
+	// hashedAPIKeyRegex matches a hashed API key in free text, capturing its scheme.
+	hashedAPIKeyRegex = regexp.MustCompile(`\b(argon2id):[^\s"'` + "`" + `]+`)
+	// emailRegex matches an email address in free text, capturing its first character and its domain.
+	emailRegex = regexp.MustCompile(`\b([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,})\b`)
+)
+
+// Text returns a copy of free text, eg. an error message, with the secret values it can recognize masked:
+// URL passwords, hashed API keys and email addresses, masked as ConnectionString, APIKey and Email mask them.
+//
+// Plaintext API keys cannot be recognized in free text, so they must never be included in error messages:
+// errors which may quote a GatewayEndpoint's values, eg. YAML decoding errors, must be redacted where they are created.
+func Text(text string) string {
+	text = urlPasswordRegex.ReplaceAllString(text, "${1}"+Redacted+"@")
+	text = hashedAPIKeyRegex.ReplaceAllString(text, "${1}:"+Redacted)
+	return emailRegex.ReplaceAllString(text, "${1}***@${2}")
+}
+
+// GatewayEndpoint returns a copy of the GatewayEndpoint with all secret values masked.
+//
+// The copy is built field by field rather than cloned, so that any field added to the
+// proto in the future is omitted until it is explicitly handled here. This also ensures
+// the provided GatewayEndpoint is never modified, including its internal proto state.
+func GatewayEndpoint(endpoint *proto.GatewayEndpoint) *proto.GatewayEndpoint {
+	if endpoint == nil {
+		return nil
+	}
+
+	redacted := &proto.GatewayEndpoint{
+		EndpointId: endpoint.GetEndpointId(),
+	}
+
+	switch {
+	case endpoint.GetAuth().GetStaticApiKey() != nil:
+		redacted.Auth = &proto.Auth{
+			AuthType: &proto.Auth_StaticApiKey{
+				StaticApiKey: &proto.StaticAPIKey{
+					ApiKey: APIKey(endpoint.GetAuth().GetStaticApiKey().GetApiKey()),
+				},
+			},
+		}
+	case endpoint.GetAuth().GetNoAuth() != nil:
+		redacted.Auth = &proto.Auth{
+			AuthType: &proto.Auth_NoAuth{NoAuth: &proto.NoAuth{}},
+		}
+	}
+
+	if metadata := endpoint.GetMetadata(); metadata != nil {
+		redacted.Metadata = &proto.Metadata{
+			Name:        metadata.GetName(),
+			AccountId:   metadata.GetAccountId(),
+			UserId:      metadata.GetUserId(),
+			PlanType:    metadata.GetPlanType(),
+			Email:       Email(metadata.GetEmail()),
+			Environment: metadata.GetEnvironment(),
+		}
+	}
+
+	return redacted
+}
+
+// GatewayEndpoints returns a copy of the GatewayEndpoints map with all secret values masked.
+func GatewayEndpoints(endpoints map[string]*proto.GatewayEndpoint) map[string]*proto.GatewayEndpoint {
+	redacted := make(map[string]*proto.GatewayEndpoint, len(endpoints))
+	for endpointID, endpoint := range endpoints {
+		redacted[endpointID] = GatewayEndpoint(endpoint)
+	}
+	return redacted
+}
+
+// AuthDataUpdate returns a copy of the AuthDataUpdate with all secret values masked.
+func AuthDataUpdate(update *proto.AuthDataUpdate) *proto.AuthDataUpdate {
+	if update == nil {
+		return nil
+	}
+	return &proto.AuthDataUpdate{
+		EndpointId:      update.EndpointId,
+		GatewayEndpoint: GatewayEndpoint(update.GatewayEndpoint),
+		Delete:          update.Delete,
+	}
+}
@@ -0,0 +1,88 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+)
+
+// DefaultInterval is how often the served GatewayEndpoints are persisted to the snapshot file.
+const DefaultInterval = time.Minute
+
+// EndpointsFunc returns the served GatewayEndpoints along with their revision,
+// which changes whenever the GatewayEndpoints change, eg. the gRPC server's GatewayEndpoints.
+type EndpointsFunc func() (map[string]*proto.GatewayEndpoint, uint64)
+
+// Persister periodically writes the served GatewayEndpoints to the snapshot file.
+//
+// The snapshot file is only written once the GatewayEndpoints are served from the data source,
+// so that a snapshot is never written over by itself while the data source is unavailable,
+// and only if they have changed since the last write.
+type Persister struct {
+	filename string
+	key      []byte
+	interval time.Duration
+
+	endpoints EndpointsFunc
+	// isLive returns true if the GatewayEndpoints are served from the data source.
+	isLive func() bool
+
+	// written is set once the snapshot file has been written, at lastRevision.
+	written      bool
+	lastRevision uint64
+
+	logger polylog.Logger
+}
+
+// NewPersister returns a Persister which writes the GatewayEndpoints to the snapshot file, encrypted with the key,
+// every interval while isLive returns true. Run must be called to start persisting.
+func NewPersister(filename string, key []byte, interval time.Duration, endpoints EndpointsFunc, isLive func() bool, logger polylog.Logger) *Persister {
+	return &Persister{
+		filename:  filename,
+		key:       key,
+		interval:  interval,
+		endpoints: endpoints,
+		isLive:    isLive,
+		logger:    logger.With("component", "snapshot_persister", "snapshot_file", filename),
+	}
+}
+
+// Run persists the GatewayEndpoints every interval until the context is done.
+func (p *Persister) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Persist(); err != nil {
+				p.logger.Error().Err(err).Msg("Failed to persist snapshot")
+			}
+		}
+	}
+}
+
+// Persist writes the GatewayEndpoints to the snapshot file, unless they are not served from
+// the data source or have not changed since the last write.
+func (p *Persister) Persist() error {
+	if !p.isLive() {
+		return nil
+	}
+
+	gatewayEndpoints, revision := p.endpoints()
+	if p.written && revision == p.lastRevision {
+		return nil
+	}
+
+	if err := Write(p.filename, p.key, Snapshot{GatewayEndpoints: gatewayEndpoints, CreatedAt: time.Now()}); err != nil {
+		return err
+	}
+	p.written, p.lastRevision = true, revision
+
+	p.logger.Debug().Int("endpoints", len(gatewayEndpoints)).Uint64("revision", revision).Msg("Persisted snapshot")
+	return nil
+}
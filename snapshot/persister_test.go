@@ -0,0 +1,54 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+)
+
+func Test_Persister(t *testing.T) {
+	c := require.New(t)
+
+	filename := filepath.Join(t.TempDir(), "snapshot")
+
+	gatewayEndpoints := map[string]*proto.GatewayEndpoint{"endpoint_1": datasourcetest.NoAuthEndpoint("endpoint_1")}
+	var revision uint64 = 1
+	endpoints := func() (map[string]*proto.GatewayEndpoint, uint64) { return gatewayEndpoints, revision }
+	live := false
+
+	persister := NewPersister(filename, testKey(1), time.Minute, endpoints, func() bool { return live }, polyzero.NewLogger())
+
+	// Nothing is written while the GatewayEndpoints are not served from the data source.
+	c.NoError(persister.Persist())
+	_, err := os.Stat(filename)
+	c.ErrorIs(err, os.ErrNotExist)
+
+	live = true
+	c.NoError(persister.Persist())
+	snapshot, err := Read(filename, testKey(1))
+	c.NoError(err)
+	c.Len(snapshot.GatewayEndpoints, 1)
+
+	// Nothing is written if the GatewayEndpoints have not changed since the last write.
+	c.NoError(os.Remove(filename))
+	c.NoError(persister.Persist())
+	_, err = os.Stat(filename)
+	c.ErrorIs(err, os.ErrNotExist)
+
+	gatewayEndpoints = map[string]*proto.GatewayEndpoint{
+		"endpoint_1": datasourcetest.NoAuthEndpoint("endpoint_1"),
+		"endpoint_2": datasourcetest.NoAuthEndpoint("endpoint_2"),
+	}
+	revision = 2
+	c.NoError(persister.Persist())
+	snapshot, err = Read(filename, testKey(1))
+	c.NoError(err)
+	c.Len(snapshot.GatewayEndpoints, 2)
+}
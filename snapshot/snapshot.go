@@ -0,0 +1,199 @@
+/*
+Package snapshot persists the GatewayEndpoints served by PADS to a local file, so that PADS may start
+serving them before its data source is available, eg. if the Postgres database is down at startup.
+
+Snapshot files contain API keys and emails, so they are always encrypted at rest using AES-256-GCM.
+The GatewayEndpoints are also checksummed using SHA-256, so that a snapshot which was corrupted,
+truncated or written by an incompatible version of PADS is never served.
+
+The file format is:
+
+	"PADSSNAP" | format version (1 byte) | nonce (12 bytes) | AES-256-GCM ciphertext
+
+where the plaintext is:
+
+	created at (8 bytes, Unix nanoseconds) | SHA-256 of the GatewayEndpoints (32 bytes) | GatewayEndpoints (protobuf AuthDataResponse)
+*/
+package snapshot
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// KeySize is the size in bytes of the AES-256 key used to encrypt snapshot files.
+const KeySize = 32
+
+const (
+	// magic identifies a snapshot file.
+	magic = "PADSSNAP"
+	// formatVersion is the version of the snapshot file format written by this version of PADS.
+	formatVersion byte = 1
+)
+
+// headerSize is the size of the unencrypted header, which is authenticated as additional data.
+const headerSize = len(magic) + 1
+
+// ErrInvalidSnapshot is returned by Read if the file is not a valid snapshot, or was encrypted with another key.
+var ErrInvalidSnapshot = errors.New("invalid snapshot file")
+
+// Snapshot is the set of GatewayEndpoints served by PADS at a point in time.
+type Snapshot struct {
+	GatewayEndpoints map[string]*proto.GatewayEndpoint
+	CreatedAt        time.Time
+}
+
+// ParseKey decodes a base64-encoded AES-256 key, eg. as generated by `openssl rand -base64 32`.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot key must be base64-encoded: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("snapshot key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// LoadKey returns the key set directly, or read from the key file if it is set instead.
+func LoadKey(key, keyFile string) ([]byte, error) {
+	if keyFile == "" {
+		return ParseKey(key)
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot key file: %w", err)
+	}
+	return ParseKey(string(data))
+}
+
+// Write encrypts the snapshot with the key and atomically writes it to the file, readable only by its owner.
+func Write(filename string, key []byte, snapshot Snapshot) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	body, err := protobuf.Marshal(&proto.AuthDataResponse{Endpoints: snapshot.GatewayEndpoints})
+	if err != nil {
+		return fmt.Errorf("failed to marshal gateway endpoints: %w", err)
+	}
+	checksum := sha256.Sum256(body)
+
+	plaintext := make([]byte, 0, 8+len(checksum)+len(body))
+	plaintext = binary.BigEndian.AppendUint64(plaintext, uint64(snapshot.CreatedAt.UnixNano()))
+	plaintext = append(plaintext, checksum[:]...)
+	plaintext = append(plaintext, body...)
+
+	header := append([]byte(magic), formatVersion)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	data := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+gcm.Overhead())
+	data = append(data, header...)
+	data = append(data, nonce...)
+	data = gcm.Seal(data, nonce, plaintext, header)
+
+	return atomicWriteFile(filename, data)
+}
+
+// Read reads and decrypts the snapshot in the file with the key.
+// It returns ErrInvalidSnapshot if the file is not a valid snapshot, or was encrypted with another key.
+func Read(filename string, key []byte) (Snapshot, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if len(data) < headerSize+gcm.NonceSize() || !bytes.HasPrefix(data, []byte(magic)) {
+		return Snapshot{}, fmt.Errorf("%w: not a snapshot file", ErrInvalidSnapshot)
+	}
+	if version := data[len(magic)]; version != formatVersion {
+		return Snapshot{}, fmt.Errorf("%w: unsupported format version %d", ErrInvalidSnapshot, version)
+	}
+
+	header, nonce, ciphertext := data[:headerSize], data[headerSize:headerSize+gcm.NonceSize()], data[headerSize+gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("%w: corrupted or encrypted with another key", ErrInvalidSnapshot)
+	}
+	if len(plaintext) < 8+sha256.Size {
+		return Snapshot{}, fmt.Errorf("%w: truncated", ErrInvalidSnapshot)
+	}
+
+	createdAt := time.Unix(0, int64(binary.BigEndian.Uint64(plaintext[:8])))
+	checksum, body := plaintext[8:8+sha256.Size], plaintext[8+sha256.Size:]
+	if actual := sha256.Sum256(body); !bytes.Equal(checksum, actual[:]) {
+		return Snapshot{}, fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+
+	var authData proto.AuthDataResponse
+	if err := protobuf.Unmarshal(body, &authData); err != nil {
+		return Snapshot{}, fmt.Errorf("%w: failed to unmarshal gateway endpoints: %v", ErrInvalidSnapshot, err)
+	}
+
+	gatewayEndpoints := authData.GetEndpoints()
+	if gatewayEndpoints == nil {
+		gatewayEndpoints = make(map[string]*proto.GatewayEndpoint)
+	}
+	return Snapshot{GatewayEndpoints: gatewayEndpoints, CreatedAt: createdAt}, nil
+}
+
+// newGCM returns the AES-256-GCM cipher for the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("snapshot key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// atomicWriteFile writes the data to a temporary file in the same directory and renames it over the file,
+// so that a snapshot is never read partially written, eg. if PADS is stopped while writing it.
+func atomicWriteFile(filename string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Removing the temporary file is a no-op once it has been renamed.
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), filename)
+}
@@ -0,0 +1,172 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+)
+
+func Test_WriteRead(t *testing.T) {
+	c := require.New(t)
+
+	filename := filepath.Join(t.TempDir(), "snapshot")
+	snapshot := Snapshot{
+		GatewayEndpoints: map[string]*proto.GatewayEndpoint{
+			"endpoint_1": datasourcetest.StaticKeyEndpoint("endpoint_1", "api_key_1"),
+			"endpoint_2": datasourcetest.NoAuthEndpoint("endpoint_2"),
+		},
+		CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	c.NoError(Write(filename, testKey(1), snapshot))
+
+	read, err := Read(filename, testKey(1))
+	c.NoError(err)
+	c.True(snapshot.CreatedAt.Equal(read.CreatedAt))
+	c.Len(read.GatewayEndpoints, 2)
+	c.Equal("api_key_1", read.GatewayEndpoints["endpoint_1"].GetAuth().GetStaticApiKey().GetApiKey())
+	c.Equal("endpoint_2", read.GatewayEndpoints["endpoint_2"].GetEndpointId())
+
+	// The snapshot is encrypted at rest and only readable by its owner.
+	data, err := os.ReadFile(filename)
+	c.NoError(err)
+	c.False(bytes.Contains(data, []byte("api_key_1")))
+
+	info, err := os.Stat(filename)
+	c.NoError(err)
+	c.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// Overwriting a snapshot leaves no temporary file behind.
+	c.NoError(Write(filename, testKey(1), Snapshot{CreatedAt: time.Now()}))
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	c.NoError(err)
+	c.Len(entries, 1)
+
+	read, err = Read(filename, testKey(1))
+	c.NoError(err)
+	c.Empty(read.GatewayEndpoints)
+	c.NotNil(read.GatewayEndpoints)
+}
+
+func Test_Read_invalidSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+		key     []byte
+	}{
+		{
+			name: "should reject snapshot encrypted with another key",
+			key:  testKey(2),
+		},
+		{
+			name:    "should reject file which is not a snapshot",
+			corrupt: func([]byte) []byte { return []byte("endpoints:\n  endpoint_1: {}\n") },
+		},
+		{
+			name:    "should reject unsupported format version",
+			corrupt: func(data []byte) []byte { data[len(magic)] = 2; return data },
+		},
+		{
+			name:    "should reject corrupted ciphertext",
+			corrupt: func(data []byte) []byte { data[len(data)-20] ^= 0xff; return data },
+		},
+		{
+			name:    "should reject truncated file",
+			corrupt: func(data []byte) []byte { return data[:len(data)/2] },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			filename := filepath.Join(t.TempDir(), "snapshot")
+			c.NoError(Write(filename, testKey(1), Snapshot{
+				GatewayEndpoints: map[string]*proto.GatewayEndpoint{"endpoint_1": datasourcetest.NoAuthEndpoint("endpoint_1")},
+				CreatedAt:        time.Now(),
+			}))
+
+			if test.corrupt != nil {
+				data, err := os.ReadFile(filename)
+				c.NoError(err)
+				c.NoError(os.WriteFile(filename, test.corrupt(data), 0600))
+			}
+
+			key := test.key
+			if key == nil {
+				key = testKey(1)
+			}
+
+			_, err := Read(filename, key)
+			c.ErrorIs(err, ErrInvalidSnapshot)
+		})
+	}
+}
+
+func Test_LoadKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "snapshot-key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(testKey(1))+"\n"), 0600))
+
+	tests := []struct {
+		name        string
+		key         string
+		keyFile     string
+		expectedKey []byte
+		wantErr     bool
+	}{
+		{
+			name:        "should parse base64-encoded key",
+			key:         base64.StdEncoding.EncodeToString(testKey(1)),
+			expectedKey: testKey(1),
+		},
+		{
+			name:        "should read key from key file",
+			keyFile:     keyFile,
+			expectedKey: testKey(1),
+		},
+		{
+			name:    "should reject key which is not base64-encoded",
+			key:     "not a key!",
+			wantErr: true,
+		},
+		{
+			name:    "should reject key which is not 32 bytes",
+			key:     base64.StdEncoding.EncodeToString([]byte("too short")),
+			wantErr: true,
+		},
+		{
+			name:    "should reject missing key file",
+			keyFile: filepath.Join(t.TempDir(), "missing"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			key, err := LoadKey(test.key, test.keyFile)
+			if test.wantErr {
+				c.Error(err)
+				return
+			}
+			c.NoError(err)
+			c.Equal(test.expectedKey, key)
+		})
+	}
+}
+
+/* ---------------------------- Test Helpers ---------------------------- */
+
+// testKey returns a deterministic key, which differs for each seed.
+func testKey(seed byte) []byte {
+	return bytes.Repeat([]byte{seed}, KeySize)
+}
@@ -0,0 +1,47 @@
+package startup
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// The default Backoff values, used for any value which is not set.
+const (
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// Backoff is an exponential backoff with jitter between attempts to connect to the data source.
+type Backoff struct {
+	// Initial is the delay before the second attempt. It doubles after each failed attempt, up to Max.
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// withDefaults returns the Backoff with the default value of any value which is not set.
+func (b Backoff) withDefaults() Backoff {
+	if b.Initial <= 0 {
+		b.Initial = DefaultInitialBackoff
+	}
+	if b.Max <= 0 {
+		b.Max = DefaultMaxBackoff
+	}
+	if b.Max < b.Initial {
+		b.Max = b.Initial
+	}
+	return b
+}
+
+// Delay returns the delay after the given failed attempt, starting from 1.
+//
+// The delay is jittered between half and all of the exponential backoff, so that
+// several PADS replicas started together do not retry the data source in lockstep.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Initial
+	for i := 1; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, b.Max)
+
+	return delay/2 + rand.N(delay/2+1)
+}
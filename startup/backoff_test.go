@@ -0,0 +1,56 @@
+package startup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Backoff_Delay(t *testing.T) {
+	tests := []struct {
+		name        string
+		backoff     Backoff
+		attempt     int
+		expectedMax time.Duration
+	}{
+		{
+			name:        "should wait up to the initial backoff after the first attempt",
+			backoff:     Backoff{Initial: time.Second, Max: time.Minute},
+			attempt:     1,
+			expectedMax: time.Second,
+		},
+		{
+			name:        "should double the backoff after each attempt",
+			backoff:     Backoff{Initial: time.Second, Max: time.Minute},
+			attempt:     4,
+			expectedMax: 8 * time.Second,
+		},
+		{
+			name:        "should not exceed the max backoff",
+			backoff:     Backoff{Initial: time.Second, Max: time.Minute},
+			attempt:     100,
+			expectedMax: time.Minute,
+		},
+		{
+			name:        "should default backoff values which are not set",
+			attempt:     1,
+			expectedMax: DefaultInitialBackoff,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			backoff := test.backoff.withDefaults()
+
+			// The delay is jittered between half and all of the backoff.
+			for range 100 {
+				delay := backoff.Delay(test.attempt)
+				c.GreaterOrEqual(delay, test.expectedMax/2)
+				c.LessOrEqual(delay, test.expectedMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+/*
+Package startup provides an implementation of the AuthDataSource interface which connects to the data source
+in the background, so that PADS starts, and serves its health check, even if the data source is unavailable,
+eg. if the Postgres database is down or its DNS name does not resolve yet.
+
+Until the data source is connected, the initial GatewayEndpoints are served: a snapshot persisted by a previous
+run of PADS, or an empty set if PADS was explicitly started empty. Otherwise, the data source is not ready until
+it is connected, and the gRPC server rejects requests so that PEAS never syncs an incomplete set of GatewayEndpoints.
+*/
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+
+	"github.com/buildwithgrove/path-auth-data-server/diff"
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+)
+
+// startupDataSource implements the AuthDataSource, AuthDataWriter and ReadinessReporter interfaces
+var (
+	_ grpc_server.AuthDataSource    = &startupDataSource{}
+	_ grpc_server.AuthDataWriter    = &startupDataSource{}
+	_ grpc_server.ReadinessReporter = &startupDataSource{}
+)
+
+// ConnectFunc connects to the data source, returning it along with a cleanup function.
+// It returns an error if the data source is unavailable, eg. if the Postgres database is down.
+type ConnectFunc func() (grpc_server.AuthDataSource, func(), error)
+
+// Initial is the set of GatewayEndpoints served until the data source is connected.
+type Initial struct {
+	// Source describes where the GatewayEndpoints come from, eg. "snapshot". It is only used in logs.
+	Source           string
+	GatewayEndpoints map[string]*proto.GatewayEndpoint
+	// Ready is true if the GatewayEndpoints may be served before the data source is connected,
+	// ie. if they are a snapshot of the data source or PADS was explicitly started empty.
+	Ready bool
+}
+
+// startupDataSource serves the initial GatewayEndpoints until the data source is connected.
+//
+// It retries connecting to the data source in the background. Once connected, it reconciles the initial
+// GatewayEndpoints with the data source, by sending an update for each GatewayEndpoint which was added,
+// changed or removed, and then forwards the data source's updates.
+type startupDataSource struct {
+	initial Initial
+	connect ConnectFunc
+	backoff Backoff
+
+	updateFeed        *grpc_server.UpdateFeed
+	authDataUpdatesCh chan *proto.AuthDataUpdate
+
+	// live is the connected data source, set once it has been reconciled with the initial GatewayEndpoints.
+	live        atomic.Pointer[grpc_server.AuthDataSource]
+	liveCleanup func()
+	liveMu      sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	logger polylog.Logger
+}
+
+// NewDataSource returns a data source which serves the initial GatewayEndpoints, and connects
+// to the data source in the background, retrying with the backoff until it succeeds.
+// Any Backoff value which is not set is defaulted.
+//
+// Close must be called to stop connecting and release the data source's resources.
+func NewDataSource(initial Initial, connect ConnectFunc, backoff Backoff, logger polylog.Logger) *startupDataSource {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &startupDataSource{
+		initial:           initial,
+		connect:           connect,
+		backoff:           backoff.withDefaults(),
+		authDataUpdatesCh: make(chan *proto.AuthDataUpdate, 100_000),
+		ctx:               ctx,
+		cancel:            cancel,
+		logger:            logger.With("component", "startup_data_source"),
+	}
+
+	// If the initial GatewayEndpoints may not be served, the first attempt is made before returning,
+	// so that an available data source's GatewayEndpoints are served from the start, as if connected directly,
+	// rather than sent as updates once connected.
+	failedAttempts := 0
+	if !initial.Ready {
+		live, liveUpdatesCh, err := s.connectAndSubscribe()
+		if err == nil {
+			s.updateFeed = grpc_server.NewUpdateFeed(live.gatewayEndpoints, s.authDataUpdatesCh)
+			s.live.Store(&live.authDataSource)
+			go s.forwardUpdates(liveUpdatesCh)
+			return s
+		}
+		failedAttempts = 1
+		s.logRetry(failedAttempts, err)
+	}
+
+	s.updateFeed = grpc_server.NewUpdateFeed(initial.GatewayEndpoints, s.authDataUpdatesCh)
+	go s.connectAndReconcile(failedAttempts)
+
+	return s
+}
+
+/* ---------- Data Source Funcs ---------- */
+
+// FetchAuthDataSync returns the served GatewayEndpoints: the initial GatewayEndpoints until the data source
+// is connected, and the data source's GatewayEndpoints once it has been reconciled with them.
+func (s *startupDataSource) FetchAuthDataSync() (*proto.AuthDataResponse, error) {
+	return &proto.AuthDataResponse{Endpoints: s.updateFeed.Snapshot()}, nil
+}
+
+// SubscribeAuthData returns the served GatewayEndpoints along with a channel of every update applied afterwards,
+// including the updates which reconcile the initial GatewayEndpoints with the data source once it is connected.
+func (s *startupDataSource) SubscribeAuthData() (*proto.AuthDataResponse, <-chan *proto.AuthDataUpdate, error) {
+	gatewayEndpoints, updatesCh, err := s.updateFeed.Subscribe()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &proto.AuthDataResponse{Endpoints: gatewayEndpoints}, updatesCh, nil
+}
+
+// WriteGatewayEndpoints writes to the data source once it is connected, if it supports writes.
+// Writes are rejected with ErrDataSourceUnavailable until then, as they could not be persisted.
+func (s *startupDataSource) WriteGatewayEndpoints(ctx context.Context, writes []grpc_server.EndpointWrite) error {
+	live := s.live.Load()
+	if live == nil {
+		return fmt.Errorf("%w: still connecting to the data source", grpc_server.ErrDataSourceUnavailable)
+	}
+
+	writer, ok := (*live).(grpc_server.AuthDataWriter)
+	if !ok {
+		return fmt.Errorf("%w: the data source does not support writes", grpc_server.ErrUnsupportedWrite)
+	}
+	return writer.WriteGatewayEndpoints(ctx, writes)
+}
+
+// Ready returns true if the initial GatewayEndpoints may be served, or once the data source is connected.
+func (s *startupDataSource) Ready() bool {
+	return s.initial.Ready || s.Live()
+}
+
+// Live returns true once the data source is connected and has been reconciled with the initial GatewayEndpoints.
+func (s *startupDataSource) Live() bool {
+	return s.live.Load() != nil
+}
+
+// Close stops connecting to the data source, and releases its resources if it is connected.
+func (s *startupDataSource) Close() {
+	s.cancel()
+
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+
+	if s.liveCleanup != nil {
+		s.liveCleanup()
+		s.liveCleanup = nil
+	}
+}
+
+/* ---------- Connect and Reconcile Funcs ---------- */
+
+// connectAndReconcile connects to the data source, retrying until it succeeds or the startup data source is closed,
+// then reconciles the initial GatewayEndpoints with the data source's GatewayEndpoints and forwards its updates.
+func (s *startupDataSource) connectAndReconcile(failedAttempts int) {
+	live, liveUpdatesCh, ok := s.connectWithRetry(failedAttempts)
+	if !ok {
+		return
+	}
+
+	changes := diff.GatewayEndpoints(s.initial.GatewayEndpoints, live.gatewayEndpoints)
+	for _, change := range changes {
+		if change.Type == diff.ChangeRemoved {
+			s.authDataUpdatesCh <- &proto.AuthDataUpdate{EndpointId: change.EndpointID, Delete: true}
+			continue
+		}
+		s.authDataUpdatesCh <- &proto.AuthDataUpdate{
+			EndpointId:      change.EndpointID,
+			GatewayEndpoint: live.gatewayEndpoints[change.EndpointID],
+		}
+	}
+
+	summary := diff.Summarize(changes)
+	s.logger.Info().
+		Str("initial_source", s.initial.Source).
+		Int("added", summary.Added).
+		Int("removed", summary.Removed).
+		Int("changed", summary.Changed).
+		Msg("Data source connected, reconciled initial GatewayEndpoints with data source")
+
+	s.live.Store(&live.authDataSource)
+
+	s.forwardUpdates(liveUpdatesCh)
+}
+
+// forwardUpdates forwards the connected data source's updates.
+func (s *startupDataSource) forwardUpdates(liveUpdatesCh <-chan *proto.AuthDataUpdate) {
+	for update := range liveUpdatesCh {
+		s.authDataUpdatesCh <- update
+	}
+}
+
+// connectedDataSource is a connected data source along with the GatewayEndpoints it served when subscribed to.
+type connectedDataSource struct {
+	authDataSource   grpc_server.AuthDataSource
+	gatewayEndpoints map[string]*proto.GatewayEndpoint
+}
+
+// connectWithRetry connects and subscribes to the data source, retrying with the backoff until it succeeds,
+// after the given number of attempts have already failed. It returns false if the startup data source is closed first.
+func (s *startupDataSource) connectWithRetry(failedAttempts int) (connectedDataSource, <-chan *proto.AuthDataUpdate, bool) {
+	for attempt := failedAttempts + 1; ; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-s.ctx.Done():
+				return connectedDataSource{}, nil, false
+			case <-time.After(s.backoff.Delay(attempt - 1)):
+			}
+		}
+
+		live, liveUpdatesCh, err := s.connectAndSubscribe()
+		if err == nil {
+			return live, liveUpdatesCh, true
+		}
+		if errors.Is(err, context.Canceled) {
+			return connectedDataSource{}, nil, false
+		}
+		s.logRetry(attempt, err)
+	}
+}
+
+// logRetry logs the failed attempt to connect to the data source.
+func (s *startupDataSource) logRetry(attempt int, err error) {
+	s.logger.Warn().Err(err).
+		Int("attempt", attempt).
+		Str("initial_source", s.initial.Source).
+		Bool("ready", s.initial.Ready).
+		Msg("Failed to connect to data source, retrying with backoff")
+}
+
+// connectAndSubscribe connects and subscribes to the data source once.
+// It returns context.Canceled if the startup data source was closed while connecting.
+func (s *startupDataSource) connectAndSubscribe() (connectedDataSource, <-chan *proto.AuthDataUpdate, error) {
+	authDataSource, cleanup, err := s.connect()
+	if err != nil {
+		return connectedDataSource{}, nil, err
+	}
+
+	authData, liveUpdatesCh, err := authDataSource.SubscribeAuthData()
+	if err != nil {
+		cleanup()
+		return connectedDataSource{}, nil, fmt.Errorf("failed to subscribe to data source: %w", err)
+	}
+
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+
+	// The startup data source was closed while connecting.
+	if s.ctx.Err() != nil {
+		cleanup()
+		return connectedDataSource{}, nil, s.ctx.Err()
+	}
+	s.liveCleanup = cleanup
+
+	return connectedDataSource{authDataSource: authDataSource, gatewayEndpoints: authData.GetEndpoints()}, liveUpdatesCh, nil
+}
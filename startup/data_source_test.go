@@ -0,0 +1,198 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildwithgrove/path-auth-data-server/datasourcetest"
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+)
+
+func Test_DataSource_reconcilesInitialEndpointsOnceConnected(t *testing.T) {
+	c := require.New(t)
+
+	initial := Initial{
+		Source: "snapshot",
+		GatewayEndpoints: map[string]*proto.GatewayEndpoint{
+			"endpoint_1": datasourcetest.StaticKeyEndpoint("endpoint_1", "api_key_1"),
+			"endpoint_2": datasourcetest.StaticKeyEndpoint("endpoint_2", "api_key_2"),
+			"endpoint_3": datasourcetest.NoAuthEndpoint("endpoint_3"),
+		},
+		Ready: true,
+	}
+	live := datasourcetest.New([]*proto.GatewayEndpoint{
+		datasourcetest.StaticKeyEndpoint("endpoint_1", "api_key_1"),
+		datasourcetest.StaticKeyEndpoint("endpoint_2", "api_key_2_rotated"),
+		datasourcetest.NoAuthEndpoint("endpoint_4"),
+	}, grpc_server.NewUpdateFeed)
+
+	// The data source is unavailable for the first two attempts, eg. while the Postgres database is down.
+	connect, attempts := failingConnect(live, 2)
+	dataSource := NewDataSource(initial, connect, testBackoff, polyzero.NewLogger())
+	t.Cleanup(dataSource.Close)
+	c.True(dataSource.Ready())
+
+	authData, updatesCh, err := dataSource.SubscribeAuthData()
+	c.NoError(err)
+	c.Equal(initial.GatewayEndpoints, authData.GetEndpoints())
+
+	// Once connected, only the GatewayEndpoints which changed since the snapshot was written are updated.
+	reconciled := make(map[string]*proto.AuthDataUpdate)
+	for range 3 {
+		update := recvUpdate(t, updatesCh)
+		reconciled[update.GetEndpointId()] = update
+	}
+	c.Equal(map[string]*proto.AuthDataUpdate{
+		"endpoint_2": {EndpointId: "endpoint_2", GatewayEndpoint: live.GatewayEndpoints()["endpoint_2"]},
+		"endpoint_3": {EndpointId: "endpoint_3", Delete: true},
+		"endpoint_4": {EndpointId: "endpoint_4", GatewayEndpoint: live.GatewayEndpoints()["endpoint_4"]},
+	}, reconciled)
+	c.Equal(3, attempts())
+	c.Eventually(dataSource.Live, time.Second, time.Millisecond)
+
+	// The data source's updates are then forwarded.
+	live.Put(datasourcetest.NoAuthEndpoint("endpoint_5"))
+	c.Equal("endpoint_5", recvUpdate(t, updatesCh).GetEndpointId())
+
+	authData, err = dataSource.FetchAuthDataSync()
+	c.NoError(err)
+	c.Len(authData.GetEndpoints(), 4)
+	c.Equal(live.GatewayEndpoints()["endpoint_2"], authData.GetEndpoints()["endpoint_2"])
+}
+
+func Test_DataSource_servesInitialEndpointsUntilConnected(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial Initial
+	}{
+		{
+			name: "should serve snapshot as ready",
+			initial: Initial{
+				Source:           "snapshot",
+				GatewayEndpoints: map[string]*proto.GatewayEndpoint{"endpoint_1": datasourcetest.NoAuthEndpoint("endpoint_1")},
+				Ready:            true,
+			},
+		},
+		{
+			name:    "should serve no endpoints as ready when started empty",
+			initial: Initial{Source: "empty", Ready: true},
+		},
+		{
+			name:    "should not be ready without initial endpoints",
+			initial: Initial{Source: "none"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			connect, attempts := failingConnect(nil, -1)
+			dataSource := NewDataSource(test.initial, connect, testBackoff, polyzero.NewLogger())
+
+			c.Eventually(func() bool { return attempts() > 2 }, time.Second, time.Millisecond)
+			c.False(dataSource.Live())
+			c.Equal(test.initial.Ready, dataSource.Ready())
+
+			authData, err := dataSource.FetchAuthDataSync()
+			c.NoError(err)
+			c.Len(authData.GetEndpoints(), len(test.initial.GatewayEndpoints))
+
+			// Writes are rejected, as they could not be persisted.
+			err = dataSource.WriteGatewayEndpoints(context.Background(), []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpDelete, EndpointID: "endpoint_1"},
+			})
+			c.ErrorIs(err, grpc_server.ErrDataSourceUnavailable)
+
+			// Closing stops connecting.
+			dataSource.Close()
+			time.Sleep(10 * time.Millisecond)
+			closedAttempts := attempts()
+			time.Sleep(10 * time.Millisecond)
+			c.Equal(closedAttempts, attempts())
+		})
+	}
+}
+
+func Test_DataSource_connectsBeforeReturningIfNotReady(t *testing.T) {
+	c := require.New(t)
+
+	live := datasourcetest.New([]*proto.GatewayEndpoint{datasourcetest.NoAuthEndpoint("endpoint_1")}, grpc_server.NewUpdateFeed)
+	connect, attempts := failingConnect(live, 0)
+	dataSource := NewDataSource(Initial{Source: "none"}, connect, testBackoff, polyzero.NewLogger())
+	t.Cleanup(dataSource.Close)
+
+	// The data source's GatewayEndpoints are served from the start, rather than sent as updates.
+	c.True(dataSource.Live())
+	c.True(dataSource.Ready())
+	c.Equal(1, attempts())
+
+	authData, updatesCh, err := dataSource.SubscribeAuthData()
+	c.NoError(err)
+	c.Equal(live.GatewayEndpoints(), authData.GetEndpoints())
+
+	live.Put(datasourcetest.NoAuthEndpoint("endpoint_2"))
+	c.Equal("endpoint_2", recvUpdate(t, updatesCh).GetEndpointId())
+}
+
+func Test_DataSource_writesOnceConnected(t *testing.T) {
+	c := require.New(t)
+
+	live := &datasourcetest.Writer[grpc_server.EndpointWrite]{DataSource: datasourcetest.New(nil, grpc_server.NewUpdateFeed)}
+	connect, _ := failingConnect(live, 1)
+	dataSource := NewDataSource(Initial{Source: "empty", Ready: true}, connect, testBackoff, polyzero.NewLogger())
+	t.Cleanup(dataSource.Close)
+
+	c.Eventually(dataSource.Live, time.Second, time.Millisecond)
+
+	writes := []grpc_server.EndpointWrite{{Op: grpc_server.WriteOpDelete, EndpointID: "endpoint_1"}}
+	c.NoError(dataSource.WriteGatewayEndpoints(context.Background(), writes))
+	c.Equal(writes, live.Writes())
+}
+
+/* ---------------------------- Test Helpers ---------------------------- */
+// testBackoff retries quickly, so that tests do not wait for the default backoff.
+var testBackoff = Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond}
+
+// failingConnect returns a ConnectFunc which fails the given number of times, or always if failures is negative,
+// before returning the data source, along with a function which returns the number of connection attempts.
+func failingConnect(authDataSource grpc_server.AuthDataSource, failures int) (ConnectFunc, func() int) {
+	var attempts int
+	var mu sync.Mutex
+
+	connect := func() (grpc_server.AuthDataSource, func(), error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if failures < 0 || attempts <= failures {
+			return nil, nil, errors.New("connection refused")
+		}
+		return authDataSource, func() {}, nil
+	}
+	getAttempts := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts
+	}
+	return connect, getAttempts
+}
+
+func recvUpdate(t *testing.T, updatesCh <-chan *proto.AuthDataUpdate) *proto.AuthDataUpdate {
+	t.Helper()
+
+	select {
+	case update := <-updatesCh:
+		return update
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for update")
+		return nil
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Package tlsconfig provides the TLS configuration for the PADS listener.
+
+It supports serving TLS using a certificate and key file, optionally requiring client
+certificates signed by a client CA (mutual TLS) and restricting the allowed client
+certificates to a list of names.
+
+The certificate, key and client CA files are watched for changes, so that rotated
+certificates are used for all new connections without restarting PADS.
+*/
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+)
+
+// Config contains the file paths and client restrictions used to build the TLS configuration.
+type Config struct {
+	// CertFile and KeyFile are the paths to the PEM encoded server certificate and key.
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile is the optional path to a PEM encoded CA bundle.
+	// If set, clients must present a certificate signed by one of its CAs.
+	ClientCAFile string
+
+	// AllowedClientNames optionally restricts the accepted client certificates to those whose
+	// Common Name or one of whose DNS, URI or email Subject Alternative Names is in the list.
+	AllowedClientNames []string
+}
+
+// Validate returns an error if the Config is incomplete.
+func (c Config) Validate() error {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return fmt.Errorf("both a certificate file and a key file must be set")
+	}
+	if len(c.AllowedClientNames) > 0 && c.ClientCAFile == "" {
+		return fmt.Errorf("allowed client names require a client CA file")
+	}
+	return nil
+}
+
+// Reloader serves the TLS configuration built from the files in a Config,
+// reloading them whenever they change on disk.
+type Reloader struct {
+	config Config
+
+	tlsConfig   *tls.Config
+	tlsConfigMu sync.RWMutex
+
+	watcher *fsnotify.Watcher
+
+	logger polylog.Logger
+}
+
+// NewReloader loads the files in the Config and starts watching them for changes.
+// The returned Reloader's Close method must be called to stop watching the files.
+func NewReloader(config Config, logger polylog.Logger) (*Reloader, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Reloader{
+		config: config,
+		logger: logger.With("component", "tls_reloader"),
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TLS file watcher: %w", err)
+	}
+
+	// The directories are watched rather than the files themselves, as certificates
+	// are commonly rotated by replacing the files (eg. Kubernetes Secret volumes swap
+	// a symlink), which would otherwise silently stop the file watch.
+	for _, dir := range r.watchedDirs() {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch TLS directory %s: %w", dir, err)
+		}
+	}
+	r.watcher = watcher
+
+	go r.watchFiles()
+
+	return r, nil
+}
+
+// TLSConfig returns a TLS configuration which always uses the most recently loaded files.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.tlsConfigMu.RLock()
+			defer r.tlsConfigMu.RUnlock()
+			return r.tlsConfig, nil
+		},
+	}
+}
+
+// Reload loads the files in the Config and replaces the served TLS configuration.
+// If any file fails to load, the previously loaded TLS configuration is kept.
+func (r *Reloader) Reload() error {
+	tlsConfig, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	r.tlsConfigMu.Lock()
+	defer r.tlsConfigMu.Unlock()
+	r.tlsConfig = tlsConfig
+
+	return nil
+}
+
+// Close stops watching the files for changes.
+func (r *Reloader) Close() error {
+	if r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
+
+// load builds a TLS configuration from the files in the Config.
+func (r *Reloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate and key: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if r.config.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	clientCAs, err := loadCertPool(r.config.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+
+	if len(r.config.AllowedClientNames) > 0 {
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyClientName(state, r.config.AllowedClientNames)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// watchFiles reloads the TLS configuration whenever one of the watched directories changes.
+func (r *Reloader) watchFiles() {
+	for {
+		select {
+		case _, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			// Any change in a watched directory triggers a reload, as the files may be
+			// symlinks whose targets are replaced. A failed reload, eg. when only one of
+			// the certificate and key has been written, keeps the previous configuration.
+			if err := r.Reload(); err != nil {
+				r.logger.Warn().Err(err).Msg("failed to reload TLS files, keeping previous configuration")
+				continue
+			}
+			r.logger.Info().Msg("reloaded TLS files")
+
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Error().Err(err).Msg("TLS file watcher error")
+		}
+	}
+}
+
+// watchedDirs returns the unique directories containing the files in the Config.
+func (r *Reloader) watchedDirs() []string {
+	var dirs []string
+	for _, file := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		if dir := filepath.Dir(file); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// loadCertPool reads a PEM encoded CA bundle into a certificate pool.
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", filename)
+	}
+	return pool, nil
+}
+
+// verifyClientName returns an error if the verified client certificate's Common Name
+// and Subject Alternative Names do not include any of the allowed names.
+func verifyClientName(state tls.ConnectionState, allowedNames []string) error {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return errors.New("no verified client certificate")
+	}
+	cert := state.VerifiedChains[0][0]
+
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+
+	for _, name := range names {
+		if name != "" && slices.Contains(allowedNames, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate %q is not in the allowed client names", cert.Subject.CommonName)
+}
@@ -0,0 +1,314 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Reloader_handshake(t *testing.T) {
+	ca := newTestCA(t, "test-ca")
+	otherCA := newTestCA(t, "other-ca")
+
+	tests := []struct {
+		name               string
+		clientCA           bool
+		allowedClientNames []string
+		clientCert         *tls.Certificate
+		wantErr            bool
+	}{
+		{
+			name:       "should accept client without certificate when client CA is not set",
+			clientCA:   false,
+			clientCert: nil,
+			wantErr:    false,
+		},
+		{
+			name:       "should reject client without certificate when client CA is set",
+			clientCA:   true,
+			clientCert: nil,
+			wantErr:    true,
+		},
+		{
+			name:       "should accept client certificate signed by client CA",
+			clientCA:   true,
+			clientCert: ca.issue(t, "peas", false),
+			wantErr:    false,
+		},
+		{
+			name:       "should reject client certificate signed by another CA",
+			clientCA:   true,
+			clientCert: otherCA.issue(t, "peas", false),
+			wantErr:    true,
+		},
+		{
+			name:               "should accept client certificate in allowed client names",
+			clientCA:           true,
+			allowedClientNames: []string{"peas"},
+			clientCert:         ca.issue(t, "peas", false),
+			wantErr:            false,
+		},
+		{
+			name:               "should reject client certificate not in allowed client names",
+			clientCA:           true,
+			allowedClientNames: []string{"peas"},
+			clientCert:         ca.issue(t, "not-peas", false),
+			wantErr:            true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			dir := t.TempDir()
+			config := Config{
+				CertFile:           filepath.Join(dir, "tls.crt"),
+				KeyFile:            filepath.Join(dir, "tls.key"),
+				AllowedClientNames: test.allowedClientNames,
+			}
+			writeKeyPair(t, ca.issue(t, "localhost", true), config.CertFile, config.KeyFile)
+			if test.clientCA {
+				config.ClientCAFile = filepath.Join(dir, "ca.crt")
+				c.NoError(os.WriteFile(config.ClientCAFile, ca.certPEM, 0600))
+			}
+
+			reloader, err := NewReloader(config, polyzero.NewLogger())
+			c.NoError(err)
+			defer reloader.Close()
+
+			_, err = handshake(t, reloader.TLSConfig(), ca.pool(), test.clientCert)
+			if test.wantErr {
+				c.Error(err)
+			} else {
+				c.NoError(err)
+			}
+		})
+	}
+}
+
+func Test_Reloader_reloadsRotatedCertificate(t *testing.T) {
+	c := require.New(t)
+
+	ca := newTestCA(t, "test-ca")
+
+	dir := t.TempDir()
+	config := Config{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+	}
+	writeKeyPair(t, ca.issue(t, "localhost", true), config.CertFile, config.KeyFile)
+
+	reloader, err := NewReloader(config, polyzero.NewLogger())
+	c.NoError(err)
+	defer reloader.Close()
+
+	serial, err := handshake(t, reloader.TLSConfig(), ca.pool(), nil)
+	c.NoError(err)
+
+	// Rotate the certificate by atomically replacing the files.
+	rotatedCert := ca.issue(t, "localhost", true)
+	writeKeyPair(t, rotatedCert, config.CertFile+".new", config.KeyFile+".new")
+	c.NoError(os.Rename(config.KeyFile+".new", config.KeyFile))
+	c.NoError(os.Rename(config.CertFile+".new", config.CertFile))
+
+	c.Eventually(func() bool {
+		rotatedSerial, err := handshake(t, reloader.TLSConfig(), ca.pool(), nil)
+		return err == nil && rotatedSerial.Cmp(serial) != 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// An invalid certificate must not replace the previously loaded configuration.
+	c.NoError(os.WriteFile(config.CertFile, []byte("not a certificate"), 0600))
+	c.Error(reloader.Reload())
+	_, err = handshake(t, reloader.TLSConfig(), ca.pool(), nil)
+	c.NoError(err)
+}
+
+func Test_Config_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name:    "should accept certificate and key",
+			config:  Config{CertFile: "tls.crt", KeyFile: "tls.key"},
+			wantErr: false,
+		},
+		{
+			name:    "should reject certificate without key",
+			config:  Config{CertFile: "tls.crt"},
+			wantErr: true,
+		},
+		{
+			name:    "should reject allowed client names without client CA",
+			config:  Config{CertFile: "tls.crt", KeyFile: "tls.key", AllowedClientNames: []string{"peas"}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.Validate()
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+/* ---------------------------------- Helpers ---------------------------------- */
+
+type testCA struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          randomSerial(t),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{
+		cert:    cert,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:     key,
+	}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// issue returns a certificate for the name signed by the CA.
+func (ca *testCA) issue(t *testing.T, name string, server bool) *tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	extKeyUsage := x509.ExtKeyUsageClientAuth
+	if server {
+		extKeyUsage = x509.ExtKeyUsageServerAuth
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(t),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{extKeyUsage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writeKeyPair(t *testing.T, cert *tls.Certificate, certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+}
+
+func randomSerial(t *testing.T) *big.Int {
+	t.Helper()
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	require.NoError(t, err)
+	return serial
+}
+
+// handshake performs a TLS handshake against the server config and
+// returns the serial number of the certificate presented by the server.
+func handshake(t *testing.T, serverConfig *tls.Config, rootCAs *x509.CertPool, clientCert *tls.Certificate) (*big.Int, error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	serverErrCh := make(chan error, 1)
+	go func() {
+		serverConn, err := ln.Accept()
+		if err != nil {
+			serverErrCh <- err
+			return
+		}
+		defer serverConn.Close()
+		serverErrCh <- tls.Server(serverConn, serverConfig).Handshake()
+	}()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer clientConn.Close()
+
+	clientConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		ServerName: "localhost",
+		NextProtos: []string{"h2"},
+	}
+	if clientCert != nil {
+		clientConfig.Certificates = []tls.Certificate{*clientCert}
+	}
+
+	client := tls.Client(clientConn, clientConfig)
+	clientErr := client.Handshake()
+	if clientErr == nil {
+		// With TLS 1.3 the server verifies the client certificate after the client
+		// handshake completes, so a read is needed to observe a rejection.
+		_ = client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		_, _ = client.Read(make([]byte, 1))
+	}
+
+	if serverErr := <-serverErrCh; serverErr != nil {
+		return nil, serverErr
+	}
+	if clientErr != nil {
+		return nil, clientErr
+	}
+	return client.ConnectionState().PeerCertificates[0].SerialNumber, nil
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxCarried is the max number of spans a Carrier holds. Spans are only carried while tracing is enabled,
+// and are taken once their update is handled or dropped, so it is only reached if an update is dropped without
+// its span being taken, in which case the carried spans are forgotten.
+const maxCarried = 100_000
+
+// Updates carries the span of each update from the data source which emits it to the server which applies it,
+// as the channels of updates carry no context. Spans are carried with the update itself, so that the updates
+// of distinct tenants, or distinct updates to the same endpoint, never continue each other's span.
+//
+// Whatever drops an update, eg. an UpdateFeed dropping an unchanged portal application, must take its span,
+// and whatever replaces an update with another, eg. a composite data source, must move its span to the new update.
+var Updates = NewCarrier[*proto.AuthDataUpdate](0)
+
+// Carried is a span carried with an update, along with when it was carried.
+type Carried struct {
+	SpanContext trace.SpanContext
+	At          time.Time
+}
+
+// Context returns the context with the carried span as its parent span.
+func (c Carried) Context(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(ctx, c.SpanContext)
+}
+
+// Carrier carries spans across channels which carry no context, by a key identifying what they are carried with.
+// Only sampled spans are carried, so a Carrier holds nothing while tracing is not enabled.
+type Carrier[K comparable] struct {
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	carried map[K]Carried
+}
+
+// NewCarrier returns an empty Carrier of spans which are taken within the max age, if set.
+func NewCarrier[K comparable](maxAge time.Duration) *Carrier[K] {
+	return &Carrier[K]{maxAge: maxAge, carried: make(map[K]Carried)}
+}
+
+// Carry carries the span of the context with the key, if it is sampled.
+func (c *Carrier[K]) Carry(ctx context.Context, key K) {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsSampled() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.carried) >= maxCarried {
+		clear(c.carried)
+	}
+	c.carried[key] = Carried{SpanContext: spanContext, At: time.Now()}
+}
+
+// Take returns the span carried with the key and forgets it, or false if none is, or if it exceeded the max age.
+func (c *Carrier[K]) Take(key K) (Carried, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	carried, ok := c.carried[key]
+	if !ok {
+		return Carried{}, false
+	}
+	delete(c.carried, key)
+	if c.maxAge > 0 && time.Since(carried.At) > c.maxAge {
+		return Carried{}, false
+	}
+	return carried, true
+}
+
+// Move carries the span carried with the key with another key instead, eg. if an update is replaced by another.
+func (c *Carrier[K]) Move(from, to K) {
+	if from == to {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if carried, ok := c.carried[from]; ok {
+		delete(c.carried, from)
+		c.carried[to] = carried
+	}
+}
+
+// Context returns the context with the span carried with the key as its parent span, if any, and forgets it.
+func (c *Carrier[K]) Context(ctx context.Context, key K) context.Context {
+	if carried, ok := c.Take(key); ok {
+		return carried.Context(ctx)
+	}
+	return ctx
+}
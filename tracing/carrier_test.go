@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sampledContext returns a context with a sampled span of the trace ID.
+func sampledContext(traceID byte) context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{traceID},
+		SpanID:     trace.SpanID{traceID},
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+func Test_Carrier(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		carry       func(*Carrier[string])
+		key         string
+		wantTraceID byte
+		wantOK      bool
+	}{
+		{
+			name:   "should take the span carried with the key",
+			carry:  func(c *Carrier[string]) { c.Carry(sampledContext(1), "endpoint_1") },
+			key:    "endpoint_1",
+			wantOK: true,
+
+			wantTraceID: 1,
+		},
+		{
+			name:  "should not take a span carried with another key",
+			carry: func(c *Carrier[string]) { c.Carry(sampledContext(1), "endpoint_1") },
+			key:   "endpoint_2",
+		},
+		{
+			name: "should not carry a span which is not sampled",
+			carry: func(c *Carrier[string]) {
+				spanContext := trace.SpanContextFromContext(sampledContext(1)).WithTraceFlags(0)
+				c.Carry(trace.ContextWithSpanContext(context.Background(), spanContext), "endpoint_1")
+			},
+			key: "endpoint_1",
+		},
+		{
+			name:  "should not carry a context without a span",
+			carry: func(c *Carrier[string]) { c.Carry(context.Background(), "endpoint_1") },
+			key:   "endpoint_1",
+		},
+		{
+			name: "should take the latest span carried with the key",
+			carry: func(c *Carrier[string]) {
+				c.Carry(sampledContext(1), "endpoint_1")
+				c.Carry(sampledContext(2), "endpoint_1")
+			},
+			key:    "endpoint_1",
+			wantOK: true,
+
+			wantTraceID: 2,
+		},
+		{
+			name: "should take a span moved to the key",
+			carry: func(c *Carrier[string]) {
+				c.Carry(sampledContext(1), "endpoint_1")
+				c.Move("endpoint_1", "endpoint_2")
+			},
+			key:    "endpoint_2",
+			wantOK: true,
+
+			wantTraceID: 1,
+		},
+		{
+			name: "should not take a span moved from the key",
+			carry: func(c *Carrier[string]) {
+				c.Carry(sampledContext(1), "endpoint_1")
+				c.Move("endpoint_1", "endpoint_2")
+			},
+			key: "endpoint_1",
+		},
+		{
+			name:   "should not take a span which exceeded the max age",
+			maxAge: time.Nanosecond,
+			carry: func(c *Carrier[string]) {
+				c.Carry(sampledContext(1), "endpoint_1")
+				time.Sleep(time.Millisecond)
+			},
+			key: "endpoint_1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			carrier := NewCarrier[string](test.maxAge)
+			test.carry(carrier)
+
+			carried, ok := carrier.Take(test.key)
+			c.Equal(test.wantOK, ok)
+			if test.wantOK {
+				c.Equal(trace.TraceID{test.wantTraceID}, carried.SpanContext.TraceID())
+				c.False(carried.At.IsZero())
+			}
+
+			// A span is forgotten once taken.
+			_, ok = carrier.Take(test.key)
+			c.False(ok)
+		})
+	}
+}
+
+func Test_Carrier_Context(t *testing.T) {
+	c := require.New(t)
+
+	carrier := NewCarrier[string](0)
+	carrier.Carry(sampledContext(1), "endpoint_1")
+
+	ctx := carrier.Context(context.Background(), "endpoint_1")
+	c.Equal(trace.TraceID{1}, trace.SpanContextFromContext(ctx).TraceID())
+
+	// The span was taken, so the context is returned unchanged.
+	ctx = carrier.Context(context.Background(), "endpoint_1")
+	c.False(trace.SpanContextFromContext(ctx).IsValid())
+}
@@ -0,0 +1,138 @@
+/*
+Package tracing traces every update to a GatewayEndpoint with OpenTelemetry, from the change detected by
+a data source to the update's delivery to the client, so that the time it took may be broken down:
+
+  - postgres.notification: from the Postgres notification of a change, until it is processed.
+  - postgres.processPortalApplicationChanges: reading the changed portal applications.
+  - pads.handleDataSourceUpdates: applying the update to the served GatewayEndpoints.
+  - pads.pendingQueue: waiting to be sent, while no client is connected or behind the updates queued before it.
+  - pads.stream.Send: sending the update to the client.
+
+Spans are exported by an OTLP exporter if one is configured. Otherwise, the global TracerProvider is a no-op
+and no span is recorded.
+*/
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The exporters which may be configured.
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+)
+
+// The OTLP protocols which may be configured.
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+)
+
+var (
+	Exporters = []string{ExporterNone, ExporterOTLP}
+	Protocols = []string{ProtocolGRPC, ProtocolHTTPProtobuf}
+)
+
+// The default Config values, used for any value which is not set.
+const (
+	DefaultExporter    = ExporterNone
+	DefaultProtocol    = ProtocolGRPC
+	DefaultSampleRatio = 1.0
+)
+
+const (
+	// instrumentationName is the name of the tracer of every PADS span.
+	instrumentationName = "github.com/buildwithgrove/path-auth-data-server"
+	// serviceName is the name of the service of every PADS span, unless overridden by OTEL_SERVICE_NAME.
+	serviceName = "pads"
+)
+
+// Config configures how spans are exported.
+type Config struct {
+	// Exporter is one of none or otlp.
+	Exporter string
+	// Protocol is the OTLP protocol, one of grpc or http/protobuf.
+	Protocol string
+	// Endpoint is the URL of the OTLP collector, eg. http://localhost:4317. If not set, the exporter's default
+	// endpoint is used, unless set by the OTEL_EXPORTER_OTLP_ENDPOINT environment variable.
+	Endpoint string
+	// SampleRatio is the ratio of the traces which are sampled, from 0 to 1.
+	SampleRatio float64
+}
+
+// Enabled returns true if spans are exported.
+func (c Config) Enabled() bool {
+	return c.Exporter == ExporterOTLP
+}
+
+// Tracer returns the tracer of PADS spans, from the global TracerProvider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup sets the global TracerProvider to export spans as configured, and returns a function which
+// exports any remaining spans and shuts the exporter down. Nothing is set up if tracing is not enabled.
+//
+// The exporter is also configured by the standard OTEL_EXPORTER_OTLP_* environment variables,
+// eg. for headers or certificates, and the resource by OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	if !config.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	// Attributes set from the environment take precedence over the default service name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+// newExporter returns the OTLP exporter of the configured protocol.
+func newExporter(ctx context.Context, config Config) (sdktrace.SpanExporter, error) {
+	switch config.Protocol {
+	case ProtocolHTTPProtobuf:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+		return otlptracehttp.New(ctx, opts...)
+
+	case ProtocolGRPC, "":
+		var opts []otlptracegrpc.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(config.Endpoint))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q: must be one of %v", config.Protocol, Protocols)
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Package validity provides support for time-bounded GatewayEndpoints.
+
+A GatewayEndpoint may optionally define a validity window (starts_at / expires_at),
+outside of which it must not be served to PEAS. The Scheduler is used by data sources to
+determine whether an endpoint is currently active and to emit AuthDataUpdates when an
+endpoint activates or expires, without requiring any change to the underlying data source.
+
+Once loaded, data sources send the updates of their endpoints through the Scheduler (see Update and Delete),
+so that they are always ordered before any activation or expiry of the same endpoint.
+
+Scheduled activations and expiries are held in memory only; they are recomputed from the
+data source whenever it is loaded, so they survive restarts of PADS.
+*/
+package validity
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog"
+
+	"github.com/buildwithgrove/path-auth-data-server/tracing"
+)
+
+// Window is the optional time window during which a GatewayEndpoint is served.
+type Window struct {
+	// StartsAt is the time the endpoint activates. If zero, the endpoint is active immediately.
+	StartsAt time.Time
+	// ExpiresAt is the time the endpoint expires. If zero, the endpoint never expires.
+	ExpiresAt time.Time
+}
+
+// IsZero returns true if the window has neither a start nor an expiry time.
+func (w Window) IsZero() bool {
+	return w.StartsAt.IsZero() && w.ExpiresAt.IsZero()
+}
+
+// IsActive returns true if the endpoint should be served at the given time.
+func (w Window) IsActive(now time.Time) bool {
+	if !w.StartsAt.IsZero() && now.Before(w.StartsAt) {
+		return false
+	}
+	if !w.ExpiresAt.IsZero() && !now.Before(w.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+// Validate returns an error if the window expires before or at the time it starts.
+func (w Window) Validate() error {
+	if !w.StartsAt.IsZero() && !w.ExpiresAt.IsZero() && !w.ExpiresAt.After(w.StartsAt) {
+		return fmt.Errorf("expires_at (%s) must be after starts_at (%s)", w.ExpiresAt.Format(time.RFC3339), w.StartsAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Scheduler tracks the validity windows of GatewayEndpoints and sends an AuthDataUpdate
+// to the provided updates channel when an endpoint activates (create) or expires (delete).
+type Scheduler struct {
+	updatesCh chan<- *proto.AuthDataUpdate
+
+	// scheduled holds the pending activation and expiry timers for each endpoint ID.
+	scheduled map[string]*scheduledEndpoint
+	// pending holds the updates which are due, in order, until they are sent.
+	pending []*proto.AuthDataUpdate
+	// sending is the endpoint ID of the update being sent, if any.
+	sending string
+	// sent is signalled whenever an update was sent.
+	sent        *sync.Cond
+	scheduledMu sync.Mutex
+
+	// sendMu serializes the sending of the pending updates, so that they are sent in order.
+	sendMu sync.Mutex
+	// done is closed once the Scheduler is stopped, so that pending updates are no longer sent.
+	done chan struct{}
+
+	// now returns the current time.
+	now func() time.Time
+
+	logger polylog.Logger
+}
+
+// scheduledEndpoint holds the pending timers for a single endpoint.
+type scheduledEndpoint struct {
+	timers []*time.Timer
+}
+
+// NewScheduler returns a Scheduler that sends activation and expiry updates to updatesCh.
+func NewScheduler(updatesCh chan<- *proto.AuthDataUpdate, logger polylog.Logger) *Scheduler {
+	s := &Scheduler{
+		updatesCh: updatesCh,
+		scheduled: make(map[string]*scheduledEndpoint),
+		done:      make(chan struct{}),
+		now:       time.Now,
+		logger:    logger,
+	}
+	s.sent = sync.NewCond(&s.scheduledMu)
+	return s
+}
+
+// Schedule records the validity window of the endpoint, replacing any previously scheduled
+// activation or expiry, and returns true if the endpoint is currently active.
+//
+// If the endpoint activates in the future, a create update is sent at activation time.
+// If the endpoint expires in the future, a delete update is sent at expiry time.
+//
+// Schedule does not send the endpoint's current state, so it must only be used when the data source is loaded:
+// afterwards, updates must be sent with Update.
+func (s *Scheduler) Schedule(endpoint *proto.GatewayEndpoint, window Window) bool {
+	s.scheduledMu.Lock()
+	defer s.scheduledMu.Unlock()
+
+	return s.scheduleLocked(endpoint, window)
+}
+
+// Update records the validity window of the endpoint as Schedule does, and sends the endpoint's current state:
+// a create update if it is active, or a delete update otherwise. It returns once the update is sent.
+//
+// The update is queued under the same lock as the scheduled activations and expiries, so that it is always sent
+// before them, even if one of them is due at once. Otherwise, an endpoint which expired while the data source sent
+// its update would be served indefinitely, or one which activated would be deleted.
+//
+// The span of the context, if any, is carried with the update (see tracing.Updates).
+func (s *Scheduler) Update(ctx context.Context, endpoint *proto.GatewayEndpoint, window Window) {
+	endpointID := endpoint.GetEndpointId()
+
+	s.scheduledMu.Lock()
+	update := &proto.AuthDataUpdate{EndpointId: endpointID, GatewayEndpoint: endpoint}
+	if !s.scheduleLocked(endpoint, window) {
+		update = &proto.AuthDataUpdate{EndpointId: endpointID, Delete: true}
+	}
+	s.queueLocked(ctx, update)
+	s.scheduledMu.Unlock()
+
+	s.sendPending()
+}
+
+// Delete removes any scheduled activation or expiry for the endpoint and sends a delete update, ordered as Update.
+// It must be used when an endpoint is deleted from the data source. It returns once the update is sent.
+func (s *Scheduler) Delete(ctx context.Context, endpointID string) {
+	s.scheduledMu.Lock()
+	s.cancelLocked(endpointID)
+	s.queueLocked(ctx, &proto.AuthDataUpdate{EndpointId: endpointID, Delete: true})
+	s.scheduledMu.Unlock()
+
+	s.sendPending()
+}
+
+// queueLocked queues the update to be sent, carrying the span of the context. The caller must hold scheduledMu.
+func (s *Scheduler) queueLocked(ctx context.Context, update *proto.AuthDataUpdate) {
+	tracing.Updates.Carry(ctx, update)
+	s.pending = append(s.pending, update)
+}
+
+// scheduleLocked records the validity window of the endpoint as described by Schedule. The caller must hold scheduledMu.
+func (s *Scheduler) scheduleLocked(endpoint *proto.GatewayEndpoint, window Window) bool {
+	endpointID := endpoint.GetEndpointId()
+
+	s.cancelLocked(endpointID)
+
+	now := s.now()
+	if window.IsZero() {
+		return true
+	}
+
+	entry := &scheduledEndpoint{}
+
+	if !window.StartsAt.IsZero() && now.Before(window.StartsAt) {
+		entry.timers = append(entry.timers, s.afterFunc(entry, window.StartsAt.Sub(now), &proto.AuthDataUpdate{
+			EndpointId:      endpointID,
+			GatewayEndpoint: endpoint,
+		}))
+	}
+
+	if !window.ExpiresAt.IsZero() && now.Before(window.ExpiresAt) {
+		entry.timers = append(entry.timers, s.afterFunc(entry, window.ExpiresAt.Sub(now), &proto.AuthDataUpdate{
+			EndpointId: endpointID,
+			Delete:     true,
+		}))
+	}
+
+	if len(entry.timers) > 0 {
+		s.scheduled[endpointID] = entry
+	}
+
+	return window.IsActive(now)
+}
+
+// Cancel removes any scheduled activation or expiry for the endpoint, without sending any update.
+func (s *Scheduler) Cancel(endpointID string) {
+	s.scheduledMu.Lock()
+	defer s.scheduledMu.Unlock()
+
+	s.cancelLocked(endpointID)
+}
+
+// Stop cancels all scheduled activations and expiries, and drops any update which is not yet sent.
+func (s *Scheduler) Stop() {
+	s.scheduledMu.Lock()
+	defer s.scheduledMu.Unlock()
+
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+
+	for endpointID := range s.scheduled {
+		s.cancelLocked(endpointID)
+	}
+	for _, update := range s.pending {
+		tracing.Updates.Take(update)
+	}
+	s.pending = nil
+}
+
+// cancelLocked stops the timers of the endpoint and drops its pending updates. The caller must hold scheduledMu.
+//
+// If an update of the endpoint is being sent, it waits until it is sent, so that it is always ordered before
+// any update queued after rescheduling or cancelling the same endpoint.
+func (s *Scheduler) cancelLocked(endpointID string) {
+	for s.sending == endpointID {
+		s.sent.Wait()
+	}
+
+	s.pending = slices.DeleteFunc(s.pending, func(update *proto.AuthDataUpdate) bool {
+		if update.EndpointId != endpointID {
+			return false
+		}
+		tracing.Updates.Take(update)
+		return true
+	})
+
+	entry, ok := s.scheduled[endpointID]
+	if !ok {
+		return
+	}
+	for _, timer := range entry.timers {
+		timer.Stop()
+	}
+	delete(s.scheduled, endpointID)
+}
+
+// afterFunc queues the update to be sent after the given duration, unless the entry has since been
+// replaced or cancelled. The update is sent without holding scheduledMu, so that a stalled consumer
+// of the updates channel never blocks the scheduling of other endpoints.
+func (s *Scheduler) afterFunc(entry *scheduledEndpoint, d time.Duration, update *proto.AuthDataUpdate) *time.Timer {
+	return time.AfterFunc(d, func() {
+		s.scheduledMu.Lock()
+		if s.scheduled[update.EndpointId] != entry {
+			s.scheduledMu.Unlock()
+			return
+		}
+
+		if update.Delete {
+			// Expiry is always the last scheduled event for an endpoint.
+			delete(s.scheduled, update.EndpointId)
+			s.logger.Info().Str("endpoint_id", update.EndpointId).Msg("gateway endpoint expired")
+		} else {
+			s.logger.Info().Str("endpoint_id", update.EndpointId).Msg("gateway endpoint activated")
+		}
+		s.pending = append(s.pending, update)
+		s.scheduledMu.Unlock()
+
+		s.sendPending()
+	})
+}
+
+// sendPending sends the pending updates, in order, until none is left or the Scheduler is stopped.
+func (s *Scheduler) sendPending() {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	for {
+		s.scheduledMu.Lock()
+		if len(s.pending) == 0 {
+			s.scheduledMu.Unlock()
+			return
+		}
+		update := s.pending[0]
+		s.pending = s.pending[1:]
+		s.sending = update.EndpointId
+		s.scheduledMu.Unlock()
+
+		select {
+		case s.updatesCh <- update:
+		case <-s.done:
+			tracing.Updates.Take(update)
+		}
+
+		s.scheduledMu.Lock()
+		s.sending = ""
+		s.sent.Broadcast()
+		s.scheduledMu.Unlock()
+	}
+}
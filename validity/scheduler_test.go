@@ -0,0 +1,251 @@
+package validity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Window_IsActive(t *testing.T) {
+	now := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		window   Window
+		expected bool
+	}{
+		{
+			name:     "should be active with no window",
+			window:   Window{},
+			expected: true,
+		},
+		{
+			name:     "should be inactive before starts_at",
+			window:   Window{StartsAt: now.Add(time.Hour)},
+			expected: false,
+		},
+		{
+			name:     "should be active at starts_at",
+			window:   Window{StartsAt: now},
+			expected: true,
+		},
+		{
+			name:     "should be inactive at expires_at",
+			window:   Window{ExpiresAt: now},
+			expected: false,
+		},
+		{
+			name:     "should be active within window",
+			window:   Window{StartsAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, test.window.IsActive(now))
+		})
+	}
+}
+
+func Test_Window_Validate(t *testing.T) {
+	now := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, Window{}.Validate())
+	require.NoError(t, Window{StartsAt: now, ExpiresAt: now.Add(time.Hour)}.Validate())
+	require.Error(t, Window{StartsAt: now, ExpiresAt: now}.Validate())
+	require.Error(t, Window{StartsAt: now, ExpiresAt: now.Add(-time.Hour)}.Validate())
+}
+
+func Test_Scheduler_Schedule(t *testing.T) {
+	endpoint := &proto.GatewayEndpoint{EndpointId: "endpoint_1_trial"}
+
+	tests := []struct {
+		name            string
+		window          func(now time.Time) Window
+		expectedActive  bool
+		expectedUpdates []*proto.AuthDataUpdate
+	}{
+		{
+			name:           "should not schedule updates for endpoint without window",
+			window:         func(now time.Time) Window { return Window{} },
+			expectedActive: true,
+		},
+		{
+			name: "should send create update at activation and delete update at expiry",
+			window: func(now time.Time) Window {
+				return Window{StartsAt: now.Add(50 * time.Millisecond), ExpiresAt: now.Add(100 * time.Millisecond)}
+			},
+			expectedActive: false,
+			expectedUpdates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_1_trial", GatewayEndpoint: endpoint},
+				{EndpointId: "endpoint_1_trial", Delete: true},
+			},
+		},
+		{
+			name: "should send only delete update for active endpoint that expires",
+			window: func(now time.Time) Window {
+				return Window{StartsAt: now.Add(-time.Hour), ExpiresAt: now.Add(50 * time.Millisecond)}
+			},
+			expectedActive: true,
+			expectedUpdates: []*proto.AuthDataUpdate{
+				{EndpointId: "endpoint_1_trial", Delete: true},
+			},
+		},
+		{
+			name: "should not schedule updates for already expired endpoint",
+			window: func(now time.Time) Window {
+				return Window{ExpiresAt: now.Add(-time.Hour)}
+			},
+			expectedActive: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			updatesCh := make(chan *proto.AuthDataUpdate, 10)
+			scheduler := NewScheduler(updatesCh, polyzero.NewLogger())
+			defer scheduler.Stop()
+
+			active := scheduler.Schedule(endpoint, test.window(time.Now()))
+			c.Equal(test.expectedActive, active)
+
+			for _, expectedUpdate := range test.expectedUpdates {
+				select {
+				case update := <-updatesCh:
+					c.Equal(expectedUpdate, update)
+				case <-time.After(time.Second):
+					t.Fatal("expected update not received")
+				}
+			}
+
+			select {
+			case update := <-updatesCh:
+				t.Fatalf("unexpected update received: %v", update)
+			case <-time.After(150 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func Test_Scheduler_Update(t *testing.T) {
+	c := require.New(t)
+
+	updatesCh := make(chan *proto.AuthDataUpdate, 10)
+	scheduler := NewScheduler(updatesCh, polyzero.NewLogger())
+	defer scheduler.Stop()
+
+	endpoint := &proto.GatewayEndpoint{EndpointId: "endpoint_1_trial"}
+
+	// An active endpoint is sent as a create, and an inactive one as a delete.
+	scheduler.Update(context.Background(), endpoint, Window{})
+	c.Equal(&proto.AuthDataUpdate{EndpointId: "endpoint_1_trial", GatewayEndpoint: endpoint}, <-updatesCh)
+	scheduler.Update(context.Background(), endpoint, Window{StartsAt: time.Now().Add(time.Hour)})
+	c.Equal(&proto.AuthDataUpdate{EndpointId: "endpoint_1_trial", Delete: true}, <-updatesCh)
+
+	// Deleting the endpoint cancels its activation.
+	scheduler.Delete(context.Background(), "endpoint_1_trial")
+	c.Equal(&proto.AuthDataUpdate{EndpointId: "endpoint_1_trial", Delete: true}, <-updatesCh)
+	c.Empty(updatesCh)
+}
+
+func Test_Scheduler_Update_timerDue(t *testing.T) {
+	endpoint := &proto.GatewayEndpoint{EndpointId: "endpoint_1_trial"}
+
+	// The expiry is due as soon as the endpoint is updated, so its timer fires while the update is sent.
+	// The expiry must always be sent after the update, so that the expired endpoint is not served.
+	for range 100 {
+		updatesCh := make(chan *proto.AuthDataUpdate, 10)
+		scheduler := NewScheduler(updatesCh, polyzero.NewLogger())
+
+		expiresAt := time.Now()
+		scheduler.now = func() time.Time { return expiresAt.Add(-time.Nanosecond) }
+		scheduler.Update(context.Background(), endpoint, Window{ExpiresAt: expiresAt})
+
+		require.Equal(t, &proto.AuthDataUpdate{EndpointId: "endpoint_1_trial", GatewayEndpoint: endpoint}, <-updatesCh)
+		select {
+		case update := <-updatesCh:
+			require.Equal(t, &proto.AuthDataUpdate{EndpointId: "endpoint_1_trial", Delete: true}, update)
+		case <-time.After(time.Second):
+			t.Fatal("expiry not received")
+		}
+
+		scheduler.Stop()
+	}
+}
+
+func Test_Scheduler_Cancel(t *testing.T) {
+	c := require.New(t)
+
+	updatesCh := make(chan *proto.AuthDataUpdate, 10)
+	scheduler := NewScheduler(updatesCh, polyzero.NewLogger())
+
+	endpoint := &proto.GatewayEndpoint{EndpointId: "endpoint_1_trial"}
+	c.False(scheduler.Schedule(endpoint, Window{StartsAt: time.Now().Add(50 * time.Millisecond)}))
+
+	// Rescheduling the endpoint without a window replaces the pending activation.
+	c.True(scheduler.Schedule(endpoint, Window{}))
+
+	endpoint2 := &proto.GatewayEndpoint{EndpointId: "endpoint_2_trial"}
+	c.True(scheduler.Schedule(endpoint2, Window{ExpiresAt: time.Now().Add(50 * time.Millisecond)}))
+	scheduler.Cancel("endpoint_2_trial")
+
+	select {
+	case update := <-updatesCh:
+		t.Fatalf("unexpected update received: %v", update)
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func Test_Scheduler_stalledConsumer(t *testing.T) {
+	c := require.New(t)
+
+	// The updates channel is not read until the end of the test, as if its consumer stalled.
+	updatesCh := make(chan *proto.AuthDataUpdate)
+	scheduler := NewScheduler(updatesCh, polyzero.NewLogger())
+
+	endpoint1 := &proto.GatewayEndpoint{EndpointId: "endpoint_1_trial"}
+	endpoint2 := &proto.GatewayEndpoint{EndpointId: "endpoint_2_trial"}
+	c.False(scheduler.Schedule(endpoint1, Window{StartsAt: time.Now().Add(10 * time.Millisecond)}))
+	c.False(scheduler.Schedule(endpoint2, Window{StartsAt: time.Now().Add(20 * time.Millisecond)}))
+
+	// Wait until both activations are due: the first is being sent, and the second is pending behind it.
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// Scheduling other endpoints, and cancelling a pending update, do not wait for the consumer.
+		c.True(scheduler.Schedule(&proto.GatewayEndpoint{EndpointId: "endpoint_3_trial"}, Window{}))
+		scheduler.Cancel("endpoint_2_trial")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler blocked by the stalled consumer")
+	}
+
+	// The update being sent is received, but the cancelled pending update is dropped.
+	select {
+	case update := <-updatesCh:
+		c.Equal(&proto.AuthDataUpdate{EndpointId: "endpoint_1_trial", GatewayEndpoint: endpoint1}, update)
+	case <-time.After(time.Second):
+		t.Fatal("expected update not received")
+	}
+
+	select {
+	case update := <-updatesCh:
+		t.Fatalf("unexpected update received: %v", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	scheduler.Stop()
+}
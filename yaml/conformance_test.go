@@ -0,0 +1,53 @@
+package yaml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+)
+
+func Test_Conformance(t *testing.T) {
+	var (
+		filename         string
+		gatewayEndpoints map[string]*proto.GatewayEndpoint
+	)
+
+	encode := func(t *testing.T) []byte {
+		data, err := EncodeGatewayEndpoints(&proto.AuthDataResponse{Endpoints: gatewayEndpoints}, nil)
+		require.NoError(t, err)
+		return data
+	}
+	// writeFile atomically replaces the YAML file with the current GatewayEndpoints, as an editor or deployment would.
+	writeFile := func(t *testing.T) {
+		require.NoError(t, atomicWriteFile(filename, encode(t)))
+	}
+
+	grpc_server.RunConformanceTests(t, grpc_server.ConformanceHarness{
+		NewDataSource: func(t *testing.T, initial []*proto.GatewayEndpoint) grpc_server.AuthDataSource {
+			filename = filepath.Join(t.TempDir(), "gateway-endpoints.yaml")
+			gatewayEndpoints = make(map[string]*proto.GatewayEndpoint)
+			for _, gatewayEndpoint := range initial {
+				gatewayEndpoints[gatewayEndpoint.GetEndpointId()] = gatewayEndpoint
+			}
+			require.NoError(t, os.WriteFile(filename, encode(t), 0600))
+
+			dataSource, err := NewYAMLDataSource(filename, polyzero.NewLogger())
+			require.NoError(t, err)
+			return dataSource
+		},
+		Put: func(t *testing.T, gatewayEndpoint *proto.GatewayEndpoint) {
+			gatewayEndpoints[gatewayEndpoint.GetEndpointId()] = gatewayEndpoint
+			writeFile(t)
+		},
+		Delete: func(t *testing.T, endpointID string) {
+			delete(gatewayEndpoints, endpointID)
+			writeFile(t)
+		},
+	})
+}
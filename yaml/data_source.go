@@ -5,24 +5,40 @@ It loads YAML data from a file which must match the format defined in gateway-en
 See an example here: yaml/testdata/gateway-endpoints.example.yaml.
 
 This package also uses a file watcher to detect changes to the YAML file and sends updates
-to the authDataUpdatesCh channel if any changes are detected.
+to the authDataUpdatesCh channel if any changes are detected. The updates are applied to
+the served GatewayEndpoints by a grpc.UpdateFeed, which subscribers read from.
+
+Endpoints with a starts_at or expires_at time are only served within their validity window;
+their activation and expiry updates are scheduled using the validity package.
 */
 package yaml
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"sync"
 
 	"github.com/buildwithgrove/path-external-auth-server/proto"
 	"github.com/fsnotify/fsnotify"
 	"github.com/pokt-network/poktroll/pkg/polylog"
-	"gopkg.in/yaml.v3"
 
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
 	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/redact"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
 )
 
-// yamlDataSource implements the AuthDataSource interface
-var _ grpc_server.AuthDataSource = &yamlDataSource{}
+// yamlDataSource implements the AuthDataSource and AuthDataExporter interfaces
+var (
+	_ grpc_server.AuthDataSource   = &yamlDataSource{}
+	_ grpc_server.AuthDataExporter = &yamlDataSource{}
+)
 
 /* --------------------------- yamlDataSource Struct ---------------------------- */
 
@@ -37,88 +53,168 @@ type yamlDataSource struct {
 	gatewayEndpointsMu sync.Mutex
 
 	authDataUpdatesCh chan *proto.AuthDataUpdate
+	// updateFeed serves the active GatewayEndpoints with every update from authDataUpdatesCh applied,
+	// and drops the updates sent for unchanged endpoints, as every endpoint in the YAML file
+	// is sent as an update whenever the file changes.
+	updateFeed *grpc_server.UpdateFeed
+
+	// scheduler sends the updates of the endpoints to authDataUpdatesCh once the YAML file is loaded,
+	// ordered with the updates it sends when time-bounded endpoints activate or expire.
+	scheduler *validity.Scheduler
+
+	// writeMu serializes writes to the YAML file from WriteGatewayEndpoints.
+	writeMu sync.Mutex
 
 	logger polylog.Logger
 }
 
 // NewYAMLDataSource creates a new yamlDataSource for the specified filename.
+// The YAML file must not contain hashed API keys, as PEAS does not yet verify them (see the apikey package).
+//
+// The activation and expiry of all time-bounded endpoints are scheduled when the YAML file
+// is loaded, so that scheduled updates are recomputed from the YAML file after a restart.
 func NewYAMLDataSource(filename string, logger polylog.Logger) (*yamlDataSource, error) {
 
+	authDataUpdatesCh := make(chan *proto.AuthDataUpdate, 100_000)
+
 	dataSource := &yamlDataSource{
 		filename:          filename,
-		authDataUpdatesCh: make(chan *proto.AuthDataUpdate, 100_000),
+		authDataUpdatesCh: authDataUpdatesCh,
+		scheduler:         validity.NewScheduler(authDataUpdatesCh, logger),
 		logger:            logger,
 	}
 
+	// Watch the YAML file for changes. The watch is added before the file is loaded, so that
+	// no change made to the file after it is loaded is missed: a change made in between is
+	// both loaded and sent as an update, which the update feed drops as it changes nothing.
+	watcher, err := dataSource.newFileWatcher()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to watch YAML file")
+	}
+
 	// Warm up the data store with the full set of GatewayEndpoints from the YAML file.
-	gatewayEndpoints, err := dataSource.loadGatewayEndpointsFromYAML()
+	gatewayEndpoints, windows, err := dataSource.loadGatewayEndpointsFromYAML()
 	if err != nil {
+		if watcher != nil {
+			watcher.Close()
+		}
 		return nil, err
 	}
 	dataSource.gatewayEndpoints = gatewayEndpoints.Endpoints
 
-	// Watch the YAML file for changes.
-	go dataSource.watchFile()
+	activeEndpoints := make(map[string]*proto.GatewayEndpoint, len(gatewayEndpoints.Endpoints))
+	for endpointID, endpoint := range gatewayEndpoints.Endpoints {
+		if dataSource.scheduler.Schedule(endpoint, windows[endpointID]) {
+			activeEndpoints[endpointID] = endpoint
+		}
+	}
+	dataSource.updateFeed = grpc_server.NewUpdateFeed(activeEndpoints, authDataUpdatesCh)
+
+	if watcher != nil {
+		go dataSource.watchFile(watcher)
+	}
 
 	return dataSource, nil
 }
 
-// FetchAuthDataSync loads the full set of GatewayEndpoints from the YAML file.
+// FetchAuthDataSync returns the full set of currently active GatewayEndpoints in the YAML file.
 func (y *yamlDataSource) FetchAuthDataSync() (*proto.AuthDataResponse, error) {
+	return &proto.AuthDataResponse{Endpoints: y.updateFeed.Snapshot()}, nil
+}
+
+// ExportAuthData returns every GatewayEndpoint in the YAML file, along with the validity windows
+// of all time-bounded GatewayEndpoints, as it is currently stored on disk.
+func (y *yamlDataSource) ExportAuthData() (*proto.AuthDataResponse, map[string]validity.Window, error) {
 	return y.loadGatewayEndpointsFromYAML()
 }
 
-// AuthDataUpdatesChan returns a channel that streams updates when the YAML file changes.
-func (y *yamlDataSource) AuthDataUpdatesChan() (<-chan *proto.AuthDataUpdate, error) {
-	return y.authDataUpdatesCh, nil
+// SubscribeAuthData returns the full set of currently active GatewayEndpoints in the YAML file,
+// along with a channel that streams updates when the YAML file changes from then on.
+// Updates which would not change the served GatewayEndpoints are dropped.
+func (y *yamlDataSource) SubscribeAuthData() (*proto.AuthDataResponse, <-chan *proto.AuthDataUpdate, error) {
+	gatewayEndpoints, updatesCh, err := y.updateFeed.Subscribe()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &proto.AuthDataResponse{Endpoints: gatewayEndpoints}, updatesCh, nil
 }
 
 // loadGatewayEndpointsFromYAML reads and parses the YAML file into proto format.
-func (y *yamlDataSource) loadGatewayEndpointsFromYAML() (*proto.AuthDataResponse, error) {
+// It also returns the validity windows of all time-bounded endpoints in the file.
+func (y *yamlDataSource) loadGatewayEndpointsFromYAML() (*proto.AuthDataResponse, map[string]validity.Window, error) {
 	data, err := os.ReadFile(y.filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var endpointsYAML gatewayEndpointsYAML
-	if err := yaml.Unmarshal(data, &endpointsYAML); err != nil {
-		return nil, err
+	authData, windows, err := DecodeGatewayEndpoints(data)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := endpointsYAML.validate(); err != nil {
-		return nil, err
+	for _, endpointID := range slices.Sorted(maps.Keys(authData.Endpoints)) {
+		if err := y.checkAPIKey(endpointID, authData.Endpoints[endpointID]); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return endpointsYAML.convertToProto(), nil
+	return authData, windows, nil
 }
 
-// watchFile monitors the YAML file for changes and triggers updates.
-func (y *yamlDataSource) watchFile() {
+// checkAPIKey returns an error if the GatewayEndpoint has a hashed API key, which PEAS cannot verify.
+func (y *yamlDataSource) checkAPIKey(endpointID string, gatewayEndpoint *proto.GatewayEndpoint) error {
+	if err := apikey.Check(gatewayEndpoint.GetAuth().GetStaticApiKey().GetApiKey()); err != nil {
+		return fmt.Errorf("endpoint %s: %w", endpointID, err)
+	}
+	return nil
+}
+
+// yamlErrorValueRegex matches the values quoted in backticks by YAML decoding errors,
+// eg. "line 5: cannot unmarshal !!str `api_key_1` into int".
+var yamlErrorValueRegex = regexp.MustCompile("`[^`]*`")
+
+// redactYAMLError masks the values included in YAML decoding errors,
+// which may contain API keys or emails from the YAML file.
+func redactYAMLError(err error) error {
+	return errors.New(yamlErrorValueRegex.ReplaceAllString(err.Error(), "`"+redact.Redacted+"`"))
+}
+
+// newFileWatcher returns a file watcher for the YAML file.
+//
+// The file's directory is watched rather than the file itself, so that changes are still
+// detected after the file is atomically replaced, eg. by WriteGatewayEndpoints or an editor.
+func (y *yamlDataSource) newFileWatcher() (*fsnotify.Watcher, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		y.logger.Error().Err(err).Msg("failed to create file watcher")
-		return
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
-	defer watcher.Close()
 
-	err = watcher.Add(y.filename)
-	if err != nil {
-		y.logger.Error().Err(err).Msg("failed to add file to watcher")
-		return
+	if err := watcher.Add(filepath.Dir(y.filename)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to add file to watcher: %w", err)
 	}
 
+	return watcher, nil
+}
+
+// watchFile monitors the YAML file for changes and triggers updates.
+func (y *yamlDataSource) watchFile(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
 	for {
 		select {
 		case event := <-watcher.Events:
-			// Check if the write operation flag is set in the event
-			isWriteEvent := event.Op & fsnotify.Write
-			if isWriteEvent == fsnotify.Write {
-				newData, err := y.loadGatewayEndpointsFromYAML()
+			if filepath.Clean(event.Name) != filepath.Clean(y.filename) {
+				continue
+			}
+			// Check if the file was written to, or created by being moved into place
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+				newData, newWindows, err := y.loadGatewayEndpointsFromYAML()
 				if err != nil {
 					y.logger.Error().Err(err).Msg("error loading new data from updated YAML file")
 					continue
 				}
-				y.handleUpdates(newData.Endpoints)
+				y.handleUpdates(newData.Endpoints, newWindows)
 			}
 
 		case err := <-watcher.Errors:
@@ -127,8 +223,10 @@ func (y *yamlDataSource) watchFile() {
 	}
 }
 
-// handleUpdates compares old and new data and sends appropriate updates.
-func (y *yamlDataSource) handleUpdates(newEndpoints map[string]*proto.GatewayEndpoint) {
+// handleUpdates compares old and new data and sends appropriate updates through the scheduler.
+// Endpoints outside of their validity window are sent as deletes and are
+// created by the scheduler once they activate.
+func (y *yamlDataSource) handleUpdates(newEndpoints map[string]*proto.GatewayEndpoint, newWindows map[string]validity.Window) {
 	y.gatewayEndpointsMu.Lock()
 	defer y.gatewayEndpointsMu.Unlock()
 
@@ -142,21 +240,13 @@ func (y *yamlDataSource) handleUpdates(newEndpoints map[string]*proto.GatewayEnd
 	// Send updates for new or modified endpoints.
 	// The onus of determining if an endpoint is new is on the receiver.
 	for id, newEndpoint := range newEndpoints {
-		update := &proto.AuthDataUpdate{
-			EndpointId:      id,
-			GatewayEndpoint: newEndpoint,
-		}
-		y.authDataUpdatesCh <- update
+		y.scheduler.Update(context.Background(), newEndpoint, newWindows[id])
 	}
 
 	// Send delete updates for removed endpoints
 	for id := range oldGatewayEndpoints {
 		if _, exists := newEndpoints[id]; !exists {
-			update := &proto.AuthDataUpdate{
-				EndpointId: id,
-				Delete:     true,
-			}
-			y.authDataUpdatesCh <- update
+			y.scheduler.Delete(context.Background(), id)
 		}
 	}
 }
@@ -1,14 +1,23 @@
 package yaml
 
 import (
+	"bytes"
+	"maps"
 	"os"
+	"slices"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/buildwithgrove/path-external-auth-server/proto"
 	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
 	"github.com/stretchr/testify/require"
+	protobuf "google.golang.org/protobuf/proto"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/redact"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
 )
 
 func Test_LoadGatewayEndpointsFromYAML(t *testing.T) {
@@ -54,6 +63,43 @@ func Test_LoadGatewayEndpointsFromYAML(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:     "should only load endpoints within their validity window",
+			filePath: "./testdata/time_bounded.yaml",
+			fileContents: `
+endpoints:
+  endpoint_1_active:
+    starts_at: "2000-01-01T00:00:00Z"
+    expires_at: "2999-01-01T00:00:00Z"
+  endpoint_2_pending:
+    starts_at: "2999-01-01T00:00:00Z"
+  endpoint_3_expired:
+    expires_at: "2000-01-01T00:00:00Z"
+`,
+			want: &proto.AuthDataResponse{
+				Endpoints: map[string]*proto.GatewayEndpoint{
+					"endpoint_1_active": {
+						EndpointId: "endpoint_1_active",
+						Auth: &proto.Auth{
+							AuthType: &proto.Auth_NoAuth{},
+						},
+						Metadata: &proto.Metadata{},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "should return error for expires_at before starts_at",
+			filePath: "./testdata/invalid_time_bounded.yaml",
+			fileContents: `
+endpoints:
+  endpoint_1_trial:
+    starts_at: "2024-02-01T00:00:00Z"
+    expires_at: "2024-01-01T00:00:00Z"
+`,
+			wantErr: true,
+		},
 		{
 			name:     "should return error for non-existent file",
 			filePath: "./testdata/non_existent.yaml",
@@ -104,6 +150,35 @@ endpoints:
 	}
 }
 
+func Test_ExportAuthData(t *testing.T) {
+	c := require.New(t)
+
+	filePath := "./testdata/temp_export_gateway_endpoints.yaml"
+	c.NoError(os.WriteFile(filePath, []byte(`
+endpoints:
+  endpoint_1_active:
+    expires_at: "2999-01-01T00:00:00Z"
+  endpoint_2_pending:
+    starts_at: "2999-01-01T00:00:00Z"
+  endpoint_3_expired:
+    expires_at: "2000-01-01T00:00:00Z"
+`), 0644))
+	defer os.Remove(filePath)
+
+	yamlDataSource, err := NewYAMLDataSource(filePath, polyzero.NewLogger())
+	c.NoError(err)
+
+	// Endpoints outside of their validity window are exported along with their windows, although they are not served.
+	authData, windows, err := yamlDataSource.ExportAuthData()
+	c.NoError(err)
+	c.ElementsMatch([]string{"endpoint_1_active", "endpoint_2_pending", "endpoint_3_expired"}, slices.Collect(maps.Keys(authData.Endpoints)))
+	c.Equal(map[string]validity.Window{
+		"endpoint_1_active":  {ExpiresAt: time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"endpoint_2_pending": {StartsAt: time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"endpoint_3_expired": {ExpiresAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, windows)
+}
+
 func Test_watchFile(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -190,7 +265,9 @@ endpoints:
 			yamlDataSource, err := NewYAMLDataSource(filePath, polyzero.NewLogger())
 			c.NoError(err)
 
-			go yamlDataSource.watchFile()
+			// The updates are received from a subscription, as the update feed consumes authDataUpdatesCh.
+			_, updatesCh, err := yamlDataSource.SubscribeAuthData()
+			c.NoError(err)
 
 			// small delay to ensure the file system processes the write
 			<-time.After(500 * time.Millisecond)
@@ -203,7 +280,7 @@ endpoints:
 
 			for range test.expectedUpdates {
 				select {
-				case update := <-yamlDataSource.authDataUpdatesCh:
+				case update := <-updatesCh:
 					receivedUpdates = append(receivedUpdates, update)
 				case <-timeout:
 					t.Fatal("expected update not received")
@@ -219,7 +296,12 @@ endpoints:
 				receivedUpdatesMap[receivedUpdate.EndpointId] = receivedUpdate
 			}
 
-			c.EqualValues(expectedUpdatesMap, receivedUpdatesMap)
+			// The updates are compared with protobuf.Equal, as the update feed's comparison of each updated
+			// GatewayEndpoint with the stored one initializes its internal message state.
+			c.Len(receivedUpdatesMap, len(expectedUpdatesMap))
+			for endpointID, expectedUpdate := range expectedUpdatesMap {
+				c.True(protobuf.Equal(expectedUpdate, receivedUpdatesMap[endpointID]), "endpoint %s: expected %v, got %v", endpointID, expectedUpdate, receivedUpdatesMap[endpointID])
+			}
 		})
 	}
 }
@@ -229,6 +311,7 @@ func Test_handleUpdates(t *testing.T) {
 		name             string
 		gatewayEndpoints map[string]*proto.GatewayEndpoint
 		newEndpoints     map[string]*proto.GatewayEndpoint
+		newWindows       map[string]validity.Window
 		expectedUpdates  []*proto.AuthDataUpdate
 	}{
 		{
@@ -326,18 +409,55 @@ func Test_handleUpdates(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "should send delete updates for endpoints outside of their validity window",
+			gatewayEndpoints: map[string]*proto.GatewayEndpoint{
+				"endpoint_1_trial": {
+					EndpointId: "endpoint_1_trial",
+				},
+			},
+			newEndpoints: map[string]*proto.GatewayEndpoint{
+				"endpoint_1_trial": {
+					EndpointId: "endpoint_1_trial",
+				},
+				"endpoint_2_pending": {
+					EndpointId: "endpoint_2_pending",
+				},
+			},
+			newWindows: map[string]validity.Window{
+				"endpoint_1_trial": {
+					ExpiresAt: time.Now().Add(-time.Hour),
+				},
+				"endpoint_2_pending": {
+					StartsAt: time.Now().Add(time.Hour),
+				},
+			},
+			expectedUpdates: []*proto.AuthDataUpdate{
+				{
+					EndpointId: "endpoint_1_trial",
+					Delete:     true,
+				},
+				{
+					EndpointId: "endpoint_2_pending",
+					Delete:     true,
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			c := require.New(t)
 
+			authDataUpdatesCh := make(chan *proto.AuthDataUpdate, len(test.expectedUpdates))
 			yamlDataSource := &yamlDataSource{
 				gatewayEndpoints:  test.gatewayEndpoints,
-				authDataUpdatesCh: make(chan *proto.AuthDataUpdate, len(test.expectedUpdates)),
+				authDataUpdatesCh: authDataUpdatesCh,
+				scheduler:         validity.NewScheduler(authDataUpdatesCh, polyzero.NewLogger()),
 			}
+			defer yamlDataSource.scheduler.Stop()
 
-			yamlDataSource.handleUpdates(test.newEndpoints)
+			yamlDataSource.handleUpdates(test.newEndpoints, test.newWindows)
 
 			// Sort the expected updates and received updates by EndpointId
 			sort.Slice(test.expectedUpdates, func(i, j int) bool {
@@ -363,3 +483,62 @@ func Test_handleUpdates(t *testing.T) {
 		})
 	}
 }
+
+func Test_logsRedactSecrets(t *testing.T) {
+	c := require.New(t)
+
+	// Secrets taken from the example gateway endpoints YAML file.
+	exampleSecrets := []string{"api_key_1", "amos.burton@opa.belt", "frodo.baggins@shire.io"}
+
+	exampleData, err := os.ReadFile("./testdata/gateway-endpoints.example.yaml")
+	c.NoError(err)
+
+	filePath := "./testdata/temp_redacted_gateway_endpoints.yaml"
+	c.NoError(os.WriteFile(filePath, exampleData, 0644))
+	defer os.Remove(filePath)
+
+	logs := &syncBuffer{}
+	logger := redact.NewLogger(polyzero.NewLogger(polyzero.WithOutput(logs)))
+
+	yamlDataSource, err := NewYAMLDataSource(filePath, logger)
+	c.NoError(err)
+
+	_, err = grpc_server.NewGRPCServer(yamlDataSource, grpc_server.StreamConfig{}, logger)
+	c.NoError(err)
+
+	// small delay to ensure the file watcher is running
+	<-time.After(500 * time.Millisecond)
+
+	// Write a file that fails to decode with an error that includes a secret value.
+	c.NoError(os.WriteFile(filePath, []byte("endpoints:\n  endpoint_1_static_key: \"api_key_1\"\n"), 0644))
+	<-time.After(500 * time.Millisecond)
+
+	// Write the example file back with a rotated API key to trigger an update.
+	// Unchanged endpoints are not sent, so at least one endpoint must change.
+	c.NoError(os.WriteFile(filePath, bytes.Replace(exampleData, []byte("api_key_1"), []byte("api_key_1_rotated"), 1), 0644))
+	<-time.After(500 * time.Millisecond)
+
+	c.Contains(logs.String(), "error loading new data from updated YAML file")
+	c.Contains(logs.String(), "gateway endpoint details")
+	for _, secret := range exampleSecrets {
+		c.NotContains(logs.String(), secret)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe to write to from multiple goroutines.
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,45 @@
+package yaml
+
+import (
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"gopkg.in/yaml.v3"
+
+	"github.com/buildwithgrove/path-auth-data-server/validity"
+)
+
+// DecodeGatewayEndpoints decodes and validates a set of GatewayEndpoints in the YAML file format,
+// returning them along with the validity windows of all time-bounded endpoints.
+func DecodeGatewayEndpoints(data []byte) (*proto.AuthDataResponse, map[string]validity.Window, error) {
+	var endpointsYAML gatewayEndpointsYAML
+	if err := yaml.Unmarshal(data, &endpointsYAML); err != nil {
+		return nil, nil, redactYAMLError(err)
+	}
+
+	if err := endpointsYAML.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return endpointsYAML.convertToProto(), endpointsYAML.validityWindows(), nil
+}
+
+// DecodeGatewayEndpoint decodes and validates a single GatewayEndpoint in the YAML file format,
+// ie. the value of one of the entries under `endpoints`, returning it along with its validity window.
+//
+// The GatewayEndpoint is validated exactly as it is when loaded from a YAML file, so that
+// GatewayEndpoints written to any data source, eg. from the admin API, follow the same rules.
+// As JSON is valid YAML, the data may also be JSON.
+func DecodeGatewayEndpoint(endpointID string, data []byte) (*proto.GatewayEndpoint, validity.Window, error) {
+	var endpointYAML gatewayEndpointYAML
+	if err := yaml.Unmarshal(data, &endpointYAML); err != nil {
+		return nil, validity.Window{}, redactYAMLError(err)
+	}
+
+	if err := endpointYAML.validate(endpointID); err != nil {
+		return nil, validity.Window{}, err
+	}
+
+	// The validity window has already been successfully parsed by validate.
+	window, _ := endpointYAML.validityWindow()
+
+	return endpointYAML.convertToProto(endpointID), window, nil
+}
@@ -0,0 +1,62 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"gopkg.in/yaml.v3"
+
+	"github.com/buildwithgrove/path-auth-data-server/validity"
+)
+
+// EncodeGatewayEndpoints encodes a set of GatewayEndpoints and the validity windows of any time-bounded
+// endpoints in the YAML file format. It is the inverse of DecodeGatewayEndpoints, so the encoded
+// GatewayEndpoints may be loaded by a YAML data source exactly as they were encoded.
+//
+// Endpoints are encoded in order of endpoint ID, so that encoding the same endpoints always returns the same file.
+func EncodeGatewayEndpoints(authData *proto.AuthDataResponse, windows map[string]validity.Window) ([]byte, error) {
+	endpointsYAML := gatewayEndpointsYAML{
+		Endpoints: make(map[string]gatewayEndpointYAML, len(authData.GetEndpoints())),
+	}
+	for endpointID, gatewayEndpoint := range authData.GetEndpoints() {
+		endpointsYAML.Endpoints[endpointID] = gatewayEndpointYAMLFromProto(gatewayEndpoint, windows[endpointID])
+	}
+
+	// Mappings are encoded with their keys sorted.
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(yamlIndent)
+	if err := encoder.Encode(endpointsYAML); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EncodeGatewayEndpointsJSON encodes a set of GatewayEndpoints and the validity windows of any time-bounded
+// endpoints in the JSON equivalent of the YAML file format. As JSON is valid YAML, the encoded
+// GatewayEndpoints may also be decoded by DecodeGatewayEndpoints.
+func EncodeGatewayEndpointsJSON(authData *proto.AuthDataResponse, windows map[string]validity.Window) ([]byte, error) {
+	data, err := EncodeGatewayEndpoints(authData, windows)
+	if err != nil {
+		return nil, err
+	}
+
+	// The JSON is converted from the encoded YAML so that both formats omit exactly the same empty fields.
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode encoded YAML: %w", err)
+	}
+
+	jsonData, err := json.MarshalIndent(jsonCompatible(document), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	return append(jsonData, '\n'), nil
+}
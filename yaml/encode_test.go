@@ -0,0 +1,138 @@
+package yaml
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildwithgrove/path-auth-data-server/validity"
+)
+
+func Test_EncodeGatewayEndpoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		authData     *proto.AuthDataResponse
+		windows      map[string]validity.Window
+		expectedYAML string
+		expectedJSON string
+	}{
+		{
+			name: "should encode gateway endpoints in order of endpoint ID",
+			authData: &proto.AuthDataResponse{
+				Endpoints: map[string]*proto.GatewayEndpoint{
+					"endpoint_2_no_auth": {
+						EndpointId: "endpoint_2_no_auth",
+						Auth:       &proto.Auth{AuthType: &proto.Auth_NoAuth{}},
+						Metadata:   &proto.Metadata{AccountId: "account_2", PlanType: "PLAN_FREE"},
+					},
+					"endpoint_1_static_key": {
+						EndpointId: "endpoint_1_static_key",
+						Auth: &proto.Auth{
+							AuthType: &proto.Auth_StaticApiKey{StaticApiKey: &proto.StaticAPIKey{ApiKey: "api_key_1"}},
+						},
+						Metadata: &proto.Metadata{Name: "endpoint_1", AccountId: "account_1"},
+					},
+				},
+			},
+			windows: map[string]validity.Window{
+				"endpoint_2_no_auth": {ExpiresAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+			expectedYAML: `endpoints:
+  endpoint_1_static_key:
+    auth:
+      api_key: api_key_1
+    metadata:
+      name: endpoint_1
+      account_id: account_1
+  endpoint_2_no_auth:
+    metadata:
+      account_id: account_2
+      plan_type: PLAN_FREE
+    expires_at: "2025-01-01T00:00:00Z"
+`,
+			expectedJSON: `{
+  "endpoints": {
+    "endpoint_1_static_key": {
+      "auth": {
+        "api_key": "api_key_1"
+      },
+      "metadata": {
+        "account_id": "account_1",
+        "name": "endpoint_1"
+      }
+    },
+    "endpoint_2_no_auth": {
+      "expires_at": "2025-01-01T00:00:00Z",
+      "metadata": {
+        "account_id": "account_2",
+        "plan_type": "PLAN_FREE"
+      }
+    }
+  }
+}
+`,
+		},
+		{
+			name:         "should encode an empty set of gateway endpoints",
+			authData:     &proto.AuthDataResponse{},
+			expectedYAML: "endpoints: {}\n",
+			expectedJSON: "{\n  \"endpoints\": {}\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			encodedYAML, err := EncodeGatewayEndpoints(test.authData, test.windows)
+			c.NoError(err)
+			c.Equal(test.expectedYAML, string(encodedYAML))
+
+			encodedJSON, err := EncodeGatewayEndpointsJSON(test.authData, test.windows)
+			c.NoError(err)
+			c.Equal(test.expectedJSON, string(encodedJSON))
+		})
+	}
+}
+
+// Test_EncodeGatewayEndpoints_roundTrip ensures that decoding and re-encoding an encoded file is lossless,
+// so that exporting and re-importing gateway endpoints never changes them.
+func Test_EncodeGatewayEndpoints_roundTrip(t *testing.T) {
+	data, err := os.ReadFile("./testdata/gateway-endpoints.example.yaml")
+	require.NoError(t, err)
+
+	authData, windows, err := DecodeGatewayEndpoints(data)
+	require.NoError(t, err)
+	// Include a validity window, which the example file does not use.
+	windows["endpoint_2_no_auth"] = validity.Window{
+		StartsAt:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExpiresAt: time.Date(2025, 2, 1, 12, 30, 0, 500, time.UTC),
+	}
+
+	encoders := map[string]func(*proto.AuthDataResponse, map[string]validity.Window) ([]byte, error){
+		"yaml": EncodeGatewayEndpoints,
+		"json": EncodeGatewayEndpointsJSON,
+	}
+
+	for format, encode := range encoders {
+		t.Run(format, func(t *testing.T) {
+			c := require.New(t)
+
+			exported, err := encode(authData, windows)
+			c.NoError(err)
+			c.Empty(Validate(exported))
+
+			importedAuthData, importedWindows, err := DecodeGatewayEndpoints(exported)
+			c.NoError(err)
+			c.Equal(authData, importedAuthData)
+			c.Equal(windows, importedWindows)
+
+			reexported, err := encode(importedAuthData, importedWindows)
+			c.NoError(err)
+			c.Equal(string(exported), string(reexported))
+		})
+	}
+}
@@ -2,8 +2,12 @@ package yaml
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/buildwithgrove/path-external-auth-server/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
 )
 
 /* ----------------------------- GatewayEndpoint YAML Struct ----------------------------- */
@@ -12,22 +16,27 @@ type (
 	// gatewayEndpointYAML represents the structure of a single GatewayEndpoint in the YAML file.
 	gatewayEndpointYAML struct {
 		// The authorization configuration for a gateway endpoint. If omitted, the endpoint will not require any authorization.
-		Auth authYAML `yaml:"auth"`
+		Auth authYAML `yaml:"auth,omitempty"`
 		// Metadata is an optional map of string keys to string values for additional information about the gateway endpoint.
-		Metadata metadataYAML `yaml:"metadata"`
+		Metadata metadataYAML `yaml:"metadata,omitempty"`
+		// StartsAt is the optional RFC3339 time from which the endpoint is served. If omitted, the endpoint is served immediately.
+		StartsAt string `yaml:"starts_at,omitempty"`
+		// ExpiresAt is the optional RFC3339 time after which the endpoint is no longer served. If omitted, the endpoint never expires.
+		ExpiresAt string `yaml:"expires_at,omitempty"`
 	}
 	// authYAML represents the Auth section of a single GatewayEndpoint in the YAML file.
 	authYAML struct {
 		// APIKey is non-empty if the auth_type is AUTH_TYPE_API_KEY.
+		// It may be either a plaintext or a hashed API key (e.g. "argon2id:<params>:<salt>:<hash>"); see the apikey package.
 		APIKey *string `yaml:"api_key,omitempty"`
 	}
 	metadataYAML struct {
-		Name        string `yaml:"name"`        // The name of the GatewayEndpoint
-		AccountId   string `yaml:"account_id"`  // Unique identifier for the GatewayEndpoint's account
-		UserId      string `yaml:"user_id"`     // Identifier for a specific user within the system
-		PlanType    string `yaml:"plan_type"`   // Subscription or account plan type (e.g., "PLAN_FREE", "PLAN_UNLIMITED")
-		Email       string `yaml:"email"`       // The email address associated with the GatewayEndpoint
-		Environment string `yaml:"environment"` // The environment the GatewayEndpoint is in (e.g., "development", "staging", "production")
+		Name        string `yaml:"name,omitempty"`        // The name of the GatewayEndpoint
+		AccountId   string `yaml:"account_id,omitempty"`  // Unique identifier for the GatewayEndpoint's account
+		UserId      string `yaml:"user_id,omitempty"`     // Identifier for a specific user within the system
+		PlanType    string `yaml:"plan_type,omitempty"`   // Subscription or account plan type (e.g., "PLAN_FREE", "PLAN_UNLIMITED")
+		Email       string `yaml:"email,omitempty"`       // The email address associated with the GatewayEndpoint
+		Environment string `yaml:"environment,omitempty"` // The environment the GatewayEndpoint is in (e.g., "development", "staging", "production")
 	}
 )
 
@@ -46,6 +55,35 @@ func (e *gatewayEndpointYAML) convertToProto(endpointID string) *proto.GatewayEn
 	}
 }
 
+// gatewayEndpointYAMLFromProto converts a GatewayEndpoint and its validity window to the YAML file format.
+// It is the inverse of convertToProto and validityWindow, used when writing GatewayEndpoints to the YAML file.
+func gatewayEndpointYAMLFromProto(endpoint *proto.GatewayEndpoint, window validity.Window) gatewayEndpointYAML {
+	endpointYAML := gatewayEndpointYAML{
+		Metadata: metadataYAML{
+			Name:        endpoint.GetMetadata().GetName(),
+			AccountId:   endpoint.GetMetadata().GetAccountId(),
+			UserId:      endpoint.GetMetadata().GetUserId(),
+			PlanType:    endpoint.GetMetadata().GetPlanType(),
+			Email:       endpoint.GetMetadata().GetEmail(),
+			Environment: endpoint.GetMetadata().GetEnvironment(),
+		},
+	}
+
+	if staticAPIKey := endpoint.GetAuth().GetStaticApiKey(); staticAPIKey != nil {
+		apiKey := staticAPIKey.GetApiKey()
+		endpointYAML.Auth.APIKey = &apiKey
+	}
+
+	if !window.StartsAt.IsZero() {
+		endpointYAML.StartsAt = window.StartsAt.Format(time.RFC3339Nano)
+	}
+	if !window.ExpiresAt.IsZero() {
+		endpointYAML.ExpiresAt = window.ExpiresAt.Format(time.RFC3339Nano)
+	}
+
+	return endpointYAML
+}
+
 func (a *authYAML) convertToProto() *proto.Auth {
 	switch {
 
@@ -73,9 +111,39 @@ func (e *gatewayEndpointYAML) validate(endpointID string) error {
 	if err := e.Auth.validate(); err != nil {
 		return err
 	}
+	window, err := e.validityWindow()
+	if err != nil {
+		return err
+	}
+	if err := window.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// validityWindow parses the optional starts_at and expires_at fields of the gateway endpoint.
+func (e *gatewayEndpointYAML) validityWindow() (validity.Window, error) {
+	var window validity.Window
+
+	if e.StartsAt != "" {
+		startsAt, err := time.Parse(time.RFC3339, e.StartsAt)
+		if err != nil {
+			return validity.Window{}, fmt.Errorf("starts_at must be an RFC3339 time: %w", err)
+		}
+		window.StartsAt = startsAt
+	}
+
+	if e.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, e.ExpiresAt)
+		if err != nil {
+			return validity.Window{}, fmt.Errorf("expires_at must be an RFC3339 time: %w", err)
+		}
+		window.ExpiresAt = expiresAt
+	}
+
+	return window, nil
+}
+
 // authYAML.validate ensures that the auth section of a GatewayEndpoint is valid by
 // checking that the correct fields are set for the given auth type and are not set
 // for any other auth type.
@@ -87,6 +155,10 @@ func (a *authYAML) validate() error {
 		if a.APIKey == nil || *a.APIKey == "" {
 			return fmt.Errorf("api_key is required for auth_type: AUTH_TYPE_API_KEY")
 		}
+		// Hashed API keys must be well-formed for PEAS to be able to verify them.
+		if err := apikey.Validate(*a.APIKey); err != nil {
+			return fmt.Errorf("invalid hashed api_key: %w", err)
+		}
 
 	// Default case means no auth is set for the endpoint, which
 	// means no authorization fields may be set for the endpoint.
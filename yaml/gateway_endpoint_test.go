@@ -163,6 +163,20 @@ func Test_authYAML_validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid hashed API key auth",
+			input: authYAML{
+				APIKey: stringPtr("argon2id:m=65536,t=1,p=4:c2FsdHNhbHRzYWx0c2FsdA:aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "malformed hashed API key auth",
+			input: authYAML{
+				APIKey: stringPtr("argon2id:not_a_valid_hash"),
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/buildwithgrove/path-external-auth-server/proto"
+
+	"github.com/buildwithgrove/path-auth-data-server/validity"
 )
 
 /* ----------------------------- GatewayEndpoints YAML Struct ----------------------------- */
@@ -21,6 +23,18 @@ func (g *gatewayEndpointsYAML) convertToProto() *proto.AuthDataResponse {
 	return &proto.AuthDataResponse{Endpoints: endpointsProto}
 }
 
+// validityWindows returns the validity windows of all time-bounded endpoints.
+// It must only be called after the endpoints have been validated.
+func (g *gatewayEndpointsYAML) validityWindows() map[string]validity.Window {
+	windows := make(map[string]validity.Window)
+	for endpointID, endpointYAML := range g.Endpoints {
+		if window, err := endpointYAML.validityWindow(); err == nil && !window.IsZero() {
+			windows[endpointID] = window
+		}
+	}
+	return windows
+}
+
 func (g *gatewayEndpointsYAML) validate() error {
 	for endpointID, endpoint := range g.Endpoints {
 		if err := endpoint.validate(endpointID); err != nil {
@@ -0,0 +1,103 @@
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
+)
+
+const yamlIndent = 2
+
+// HashAPIKeys replaces every plaintext `api_key` in the gateway endpoints YAML data with
+// its Argon2id hash. API keys that are already hashed are left unchanged.
+//
+// The YAML is rewritten at the node level so that comments and key ordering are preserved.
+// It returns the rewritten YAML and the number of API keys that were hashed.
+//
+// The YAML data source does not yet accept the hashed file, as PEAS does not yet verify hashed API keys.
+func HashAPIKeys(data []byte) ([]byte, int, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, 0, err
+	}
+
+	// Validate the file before rewriting it, so that invalid files are never hashed.
+	var endpointsYAML gatewayEndpointsYAML
+	if err := root.Decode(&endpointsYAML); err != nil {
+		return nil, 0, err
+	}
+	if err := endpointsYAML.validate(); err != nil {
+		return nil, 0, err
+	}
+
+	hashedCount := 0
+	for _, apiKeyNode := range findAPIKeyNodes(&root) {
+		if apikey.IsHashed(apiKeyNode.Value) {
+			continue
+		}
+
+		hashedAPIKey, err := apikey.Hash(apiKeyNode.Value)
+		if err != nil {
+			return nil, 0, err
+		}
+		apiKeyNode.Value = hashedAPIKey
+		apiKeyNode.Style = yaml.DoubleQuotedStyle
+		hashedCount++
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(yamlIndent)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, 0, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+
+	return buf.Bytes(), hashedCount, nil
+}
+
+// findAPIKeyNodes returns the scalar nodes holding the value of each endpoint's `auth.api_key` field.
+func findAPIKeyNodes(root *yaml.Node) []*yaml.Node {
+	var apiKeyNodes []*yaml.Node
+
+	endpointsNode := mappingValue(documentContent(root), "endpoints")
+	if endpointsNode == nil || endpointsNode.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	// Mapping node content alternates between key and value nodes.
+	for i := 1; i < len(endpointsNode.Content); i += 2 {
+		authNode := mappingValue(endpointsNode.Content[i], "auth")
+		if apiKeyNode := mappingValue(authNode, "api_key"); apiKeyNode != nil && apiKeyNode.Kind == yaml.ScalarNode {
+			apiKeyNodes = append(apiKeyNodes, apiKeyNode)
+		}
+	}
+
+	return apiKeyNodes
+}
+
+// documentContent returns the top-level node of a YAML document node.
+func documentContent(node *yaml.Node) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// mappingValue returns the value node for the given key of a mapping node, or nil if not found.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package yaml
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildwithgrove/path-auth-data-server/apikey"
+)
+
+// argon2idHashRegex matches the Argon2id hashed API keys in a hashed YAML file, which are salted and so differ on every run.
+var argon2idHashRegex = regexp.MustCompile(`"argon2id:[^"]+"`)
+
+func Test_HashAPIKeys(t *testing.T) {
+	const hashedAPIKey = "argon2id:m=65536,t=1,p=4:c2FsdHNhbHRzYWx0c2FsdA:aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
+
+	tests := []struct {
+		name              string
+		fileContents      string
+		expectedContents  string
+		expectedAPIKeys   []string
+		expectedHashCount int
+		wantErr           bool
+	}{
+		{
+			name: "should hash plaintext API keys and preserve comments",
+			fileContents: `# Gateway endpoints
+endpoints:
+  endpoint_1_static_key: # The endpoint ID
+    auth:
+      api_key: "api_key_1" # The API key
+    metadata:
+      account_id: "account_1"
+  endpoint_2_no_auth:
+    metadata:
+      account_id: "account_2"
+`,
+			expectedContents: `# Gateway endpoints
+endpoints:
+  endpoint_1_static_key: # The endpoint ID
+    auth:
+      api_key: "argon2id" # The API key
+    metadata:
+      account_id: "account_1"
+  endpoint_2_no_auth:
+    metadata:
+      account_id: "account_2"
+`,
+			expectedAPIKeys:   []string{"api_key_1"},
+			expectedHashCount: 1,
+		},
+		{
+			name: "should not re-hash already hashed API keys",
+			fileContents: `endpoints:
+  endpoint_1_static_key:
+    auth:
+      api_key: "` + hashedAPIKey + `"
+`,
+			expectedContents: `endpoints:
+  endpoint_1_static_key:
+    auth:
+      api_key: "argon2id"
+`,
+			expectedHashCount: 0,
+		},
+		{
+			name: "should return error for invalid gateway endpoints file",
+			fileContents: `endpoints:
+  "":
+    auth:
+      api_key: "api_key_1"
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			hashed, hashedCount, err := HashAPIKeys([]byte(test.fileContents))
+			if test.wantErr {
+				c.Error(err)
+				return
+			}
+			c.NoError(err)
+			c.Equal(test.expectedContents, argon2idHashRegex.ReplaceAllString(string(hashed), `"argon2id"`))
+			c.Equal(test.expectedHashCount, hashedCount)
+
+			// Every hashed API key must verify against the plaintext API key it replaced.
+			hashedAPIKeys := argon2idHashRegex.FindAllString(string(hashed), -1)
+			for i, apiKey := range test.expectedAPIKeys {
+				ok, err := apikey.Verify(hashedAPIKeys[i][1:len(hashedAPIKeys[i])-1], apiKey)
+				c.NoError(err)
+				c.True(ok)
+			}
+		})
+	}
+}
+
+func Test_HashAPIKeys_exampleFile(t *testing.T) {
+	c := require.New(t)
+
+	data, err := os.ReadFile("./testdata/gateway-endpoints.example.yaml")
+	c.NoError(err)
+
+	hashed, hashedCount, err := HashAPIKeys(data)
+	c.NoError(err)
+	c.Equal(1, hashedCount)
+
+	filePath := "./testdata/temp_hashed_gateway_endpoints.yaml"
+	c.NoError(os.WriteFile(filePath, hashed, 0644))
+	defer os.Remove(filePath)
+
+	// The hashed file is still a valid gateway endpoints file.
+	authData, _, err := DecodeGatewayEndpoints(hashed)
+	c.NoError(err)
+	storedAPIKey := authData.Endpoints["endpoint_1_static_key"].GetAuth().GetStaticApiKey().GetApiKey()
+	c.True(apikey.IsHashed(storedAPIKey))
+
+	// The hashed file is rejected by the YAML data source, as PEAS does not yet verify hashed API keys.
+	_, err = NewYAMLDataSource(filePath, polyzero.NewLogger())
+	c.ErrorIs(err, apikey.ErrHashedKeysUnsupported)
+}
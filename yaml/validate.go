@@ -0,0 +1,285 @@
+package yaml
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// The kinds of ValidationError, in the order the validation stages are run.
+const (
+	// ValidationKindSyntax is a YAML syntax error. No further validation is run.
+	ValidationKindSyntax = "syntax"
+	// ValidationKindSchema is a violation of gateway-endpoints.schema.yaml.
+	ValidationKindSchema = "schema"
+	// ValidationKindSemantic is an error that is not captured by the schema,
+	// eg. an invalid hashed API key or an API key shared by several endpoints.
+	ValidationKindSemantic = "semantic"
+)
+
+//go:embed gateway-endpoints.schema.yaml
+var schemaYAML []byte
+
+// ValidationError is a single problem found by Validate, in a form suitable for machine-readable output.
+// It never contains secret values, such as API keys.
+type ValidationError struct {
+	Kind       string `json:"kind"`
+	EndpointID string `json:"endpoint_id,omitempty"`
+	// Field is the dot-separated path of the invalid field, eg. `endpoints.endpoint_1.auth.api_key`.
+	Field string `json:"field,omitempty"`
+	// Line is the line of the invalid field in the file, if known.
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	var location string
+	if e.Line > 0 {
+		location = fmt.Sprintf("line %d: ", e.Line)
+	}
+	if e.Field != "" {
+		location += e.Field + ": "
+	}
+	return location + e.Message
+}
+
+// Validate runs every validation of a gateway endpoints YAML file and returns all of the problems found,
+// sorted by line. It is used to lint files before they are loaded by a YAML data source.
+//
+// The file is validated in stages: its YAML syntax, then gateway-endpoints.schema.yaml, then the same
+// semantic validation that is run when the file is loaded, along with checks which are only linted,
+// such as API keys shared by several endpoints. Later stages are only run if the earlier stages pass.
+func Validate(data []byte) []ValidationError {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []ValidationError{syntaxError(err)}
+	}
+
+	if errs := validateSchema(&root); len(errs) > 0 {
+		return errs
+	}
+
+	return validateSemantics(&root)
+}
+
+/* ---------------------------------- Syntax ---------------------------------- */
+
+// yamlErrorLineRegex matches the line number in YAML syntax errors, eg. `yaml: line 3: mapping values are not allowed`.
+var yamlErrorLineRegex = regexp.MustCompile(`line (\d+)`)
+
+func syntaxError(err error) ValidationError {
+	validationErr := ValidationError{
+		Kind:    ValidationKindSyntax,
+		Message: redactYAMLError(err).Error(),
+	}
+	if match := yamlErrorLineRegex.FindStringSubmatch(err.Error()); match != nil {
+		validationErr.Line, _ = strconv.Atoi(match[1])
+	}
+	return validationErr
+}
+
+/* ---------------------------------- Schema ---------------------------------- */
+
+// fieldSeparator separates the path elements of schema errors; it cannot appear in a YAML key.
+const fieldSeparator = "\x00"
+
+// validateSchema validates the document against gateway-endpoints.schema.yaml.
+func validateSchema(root *yaml.Node) []ValidationError {
+	schema, err := loadSchema()
+	if err != nil {
+		// The schema is embedded, so this is only possible if the schema itself is invalid.
+		return []ValidationError{{Kind: ValidationKindSchema, Message: fmt.Sprintf("failed to load schema: %v", err)}}
+	}
+
+	var document any
+	if err := root.Decode(&document); err != nil {
+		return []ValidationError{syntaxError(err)}
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(jsonCompatible(document)))
+	if err != nil {
+		return []ValidationError{{Kind: ValidationKindSchema, Message: err.Error()}}
+	}
+
+	var errs []ValidationError
+	for _, resultErr := range result.Errors() {
+		// eg. ["(root)", "endpoints", "endpoint_1", "auth"]
+		path := strings.Split(resultErr.Context().String(fieldSeparator), fieldSeparator)[1:]
+		validationErr := newValidationError(root, ValidationKindSchema, path, resultErr.Description())
+		// A field that violates several subschemas, eg. of a oneOf, may be reported more than once.
+		if !slices.Contains(errs, validationErr) {
+			errs = append(errs, validationErr)
+		}
+	}
+	return sortValidationErrors(errs)
+}
+
+func loadSchema() (*gojsonschema.Schema, error) {
+	var schema any
+	if err := yaml.Unmarshal(schemaYAML, &schema); err != nil {
+		return nil, err
+	}
+	return gojsonschema.NewSchema(gojsonschema.NewGoLoader(jsonCompatible(schema)))
+}
+
+// jsonCompatible converts the mappings of a decoded YAML document with non-string keys,
+// which cannot be encoded as JSON, to mappings with string keys.
+func jsonCompatible(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, element := range value {
+			value[key] = jsonCompatible(element)
+		}
+		return value
+	case map[any]any:
+		converted := make(map[string]any, len(value))
+		for key, element := range value {
+			converted[fmt.Sprint(key)] = jsonCompatible(element)
+		}
+		return converted
+	case []any:
+		for i, element := range value {
+			value[i] = jsonCompatible(element)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+/* ---------------------------------- Semantics ---------------------------------- */
+
+// lintYAML holds the fields of the file which are only linted, as they are not served by PADS.
+type lintYAML struct {
+	Endpoints map[string]struct {
+		RateLimiting *rateLimitingYAML `yaml:"rate_limiting"`
+	} `yaml:"endpoints"`
+}
+
+// rateLimitingYAML is the rate_limiting section of a GatewayEndpoint, defined in the schema.
+type rateLimitingYAML struct {
+	ThroughputLimit     *int   `yaml:"throughput_limit"`
+	CapacityLimit       *int   `yaml:"capacity_limit"`
+	CapacityLimitPeriod string `yaml:"capacity_limit_period"`
+}
+
+// validateSemantics runs the validation that is run when the file is loaded for every endpoint,
+// along with the checks which are only linted.
+func validateSemantics(root *yaml.Node) []ValidationError {
+	var endpointsYAML gatewayEndpointsYAML
+	if err := root.Decode(&endpointsYAML); err != nil {
+		return []ValidationError{syntaxError(err)}
+	}
+	var lint lintYAML
+	if err := root.Decode(&lint); err != nil {
+		return []ValidationError{syntaxError(err)}
+	}
+
+	var errs []ValidationError
+	semanticError := func(endpointID string, field string, format string, args ...any) {
+		path := []string{"endpoints", endpointID}
+		if field != "" {
+			path = append(path, strings.Split(field, ".")...)
+		}
+		errs = append(errs, newValidationError(root, ValidationKindSemantic, path, fmt.Sprintf(format, args...)))
+	}
+
+	// endpointIDsByAPIKey is used to find API keys shared by several endpoints.
+	endpointIDsByAPIKey := make(map[string][]string)
+
+	for endpointID, endpoint := range endpointsYAML.Endpoints {
+		if err := endpoint.validate(endpointID); err != nil {
+			semanticError(endpointID, "", "%v", err)
+		}
+
+		if endpoint.Auth.APIKey != nil && *endpoint.Auth.APIKey != "" {
+			endpointIDsByAPIKey[*endpoint.Auth.APIKey] = append(endpointIDsByAPIKey[*endpoint.Auth.APIKey], endpointID)
+		}
+
+		if rateLimiting := lint.Endpoints[endpointID].RateLimiting; rateLimiting != nil {
+			if rateLimiting.ThroughputLimit != nil && *rateLimiting.ThroughputLimit <= 0 {
+				semanticError(endpointID, "rate_limiting.throughput_limit", "throughput_limit must be positive")
+			}
+			if rateLimiting.CapacityLimit != nil && *rateLimiting.CapacityLimit <= 0 {
+				semanticError(endpointID, "rate_limiting.capacity_limit", "capacity_limit must be positive")
+			}
+			if rateLimiting.CapacityLimit != nil && rateLimiting.CapacityLimitPeriod == "" {
+				semanticError(endpointID, "rate_limiting", "capacity_limit requires capacity_limit_period")
+			}
+			if rateLimiting.CapacityLimit == nil && rateLimiting.CapacityLimitPeriod != "" {
+				semanticError(endpointID, "rate_limiting", "capacity_limit_period requires capacity_limit")
+			}
+		}
+	}
+
+	// A request with a shared API key would be authorized as whichever endpoint PEAS matches first.
+	for _, endpointIDs := range endpointIDsByAPIKey {
+		if len(endpointIDs) < 2 {
+			continue
+		}
+		sort.Strings(endpointIDs)
+		for _, endpointID := range endpointIDs {
+			semanticError(endpointID, "auth.api_key", "api_key is shared by endpoints %s", strings.Join(endpointIDs, ", "))
+		}
+	}
+
+	return sortValidationErrors(errs)
+}
+
+/* ---------------------------------- Helpers ---------------------------------- */
+
+// newValidationError returns a ValidationError for the field at the path,
+// including the endpoint ID and line if the field is found in the document.
+func newValidationError(root *yaml.Node, kind string, path []string, message string) ValidationError {
+	validationErr := ValidationError{
+		Kind:    kind,
+		Field:   strings.Join(path, "."),
+		Message: message,
+	}
+	if len(path) >= 2 && path[0] == "endpoints" {
+		validationErr.EndpointID = path[1]
+	}
+	if node := findNode(documentContent(root), path); node != nil {
+		validationErr.Line = node.Line
+	}
+	return validationErr
+}
+
+// findNode returns the deepest node found along the path of mapping keys, or nil if the path is empty.
+func findNode(node *yaml.Node, path []string) *yaml.Node {
+	var found *yaml.Node
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			break
+		}
+		keyIndex := mappingKeyIndex(node, key)
+		if keyIndex < 0 {
+			break
+		}
+		// The key node is returned for the last element, so that the line is that of the field name.
+		found = node.Content[keyIndex]
+		node = node.Content[keyIndex+1]
+	}
+	return found
+}
+
+// sortValidationErrors sorts the errors by line, then field, then message, so that the output is deterministic.
+func sortValidationErrors(errs []ValidationError) []ValidationError {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		if errs[i].Field != errs[j].Field {
+			return errs[i].Field < errs[j].Field
+		}
+		return errs[i].Message < errs[j].Message
+	})
+	return errs
+}
@@ -0,0 +1,129 @@
+package yaml
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		fileContents string
+		expected     []ValidationError
+	}{
+		{
+			name: "should return no errors for a valid file",
+			fileContents: `endpoints:
+  endpoint_1:
+    auth:
+      api_key: "api_key_1"
+    rate_limiting:
+      throughput_limit: 30
+      capacity_limit: 100000
+      capacity_limit_period: "CAPACITY_LIMIT_PERIOD_MONTHLY"
+    starts_at: 2025-01-01T00:00:00Z
+  endpoint_2:
+    metadata:
+      account_id: "account_2"
+`,
+		},
+		{
+			name: "should return a syntax error and stop",
+			fileContents: `endpoints:
+  endpoint_1: [
+`,
+			expected: []ValidationError{
+				{Kind: ValidationKindSyntax, Line: 2, Message: "yaml: line 2: did not find expected node content"},
+			},
+		},
+		{
+			name: "should return all schema errors and not run semantic validation",
+			fileContents: `endpoints:
+  endpoint_1:
+    unknown_field: true
+    rate_limiting:
+      capacity_limit_period: "CAPACITY_LIMIT_PERIOD_HOURLY"
+  endpoint_2:
+    auth:
+      api_key: 1234
+  "":
+    starts_at: "2025-01-01T00:00:00Z"
+`,
+			expected: []ValidationError{
+				{Kind: ValidationKindSchema, EndpointID: "endpoint_1", Field: "endpoints.endpoint_1", Line: 2, Message: "Additional property unknown_field is not allowed"},
+				{Kind: ValidationKindSchema, EndpointID: "endpoint_1", Field: "endpoints.endpoint_1.rate_limiting.capacity_limit_period", Line: 5,
+					Message: `endpoints.endpoint_1.rate_limiting.capacity_limit_period must be one of the following: "CAPACITY_LIMIT_PERIOD_DAILY", "CAPACITY_LIMIT_PERIOD_WEEKLY", "CAPACITY_LIMIT_PERIOD_MONTHLY"`},
+				{Kind: ValidationKindSchema, EndpointID: "endpoint_2", Field: "endpoints.endpoint_2.auth", Line: 7, Message: "Must validate one and only one schema (oneOf)"},
+				{Kind: ValidationKindSchema, EndpointID: "endpoint_2", Field: "endpoints.endpoint_2.auth.api_key", Line: 8, Message: "Invalid type. Expected: string, given: integer"},
+			},
+		},
+		{
+			name: "should return all semantic errors",
+			fileContents: `endpoints:
+  endpoint_1:
+    auth:
+      api_key: "shared_api_key"
+    rate_limiting:
+      capacity_limit: -1
+  endpoint_2:
+    auth:
+      api_key: "shared_api_key"
+    rate_limiting:
+      throughput_limit: 0
+      capacity_limit_period: "CAPACITY_LIMIT_PERIOD_DAILY"
+  endpoint_3:
+    starts_at: "2026-01-01T00:00:00Z"
+    expires_at: "2025-01-01T00:00:00Z"
+  "":
+    metadata:
+      account_id: "account_4"
+`,
+			expected: []ValidationError{
+				{Kind: ValidationKindSemantic, EndpointID: "endpoint_1", Field: "endpoints.endpoint_1.auth.api_key", Line: 4, Message: "api_key is shared by endpoints endpoint_1, endpoint_2"},
+				{Kind: ValidationKindSemantic, EndpointID: "endpoint_1", Field: "endpoints.endpoint_1.rate_limiting", Line: 5, Message: "capacity_limit requires capacity_limit_period"},
+				{Kind: ValidationKindSemantic, EndpointID: "endpoint_1", Field: "endpoints.endpoint_1.rate_limiting.capacity_limit", Line: 6, Message: "capacity_limit must be positive"},
+				{Kind: ValidationKindSemantic, EndpointID: "endpoint_2", Field: "endpoints.endpoint_2.auth.api_key", Line: 9, Message: "api_key is shared by endpoints endpoint_1, endpoint_2"},
+				{Kind: ValidationKindSemantic, EndpointID: "endpoint_2", Field: "endpoints.endpoint_2.rate_limiting", Line: 10, Message: "capacity_limit_period requires capacity_limit"},
+				{Kind: ValidationKindSemantic, EndpointID: "endpoint_2", Field: "endpoints.endpoint_2.rate_limiting.throughput_limit", Line: 11, Message: "throughput_limit must be positive"},
+				{Kind: ValidationKindSemantic, EndpointID: "endpoint_3", Field: "endpoints.endpoint_3", Line: 13, Message: "expires_at (2025-01-01T00:00:00Z) must be after starts_at (2026-01-01T00:00:00Z)"},
+				{Kind: ValidationKindSemantic, Field: "endpoints.", Line: 16, Message: "endpoint_id is required"},
+			},
+		},
+		{
+			name: "should not include API key values in errors",
+			fileContents: `endpoints:
+  endpoint_1:
+    auth:
+      api_key: "argon2id:secret_api_key"
+`,
+			expected: []ValidationError{
+				{Kind: ValidationKindSemantic, EndpointID: "endpoint_1", Field: "endpoints.endpoint_1", Line: 2,
+					Message: "invalid hashed api_key: invalid argon2id API key hash: expected format argon2id:m=<memory>,t=<iterations>,p=<threads>:<salt>:<hash>"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			errs := Validate([]byte(test.fileContents))
+			c.Equal(test.expected, errs)
+			for _, err := range errs {
+				c.NotContains(err.Error(), "secret_api_key")
+				c.NotContains(err.Error(), "shared_api_key")
+			}
+		})
+	}
+}
+
+func Test_Validate_exampleFile(t *testing.T) {
+	c := require.New(t)
+
+	data, err := os.ReadFile("./testdata/gateway-endpoints.example.yaml")
+	c.NoError(err)
+
+	c.Empty(Validate(data))
+}
@@ -0,0 +1,215 @@
+package yaml
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+)
+
+// yamlDataSource implements the AuthDataWriter interface
+var _ grpc_server.AuthDataWriter = &yamlDataSource{}
+
+// WriteGatewayEndpoints applies the writes to the YAML file and sends updates for the resulting changes.
+//
+// The YAML file is rewritten at the node level so that comments and the order of existing endpoints
+// are preserved, and is replaced atomically so that a partially written file is never loaded.
+// Hashed API keys are rejected with ErrUnsupportedWrite, as PEAS does not yet verify them.
+func (y *yamlDataSource) WriteGatewayEndpoints(ctx context.Context, writes []grpc_server.EndpointWrite) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, write := range writes {
+		if write.Op == grpc_server.WriteOpDelete {
+			continue
+		}
+		if err := y.checkAPIKey(write.EndpointID, write.GatewayEndpoint); err != nil {
+			return fmt.Errorf("%w: %v", grpc_server.ErrUnsupportedWrite, err)
+		}
+	}
+
+	y.writeMu.Lock()
+	defer y.writeMu.Unlock()
+
+	data, err := os.ReadFile(y.filename)
+	if err != nil {
+		return err
+	}
+
+	newData, err := applyEndpointWrites(data, writes)
+	if err != nil {
+		return err
+	}
+
+	if err := atomicWriteFile(y.filename, newData); err != nil {
+		return fmt.Errorf("failed to write YAML file: %w", err)
+	}
+
+	// Send the updates immediately rather than waiting for the file watcher,
+	// so that the changes are on their way to PEAS once the write returns.
+	authData, windows, err := DecodeGatewayEndpoints(newData)
+	if err != nil {
+		return err
+	}
+	y.handleUpdates(authData.Endpoints, windows)
+
+	return nil
+}
+
+// applyEndpointWrites applies the writes to the gateway endpoints YAML data and returns the rewritten YAML.
+// The rewritten YAML is validated exactly as it is when loaded, so an invalid file is never returned.
+func applyEndpointWrites(data []byte, writes []grpc_server.EndpointWrite) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, redactYAMLError(err)
+	}
+
+	// An empty file has no document node, so start a new document.
+	if root.Kind == 0 {
+		root = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	endpointsNode, err := endpointsMapping(documentContent(&root))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, write := range writes {
+		keyIndex := mappingKeyIndex(endpointsNode, write.EndpointID)
+
+		switch write.Op {
+		case grpc_server.WriteOpCreate:
+			if keyIndex >= 0 {
+				return nil, fmt.Errorf("%w: %s", grpc_server.ErrEndpointExists, write.EndpointID)
+			}
+		case grpc_server.WriteOpUpdate, grpc_server.WriteOpDelete:
+			if keyIndex < 0 {
+				return nil, fmt.Errorf("%w: %s", grpc_server.ErrEndpointNotFound, write.EndpointID)
+			}
+		case grpc_server.WriteOpUpsert:
+		default:
+			return nil, fmt.Errorf("%w: unknown write operation %q for endpoint %s", grpc_server.ErrUnsupportedWrite, write.Op, write.EndpointID)
+		}
+
+		// Mapping node content alternates between key and value nodes.
+		if write.Op == grpc_server.WriteOpDelete {
+			endpointsNode.Content = append(endpointsNode.Content[:keyIndex], endpointsNode.Content[keyIndex+2:]...)
+			continue
+		}
+
+		var endpointNode yaml.Node
+		if err := endpointNode.Encode(gatewayEndpointYAMLFromProto(write.GatewayEndpoint, write.Window)); err != nil {
+			return nil, fmt.Errorf("failed to encode endpoint %s: %w", write.EndpointID, err)
+		}
+
+		if keyIndex >= 0 {
+			endpointsNode.Content[keyIndex+1] = &endpointNode
+		} else {
+			endpointsNode.Content = append(endpointsNode.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: write.EndpointID},
+				&endpointNode,
+			)
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(yamlIndent)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+
+	if _, _, err := DecodeGatewayEndpoints(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// endpointsMapping returns the mapping node of the top-level `endpoints` field,
+// adding or replacing it with an empty mapping if it is missing or empty.
+func endpointsMapping(top *yaml.Node) (*yaml.Node, error) {
+	if top == nil || top.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("YAML file must contain a mapping with an endpoints field")
+	}
+
+	endpointsNode := mappingValue(top, "endpoints")
+	switch {
+	case endpointsNode == nil:
+		endpointsNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		top.Content = append(top.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "endpoints"}, endpointsNode)
+
+	// eg. `endpoints:` with no value
+	case endpointsNode.Kind == yaml.ScalarNode && endpointsNode.Tag == "!!null":
+		*endpointsNode = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: endpointsNode.HeadComment}
+
+	case endpointsNode.Kind != yaml.MappingNode:
+		return nil, fmt.Errorf("the endpoints field of the YAML file must be a mapping")
+	}
+
+	// eg. `endpoints: {}`, which would otherwise keep new endpoints on a single line.
+	endpointsNode.Style &^= yaml.FlowStyle
+
+	return endpointsNode, nil
+}
+
+// mappingKeyIndex returns the index of the key node for the given key of a mapping node, or -1 if not found.
+func mappingKeyIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// atomicWriteFile replaces the file with data by writing a temporary file in the same
+// directory and renaming it over the file, so that a partially written file is never read.
+// If the file is a symlink, its target is replaced.
+func atomicWriteFile(filename string, data []byte) error {
+	target, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Removing the temporary file is a no-op once it has been renamed.
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), target)
+}
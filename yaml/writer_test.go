@@ -0,0 +1,220 @@
+package yaml
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildwithgrove/path-external-auth-server/proto"
+	"github.com/pokt-network/poktroll/pkg/polylog/polyzero"
+	"github.com/stretchr/testify/require"
+
+	grpc_server "github.com/buildwithgrove/path-auth-data-server/grpc"
+	"github.com/buildwithgrove/path-auth-data-server/validity"
+)
+
+const writerTestYAML = `# Gateway endpoints for testing writes
+endpoints:
+  # The first endpoint
+  endpoint_1_static_key:
+    auth:
+      api_key: "api_key_1"
+    metadata:
+      account_id: "account_1"
+      plan_type: "PLAN_UNLIMITED"
+  endpoint_2_no_auth:
+    metadata:
+      account_id: "account_2"
+`
+
+func Test_applyEndpointWrites(t *testing.T) {
+	staticKeyEndpoint := &proto.GatewayEndpoint{
+		EndpointId: "endpoint_3_static_key",
+		Auth: &proto.Auth{
+			AuthType: &proto.Auth_StaticApiKey{
+				StaticApiKey: &proto.StaticAPIKey{ApiKey: "api_key_3"},
+			},
+		},
+		Metadata: &proto.Metadata{AccountId: "account_3", PlanType: "PLAN_FREE"},
+	}
+	noAuthEndpoint := &proto.GatewayEndpoint{
+		EndpointId: "endpoint_1_static_key",
+		Auth:       &proto.Auth{AuthType: &proto.Auth_NoAuth{}},
+		Metadata:   &proto.Metadata{AccountId: "account_1"},
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		writes   []grpc_server.EndpointWrite
+		expected string
+		wantErr  error
+	}{
+		{
+			name: "should create endpoint and preserve comments",
+			data: writerTestYAML,
+			writes: []grpc_server.EndpointWrite{
+				{
+					Op:              grpc_server.WriteOpCreate,
+					EndpointID:      "endpoint_3_static_key",
+					GatewayEndpoint: staticKeyEndpoint,
+					Window:          validity.Window{ExpiresAt: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+			expected: `# Gateway endpoints for testing writes
+endpoints:
+  # The first endpoint
+  endpoint_1_static_key:
+    auth:
+      api_key: "api_key_1"
+    metadata:
+      account_id: "account_1"
+      plan_type: "PLAN_UNLIMITED"
+  endpoint_2_no_auth:
+    metadata:
+      account_id: "account_2"
+  endpoint_3_static_key:
+    auth:
+      api_key: api_key_3
+    metadata:
+      account_id: account_3
+      plan_type: PLAN_FREE
+    expires_at: "2025-01-15T00:00:00Z"
+`,
+		},
+		{
+			name: "should update and delete endpoints",
+			data: writerTestYAML,
+			writes: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpUpdate, EndpointID: "endpoint_1_static_key", GatewayEndpoint: noAuthEndpoint},
+				{Op: grpc_server.WriteOpDelete, EndpointID: "endpoint_2_no_auth"},
+			},
+			expected: `# Gateway endpoints for testing writes
+endpoints:
+  # The first endpoint
+  endpoint_1_static_key:
+    metadata:
+      account_id: account_1
+`,
+		},
+		{
+			name: "should upsert endpoints into an empty file",
+			data: "",
+			writes: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpUpsert, EndpointID: "endpoint_3_static_key", GatewayEndpoint: staticKeyEndpoint},
+			},
+			expected: `endpoints:
+  endpoint_3_static_key:
+    auth:
+      api_key: api_key_3
+    metadata:
+      account_id: account_3
+      plan_type: PLAN_FREE
+`,
+		},
+		{
+			name: "should fail to create an existing endpoint",
+			data: writerTestYAML,
+			writes: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpCreate, EndpointID: "endpoint_1_static_key", GatewayEndpoint: noAuthEndpoint},
+			},
+			wantErr: grpc_server.ErrEndpointExists,
+		},
+		{
+			name: "should fail to delete a missing endpoint",
+			data: writerTestYAML,
+			writes: []grpc_server.EndpointWrite{
+				{Op: grpc_server.WriteOpDelete, EndpointID: "endpoint_3_static_key"},
+			},
+			wantErr: grpc_server.ErrEndpointNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := require.New(t)
+
+			result, err := applyEndpointWrites([]byte(test.data), test.writes)
+			if test.wantErr != nil {
+				c.True(errors.Is(err, test.wantErr))
+				return
+			}
+			c.NoError(err)
+			c.Equal(test.expected, string(result))
+		})
+	}
+}
+
+func Test_WriteGatewayEndpoints(t *testing.T) {
+	c := require.New(t)
+
+	filePath := filepath.Join(t.TempDir(), "gateway-endpoints.yaml")
+	c.NoError(os.WriteFile(filePath, []byte(writerTestYAML), 0600))
+
+	yamlDataSource, err := NewYAMLDataSource(filePath, polyzero.NewLogger())
+	c.NoError(err)
+
+	// The updates are received from a subscription, as the update feed consumes authDataUpdatesCh.
+	_, updatesCh, err := yamlDataSource.SubscribeAuthData()
+	c.NoError(err)
+
+	err = yamlDataSource.WriteGatewayEndpoints(context.Background(), []grpc_server.EndpointWrite{
+		{Op: grpc_server.WriteOpDelete, EndpointID: "endpoint_2_no_auth"},
+	})
+	c.NoError(err)
+
+	// The file must be rewritten with its original permissions.
+	info, err := os.Stat(filePath)
+	c.NoError(err)
+	c.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	authData, _, err := yamlDataSource.loadGatewayEndpointsFromYAML()
+	c.NoError(err)
+	c.Len(authData.Endpoints, 1)
+	c.Contains(authData.Endpoints, "endpoint_1_static_key")
+
+	// The deletion must be sent to the update path.
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case update := <-updatesCh:
+			if update.EndpointId == "endpoint_2_no_auth" && update.Delete {
+				return
+			}
+		case <-timeout:
+			t.Fatal("expected delete update not received")
+		}
+	}
+}
+
+func Test_WriteGatewayEndpoints_hashedAPIKey(t *testing.T) {
+	c := require.New(t)
+
+	filePath := filepath.Join(t.TempDir(), "gateway-endpoints.yaml")
+	c.NoError(os.WriteFile(filePath, []byte(writerTestYAML), 0600))
+
+	yamlDataSource, err := NewYAMLDataSource(filePath, polyzero.NewLogger())
+	c.NoError(err)
+
+	err = yamlDataSource.WriteGatewayEndpoints(context.Background(), []grpc_server.EndpointWrite{
+		{
+			Op:         grpc_server.WriteOpUpsert,
+			EndpointID: "endpoint_3_hashed_key",
+			GatewayEndpoint: &proto.GatewayEndpoint{
+				EndpointId: "endpoint_3_hashed_key",
+				Auth: &proto.Auth{AuthType: &proto.Auth_StaticApiKey{
+					StaticApiKey: &proto.StaticAPIKey{ApiKey: "argon2id:m=65536,t=1,p=4:c2FsdHNhbHRzYWx0c2FsdA:aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"},
+				}},
+			},
+		},
+	})
+	c.ErrorIs(err, grpc_server.ErrUnsupportedWrite)
+
+	// The file must not be rewritten.
+	data, err := os.ReadFile(filePath)
+	c.NoError(err)
+	c.Equal(writerTestYAML, string(data))
+}